@@ -5,11 +5,11 @@ go 1.24.2
 require (
 	github.com/chzyer/readline v1.5.1
 	github.com/hashicorp/mdns v1.0.5
+	golang.org/x/sys v0.39.0
 	golang.org/x/term v0.38.0
 )
 
 require (
 	github.com/miekg/dns v1.1.41 // indirect
 	golang.org/x/net v0.48.0 // indirect
-	golang.org/x/sys v0.39.0 // indirect
 )
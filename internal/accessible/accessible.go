@@ -0,0 +1,108 @@
+// Package accessible rewrites terminal output for screen readers: status
+// symbols become words, colors are removed and spinner updates are dropped.
+package accessible
+
+import (
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// ansiRegex matches ANSI escape sequences (colors, line clears)
+var ansiRegex = regexp.MustCompile(`\x1b\[[0-9;?]*[A-Za-z]`)
+
+// deniedRegex matches ✗ markers for declined actions, as opposed to errors
+var deniedRegex = regexp.MustCompile(`✗ ((Auto-)?[Dd]enied|Declined)`)
+
+// Enabled reports whether accessibility mode is on, via config/flag or the
+// ACCESSIBLE environment variable (ACCESSIBLE=1)
+func Enabled(configured bool) bool {
+	if configured {
+		return true
+	}
+	v := strings.ToLower(os.Getenv("ACCESSIBLE"))
+	return v != "" && v != "0" && v != "false"
+}
+
+// Translate rewrites one chunk of output. It returns "" for spinner updates.
+func Translate(s string) string {
+	// Spinner redraws ("\r\033[K...Thinking... [n tokens]") carry no new information
+	if strings.HasPrefix(s, "\r") && strings.Contains(s, "Thinking...") {
+		return ""
+	}
+	s = ansiRegex.ReplaceAllString(s, "")
+	s = strings.ReplaceAll(s, "Thinking... (Esc to interrupt)", "Thinking...\n")
+
+	s = deniedRegex.ReplaceAllString(s, "DENIED: $1")
+	replacer := strings.NewReplacer(
+		"✓ ", "SUCCESS: ",
+		"✓", "SUCCESS",
+		"✗ ", "ERROR: ",
+		"✗", "ERROR",
+		"⚠ ", "WARNING: ",
+		"⚠", "WARNING",
+		"⬆ ", "",
+	)
+	return replacer.Replace(s)
+}
+
+// restore undoes the active Wrap, if any
+var (
+	restoreMu sync.Mutex
+	restore   func()
+)
+
+// Wrap redirects os.Stdout through Translate. The returned function is
+// Restore.
+func Wrap() func() {
+	orig := os.Stdout
+	r, w, err := os.Pipe()
+	if err != nil {
+		return func() {}
+	}
+	os.Stdout = w
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		buf := make([]byte, 32*1024)
+		for {
+			n, err := r.Read(buf)
+			if n > 0 {
+				io.WriteString(orig, Translate(string(buf[:n])))
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	restoreMu.Lock()
+	restore = func() {
+		os.Stdout = orig
+		w.Close()
+		<-done
+	}
+	restoreMu.Unlock()
+	return Restore
+}
+
+// Restore restores os.Stdout and waits for pending output to be written.
+// It does nothing if output isn't wrapped, so it is safe to call twice.
+func Restore() {
+	restoreMu.Lock()
+	defer restoreMu.Unlock()
+	if restore != nil {
+		restore()
+		restore = nil
+	}
+}
+
+// Exit is os.Exit for programs that may have called Wrap: output still in
+// the pipe is written first, which a deferred Restore would miss
+func Exit(code int) {
+	Restore()
+	os.Exit(code)
+}
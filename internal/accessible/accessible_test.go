@@ -0,0 +1,47 @@
+package accessible
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"testing"
+)
+
+func TestTranslate(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"\033[32m✓ Wrote main.go\033[0m\n", "SUCCESS: Wrote main.go\n"},
+		{"\033[31m✗ Declined\033[0m", "DENIED: Declined"},
+		{"✗ Build failed", "ERROR: Build failed"},
+		{"⚠ Web rate limit reached", "WARNING: Web rate limit reached"},
+		{"\r\033[K\033[90mThinking... [12 tokens] (Esc to interrupt)\033[0m", ""},
+		{"plain text", "plain text"},
+	}
+	for _, tt := range tests {
+		if got := Translate(tt.in); got != tt.want {
+			t.Errorf("Translate(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestRestoreWritesPendingOutput(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	orig := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = orig }()
+
+	Wrap()
+	fmt.Println("✓ done")
+	Restore()
+	Restore() // A second call does nothing
+	w.Close()
+
+	out, _ := io.ReadAll(r)
+	if got := string(out); got != "SUCCESS: done\n" {
+		t.Errorf("output = %q, want %q", got, "SUCCESS: done\n")
+	}
+}
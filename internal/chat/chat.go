@@ -1,20 +1,37 @@
 package chat
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"math"
+	"net/http"
 	"os"
+	"os/exec"
+	"os/signal"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/chzyer/readline"
+	"golang.org/x/term"
 
+	"aicli/internal/accessible"
 	"aicli/internal/client"
 	"aicli/internal/config"
+	"aicli/internal/diff"
 	"aicli/internal/executor"
 	"aicli/internal/keylistener"
+	"aicli/internal/lang"
+	"aicli/internal/logging"
 	"aicli/internal/plan"
 	"aicli/internal/session"
 	"aicli/internal/tools"
@@ -25,6 +42,7 @@ type Chat struct {
 	client        *client.Client
 	cfg           *config.Config
 	rl            *readline.Instance
+	stdin         *interruptibleStdin
 	exec          *executor.Executor
 	web           *web.WebSearch
 	recorder      *session.Recorder
@@ -35,39 +53,235 @@ type Chat struct {
 	playback      *session.Playback
 	keyListener   *keylistener.Listener
 	followUpInput string
+	batchDecision int            // batchNone, batchApproved or batchDenied for the current tool batch
+	planProgress  string         // Live plan progress shown while a plan step runs (TTY only)
+	fixAttempts   map[string]int // How often each fix todo set has been suggested
+	cmdFailures   map[string]int // Consecutive failures per command, for the circuit breaker
+	lastNudge     string         // Last user-interrupt nudge, to avoid repeating it
+	lastErr       error          // Error of the last failed model request
+	notices       <-chan string  // Background notices (update available), printed at the prompt
+	recentInputs  []string       // Inputs since the last /macro command, for /macro save
+	gitBranch     string         // Cached branch for the prompt
+	gitDirty      bool           // Cached dirty state for the prompt
+	gitFresh      bool           // Whether gitBranch/gitDirty are up to date
+	lastSearch    string         // Query of the last /search, for /search more
+	noTools       bool           // Plain chat: no tools sent, text tool calls ignored
+	toolsChecked  bool           // Whether the first reply was checked for tool support
+	searchPage    int            // Page of lastSearch shown last
+	stats         RunStats       // Tool outcomes, for the single-prompt summary
+	maxSteps      int            // Tool-call rounds allowed per prompt (--max-steps), 0 = no cap
+
+	quietOut io.Writer // If set (--quiet), only the final reply is written here
+
+	rlClose     sync.Once      // Closes rl once, from Run or shutdown
+	exit        func(code int) // Ends the process after shutdown; accessible.Exit unless replaced in tests
+	stopSignals func()         // Unregisters the SIGINT/SIGTERM handler, nil if none is registered
+
+	activeMu     sync.Mutex         // Guards activeCancel
+	activeCancel context.CancelFunc // Cancels the in-flight request or command, if any
 }
 
+// RunStats counts tool outcomes during a run
+type RunStats struct {
+	FilesWritten int
+	CommandsRun  int
+	Failures     int // Tool calls that failed or were refused
+	Declined     int // Tool calls the user (or the confirm_default policy) declined
+	Unrecovered  int // Failures not followed by a success of the same call
+	StepLimit    int // The --max-steps cap, if the run stopped at it
+
+	failing map[string]bool // Calls whose latest attempt failed, by callKey
+}
+
+// String renders the stats as the single-prompt summary line
+func (s RunStats) String() string {
+	line := fmt.Sprintf("%s written, %s run, %s",
+		plural(s.FilesWritten, "file"), plural(s.CommandsRun, "command"), plural(s.Failures, "failure"))
+	if s.Declined > 0 {
+		line += fmt.Sprintf(", %d declined", s.Declined)
+	}
+	if s.StepLimit > 0 {
+		line += fmt.Sprintf(", stopped at the %d-step limit", s.StepLimit)
+	}
+	return line
+}
+
+// plural formats a count with a simple English plural, e.g. "1 file", "3 files"
+func plural(n int, noun string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, noun)
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+// toolFailurePrefixes start the results of tool calls that didn't do what
+// was asked
+var toolFailurePrefixes = []string{
+	"COMMAND FAILED", "OPERATION FAILED", "NOT RUN", "INVALID ARGUMENTS",
+	"ARGUMENTS TRUNCATED", "RATE LIMITED", "Error:", "Failed to", "Fetch failed",
+	"Search failed", "Unknown tool",
+}
+
+// declinedPrefix starts the result of a tool call that was declined
+const declinedPrefix = "OPERATION FAILED: User declined"
+
+// record updates the stats with the result of one tool call
+func (s *RunStats) record(name, args, result string) {
+	if s.failing == nil {
+		s.failing = make(map[string]bool)
+	}
+	if strings.HasPrefix(result, declinedPrefix) {
+		// Not a failure: nothing was tried
+		s.Declined++
+		return
+	}
+	key := callKey(name, args)
+	for _, prefix := range toolFailurePrefixes {
+		if strings.HasPrefix(result, prefix) {
+			s.Failures++
+			if !s.failing[key] {
+				s.failing[key] = true
+				s.Unrecovered++
+			}
+			// A command that ran and failed still counts as run
+			if prefix == "COMMAND FAILED" {
+				s.CommandsRun++
+			}
+			return
+		}
+	}
+	if s.failing[key] {
+		delete(s.failing, key)
+		s.Unrecovered--
+	}
+	switch name {
+	case "write_file", "write_doc":
+		if strings.HasPrefix(result, "Successfully wrote") {
+			s.FilesWritten++
+		}
+	case "run_command", "run_command_tracked", "project_command", "lint":
+		s.CommandsRun++
+	}
+}
+
+// recordToolResult records a tool result in the session, the run stats and
+// the event log, and drops the cached project languages after a tool that
+// may have changed the project
+func (c *Chat) recordToolResult(tc tools.ToolCall, result string, elapsed time.Duration) {
+	c.recorder.RecordToolResult(tc.Function.Name, result)
+	if !tools.IsReadOnly(tc.Function.Name) {
+		// Writes and commands can add a manifest (go.mod, package.json)
+		lang.ForgetLanguages(c.exec.WorkDir())
+	}
+	before := c.stats
+	c.stats.record(tc.Function.Name, tc.Function.Arguments, result)
+
+	outcome := strings.SplitN(result, "\n", 2)[0]
+	attrs := []any{"tool", tc.Function.Name, "args", truncate(tc.Function.Arguments, 200),
+		"duration", elapsed.Round(time.Millisecond), "result", truncate(outcome, 200)}
+	if c.stats.Failures > before.Failures {
+		logging.Warn("tool failed", attrs...)
+	} else if c.stats.Declined > before.Declined {
+		logging.Info("tool declined", attrs...)
+	} else {
+		logging.Info("tool executed", attrs...)
+	}
+}
+
+// callKey identifies "the same call" for recovery tracking: the tool name
+// plus its target (command, action, path, URL or query), so a file written
+// successfully after a failed write counts as recovered even though the
+// content differs
+func callKey(name, args string) string {
+	var fields map[string]interface{}
+	json.Unmarshal([]byte(args), &fields)
+	for _, field := range []string{"command", "action", "path", "url", "query"} {
+		if v, ok := fields[field].(string); ok {
+			return name + " " + strings.TrimSpace(v)
+		}
+	}
+	return name
+}
+
+// Failed reports whether the run should exit non-zero under the given
+// fail_on policy. Declined calls don't count. A run stopped by the step
+// limit always fails: the model wasn't done.
+func (s RunStats) Failed(policy string) bool {
+	if s.StepLimit > 0 {
+		return true
+	}
+	if policy == config.FailOnUnrecovered {
+		return s.Unrecovered > 0
+	}
+	return s.Failures > 0
+}
+
+// Stats returns the tool outcomes recorded so far
+func (c *Chat) Stats() RunStats {
+	return c.stats
+}
+
+// maxCommandFailures is how many times the same command may fail before it
+// is no longer run or suggested again (circuit breaker)
+const maxCommandFailures = 3
+
+// maxFixSuggestions is how many times the same fix is suggested for a failing
+// command before the model is told to stop and ask the user instead
+const maxFixSuggestions = 3
+
+// Batch confirmation decisions for multi-tool responses
+const (
+	batchNone     = iota // No batch decision - prompt per tool
+	batchApproved        // User approved the whole batch
+	batchDenied          // User denied the whole batch
+)
+
 func New(cfg *config.Config) (*Chat, error) {
-	rl, err := readline.NewEx(&readline.Config{
+	workDir, _ := os.Getwd()
+
+	// readline treats 0 as its default (500) and -1 as "no history"
+	historyLimit := cfg.GetHistoryMaxLines()
+	if historyLimit == 0 {
+		historyLimit = math.MaxInt32
+	}
+
+	rlConfig := &readline.Config{
 		Prompt:          "\033[36m>>> \033[0m",
-		HistoryFile:     getHistoryPath(),
+		HistoryFile:     getHistoryPath(cfg, workDir),
+		HistoryLimit:    historyLimit,
 		InterruptPrompt: "^C",
 		EOFPrompt:       "exit",
-	})
+	}
+	stdin := newInterruptibleStdin()
+	if stdin != nil {
+		rlConfig.Stdin = stdin
+	}
+	rl, err := readline.NewEx(rlConfig)
 	if err != nil {
 		return nil, err
 	}
 
-	workDir, _ := os.Getwd()
-
 	// Initialize version file if not exists
-	exec := executor.New(workDir)
+	exec := newExecutor(cfg, workDir)
 	exec.InitVersion()
 
+	ensureGitignore(cfg, workDir)
 	c := client.NewWithDebug(cfg, workDir)
 
 	return &Chat{
 		client:      c,
 		cfg:         cfg,
 		rl:          rl,
+		stdin:       stdin,
 		exec:        exec,
-		web:         web.NewSearch(),
+		web:         newWebSearch(cfg),
 		recorder:    session.NewRecorder(workDir),
 		todoFile:    session.NewTodoFile(workDir),
 		changelog:   session.NewChangelogFile(workDir),
 		history:     session.NewHistoryFile(workDir),
 		autoExec:    false,
 		keyListener: keylistener.New(),
+		exit:        accessible.Exit,
 	}, nil
 }
 
@@ -75,9 +289,10 @@ func New(cfg *config.Config) (*Chat, error) {
 func NewNonInteractive(cfg *config.Config, autoExec bool) (*Chat, error) {
 	workDir, _ := os.Getwd()
 
-	exec := executor.New(workDir)
+	exec := newExecutor(cfg, workDir)
 	exec.InitVersion()
 
+	ensureGitignore(cfg, workDir)
 	c := client.NewWithDebug(cfg, workDir)
 
 	return &Chat{
@@ -85,16 +300,31 @@ func NewNonInteractive(cfg *config.Config, autoExec bool) (*Chat, error) {
 		cfg:         cfg,
 		rl:          nil, // No readline for non-interactive mode
 		exec:        exec,
-		web:         web.NewSearch(),
+		web:         newWebSearch(cfg),
 		recorder:    session.NewRecorder(workDir),
 		todoFile:    session.NewTodoFile(workDir),
 		changelog:   session.NewChangelogFile(workDir),
 		history:     session.NewHistoryFile(workDir),
 		keyListener: keylistener.New(),
 		autoExec:    autoExec,
+		exit:        accessible.Exit,
 	}, nil
 }
 
+// ensureGitignore adds .aicli/ to the project's .gitignore the first time
+// a session directory is created (unless disabled in config)
+func ensureGitignore(cfg *config.Config, workDir string) {
+	if cfg.DisableGitignore {
+		return
+	}
+	if _, err := os.Stat(filepath.Join(workDir, ".aicli")); err == nil {
+		return // Not the first session
+	}
+	if added, err := session.EnsureGitignore(workDir); err == nil && added {
+		fmt.Println("\033[90mAdded .aicli/ to .gitignore\033[0m")
+	}
+}
+
 // RunPlan creates a plan from a goal (non-interactive)
 func (c *Chat) RunPlan(goal string) error {
 	c.createPlan(goal)
@@ -115,15 +345,260 @@ func (c *Chat) RunPlanAll() error {
 
 // RunSingle executes a single prompt with full tool support
 func (c *Chat) RunSingle(prompt string) error {
+	defer c.handleSignals()()
 	c.recorder.RecordUser(prompt)
 	c.history.AddRequest(prompt)
 	c.sendMessage(prompt)
+	c.notifyCompletion("completed")
+
+	if c.quietOut != nil {
+		if content := strings.TrimSpace(c.recorder.LastAssistant()); content != "" {
+			fmt.Fprintln(c.quietOut, content)
+		}
+	}
 	return nil
 }
 
-// pushTodo adds a required action to the todo list (persistent)
-func (c *Chat) pushTodo(action string) {
-	c.todoFile.AddTodo(action)
+// SetQuietOutput enables quiet mode for RunSingle: the caller silences
+// stdout, and only the final assistant reply is written to w
+func (c *Chat) SetQuietOutput(w io.Writer) {
+	c.quietOut = w
+}
+
+// SetMaxSteps caps how many rounds of tool calls one prompt may run before
+// the loop gives up (0 = no cap). Used by single-prompt runs (--max-steps).
+func (c *Chat) SetMaxSteps(n int) {
+	c.maxSteps = n
+}
+
+// SetToolsEnabled switches tools on or off. With tools off no tool
+// definitions are sent and tool calls written as text are not executed.
+func (c *Chat) SetToolsEnabled(enabled bool) {
+	c.noTools = !enabled
+	if enabled {
+		c.client.ResetUseTools()
+	} else {
+		c.client.SetUseTools(false)
+	}
+}
+
+// sendRaw sends text as a lone user message (no history, system prompt or
+// tools) and prints the server's response verbatim
+func (c *Chat) sendRaw(text string) {
+	fmt.Printf("\033[90mPOST %s/chat/completions (model %s, no system prompt, no tools)\033[0m\n", c.cfg.OpenAIBaseURL(), c.cfg.Model)
+	status, body, err := c.client.RawRequest(text)
+	if err != nil {
+		fmt.Printf("\033[31mError: %v\033[0m\n", err)
+		return
+	}
+
+	fmt.Printf("\033[90mHTTP %d\033[0m\n", status)
+	var pretty bytes.Buffer
+	if json.Indent(&pretty, body, "", "  ") == nil {
+		fmt.Println(pretty.String())
+	} else {
+		fmt.Println(string(body))
+	}
+}
+
+// projectCommand resolves the command for a build/test/run/lint action from
+// the config's commands, falling back to the detected languages' defaults
+func (c *Chat) projectCommand(action string) (string, string, error) {
+	command, source, err := lang.ResolveCommand(action, c.cfg.Commands, lang.ProjectLanguages(c.exec.WorkDir()))
+	if err != nil {
+		// The project may have gained a language since it was detected
+		// (go mod init, npm init in a shell the tools don't see)
+		lang.ForgetLanguages(c.exec.WorkDir())
+		command, source, err = lang.ResolveCommand(action, c.cfg.Commands, lang.ProjectLanguages(c.exec.WorkDir()))
+	}
+	return command, source, err
+}
+
+// runLint runs the linter and returns its findings as a list. From a tool
+// call (confirm set) the command goes through the usual confirmation.
+func (c *Chat) runLint(confirm bool) string {
+	command, source, err := c.projectCommand("lint")
+	if err != nil {
+		return fmt.Sprintf("OPERATION FAILED: %v", err)
+	}
+	fmt.Printf("\033[90m$ %s (%s)\033[0m\n", command, source)
+	if confirm && !c.confirmTool("lint", fmt.Sprintf("Run linter: %s", command)) {
+		return "OPERATION FAILED: User declined to run the linter. It was NOT run."
+	}
+
+	result := c.exec.Run(command)
+	output := strings.TrimSpace(result.Output + "\n" + result.Error)
+	issues := lang.ParseLintIssues(output)
+
+	if result.Success() && len(issues) == 0 {
+		return fmt.Sprintf("LINT PASSED: %s reported no issues.", command)
+	}
+
+	var sb strings.Builder
+	if len(issues) > 0 {
+		sb.WriteString(fmt.Sprintf("LINT: %s reported %d issue(s):\n", command, len(issues)))
+		for _, issue := range issues {
+			sb.WriteString("- " + issue.String() + "\n")
+		}
+	} else {
+		// Failed without parseable findings (linter missing, config error, ...)
+		sb.WriteString(fmt.Sprintf("LINT FAILED: %s exited with code %d.\n", command, result.ExitCode))
+	}
+	sb.WriteString("\nOutput:\n" + truncate(output, 3000))
+	return sb.String()
+}
+
+// formatFile runs the file type's formatter on path, shows the changes it
+// made and returns a note naming them, like "formatted with gofmt: 2 hunks
+// changed (@@ -3,2 +3,2 @@, @@ -9,1 +9,1 @@)", or "" if nothing was done
+func (c *Chat) formatFile(path string) string {
+	formatter := lang.FormatterFor(path)
+	if formatter == nil {
+		return ""
+	}
+	hunks, err := c.exec.FormatFile(path, formatter)
+	if err != nil {
+		fmt.Printf("\033[33m⚠ %v\033[0m\n", err)
+		return ""
+	}
+	if len(hunks) == 0 {
+		return ""
+	}
+	fmt.Printf("\033[90m%s formatted with %s:\033[0m\n", path, formatter[0])
+	printHunks(hunks)
+	headers := make([]string, len(hunks))
+	for i, h := range hunks {
+		headers[i] = h.Header()
+	}
+	return fmt.Sprintf("formatted with %s: %s changed (%s)", formatter[0], plural(len(hunks), "hunk"), strings.Join(headers, ", "))
+}
+
+// formatCommand formats path, or every changed file if path is empty
+func (c *Chat) formatCommand(path string) {
+	files := []string{path}
+	if path == "" {
+		files = c.exec.ChangedFiles()
+		if len(files) == 0 {
+			fmt.Println("No changed files to format. Usage: /format [path]")
+			return
+		}
+	}
+
+	formatted := 0
+	for _, file := range files {
+		if lang.FormatterFor(file) == nil {
+			if path != "" {
+				fmt.Printf("No installed formatter for %s\n", file)
+			}
+			continue
+		}
+		if c.formatFile(file) != "" {
+			formatted++
+		}
+	}
+	fmt.Printf("\033[32m✓ Formatted %d file(s)\033[0m\n", formatted)
+}
+
+// checkToolSupport warns once, on the first reply, if tools were sent but
+// the model wrote a tool call or shell command in a code block instead of
+// calling a tool - a sign that the endpoint ignores the tools field or the
+// model can't call tools
+func (c *Chat) checkToolSupport(result *client.ChatResult) {
+	if c.toolsChecked {
+		return
+	}
+	c.toolsChecked = true
+	if c.noTools || !c.client.UsesTools() || len(result.ToolCalls) > 0 {
+		return
+	}
+	if client.HasFencedAction(result.Content) {
+		fmt.Printf("\033[33m⚠ The model described actions instead of calling tools. The endpoint or %s may not support tool calling; try a tool-capable model (/models, /model <name>).\033[0m\n", c.cfg.Model)
+	}
+}
+
+// checkCapabilities applies the cached capability probe for the current
+// model, probing first if enabled and nothing is cached
+func (c *Chat) checkCapabilities() {
+	caps, ok := client.LoadCapabilities(c.exec.WorkDir(), c.cfg.APIEndpoint, c.cfg.Model)
+	if !ok {
+		if !c.cfg.ProbeCapabilities {
+			return
+		}
+		if caps = c.probeCapabilities(); caps == nil {
+			return
+		}
+	}
+	c.applyCapabilities(caps)
+}
+
+// probeCapabilities probes the current model and caches the result
+func (c *Chat) probeCapabilities() *client.Capabilities {
+	fmt.Printf("\033[90mProbing %s capabilities...\033[0m", c.cfg.Model)
+	os.Stdout.Sync()
+	caps, err := c.client.ProbeCapabilities()
+	fmt.Print("\r\033[K")
+	if err != nil {
+		fmt.Printf("\033[33m⚠ Capability probe failed: %v\033[0m\n", err)
+		return nil
+	}
+	if err := client.SaveCapabilities(c.exec.WorkDir(), caps); err != nil {
+		fmt.Printf("\033[33mWarning: could not save capabilities: %v\033[0m\n", err)
+	}
+	fmt.Printf("\033[90m%s: %s\033[0m\n", c.cfg.Model, caps.Summary())
+	return caps
+}
+
+// applyCapabilities adjusts behavior to what the model supports: tool
+// definitions aren't sent to a model that rejects or ignores them (tool
+// calls written as text are still run)
+func (c *Chat) applyCapabilities(caps *client.Capabilities) {
+	if caps.ToolCalls == client.ToolCallsNone && !c.noTools && c.client.UsesTools() {
+		c.client.SetUseTools(false)
+		fmt.Printf("\033[33m⚠ %s didn't call tools when probed; not sending tool definitions. Use a tool-capable model for file edits and commands (/capabilities probe to re-check).\033[0m\n", c.cfg.Model)
+	}
+}
+
+// handleCapabilitiesCommand shows the cached capabilities of the current
+// model, or probes it again
+func (c *Chat) handleCapabilitiesCommand(args []string) {
+	if len(args) > 0 && args[0] == "probe" {
+		if caps := c.probeCapabilities(); caps != nil {
+			if caps.ToolCalls != client.ToolCallsNone && !c.noTools {
+				c.client.ResetUseTools()
+			}
+			c.applyCapabilities(caps)
+		}
+		return
+	}
+	caps, ok := client.LoadCapabilities(c.exec.WorkDir(), c.cfg.APIEndpoint, c.cfg.Model)
+	if !ok {
+		fmt.Printf("%s hasn't been probed. Use /capabilities probe.\n", c.cfg.Model)
+		return
+	}
+	fmt.Printf("%s: %s\n", c.cfg.Model, caps.Summary())
+}
+
+// parseTextToolCalls extracts tool calls written as text, unless tools are off
+func (c *Chat) parseTextToolCalls(content string) ([]tools.ToolCall, string) {
+	if c.noTools {
+		return nil, content
+	}
+	return client.ParseToolCallsFromText(content)
+}
+
+// AttachImage attaches an image file (relative to the project) to the next
+// user message
+func (c *Chat) AttachImage(path string) error {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(c.exec.WorkDir(), path)
+	}
+	return c.client.AttachImage(path)
+}
+
+// pushTodo adds a required error-fix action to the todo list (persistent). If
+// command is set, the todo is completed when exactly that command succeeds.
+func (c *Chat) pushTodo(action, command string) {
+	c.todoFile.AddBlockingTodo(action, command)
 }
 
 // clearTodosMatching removes all todos containing the given substring
@@ -131,19 +606,30 @@ func (c *Chat) clearTodosMatching(substr string) {
 	c.todoFile.RemoveByContent(substr)
 }
 
-// popTodo marks the first todo as completed and returns its content
-func (c *Chat) popTodo() string {
-	return c.todoFile.PopFirst()
+// clearTodos removes all error-fix todos; regular todos are kept
+func (c *Chat) clearTodos() {
+	c.todoFile.ClearBlocking()
 }
 
-// clearTodos removes all todos
-func (c *Chat) clearTodos() {
-	c.todoFile.Clear()
+// pendingTodosEqual reports whether the pending error-fix todos are exactly
+// the given items, in order
+func (c *Chat) pendingTodosEqual(items []session.TodoItem) bool {
+	pending := c.todoFile.GetBlocking()
+	if len(pending) != len(items) {
+		return false
+	}
+	for i, item := range pending {
+		if item.Content != items[i].Content || item.Command != items[i].Command {
+			return false
+		}
+	}
+	return true
 }
 
-// getTodoPrompt returns a prompt prefix if there are pending todos
+// getTodoPrompt returns a prompt prefix if there are pending blocking
+// (error-fix) todos. Regular todos never hijack the prompt.
 func (c *Chat) getTodoPrompt() string {
-	pending := c.todoFile.GetPending()
+	pending := c.todoFile.GetBlocking()
 	if len(pending) == 0 {
 		return ""
 	}
@@ -170,39 +656,110 @@ func NewPlaybackMode(cfg *config.Config, sessionPath string) (*Chat, error) {
 	return &Chat{
 		client:   c,
 		cfg:      cfg,
-		exec:     executor.New(workDir),
-		web:      web.NewSearch(),
+		exec:     newExecutor(cfg, workDir),
+		web:      newWebSearch(cfg),
 		autoExec: true, // Auto-execute in playback mode
 		playback: playback,
 	}, nil
 }
 
-func getHistoryPath() string {
-	home, err := os.UserHomeDir()
+// newExecutor creates the executor with the configured read cap, manifest
+// version syncing and release tagging
+func newExecutor(cfg *config.Config, workDir string) *executor.Executor {
+	e := executor.New(workDir)
+	e.SetMaxReadBytes(cfg.GetMaxReadBytes())
+	e.SetSyncManifests(cfg.SyncManifestVersion)
+	e.SetTagVersions(cfg.TagVersions)
+	return e
+}
+
+// newWebSearch creates the web client with the configured rate limit,
+// User-Agent and headers
+func newWebSearch(cfg *config.Config) *web.WebSearch {
+	w := web.NewSearch()
+	w.SetRateLimit(cfg.GetWebRateLimit())
+	w.SetUserAgent(cfg.WebUserAgent)
+	w.SetHeaders(cfg.WebHeaders)
+	return w
+}
+
+// getHistoryPath returns the readline history file: history_file if set,
+// .aicli/history with project_history, else ~/.config/aicli/history
+func getHistoryPath(cfg *config.Config, workDir string) string {
+	var path string
+	switch {
+	case cfg.HistoryFile != "":
+		path = cfg.HistoryFile
+		if strings.HasPrefix(path, "~/") {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return ""
+			}
+			path = filepath.Join(home, path[2:])
+		}
+	case cfg.ProjectHistory:
+		path = filepath.Join(workDir, ".aicli", "history")
+	default:
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		path = filepath.Join(home, ".config", "aicli", "history")
+	}
+	os.MkdirAll(filepath.Dir(path), 0755)
+	return path
+}
+
+// trimHistoryFile keeps only the last maxLines lines of the history file
+func trimHistoryFile(path string, maxLines int) error {
+	if path == "" || maxLines <= 0 {
+		return nil
+	}
+	data, err := os.ReadFile(path)
 	if err != nil {
-		return ""
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	if len(lines) <= maxLines {
+		return nil
 	}
-	dir := filepath.Join(home, ".config", "aicli")
-	os.MkdirAll(dir, 0755)
-	return filepath.Join(dir, "history")
+	lines = lines[len(lines)-maxLines:]
+	return session.WriteFileAtomic(path, []byte(strings.Join(lines, "\n")+"\n"), 0600)
 }
 
-func (c *Chat) Run() error {
-	if c.rl != nil {
-		defer c.rl.Close()
+// closeReadline closes readline and trims the history file to its cap.
+// Both Run and shutdown call it; only the first call does anything.
+func (c *Chat) closeReadline() {
+	if c.rl == nil {
+		return
 	}
+	c.rlClose.Do(func() {
+		c.rl.Close()
+		trimHistoryFile(c.rl.Config.HistoryFile, c.cfg.GetHistoryMaxLines())
+	})
+}
+
+func (c *Chat) Run() error {
+	defer c.closeReadline()
 
 	// Check if playback mode
 	if c.playback != nil {
 		return c.runPlayback()
 	}
 
+	defer c.handleSignals()()
+
 	v, _ := c.exec.GetVersion()
 	fmt.Printf("AI Coding Assistant - aicli v%s (project v%s)\n", config.AppVersion, v.String())
 	fmt.Println("Commands: /help, /clear, /file, /auto, /plan, /models, /model, /quit")
 	fmt.Printf("Working directory: %s\n", c.exec.WorkDir())
 	fmt.Printf("Session: %s\n\n", c.recorder.SessionPath())
 
+	c.checkCapabilities()
+
 	// Check for incomplete session from previous run
 	resumed := false
 	latestPath, _ := session.GetLatestSession(c.exec.WorkDir())
@@ -284,6 +841,8 @@ func (c *Chat) Run() error {
 			c.followUpInput = ""
 			fmt.Printf("\033[36m>>> %s\033[0m\n", line) // Echo the captured input
 		} else {
+			c.printNotices()
+			c.rl.SetPrompt(c.renderPrompt())
 			line, err = c.rl.Readline()
 			if err == readline.ErrInterrupt {
 				continue
@@ -298,81 +857,318 @@ func (c *Chat) Run() error {
 			continue
 		}
 
-		if strings.HasPrefix(line, "/") {
-			if c.handleCommand(line) {
+		if isMacroCommand(line) {
+			quit := c.handleMacroCommand(strings.Fields(line)[1:])
+			c.recentInputs = nil
+			if quit {
 				break
 			}
 			continue
 		}
+		c.recentInputs = append(c.recentInputs, line)
 
-		c.recorder.RecordUser(line)
-		c.history.AddRequest(line)
-		c.sendMessage(line)
+		if c.processInput(line) {
+			break
+		}
 	}
 
+	c.notifyCompletion("exited")
 	return nil
 }
 
-func (c *Chat) runPlayback() error {
-	fmt.Printf("Playback mode: %d entries\n", c.playback.Total())
-	fmt.Println("Press Enter to step through, 'q' to quit, 'a' to run all")
-
-	inputs := c.playback.GetUserInputs()
-	for i, input := range inputs {
-		fmt.Printf("\n\033[36m[%d/%d] User: %s\033[0m\n", i+1, len(inputs), input)
+// SetNotices sets a channel of one-line notices from background work, shown
+// before the next prompt once they arrive
+func (c *Chat) SetNotices(notices <-chan string) {
+	c.notices = notices
+}
 
-		// Wait for user
-		fmt.Print("(Enter to continue, 'q' to quit): ")
-		var response string
-		fmt.Scanln(&response)
-		if response == "q" {
-			break
+// printNotices prints the notices that have arrived, without waiting
+func (c *Chat) printNotices() {
+	for c.notices != nil {
+		select {
+		case notice, ok := <-c.notices:
+			if !ok {
+				c.notices = nil
+				return
+			}
+			fmt.Printf("\033[33m%s\033[0m\n", notice)
+		default:
+			return
 		}
-
-		c.sendMessage(input)
 	}
+}
 
-	fmt.Println("\nPlayback complete.")
-	return nil
+// defaultPrompt is the interactive prompt used when none is configured
+const defaultPrompt = "{git}{cyan}>>> {reset}"
+
+// promptColors maps prompt template color tokens to ANSI escapes
+var promptColors = []string{
+	"{cyan}", "\033[36m",
+	"{green}", "\033[32m",
+	"{yellow}", "\033[33m",
+	"{red}", "\033[31m",
+	"{gray}", "\033[90m",
+	"{reset}", "\033[0m",
 }
 
-func (c *Chat) handleCommand(cmd string) bool {
-	parts := strings.Fields(cmd)
-	if len(parts) == 0 {
-		return false
+// renderPrompt expands the configured prompt template
+func (c *Chat) renderPrompt() string {
+	tmpl := c.cfg.Prompt
+	if tmpl == "" {
+		tmpl = defaultPrompt
 	}
+	return expandPrompt(tmpl, c.cfg.Model, c.exec.WorkDir(), c.promptGitStatus)
+}
 
-	switch parts[0] {
-	case "/quit", "/exit", "/q":
-		fmt.Println("Goodbye!")
-		return true
-
-	case "/clear", "/new":
-		c.client.ClearHistory()
-		fmt.Println("Conversation cleared.")
+// promptGitStatus returns the branch and dirty state for the prompt. They are
+// cached between turns and refreshed after anything that may change them.
+func (c *Chat) promptGitStatus() (string, bool) {
+	if !c.gitFresh {
+		c.gitBranch = c.exec.CurrentBranch()
+		c.gitDirty = c.gitBranch != "" && len(c.exec.ChangedFiles()) > 0
+		c.gitFresh = true
+	}
+	return c.gitBranch, c.gitDirty
+}
 
-	case "/file", "/f":
-		if len(parts) < 2 {
-			fmt.Println("Usage: /file <path>")
-			return false
+// expandPrompt substitutes {model}, {dir}, {branch}, {dirty}, {git} and
+// color tokens in a prompt template. Git status is only looked up if the
+// template uses it.
+func expandPrompt(tmpl, model, workDir string, gitStatus func() (string, bool)) string {
+	pairs := append([]string{
+		"{model}", model,
+		"{dir}", filepath.Base(workDir),
+	}, promptColors...)
+	if strings.Contains(tmpl, "{branch}") || strings.Contains(tmpl, "{dirty}") || strings.Contains(tmpl, "{git}") {
+		branch, dirty := gitStatus()
+		dirtyMark := ""
+		if dirty {
+			dirtyMark = "*"
 		}
-		c.addFileContext(parts[1])
+		pairs = append(pairs,
+			"{branch}", branch,
+			"{dirty}", dirtyMark,
+			"{git}", gitPromptStatus(branch, dirty))
+	}
+	return strings.NewReplacer(pairs...).Replace(tmpl)
+}
 
-	case "/files":
-		if len(parts) < 2 {
-			fmt.Println("Usage: /files <path1> <path2> ...")
-			return false
-		}
-		for _, path := range parts[1:] {
-			c.addFileContext(path)
-		}
+// gitPromptStatus renders the {git} prompt token, e.g. "[main*] ", or ""
+// outside a git repository
+func gitPromptStatus(branch string, dirty bool) string {
+	if branch == "" {
+		return ""
+	}
+	if dirty {
+		branch += "*"
+	}
+	return "\033[90m[" + branch + "]\033[0m "
+}
 
-	case "/cd":
-		if len(parts) < 2 {
-			fmt.Printf("Current: %s\n", c.exec.WorkDir())
-			return false
+// processInput handles one line of user input: a slash command or a message
+// for the model. Returns true if the session should end.
+func (c *Chat) processInput(line string) bool {
+	if strings.HasPrefix(line, "/") {
+		// Commands that can change the branch or working tree refresh the prompt
+		switch strings.Fields(line)[0] {
+		case "/cd", "/git", "/run", "/build", "/test", "/lint", "/plan", "/resume-plan":
+			c.gitFresh = false
 		}
-		newDir := parts[1]
+		return c.handleCommand(line)
+	}
+
+	// The model may run tools that touch the tree
+	c.gitFresh = false
+
+	c.recorder.RecordUser(line)
+	c.history.AddRequest(line)
+	c.sendMessage(line)
+	return false
+}
+
+// exportSession writes a session (the current one by default) as a single
+// pretty-printed JSON document in .aicli/exports, e.g. for sharing
+func (c *Chat) exportSession(args []string) {
+	path := c.recorder.SessionPath()
+	if len(args) > 0 {
+		path = args[0]
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(c.exec.WorkDir(), ".aicli", path)
+		}
+	}
+	s, err := session.LoadSession(path)
+	if err != nil {
+		fmt.Printf("Error loading session: %v\n", err)
+		return
+	}
+	// Under .aicli so exports don't clutter the project, but not next to
+	// the sessions, where they would be listed as sessions themselves
+	dir := filepath.Join(c.exec.WorkDir(), ".aicli", "exports")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		fmt.Printf("\033[31m✗ Export failed: %v\033[0m\n", err)
+		return
+	}
+	out := filepath.Join(dir, strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))+".json")
+	if err := s.Export(out); err != nil {
+		fmt.Printf("\033[31m✗ Export failed: %v\033[0m\n", err)
+		return
+	}
+	fmt.Printf("\033[32m✓ Exported %d entries to %s\033[0m\n", len(s.Entries), out)
+}
+
+// editSession opens a session file (the current one by default) in the
+// user's editor
+func (c *Chat) editSession(args []string) {
+	path := c.recorder.SessionPath()
+	if len(args) > 0 {
+		path = args[0]
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(c.exec.WorkDir(), ".aicli", path)
+		}
+	}
+	if _, err := os.Stat(path); err != nil {
+		if os.IsNotExist(err) && len(args) == 0 {
+			fmt.Println("Nothing recorded in this session yet.")
+			return
+		}
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+
+	cmd := editorCommand(os.Getenv("VISUAL"), os.Getenv("EDITOR"), path)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	fmt.Printf("\033[90m$ %s\033[0m\n", strings.Join(cmd.Args, " "))
+	if err := cmd.Run(); err != nil {
+		fmt.Printf("\033[31m✗ Editor failed: %v\033[0m\n", err)
+	}
+}
+
+// diffColumnWidth is the width of each column of /diff-sessions
+const diffColumnWidth = 48
+
+// diffSessions prints two recorded sessions side by side, step by step,
+// marking where they took different actions or got different results
+func (c *Chat) diffSessions(nameA, nameB string) {
+	var sessions [2]*session.Session
+	for i, name := range []string{nameA, nameB} {
+		path := name
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(c.exec.WorkDir(), ".aicli", path)
+		}
+		s, err := session.LoadSession(path)
+		if err != nil {
+			fmt.Printf("Error loading session: %v\n", err)
+			return
+		}
+		sessions[i] = s
+	}
+
+	d := session.Diff(sessions[0], sessions[1])
+	if len(d.Rows) == 0 {
+		fmt.Println("Both sessions are empty.")
+		return
+	}
+	fmt.Print(session.FormatDiff(d, filepath.Base(nameA), filepath.Base(nameB), diffColumnWidth))
+}
+
+// editorCommand builds the command that opens path in $VISUAL, else
+// $EDITOR, else vi. The variables may carry arguments ("code --wait").
+func editorCommand(visual, editor, path string) *exec.Cmd {
+	fields := strings.Fields(visual)
+	if len(fields) == 0 {
+		fields = strings.Fields(editor)
+	}
+	if len(fields) == 0 {
+		fields = []string{"vi"}
+	}
+	return exec.Command(fields[0], append(fields[1:], path)...)
+}
+
+// replayInto loads a recorded session's full conversation (including tool
+// calls and results) into the live chat, replacing the current history
+func (c *Chat) replayInto(name string) {
+	sessionPath := name
+	if !filepath.IsAbs(sessionPath) {
+		sessionPath = filepath.Join(c.exec.WorkDir(), ".aicli", sessionPath)
+	}
+	prev, err := session.LoadSession(sessionPath)
+	if err != nil {
+		fmt.Printf("Error loading session: %v\n", err)
+		return
+	}
+
+	entries := prev.GetEntries()
+	n := c.client.LoadHistory(entries)
+	fmt.Printf("\033[32m✓ Loaded %d messages from %s (%d recorded entries) - continue the conversation\033[0m\n", n, filepath.Base(sessionPath), len(entries))
+	c.recorder.RecordUser(fmt.Sprintf("[Replayed %s into this chat]", filepath.Base(sessionPath)))
+}
+
+func (c *Chat) runPlayback() error {
+	fmt.Printf("Playback mode: %d entries\n", c.playback.Total())
+	fmt.Println("Press Enter to step through, 'q' to quit, 'a' to run all")
+
+	inputs := c.playback.GetUserInputs()
+	for i, input := range inputs {
+		fmt.Printf("\n\033[36m[%d/%d] User: %s\033[0m\n", i+1, len(inputs), input)
+
+		// Wait for user
+		fmt.Print("(Enter to continue, 'q' to quit): ")
+		var response string
+		fmt.Scanln(&response)
+		if response == "q" {
+			break
+		}
+
+		c.sendMessage(input)
+	}
+
+	fmt.Println("\nPlayback complete.")
+	return nil
+}
+
+func (c *Chat) handleCommand(cmd string) bool {
+	parts := strings.Fields(cmd)
+	if len(parts) == 0 {
+		return false
+	}
+
+	switch parts[0] {
+	case "/quit", "/exit", "/q":
+		fmt.Println("Goodbye!")
+		return true
+
+	case "/clear", "/new":
+		c.client.ClearHistory()
+		fmt.Println("Conversation cleared.")
+
+	case "/compact":
+		c.compactConversation()
+
+	case "/context":
+		c.showContext()
+
+	case "/file", "/f":
+		if len(parts) < 2 {
+			fmt.Println("Usage: /file <path>")
+			return false
+		}
+		c.addFileContext(parts[1])
+
+	case "/files":
+		if len(parts) < 2 {
+			fmt.Println("Usage: /files <path1> <path2> ...")
+			return false
+		}
+		for _, path := range parts[1:] {
+			c.addFileContext(path)
+		}
+
+	case "/cd":
+		if len(parts) < 2 {
+			fmt.Printf("Current: %s\n", c.exec.WorkDir())
+			return false
+		}
+		newDir := parts[1]
 		if !filepath.IsAbs(newDir) {
 			newDir = filepath.Join(c.exec.WorkDir(), newDir)
 		}
@@ -381,8 +1177,26 @@ func (c *Chat) handleCommand(cmd string) bool {
 			return false
 		}
 		c.exec.SetWorkDir(newDir)
+		// The directory may have changed since it was last detected
+		lang.ForgetLanguages(newDir)
 		fmt.Printf("Changed to: %s\n", newDir)
 
+	case "/languages", "/langs":
+		dir := c.exec.WorkDir()
+		if len(parts) > 1 && parts[1] == "redetect" {
+			lang.ForgetLanguages(dir)
+		}
+		langs := lang.ProjectLanguages(dir)
+		if len(langs) == 0 {
+			fmt.Println("No languages detected")
+			return false
+		}
+		names := make([]string, len(langs))
+		for i, l := range langs {
+			names[i] = string(l)
+		}
+		fmt.Printf("Detected languages: %s\n", strings.Join(names, ", "))
+
 	case "/auto":
 		c.autoExec = !c.autoExec
 		if c.autoExec {
@@ -400,6 +1214,23 @@ func (c *Chat) handleCommand(cmd string) bool {
 		result := c.exec.Run(command)
 		fmt.Println(result.String())
 
+	case "/lint":
+		fmt.Println(c.runLint(false))
+
+	case "/format":
+		c.formatCommand(strings.Join(parts[1:], " "))
+
+	case "/build", "/test":
+		action := strings.TrimPrefix(parts[0], "/")
+		command, source, err := c.projectCommand(action)
+		if err != nil {
+			fmt.Printf("\033[31m✗ %v\033[0m\n", err)
+			return false
+		}
+		fmt.Printf("\033[90m$ %s (%s)\033[0m\n", command, source)
+		result := c.exec.Run(command)
+		fmt.Println(result.String())
+
 	case "/git":
 		if len(parts) < 2 {
 			fmt.Println("Usage: /git status|diff|log|add|commit")
@@ -412,6 +1243,10 @@ func (c *Chat) handleCommand(cmd string) bool {
 		fmt.Printf("Version: %s\n", v.String())
 
 	case "/sessions":
+		if len(parts) > 1 && parts[1] == "export" {
+			c.exportSession(parts[2:])
+			return false
+		}
 		sessions, err := session.ListSessions(c.exec.WorkDir())
 		if err != nil {
 			fmt.Printf("Error: %v\n", err)
@@ -426,6 +1261,25 @@ func (c *Chat) handleCommand(cmd string) bool {
 			fmt.Printf("  %s\n", filepath.Base(s))
 		}
 
+	case "/edit-session":
+		c.editSession(parts[1:])
+
+	case "/diff-sessions":
+		if len(parts) < 3 {
+			fmt.Println("Usage: /diff-sessions <file_a> <file_b>")
+			return false
+		}
+		c.diffSessions(parts[1], parts[2])
+
+	case "/disk":
+		usage, err := session.DiskUsageOf(c.exec.WorkDir())
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return false
+		}
+		fmt.Printf("Disk usage of %s:\n", filepath.Join(c.exec.WorkDir(), ".aicli"))
+		fmt.Print(session.FormatDiskUsage(usage))
+
 	case "/playback":
 		if len(parts) < 2 {
 			fmt.Println("Usage: /playback <session_file>")
@@ -445,20 +1299,41 @@ func (c *Chat) handleCommand(cmd string) bool {
 		c.runPlayback()
 		c.playback = nil
 
+	case "/replay-into":
+		if len(parts) < 2 {
+			fmt.Println("Usage: /replay-into <session_file>")
+			return false
+		}
+		c.replayInto(parts[1])
+
 	case "/search":
 		if len(parts) < 2 {
-			fmt.Println("Usage: /search <query>")
+			fmt.Println("Usage: /search <query> | /search more")
 			return false
 		}
-		query := strings.Join(parts[1:], " ")
-		results, err := c.web.Search(query, 5)
+		query, page := strings.Join(parts[1:], " "), 1
+		if query == "more" {
+			if c.lastSearch == "" {
+				fmt.Println("No previous search. Usage: /search <query>")
+				return false
+			}
+			query, page = c.lastSearch, c.searchPage+1
+		}
+		results, err := c.web.SearchPage(query, 5, page)
 		if err != nil {
 			fmt.Printf("Error: %v\n", err)
 			return false
 		}
+		if len(results) == 0 {
+			fmt.Println("No more results.")
+			return false
+		}
+		c.lastSearch, c.searchPage = query, page
+		offset := (page - 1) * 5
 		for i, r := range results {
-			fmt.Printf("%d. %s\n   %s\n   %s\n\n", i+1, r.Title, r.URL, r.Snippet)
+			fmt.Printf("%d. %s\n   %s\n   %s\n\n", offset+i+1, r.Title, r.URL, r.Snippet)
 		}
+		fmt.Println("\033[90m(/search more for the next results)\033[0m")
 
 	case "/screenshot":
 		outputPath := ""
@@ -468,6 +1343,76 @@ func (c *Chat) handleCommand(cmd string) bool {
 		result := c.exec.ScreenCapture(outputPath, true)
 		fmt.Println(result.String())
 
+	case "/raw":
+		if len(parts) < 2 {
+			fmt.Println("Usage: /raw <text>")
+			return false
+		}
+		c.sendRaw(strings.TrimSpace(strings.TrimPrefix(cmd, parts[0])))
+
+	case "/tools":
+		if len(parts) > 1 {
+			switch parts[1] {
+			case "on":
+				c.SetToolsEnabled(true)
+			case "off":
+				c.SetToolsEnabled(false)
+			default:
+				fmt.Println("Usage: /tools [on|off]")
+				return false
+			}
+		}
+		if c.noTools {
+			fmt.Println("Tools: off (plain chat - the model can't run commands or edit files)")
+		} else {
+			fmt.Println("Tools: on")
+		}
+
+	case "/capabilities", "/caps":
+		c.handleCapabilitiesCommand(parts[1:])
+
+	case "/think":
+		if len(parts) > 1 {
+			effort := strings.ToLower(parts[1])
+			if effort == "default" {
+				effort = ""
+			}
+			if effort != "" && !slices.Contains(config.ReasoningEfforts, effort) {
+				fmt.Println("Usage: /think [low|medium|high|default]")
+				return false
+			}
+			c.cfg.ReasoningEffort = effort
+		}
+		if effort := c.cfg.GetReasoningEffort(); effort != "" {
+			fmt.Printf("Reasoning effort: %s\n", effort)
+		} else {
+			fmt.Println("Reasoning effort: model default")
+		}
+
+	case "/image":
+		if len(parts) < 2 {
+			fmt.Println("Usage: /image <path>")
+			return false
+		}
+		path := strings.Join(parts[1:], " ")
+		if err := c.AttachImage(path); err != nil {
+			fmt.Printf("\033[31m✗ %v\033[0m\n", err)
+			return false
+		}
+		fmt.Printf("\033[32m✓ Attached %s to your next message (%d image(s) pending)\033[0m\n", path, c.client.PendingImages())
+
+	case "/open":
+		if len(parts) < 2 {
+			fmt.Println("Usage: /open <path-or-url>")
+			return false
+		}
+		target := strings.Join(parts[1:], " ")
+		if err := c.exec.Open(target); err != nil {
+			fmt.Printf("\033[31m✗ %v\033[0m\n", err)
+			return false
+		}
+		fmt.Printf("\033[90mOpened %s\033[0m\n", target)
+
 	case "/help", "/h", "/?":
 		c.printHelp()
 
@@ -489,6 +1434,16 @@ func (c *Chat) handleCommand(cmd string) bool {
 			}
 		}
 
+	case "/debug":
+		c.handleDebugCommand(parts[1:])
+
+	case "/pull":
+		if len(parts) < 2 {
+			fmt.Println("Usage: /pull <model>")
+			return false
+		}
+		c.pullModel(parts[1])
+
 	case "/model":
 		if len(parts) < 2 {
 			fmt.Printf("Current model: %s\n", c.cfg.Model)
@@ -528,9 +1483,34 @@ func (c *Chat) handleCommand(cmd string) bool {
 	case "/history":
 		c.handleHistoryCommand(parts[1:])
 
+	case "/reset":
+		c.resetProjectState(parts[1:])
+
+	case "/export-changelog":
+		path := "CHANGELOG.keepachangelog.md"
+		if len(parts) > 1 {
+			path = parts[1]
+		}
+		c.exportChangelog(path)
+
 	case "/plan":
 		c.handlePlanCommand(parts[1:])
 
+	case "/plan-edit":
+		c.handlePlanEditCommand(parts[1:])
+
+	case "/plan-regen":
+		c.regeneratePlan()
+
+	case "/resume-plan":
+		c.resumePlan()
+
+	case "/why":
+		c.explainLastTool()
+
+	case "/memory", "/mem":
+		c.handleMemoryCommand(parts[1:])
+
 	default:
 		fmt.Printf("Unknown command: %s\n", parts[0])
 	}
@@ -555,11 +1535,30 @@ func (c *Chat) handleGitCommand(args []string) {
 			result = c.exec.GitAdd()
 		}
 	case "commit":
-		if len(args) < 2 {
-			fmt.Println("Usage: /git commit <message>")
+		dryRun := false
+		var words []string
+		for _, arg := range args[1:] {
+			if arg == "--dry-run" {
+				dryRun = true
+			} else {
+				words = append(words, arg)
+			}
+		}
+		if len(words) == 0 {
+			fmt.Println("Usage: /git commit [--dry-run] <message>")
+			return
+		}
+		msg := strings.Join(words, " ")
+		if dryRun {
+			preview, err := c.exec.PreviewCommit(msg, "patch")
+			if err != nil {
+				fmt.Printf("\033[31m✗ %v\033[0m\n", err)
+				return
+			}
+			fmt.Println(preview)
+			fmt.Println("\033[90m(dry run - nothing was committed)\033[0m")
 			return
 		}
-		msg := strings.Join(args[1:], " ")
 		result = c.exec.GitCommitWithVersion(msg, "patch")
 	default:
 		fmt.Printf("Unknown git command: %s\n", args[0])
@@ -659,11 +1658,17 @@ func (c *Chat) handleTodosCommand(args []string) {
 				status = "[x]"
 				statusColor = "\033[32m" // green
 			}
-			fmt.Printf("  %s%s\033[0m %d. %s\n", statusColor, status, i+1, todo.Content)
+			blocking := ""
+			if todo.Blocking && todo.Status != "completed" {
+				blocking = " \033[31m(blocking)\033[0m"
+			}
+			fmt.Printf("  %s%s\033[0m %d. %s%s\n", statusColor, status, i+1, todo.Content, blocking)
 		}
 		fmt.Println("─────────────────────────────────────")
 		fmt.Println("Usage: /todos clear       - clear all todos")
 		fmt.Println("       /todos add <text>  - add a new todo")
+		fmt.Println("       /todos done <n>    - mark todo n as completed")
+		fmt.Println("       /todos rm <n>      - remove todo n")
 		return
 	}
 
@@ -682,63 +1687,377 @@ func (c *Chat) handleTodosCommand(args []string) {
 		c.history.AddTodo(content, "added")
 		fmt.Printf("Added todo: %s\n", content)
 
+	case "done", "rm", "remove":
+		if len(args) < 2 {
+			fmt.Printf("Usage: /todos %s <n>\n", args[0])
+			return
+		}
+		index, ok := c.todoIndex(args[1])
+		if !ok {
+			return
+		}
+		content := c.todoFile.GetAll()[index].Content
+		if args[0] == "done" {
+			c.todoFile.Complete(index)
+			c.history.AddTodo(content, "completed")
+			fmt.Printf("\033[32m✓ Completed todo: %s\033[0m\n", content)
+		} else {
+			c.todoFile.Remove(index)
+			fmt.Printf("Removed todo: %s\n", content)
+		}
+
 	default:
-		fmt.Println("Unknown subcommand. Use: /todos [clear|add]")
+		fmt.Println("Unknown subcommand. Use: /todos [clear|add|done|rm]")
 	}
 }
 
-func (c *Chat) handleChangelogCommand(args []string) {
-	if len(args) == 0 {
-		// Show recent changelog entries
-		entries := c.changelog.GetRecent(10)
-		if len(entries) == 0 {
-			fmt.Println("No changelog entries.")
-			return
-		}
+// maxMacroLines caps how many inputs /macro save captures by default
+const maxMacroLines = 20
 
-		fmt.Println("\nRecent Changes:")
+// handleMacroCommand saves, runs, lists and removes input macros. It
+// returns true if a replayed line quit the chat.
+func (c *Chat) handleMacroCommand(args []string) bool {
+	if len(args) == 0 || args[0] == "list" {
+		if len(c.cfg.Macros) == 0 {
+			fmt.Println("No macros. Use /macro save <name> [n] to save recent inputs.")
+			return false
+		}
+		fmt.Println("\nMacros:")
 		fmt.Println("─────────────────────────────────────")
-		for _, entry := range entries {
-			timeStr := entry.Timestamp.Format("2006-01-02 15:04")
-			files := ""
-			if len(entry.Files) > 0 {
-				files = fmt.Sprintf(" (%s)", strings.Join(entry.Files, ", "))
+		for name, lines := range c.cfg.Macros {
+			fmt.Printf("  \033[36m%s\033[0m\n", name)
+			for _, line := range lines {
+				fmt.Printf("    %s\n", line)
 			}
-			fmt.Printf("  [%s] %s: %s%s\n", timeStr, entry.Type, entry.Description, files)
 		}
 		fmt.Println("─────────────────────────────────────")
-		fmt.Printf("Full changelog: %s\n", c.changelog.FilePath())
-		return
+		fmt.Println("Usage: /macro save <name> [n]  - save inputs since the last /macro (or the last n)")
+		fmt.Println("       /macro run <name> [args] - replay, filling {1}, {2}, ... and {*}")
+		fmt.Println("       /macro rm <name>         - delete a macro")
+		return false
 	}
 
 	switch args[0] {
-	case "add":
-		if len(args) < 3 {
-			fmt.Println("Usage: /changelog add <type> <description>")
-			fmt.Println("Types: added, changed, fixed, removed")
-			return
+	case "save":
+		if len(args) < 2 {
+			fmt.Println("Usage: /macro save <name> [n]")
+			return false
 		}
-		entryType := args[1]
-		description := strings.Join(args[2:], " ")
-		c.changelog.AddEntry(entryType, description, nil)
-		fmt.Printf("Added changelog entry: [%s] %s\n", entryType, description)
-
-	default:
-		fmt.Println("Unknown subcommand. Use: /changelog [add]")
-	}
-}
-
-func (c *Chat) handleHistoryCommand(args []string) {
-	count := 10
-	if len(args) > 0 {
-		if n, err := fmt.Sscanf(args[0], "%d", &count); n == 1 && err == nil {
-			// Use provided count
+		lines := c.recentInputs
+		if len(args) > 2 {
+			var n int
+			if _, err := fmt.Sscanf(args[2], "%d", &n); err != nil || n < 1 {
+				fmt.Printf("\033[31mInvalid count: %s\033[0m\n", args[2])
+				return false
+			}
+			if n < len(lines) {
+				lines = lines[len(lines)-n:]
+			}
+		} else if len(lines) > maxMacroLines {
+			lines = lines[len(lines)-maxMacroLines:]
 		}
-	}
-
-	entries := c.history.GetRecent(count)
-	if len(entries) == 0 {
-		fmt.Println("No history entries.")
+		if len(lines) == 0 {
+			fmt.Println("Nothing to save - enter some commands or prompts first.")
+			return false
+		}
+		if c.cfg.Macros == nil {
+			c.cfg.Macros = make(map[string][]string)
+		}
+		c.cfg.Macros[args[1]] = append([]string(nil), lines...)
+		if err := c.cfg.Save(); err != nil {
+			fmt.Printf("Error saving config: %v\n", err)
+			return false
+		}
+		fmt.Printf("\033[32m✓ Saved macro %s (%d lines)\033[0m\n", args[1], len(lines))
+
+	case "run":
+		if len(args) < 2 {
+			fmt.Println("Usage: /macro run <name> [args...]")
+			return false
+		}
+		lines, ok := c.cfg.Macros[args[1]]
+		if !ok {
+			fmt.Printf("\033[31mNo macro named %s\033[0m\n", args[1])
+			return false
+		}
+		expanded, err := expandMacro(lines, args[2:])
+		if err != nil {
+			fmt.Printf("\033[31m✗ %v\033[0m\n", err)
+			return false
+		}
+		for _, line := range expanded {
+			if isMacroCommand(line) {
+				continue // No nested macros
+			}
+			fmt.Printf("\033[36m>>> %s\033[0m\n", line)
+			if c.processInput(line) {
+				return true
+			}
+		}
+
+	case "rm", "remove":
+		if len(args) < 2 {
+			fmt.Println("Usage: /macro rm <name>")
+			return false
+		}
+		if _, ok := c.cfg.Macros[args[1]]; !ok {
+			fmt.Printf("\033[31mNo macro named %s\033[0m\n", args[1])
+			return false
+		}
+		delete(c.cfg.Macros, args[1])
+		if err := c.cfg.Save(); err != nil {
+			fmt.Printf("Error saving config: %v\n", err)
+			return false
+		}
+		fmt.Printf("Removed macro %s\n", args[1])
+
+	default:
+		fmt.Println("Unknown subcommand. Use: /macro [list|save|run|rm]")
+	}
+	return false
+}
+
+// isMacroCommand reports whether an input line is a /macro command (and not
+// another command that merely starts with "/macro")
+func isMacroCommand(line string) bool {
+	return line == "/macro" || strings.HasPrefix(line, "/macro ")
+}
+
+// macroPlaceholder matches {1}, {2}, ... and {*} in macro lines
+var macroPlaceholder = regexp.MustCompile(`\{(\d+|\*)\}`)
+
+// expandMacro substitutes arguments into macro lines: {n} is the nth
+// argument (1-based) and {*} is all arguments joined by spaces
+func expandMacro(lines, args []string) ([]string, error) {
+	var missing error
+	expanded := make([]string, len(lines))
+	for i, line := range lines {
+		expanded[i] = macroPlaceholder.ReplaceAllStringFunc(line, func(m string) string {
+			key := m[1 : len(m)-1]
+			if key == "*" {
+				return strings.Join(args, " ")
+			}
+			var n int
+			fmt.Sscanf(key, "%d", &n)
+			if n < 1 || n > len(args) {
+				if missing == nil {
+					missing = fmt.Errorf("macro needs argument %s but got %d argument(s)", m, len(args))
+				}
+				return m
+			}
+			return args[n-1]
+		})
+	}
+	return expanded, missing
+}
+
+// todoIndex converts a 1-based todo number as shown by /todos into an index,
+// printing an error if it is not a number or out of range
+func (c *Chat) todoIndex(arg string) (int, bool) {
+	var n int
+	if _, err := fmt.Sscanf(arg, "%d", &n); err != nil {
+		fmt.Printf("\033[31mInvalid todo number: %s\033[0m\n", arg)
+		return 0, false
+	}
+	count := len(c.todoFile.GetAll())
+	if n < 1 || n > count {
+		fmt.Printf("\033[31mNo todo %d (have %d)\033[0m\n", n, count)
+		return 0, false
+	}
+	return n - 1, true
+}
+
+func (c *Chat) handleChangelogCommand(args []string) {
+	if len(args) == 0 {
+		// Show recent changelog entries
+		entries := c.changelog.GetRecent(10)
+		if len(entries) == 0 {
+			fmt.Println("No changelog entries.")
+			return
+		}
+
+		fmt.Println("\nRecent Changes:")
+		fmt.Println("─────────────────────────────────────")
+		for _, entry := range entries {
+			timeStr := entry.Timestamp.Format("2006-01-02 15:04")
+			files := ""
+			if len(entry.Files) > 0 {
+				files = fmt.Sprintf(" (%s)", strings.Join(entry.Files, ", "))
+			}
+			fmt.Printf("  [%s] %s: %s%s\n", timeStr, entry.Type, entry.Description, files)
+		}
+		fmt.Println("─────────────────────────────────────")
+		fmt.Printf("Full changelog: %s\n", c.changelog.FilePath())
+		return
+	}
+
+	switch args[0] {
+	case "add":
+		if len(args) < 3 {
+			fmt.Println("Usage: /changelog add <type> <description>")
+			fmt.Println("Types: added, changed, fixed, removed")
+			return
+		}
+		entryType := args[1]
+		description := strings.Join(args[2:], " ")
+		c.changelog.AddEntry(entryType, description, nil)
+		fmt.Printf("Added changelog entry: [%s] %s\n", entryType, description)
+
+	default:
+		fmt.Println("Unknown subcommand. Use: /changelog [add]")
+	}
+}
+
+// handleMemoryCommand lists, adds and removes project memory notes, which
+// are included in the system prompt of every new conversation
+func (c *Chat) handleMemoryCommand(args []string) {
+	memory := session.NewMemoryFile(c.exec.WorkDir())
+
+	if len(args) == 0 || args[0] == "list" {
+		entries := memory.Entries()
+		if len(entries) == 0 {
+			fmt.Println("No project memory.")
+		} else {
+			fmt.Println("\nProject Memory:")
+			fmt.Println("─────────────────────────────────────")
+			for i, entry := range entries {
+				fmt.Printf("  %d. %s\n", i+1, entry)
+			}
+			fmt.Println("─────────────────────────────────────")
+		}
+		fmt.Println("Usage: /memory add <text>  - remember a fact for this project")
+		fmt.Println("       /memory rm <n>      - forget entry n")
+		return
+	}
+
+	switch args[0] {
+	case "add":
+		if len(args) < 2 {
+			fmt.Println("Usage: /memory add <text>")
+			return
+		}
+		if err := memory.Add(strings.Join(args[1:], " ")); err != nil {
+			fmt.Printf("\033[31mError saving memory: %v\033[0m\n", err)
+			return
+		}
+		fmt.Println("\033[32m✓ Remembered (applies to new conversations; use /clear to start one)\033[0m")
+
+	case "rm", "remove":
+		if len(args) < 2 {
+			fmt.Println("Usage: /memory rm <n>")
+			return
+		}
+		var n int
+		if _, err := fmt.Sscanf(args[1], "%d", &n); err != nil {
+			fmt.Printf("\033[31mInvalid entry number: %s\033[0m\n", args[1])
+			return
+		}
+		if err := memory.Remove(n - 1); err != nil {
+			fmt.Printf("\033[31m%v\033[0m\n", err)
+			return
+		}
+		fmt.Printf("Forgot entry %d\n", n)
+
+	default:
+		fmt.Println("Unknown subcommand. Use: /memory [list|add|rm]")
+	}
+}
+
+// exportChangelog writes the changelog in strict Keep a Changelog format to
+// path (relative to the project), leaving the live CHANGELOG.md untouched
+func (c *Chat) exportChangelog(path string) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(c.exec.WorkDir(), path)
+	}
+	content := c.changelog.ExportKeepAChangelog(c.exec.RemoteWebURL(), c.exec.Tags())
+	if err := session.WriteFileAtomic(path, []byte(content), 0644); err != nil {
+		fmt.Printf("\033[31m✗ Error writing %s: %v\033[0m\n", path, err)
+		return
+	}
+	fmt.Printf("\033[32m✓ Exported changelog to %s\033[0m\n", path)
+}
+
+// resetTargets are the kinds of project state /reset clears, in order
+var resetTargets = []string{"history", "todos", "changelog", "debug", "plan"}
+
+// resetProjectState clears the project's aicli state - all of it, or the
+// targets named in args - after confirmation, and reports what was removed
+func (c *Chat) resetProjectState(args []string) {
+	targets := resetTargets
+	if len(args) > 0 {
+		targets = nil
+		for _, arg := range args {
+			if !slices.Contains(resetTargets, arg) {
+				fmt.Printf("Unknown target %q. Use: /reset [%s ...]\n", arg, strings.Join(resetTargets, "|"))
+				return
+			}
+			if !slices.Contains(targets, arg) {
+				targets = append(targets, arg)
+			}
+		}
+	}
+
+	if !c.confirm(fmt.Sprintf("Clear %s?", strings.Join(targets, ", "))) {
+		fmt.Println("Reset cancelled.")
+		return
+	}
+
+	var removed []string
+	for _, target := range targets {
+		switch target {
+		case "history":
+			if n := c.history.Len(); n > 0 {
+				c.history.Clear()
+				removed = append(removed, fmt.Sprintf("%d history entries", n))
+			}
+		case "todos":
+			if n := len(c.todoFile.GetAll()); n > 0 {
+				c.todoFile.Clear()
+				removed = append(removed, fmt.Sprintf("%d todos", n))
+			}
+		case "changelog":
+			if n := c.changelog.ClearUnreleased(); n > 0 {
+				removed = append(removed, fmt.Sprintf("%d unreleased changelog entries", n))
+			}
+		case "debug":
+			n, err := c.client.ClearDebugLogs()
+			if err != nil {
+				fmt.Printf("\033[31m✗ Could not clear debug logs: %v\033[0m\n", err)
+			}
+			if n > 0 {
+				removed = append(removed, fmt.Sprintf("%d debug logs", n))
+			}
+		case "plan":
+			if plan.Exists(c.exec.WorkDir()) {
+				plan.Remove(c.exec.WorkDir())
+				removed = append(removed, "the plan")
+			}
+		}
+	}
+
+	if len(removed) == 0 {
+		fmt.Println("Nothing to clear.")
+		return
+	}
+	fmt.Printf("\033[32m✓ Removed %s\033[0m\n", strings.Join(removed, ", "))
+}
+
+func (c *Chat) handleHistoryCommand(args []string) {
+	if len(args) > 0 && args[0] == "search" {
+		c.searchPromptHistory(strings.Join(args[1:], " "))
+		return
+	}
+
+	count := 10
+	if len(args) > 0 {
+		if n, err := fmt.Sscanf(args[0], "%d", &count); n == 1 && err == nil {
+			// Use provided count
+		}
+	}
+
+	entries := c.history.GetRecent(count)
+	if len(entries) == 0 {
+		fmt.Println("No history entries.")
 		return
 	}
 
@@ -766,6 +2085,52 @@ func (c *Chat) handleHistoryCommand(args []string) {
 	fmt.Printf("Full history: %s\n", c.history.FilePath())
 }
 
+// maxPromptMatches caps the prompts listed by /history search
+const maxPromptMatches = 20
+
+// searchPromptHistory lists past user prompts from all recorded sessions
+// that match term and lets the user pick one to send again
+func (c *Chat) searchPromptHistory(term string) {
+	if strings.TrimSpace(term) == "" {
+		fmt.Println("Usage: /history search <term>")
+		return
+	}
+	matches, err := session.SearchPrompts(c.exec.WorkDir(), term)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	if len(matches) == 0 {
+		fmt.Printf("No past prompts match %q.\n", term)
+		return
+	}
+	if len(matches) > maxPromptMatches {
+		fmt.Printf("\033[90m%d matches, showing the newest %d\033[0m\n", len(matches), maxPromptMatches)
+		matches = matches[:maxPromptMatches]
+	}
+
+	for i, m := range matches {
+		prompt := strings.Join(strings.Fields(m.Prompt), " ")
+		fmt.Printf("  %2d. \033[90m[%s %s]\033[0m %s\n", i+1, m.Time.Format("2006-01-02 15:04"), m.Session, truncate(prompt, 70))
+	}
+	if c.rl == nil {
+		return
+	}
+
+	fmt.Printf("\033[33mRe-send which prompt? [1-%d] (Enter = cancel): \033[0m", len(matches))
+	line, err := c.rl.Readline()
+	if err != nil {
+		return
+	}
+	var n int
+	if _, err := fmt.Sscanf(strings.TrimSpace(line), "%d", &n); err != nil || n < 1 || n > len(matches) {
+		fmt.Println("Cancelled.")
+		return
+	}
+	// Sent by the main loop as if it had just been typed
+	c.followUpInput = matches[n-1].Prompt
+}
+
 func (c *Chat) addFileContext(path string) {
 	content, err := c.exec.ReadFile(path)
 	if err != nil {
@@ -784,6 +2149,43 @@ func (c *Chat) addFileContext(path string) {
 	c.client.Chat(contextMsg, false, nil)
 }
 
+// explainLastTool asks the model why it made its most recent tool call,
+// using a side request that doesn't touch the main conversation history
+func (c *Chat) explainLastTool() {
+	last := c.recorder.LastToolCall()
+	if last == nil {
+		fmt.Println("No tool calls in this session yet.")
+		return
+	}
+
+	fmt.Print("\033[90mAsking why...\033[0m")
+	os.Stdout.Sync()
+	result, err := c.client.Aside(buildWhyQuestion(last.ToolName, last.ToolArgs))
+	fmt.Print("\r\033[K")
+	if err != nil {
+		fmt.Printf("\033[31mError: %v\033[0m\n", err)
+		return
+	}
+	fmt.Printf("\033[36mWhy %s:\033[0m\n%s\n", last.ToolName, strings.TrimSpace(result.Content))
+}
+
+// buildWhyQuestion constructs the one-shot question sent by /why
+func buildWhyQuestion(toolName, toolArgs string) string {
+	call := toolName
+	if args := strings.TrimSpace(toolArgs); args != "" && args != "{}" {
+		call = fmt.Sprintf("%s with arguments %s", toolName, truncate(args, 500))
+	}
+	return fmt.Sprintf("Explain briefly why you just ran %s. What were you trying to achieve, and what did you expect it to do? Do not call any tools.", call)
+}
+
+// truncate shortens s to at most n bytes, marking the cut with "..."
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
+
 func extToLang(ext string) string {
 	langs := map[string]string{
 		".go": "go", ".py": "python", ".js": "javascript", ".ts": "typescript",
@@ -798,10 +2200,287 @@ func extToLang(ext string) string {
 	return ""
 }
 
-// streamWithInterrupt runs AI streaming with escape key detection
+// streamWithInterrupt runs AI streaming with escape key detection. When the
+// response has several alternatives (n > 1), the user picks one afterwards.
 func (c *Chat) streamWithInterrupt(sendFunc func(context.Context) (*client.ChatResult, error)) (*client.ChatResult, bool) {
+	result, interrupted := c.streamRequest(sendFunc)
+	if result == nil && !interrupted && c.offerCompaction() {
+		fmt.Print("\033[90mRetrying... (Esc to interrupt)\033[0m")
+		os.Stdout.Sync()
+		// The request is still the last message in history
+		result, interrupted = c.streamRequest(func(ctx context.Context) (*client.ChatResult, error) {
+			return c.client.ContinueWithToolResultsContext(ctx, true, nil)
+		})
+		fmt.Print("\r\033[K")
+	}
+	if result != nil && result.Compacted > 0 {
+		fmt.Printf("\r\033[K\033[33m[Compacted %d earlier messages to fit the context]\033[0m\n", result.Compacted)
+	}
+	if !interrupted && result != nil && len(result.Alternatives) > 1 {
+		result = c.chooseAlternative(result)
+	}
+	return result, interrupted
+}
+
+// offerCompaction asks whether to compact the conversation after the server
+// rejected it as too long for the model's context, and compacts it if so.
+// With auto_compact the client has already tried.
+func (c *Chat) offerCompaction() bool {
+	if !client.IsContextLengthError(c.lastErr) || c.cfg.AutoCompact {
+		return false
+	}
+	fmt.Print("\r\033[K")
+	fmt.Printf("\033[33m%v\033[0m\n", c.lastErr)
+	if !c.confirm("Compact the conversation and retry?") {
+		return false
+	}
+	return c.compactConversation()
+}
+
+// compactConversation summarizes older messages with the model and reports
+// the result
+func (c *Chat) compactConversation() bool {
+	fmt.Print("\033[90mCompacting conversation...\033[0m")
+	os.Stdout.Sync()
+	removed, err := c.client.Compact(context.Background())
+	fmt.Print("\r\033[K")
+	if err != nil {
+		fmt.Printf("\033[31m✗ %v\033[0m\n", err)
+		return false
+	}
+	fmt.Printf("\033[32m✓ Replaced %d earlier messages with a summary\033[0m\n", removed)
+	c.recorder.RecordUser(fmt.Sprintf("[Compacted %d messages]", removed))
+	return true
+}
+
+// contextBarWidth is the number of cells in the /context bar
+const contextBarWidth = 30
+
+// maxContextOldest caps the oldest messages /context lists
+const maxContextOldest = 5
+
+// showContext prints the estimated size of the next request against the
+// configured context window, and the oldest messages
+func (c *Chat) showContext() {
+	usage := c.client.ContextUsage()
+	window := c.cfg.GetContextWindow()
+	total := usage.Total()
+	conversation := total - usage.SystemTokens - usage.ToolTokens
+
+	fmt.Printf("Context: ~%d of %d tokens (estimated)\n", total, window)
+	color := "\033[32m"
+	switch pct := total * 100 / window; {
+	case pct >= 90:
+		color = "\033[31m"
+	case pct >= 70:
+		color = "\033[33m"
+	}
+	fmt.Printf("%s%s\033[0m\n", color, contextBar(total, window))
+	fmt.Printf("  System prompt     ~%d tokens\n", usage.SystemTokens)
+	if usage.ToolTokens > 0 {
+		fmt.Printf("  Tool definitions  ~%d tokens\n", usage.ToolTokens)
+	}
+	fmt.Printf("  Conversation      ~%d tokens in %d messages\n", conversation, len(usage.Messages))
+
+	if usage.Compactable == 0 {
+		fmt.Println("\033[90mThe conversation is short; /compact has nothing to replace yet.\033[0m")
+		return
+	}
+	compactable := 0
+	for _, m := range usage.Messages[:usage.Compactable] {
+		compactable += m.Tokens
+	}
+	fmt.Printf("Oldest messages (/compact would replace %d, ~%d tokens):\n", usage.Compactable, compactable)
+	for i, m := range usage.Messages[:min(usage.Compactable, maxContextOldest)] {
+		fmt.Printf("  %2d. %-9s ~%-6d %s\n", i+1, m.Role, m.Tokens, truncate(m.Preview, 60))
+	}
+	if usage.Compactable > maxContextOldest {
+		fmt.Printf("\033[90m  ... and %d more\033[0m\n", usage.Compactable-maxContextOldest)
+	}
+}
+
+// contextBar renders used against window as "[#####.....]  50%"; the
+// percentage can pass 100 when the conversation no longer fits
+func contextBar(used, window int) string {
+	if window <= 0 {
+		return ""
+	}
+	filled := min(used*contextBarWidth/window, contextBarWidth)
+	return fmt.Sprintf("[%s%s] %3d%%", strings.Repeat("#", filled), strings.Repeat(".", contextBarWidth-filled), used*100/window)
+}
+
+// printRequestError reports a model request that returned no response
+func (c *Chat) printRequestError() {
+	if c.lastErr != nil {
+		fmt.Printf("\033[31mError: %v\033[0m\n", c.lastErr)
+		return
+	}
+	fmt.Printf("\033[31mError: failed to get response\033[0m\n")
+}
+
+// chooseAlternative shows each alternative response and lets the user pick
+// which one becomes the assistant turn. Non-interactive mode keeps the first.
+func (c *Chat) chooseAlternative(result *client.ChatResult) *client.ChatResult {
+	if c.rl == nil {
+		return result
+	}
+
+	fmt.Print("\r\033[K")
+	for i, alt := range result.Alternatives {
+		fmt.Printf("\033[36m── Choice %d ──\033[0m\n", i+1)
+		if alt.Content != "" {
+			fmt.Println(truncate(alt.Content, 800))
+		}
+		for _, tc := range alt.ToolCalls {
+			fmt.Printf("\033[33m[Tool: %s]\033[0m %s\n", tc.Function.Name, truncate(tc.Function.Arguments, 120))
+		}
+	}
+	fmt.Printf("\033[33mPick a response [1-%d] (Enter = 1): \033[0m", len(result.Alternatives))
+	os.Stdout.Sync()
+
+	line, err := c.rl.Readline()
+	if err != nil {
+		return result
+	}
+	n := alternativeChoice(line, len(result.Alternatives))
+	if n == 1 {
+		return result
+	}
+
+	alt := result.Alternatives[n-1]
+	c.client.SelectAlternative(alt)
+	return &alt
+}
+
+// alternativeChoice parses the 1-based pick among count alternatives,
+// falling back to the first for empty or invalid input
+func alternativeChoice(line string, count int) int {
+	var n int
+	if _, err := fmt.Sscanf(strings.TrimSpace(line), "%d", &n); err != nil || n < 1 || n > count {
+		return 1
+	}
+	return n
+}
+
+// handleSignals installs a SIGINT/SIGTERM handler that shuts down cleanly.
+// Ctrl-C at the readline prompt is handled by readline and never gets here.
+// Callers defer the returned stop, which unregisters the handler again.
+func (c *Chat) handleSignals() (stop func()) {
+	if c.stopSignals != nil {
+		return func() {} // Already registered by an outer Run
+	}
+	sigCh := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case <-sigCh:
+			c.shutdown()
+		case <-done:
+		}
+	}()
+	c.stopSignals = func() {
+		signal.Stop(sigCh)
+		close(done)
+		c.stopSignals = nil
+	}
+	return c.stopSignals
+}
+
+// shutdown cancels any in-flight request or command, flushes the session
+// and exits. The todo, changelog and history files are saved as they
+// change; saving them again here could overwrite a concurrent run's update.
+// It runs on the signal goroutine: the recorder's lock makes Flush wait for
+// an entry the main loop is writing.
+func (c *Chat) shutdown() {
+	c.setActiveCancel(nil, true)
+	if c.keyListener != nil {
+		c.keyListener.Stop()
+	}
+	c.recorder.Flush()
+	c.closeReadline()
+	c.notifyCompletion("interrupted")
+	fmt.Println("\n\033[33mInterrupted - state saved.\033[0m")
+	c.exit(130)
+}
+
+// completionSummary is the payload sent by notifyCompletion
+type completionSummary struct {
+	Event     string    `json:"event"`
+	Status    string    `json:"status"` // completed, exited or interrupted
+	Project   string    `json:"project"`
+	Model     string    `json:"model"`
+	Summary   string    `json:"summary"`
+	Session   string    `json:"session"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// notifyCompletion runs the configured notify command and/or posts to the
+// notify webhook with a summary of the finished session. Failures are
+// reported but never affect the session.
+func (c *Chat) notifyCompletion(status string) {
+	if c.cfg.NotifyCommand == "" && c.cfg.NotifyWebhook == "" {
+		return
+	}
+
+	summary := completionSummary{
+		Event:     "session_complete",
+		Status:    status,
+		Project:   c.exec.WorkDir(),
+		Model:     c.cfg.Model,
+		Summary:   truncate(strings.TrimSpace(c.recorder.LastAssistant()), 500),
+		Session:   c.recorder.SessionPath(),
+		Timestamp: time.Now(),
+	}
+	payload, err := json.Marshal(summary)
+	if err != nil {
+		return
+	}
+
+	if c.cfg.NotifyCommand != "" {
+		cmd := exec.Command("sh", "-c", c.cfg.NotifyCommand)
+		cmd.Dir = c.exec.WorkDir()
+		cmd.Stdin = bytes.NewReader(payload)
+		cmd.Env = append(os.Environ(),
+			"AICLI_STATUS="+summary.Status,
+			"AICLI_PROJECT="+summary.Project,
+			"AICLI_SUMMARY="+summary.Summary)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: notify command failed: %v %s\n", err, strings.TrimSpace(string(out)))
+		}
+	}
+
+	if c.cfg.NotifyWebhook != "" {
+		httpClient := &http.Client{Timeout: 10 * time.Second}
+		resp, err := httpClient.Post(c.cfg.NotifyWebhook, "application/json", bytes.NewReader(payload))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: notify webhook failed: %v\n", err)
+			return
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			fmt.Fprintf(os.Stderr, "Warning: notify webhook returned %s\n", resp.Status)
+		}
+	}
+}
+
+// setActiveCancel records the cancel func of the in-flight request or
+// command. With cancelOld set, the previous one is cancelled first.
+func (c *Chat) setActiveCancel(cancel context.CancelFunc, cancelOld bool) {
+	c.activeMu.Lock()
+	defer c.activeMu.Unlock()
+	if cancelOld && c.activeCancel != nil {
+		c.activeCancel()
+	}
+	c.activeCancel = cancel
+}
+
+// streamRequest runs a request with escape key detection
+func (c *Chat) streamRequest(sendFunc func(context.Context) (*client.ChatResult, error)) (*client.ChatResult, bool) {
 	ctx, cancel := context.WithCancel(context.Background())
+	c.setActiveCancel(cancel, false)
 	defer func() {
+		c.setActiveCancel(nil, false)
 		cancel()
 		if c.keyListener != nil {
 			c.keyListener.Stop()
@@ -809,13 +2488,16 @@ func (c *Chat) streamWithInterrupt(sendFunc func(context.Context) (*client.ChatR
 	}()
 
 	// Start key listener for raw terminal input
+	c.lastErr = nil
 	if c.keyListener == nil {
-		result, _ := sendFunc(ctx)
+		result, err := sendFunc(ctx)
+		c.lastErr = err
 		return result, false
 	}
 	if err := c.keyListener.Start(); err != nil {
 		// Fall back to non-interruptible streaming
-		result, _ := sendFunc(ctx)
+		result, err := sendFunc(ctx)
+		c.lastErr = err
 		return result, false
 	}
 
@@ -846,11 +2528,19 @@ func (c *Chat) streamWithInterrupt(sendFunc func(context.Context) (*client.ChatR
 				fmt.Print("\r\033[K\033[33m[Interrupted]\033[0m\n")
 				return res.result, true
 			}
+			if event.Key == keylistener.KeyCtrlC {
+				// The listener's raw mode turns Ctrl-C into a key press
+				// instead of SIGINT, so shut down the same way here
+				cancel()
+				<-resultCh
+				c.shutdown()
+			}
 
 		case res := <-resultCh:
 			// Streaming completed normally
 			c.followUpInput = c.keyListener.GetBufferedInput()
 			if res.err != nil {
+				c.lastErr = res.err
 				return nil, false
 			}
 			return res.result, false
@@ -861,7 +2551,9 @@ func (c *Chat) streamWithInterrupt(sendFunc func(context.Context) (*client.ChatR
 // execWithInterrupt runs a command with escape key interruption support
 func (c *Chat) execWithInterrupt(command string) *executor.Result {
 	ctx, cancel := context.WithCancel(context.Background())
+	c.setActiveCancel(cancel, false)
 	defer func() {
+		c.setActiveCancel(nil, false)
 		cancel()
 		if c.keyListener != nil {
 			c.keyListener.Stop()
@@ -899,6 +2591,21 @@ func (c *Chat) execWithInterrupt(command string) *executor.Result {
 }
 
 func (c *Chat) sendMessage(msg string) {
+	// A new user message may follow a manual fix, so failing commands get
+	// a fresh budget
+	c.cmdFailures = nil
+
+	if c.cfg.RecentFiles > 0 && c.client.IsNewConversation() {
+		msg = formatRecentFiles(c.gatherRecentFiles(c.cfg.RecentFiles)) + msg
+	}
+
+	// Blocking error-fix todos left over from earlier turns must be done first;
+	// they are completed (and the prefix dropped) once their command succeeds
+	if prefix := c.getTodoPrompt(); prefix != "" {
+		fmt.Printf("\033[33m[Blocking todo: %s]\033[0m\n", c.todoFile.GetBlocking()[0].Content)
+		msg = prefix + msg
+	}
+
 	tokenCount := 0
 	fmt.Print("\033[90mThinking... (Esc to interrupt)\033[0m")
 	os.Stdout.Sync()
@@ -916,7 +2623,7 @@ func (c *Chat) sendMessage(msg string) {
 	os.Stdout.Sync()
 
 	if result == nil {
-		fmt.Printf("\033[31mError: failed to get response\033[0m\n")
+		c.printRequestError()
 		return
 	}
 
@@ -931,7 +2638,7 @@ func (c *Chat) sendMessage(msg string) {
 	}
 
 	// Parse text-based tool calls from content
-	textToolCalls, cleanedContent := client.ParseToolCallsFromText(result.Content)
+	textToolCalls, cleanedContent := c.parseTextToolCalls(result.Content)
 	if len(textToolCalls) > 0 {
 		result.ToolCalls = append(result.ToolCalls, textToolCalls...)
 		result.Content = cleanedContent
@@ -948,6 +2655,8 @@ func (c *Chat) sendMessage(msg string) {
 		fmt.Println()
 	}
 
+	c.checkToolSupport(result)
+
 	// Auto-continue: if model narrated an action but didn't call a tool, nudge it
 	if len(result.ToolCalls) == 0 && shouldAutoContinue(result.Content) {
 		fmt.Printf("\033[33m[Auto-continue: model described action without executing]\033[0m\n")
@@ -965,7 +2674,7 @@ func (c *Chat) sendMessage(msg string) {
 		})
 		fmt.Print("\r\033[K")
 		if result == nil {
-			fmt.Printf("\033[31mError: failed to get response\033[0m\n")
+			c.printRequestError()
 			return
 		}
 		if interrupted {
@@ -978,7 +2687,7 @@ func (c *Chat) sendMessage(msg string) {
 		}
 
 		// Parse text-based tool calls from continuation
-		textToolCalls, cleanedContent = client.ParseToolCallsFromText(result.Content)
+		textToolCalls, cleanedContent = c.parseTextToolCalls(result.Content)
 		if len(textToolCalls) > 0 {
 			result.ToolCalls = append(result.ToolCalls, textToolCalls...)
 			result.Content = cleanedContent
@@ -991,13 +2700,23 @@ func (c *Chat) sendMessage(msg string) {
 		fmt.Println()
 	}
 
+	steps := 0
 	for len(result.ToolCalls) > 0 {
+		if c.maxSteps > 0 && steps >= c.maxSteps {
+			fmt.Printf("\033[33m[Stopped after %d tool steps (--max-steps); %d tool call(s) not run]\033[0m\n", steps, len(result.ToolCalls))
+			logging.Warn("step limit reached", "steps", steps, "pending", len(result.ToolCalls))
+			c.stats.StepLimit = c.maxSteps
+			break
+		}
+		steps++
 		commandFailed := false
 		var failedToolResult string
+		c.confirmBatch(result.ToolCalls)
 		for _, tc := range result.ToolCalls {
 			c.recorder.RecordToolCall(tc.Function.Name, tc.Function.Arguments)
+			start := time.Now()
 			toolResult := c.executeTool(tc)
-			c.recorder.RecordToolResult(tc.Function.Name, toolResult)
+			c.recordToolResult(tc, toolResult, time.Since(start))
 
 			// Check if tool result contains an image (for vision models)
 			if strings.HasPrefix(toolResult, executor.ImagePrefix) {
@@ -1018,6 +2737,7 @@ func (c *Chat) sendMessage(msg string) {
 				break
 			}
 		}
+		c.batchDecision = batchNone
 		// If command failed, optionally inject user message to interrupt and force attention
 		// Smarter models (qwen2.5:72b) don't need this; they follow the TODO in tool result
 		if commandFailed {
@@ -1026,7 +2746,7 @@ func (c *Chat) sendMessage(msg string) {
 			if c.cfg.UserInterrupts {
 				// Build user interrupt message with the first todo
 				interruptMsg := "STOP. The command failed. "
-				pending := c.todoFile.GetPending()
+				pending := c.todoFile.GetBlocking()
 				if len(pending) > 0 {
 					interruptMsg += fmt.Sprintf("You MUST run this command now: %s", pending[0].Content)
 				} else {
@@ -1050,7 +2770,7 @@ func (c *Chat) sendMessage(msg string) {
 		})
 		fmt.Print("\r\033[K")
 		if result == nil {
-			fmt.Printf("\033[31mError: failed to get response\033[0m\n")
+			c.printRequestError()
 			return
 		}
 		if interrupted {
@@ -1063,7 +2783,7 @@ func (c *Chat) sendMessage(msg string) {
 		}
 
 		// Parse text-based tool calls from continuation
-		textToolCalls, cleanedContent = client.ParseToolCallsFromText(result.Content)
+		textToolCalls, cleanedContent = c.parseTextToolCalls(result.Content)
 		if len(textToolCalls) > 0 {
 			result.ToolCalls = append(result.ToolCalls, textToolCalls...)
 			result.Content = cleanedContent
@@ -1076,22 +2796,131 @@ func (c *Chat) sendMessage(msg string) {
 		fmt.Println()
 	}
 
+	if note := truncationNote(result, c.cfg.GetMaxTokens()); note != "" {
+		fmt.Printf("\033[33m%s\033[0m\n", note)
+	}
 	fmt.Println()
 }
 
+// truncationNote returns a note for a response that was cut off by the
+// token limit (finish_reason "length"), or "" if it finished normally
+func truncationNote(result *client.ChatResult, maxTokens int) string {
+	if result == nil || result.FinishReason != "length" {
+		return ""
+	}
+	limit := "the response token limit"
+	if maxTokens > 0 {
+		limit = fmt.Sprintf("the %d token limit", maxTokens)
+	}
+	return fmt.Sprintf("[truncated] The response hit %s. Type \"continue\" to have the model pick up where it stopped.", limit)
+}
+
+// lineRange describes a start/end line pair for the tool progress messages;
+// an end of 0 means through the end of the file
+func lineRange(start, end int) string {
+	if end <= 0 {
+		return fmt.Sprintf("from line %d", max(start, 1))
+	}
+	return fmt.Sprintf("lines %d-%d", max(start, 1), end)
+}
+
 func (c *Chat) executeTool(tc tools.ToolCall) string {
 	name := tc.Function.Name
 	args := tc.Function.Arguments
 
 	fmt.Printf("\n\033[33m[Tool: %s]\033[0m\n", name)
 
+	// Reject calls missing required fields so the model can correct itself
+	if err := tools.ValidateArgs(name, args); err != nil {
+		fmt.Printf("\033[31m✗ Invalid arguments: %v\033[0m\n", err)
+		return fmt.Sprintf("INVALID ARGUMENTS for %s: %v. The tool was NOT run. Call %s again with all required fields.", name, err, name)
+	}
+
+	// Repaired arguments are fine for lookups, but a cut-off file body or
+	// command must not be acted on
+	if tc.Repaired && !tools.IsReadOnly(name) {
+		fmt.Printf("\033[31m✗ Arguments were truncated - not running %s\033[0m\n", name)
+		return fmt.Sprintf("ARGUMENTS TRUNCATED for %s: the response was cut off mid tool call (received: %s). The tool was NOT run. Call it again; for large files write them in smaller parts.", name, truncate(args, 300))
+	}
+
 	switch name {
+	case "lint":
+		return c.runLint(true)
+
+	case "project_command":
+		var a tools.ProjectCommandArgs
+		if msg := parseToolArgs(args, &a); msg != "" {
+			return msg
+		}
+		command, source, err := c.projectCommand(a.Action)
+		if err != nil {
+			return fmt.Sprintf("OPERATION FAILED: %v. Use run_command with the right command instead.", err)
+		}
+		fmt.Printf("\033[90m%s: %s (%s)\033[0m\n", a.Action, command, source)
+
+		// Run it as a regular command so confirmation and error tracking apply
+		run := tc
+		run.Function.Name = "run_command"
+		runArgs, _ := json.Marshal(tools.RunCommandArgs{Command: command})
+		run.Function.Arguments = string(runArgs)
+		return c.executeTool(run)
+
+	case "run_command_tracked":
+		var a tools.RunCommandArgs
+		if msg := parseToolArgs(args, &a); msg != "" {
+			return msg
+		}
+		before, trackErr := c.exec.FileStates()
+
+		// Run it as a regular command so confirmation and error tracking apply
+		run := tc
+		run.Function.Name = "run_command"
+		output := c.executeTool(run)
+		if strings.HasPrefix(output, declinedPrefix) || strings.HasPrefix(output, "NOT RUN:") {
+			return output
+		}
+		if trackErr != nil {
+			return fmt.Sprintf("%s\n\nChanged files could not be tracked: %v", output, trackErr)
+		}
+		after, err := c.exec.FileStates()
+		if err != nil {
+			return fmt.Sprintf("%s\n\nChanged files could not be tracked: %v", output, err)
+		}
+
+		changes := executor.ChangedFileStates(before, after)
+		if len(changes) == 0 {
+			fmt.Println("\033[90mNo files changed\033[0m")
+			return output + "\n\nFiles changed by the command: none"
+		}
+		var sb strings.Builder
+		var paths []string
+		sb.WriteString(fmt.Sprintf("\n\nFiles changed by the command (%d):\n", len(changes)))
+		for _, ch := range changes {
+			sb.WriteString(fmt.Sprintf("  %-8s %s\n", ch.Status, ch.Path))
+			paths = append(paths, ch.Path)
+		}
+
+		// Log to changelog and history like write_file does
+		desc := fmt.Sprintf("Ran `%s`", truncate(strings.TrimSpace(a.Command), 80))
+		c.changelog.AddEntry("Changed", desc, paths)
+		c.history.AddChange(desc, paths)
+		fmt.Printf("\033[90m%s\033[0m", strings.TrimPrefix(sb.String(), "\n\n"))
+		return output + strings.TrimRight(sb.String(), "\n")
+
 	case "run_command":
 		var a tools.RunCommandArgs
-		json.Unmarshal([]byte(args), &a)
+		if msg := parseToolArgs(args, &a); msg != "" {
+			return msg
+		}
 		fmt.Printf("\033[90m$ %s (Esc to interrupt)\033[0m\n", a.Command)
 
-		if !c.confirmTool("run_command", fmt.Sprintf("Execute command: %s", a.Command)) {
+		cmdKey := strings.TrimSpace(a.Command)
+		if n := c.cmdFailures[cmdKey]; n >= maxCommandFailures {
+			fmt.Printf("\033[31m✗ Not running - this command already failed %d times\033[0m\n", n)
+			return fmt.Sprintf("NOT RUN: `%s` has failed %d times in a row. Running it again will not help; a different approach is needed. Modify the code (which re-enables it) or use a different command, or explain the problem to the user and ask how to proceed.", cmdKey, n)
+		}
+
+		if !c.confirmCommand(a.Command) {
 			return "OPERATION FAILED: User declined to execute command. The command was NOT run."
 		}
 
@@ -1109,30 +2938,25 @@ func (c *Chat) executeTool(tc tools.ToolCall) string {
 			strings.Contains(stderr, "undefined"))
 
 		if result.Success() && !stderrHasError {
-			// Check if this completes a pending todo - only pop if command is in the todo
-			pendingItems := c.todoFile.GetPending()
-			if len(pendingItems) > 0 {
-				firstTodo := pendingItems[0].Content
-				// Extract the command from the todo (after "Run: " or "Then re-run: ")
-				todoCmd := firstTodo
-				if strings.HasPrefix(todoCmd, "Run: ") {
-					todoCmd = strings.TrimPrefix(todoCmd, "Run: ")
-				} else if strings.HasPrefix(todoCmd, "Then re-run: ") {
-					todoCmd = strings.TrimPrefix(todoCmd, "Then re-run: ")
-				}
-				// Only pop if the actual command matches
-				if strings.Contains(a.Command, todoCmd) || strings.Contains(todoCmd, a.Command) {
-					c.popTodo()
-				}
-			}
+			delete(c.cmdFailures, cmdKey)
+
+			// Complete the first pending todo if it was created for this exact command
+			c.todoFile.CompleteCommand(a.Command)
 
-			// If there are remaining todos, optionally inject user interrupt to continue
-			pendingItems = c.todoFile.GetPending()
+			// If there are remaining fix todos, optionally inject user interrupt to continue
+			pendingItems := c.todoFile.GetBlocking()
+			if len(pendingItems) == 0 {
+				c.fixAttempts = nil
+				c.lastNudge = ""
+			}
 			if len(pendingItems) > 0 && c.cfg.UserInterrupts {
 				nextTodo := pendingItems[0].Content
 				interruptMsg := fmt.Sprintf("Good. Now run the next command: %s", nextTodo)
-				c.client.AddUserInterrupt(interruptMsg)
-				fmt.Printf("\033[33m[User: %s]\033[0m\n", interruptMsg)
+				if interruptMsg != c.lastNudge {
+					c.lastNudge = interruptMsg
+					c.client.AddUserInterrupt(interruptMsg)
+					fmt.Printf("\033[33m[User: %s]\033[0m\n", interruptMsg)
+				}
 				return fmt.Sprintf("Command succeeded:\n%s", output)
 			}
 			return fmt.Sprintf("Command succeeded:\n%s", output)
@@ -1144,35 +2968,82 @@ func (c *Chat) executeTool(tc tools.ToolCall) string {
 		if errorSummary == "" {
 			errorSummary = extractErrorSummary(output, a.Command)
 		}
-		fixCmd, isConcrete := getFixCommand(stderr)
-		if fixCmd == "" {
-			fixCmd, isConcrete = getFixCommand(output)
+
+		// Circuit breaker: after repeated identical failures stop pushing
+		// re-run todos and require a different approach
+		if c.cmdFailures == nil {
+			c.cmdFailures = make(map[string]int)
 		}
+		c.cmdFailures[cmdKey]++
+		if n := c.cmdFailures[cmdKey]; n >= maxCommandFailures {
+			c.clearTodos()
+			fmt.Printf("\033[31m✗ Command failed %d times in a row - it won't be run again\033[0m\n", n)
+			return fmt.Sprintf(`COMMAND FAILED (exit %d)
+Error Summary: %s
 
-		// Clear old todos and set fresh ones for this error
-		// Clear any existing todos - start fresh with the current fix
-		c.clearTodos()
+This command has failed %d times; a different approach is needed.
+Do NOT run it again unchanged - it will be refused until a file is modified. Change the code or the command, or explain the problem to the user and ask how to proceed.`, result.ExitCode, errorSummary, n)
+		}
+		remoteURL := c.exec.RemoteURL()
+		fixCmd, isConcrete := getFixCommand(stderr, c.exec.WorkDir(), remoteURL)
+		if fixCmd == "" {
+			fixCmd, isConcrete = getFixCommand(output, c.exec.WorkDir(), remoteURL)
+		}
 
 		// Check if the error indicates the command itself is wrong (not just missing prereqs)
 		unfixable := isUnfixableByRerun(stderr) || isUnfixableByRerun(output)
 
+		// Build the fix todos in the order they should be done
+		var fixTodos []session.TodoItem
 		if fixCmd != "" {
-			// Build todo list in order (pushTodo prepends, so add in reverse)
-			if !unfixable {
-				c.pushTodo(fmt.Sprintf("Then re-run: %s", a.Command))
-			}
 			if isConcrete {
-				c.pushTodo(fmt.Sprintf("Run: %s", fixCmd))
+				fixTodos = append(fixTodos, session.TodoItem{Content: fmt.Sprintf("Run: %s", fixCmd), Command: fixCmd})
 			} else {
-				c.pushTodo(fmt.Sprintf("Fix: %s", fixCmd))
+				fixTodos = append(fixTodos, session.TodoItem{Content: fmt.Sprintf("Fix: %s", fixCmd)})
+			}
+			if !unfixable {
+				fixTodos = append(fixTodos, session.TodoItem{Content: fmt.Sprintf("Then re-run: %s", a.Command), Command: strings.TrimSpace(a.Command)})
 			}
 		} else if unfixable {
 			// No fix command but error is unfixable - tell model to check the command
-			c.pushTodo("Check the command - the package/version may not exist. Use 'go list -m -versions <module>@latest' to find valid versions")
+			fixTodos = append(fixTodos, session.TodoItem{Content: "Check the command - the package/version may not exist. Use 'go list -m -versions <module>@latest' to find valid versions"})
+		}
+
+		// Count repeated suggestions of the same fix; past the cap, stop
+		// suggesting it and have the model ask the user instead
+		if len(fixTodos) > 0 {
+			if c.fixAttempts == nil {
+				c.fixAttempts = make(map[string]int)
+			}
+			var contents []string
+			for _, t := range fixTodos {
+				contents = append(contents, t.Content)
+			}
+			key := strings.Join(contents, "\n")
+			c.fixAttempts[key]++
+			if c.fixAttempts[key] > maxFixSuggestions {
+				c.clearTodos()
+				fmt.Printf("\033[31m✗ Same fix suggested %d times without success - asking the user\033[0m\n", maxFixSuggestions)
+				return fmt.Sprintf(`COMMAND FAILED (exit %d)
+Error Summary: %s
+
+The suggested fix (%s) has already been tried %d times without success.
+STOP retrying. Explain the problem to the user and ask how to proceed.`, result.ExitCode, errorSummary, fixTodos[0].Content, maxFixSuggestions)
+			}
+		}
+
+		// Replace the todo stack with the fix for this error, unless the
+		// same fix is already pending (avoids thrashing on repeated failures)
+		if !c.pendingTodosEqual(fixTodos) {
+			c.clearTodos()
+			// pushTodo prepends, so add in reverse
+			for i := len(fixTodos) - 1; i >= 0; i-- {
+				c.pushTodo(fixTodos[i].Content, fixTodos[i].Command)
+			}
 		}
 
 		todoList := ""
-		todoItems := c.todoFile.GetPending()
+		todoItems := c.todoFile.GetBlocking()
 		if len(todoItems) > 0 {
 			todoList = "\n\nREQUIRED TODO STACK (do these in order):\n"
 			for i, todo := range todoItems {
@@ -1196,17 +3067,31 @@ DO NOT run other commands. Execute the first TODO item NOW.`, result.ExitCode, s
 
 	case "write_file":
 		var a tools.WriteFileArgs
-		json.Unmarshal([]byte(args), &a)
+		if msg := parseToolArgs(args, &a); msg != "" {
+			return msg
+		}
 		return c.handleWriteFile(a.Path, a.Content, "file")
 
 	case "write_doc":
 		var a tools.WriteDocArgs
-		json.Unmarshal([]byte(args), &a)
+		if msg := parseToolArgs(args, &a); msg != "" {
+			return msg
+		}
 		return c.handleWriteFile(a.Path, a.Content, "documentation")
 
 	case "read_file":
 		var a tools.ReadFileArgs
-		json.Unmarshal([]byte(args), &a)
+		if msg := parseToolArgs(args, &a); msg != "" {
+			return msg
+		}
+		if a.StartLine > 0 || a.EndLine > 0 {
+			fmt.Printf("\033[90mReading: %s (%s)\033[0m\n", a.Path, lineRange(a.StartLine, a.EndLine))
+			content, err := c.exec.ReadFileLines(a.Path, a.StartLine, a.EndLine)
+			if err != nil {
+				return fmt.Sprintf("Failed to read file: %v", err)
+			}
+			return fmt.Sprintf("Contents of %s (from line %d):\n```\n%s\n```", a.Path, max(a.StartLine, 1), content)
+		}
 		fmt.Printf("\033[90mReading: %s\033[0m\n", a.Path)
 
 		content, err := c.exec.ReadFile(a.Path)
@@ -1217,7 +3102,9 @@ DO NOT run other commands. Execute the first TODO item NOW.`, result.ExitCode, s
 
 	case "web_search":
 		var a tools.WebSearchArgs
-		json.Unmarshal([]byte(args), &a)
+		if msg := parseToolArgs(args, &a); msg != "" {
+			return msg
+		}
 		fmt.Printf("\033[90mSearching: %s\033[0m\n", a.Query)
 
 		maxResults := a.MaxResults
@@ -1225,25 +3112,47 @@ DO NOT run other commands. Execute the first TODO item NOW.`, result.ExitCode, s
 			maxResults = 5
 		}
 
-		results, err := c.web.Search(a.Query, maxResults)
+		page := a.Page
+		if page < 1 {
+			page = 1
+		}
+
+		results, err := c.web.SearchPage(a.Query, maxResults, page)
+		if errors.Is(err, web.ErrRateLimited) {
+			fmt.Printf("\033[33m⚠ Web rate limit reached\033[0m\n")
+			return "RATE LIMITED: too many web requests this minute. Do not retry now; continue with what you have or try later."
+		}
 		if err != nil {
 			return fmt.Sprintf("Search failed: %v", err)
 		}
 
+		if len(results) == 0 && page > 1 {
+			return fmt.Sprintf("No more results for '%s' (page %d).", a.Query, page)
+		}
+
+		offset := (page - 1) * maxResults
 		var sb strings.Builder
-		sb.WriteString(fmt.Sprintf("Search results for '%s':\n\n", a.Query))
+		sb.WriteString(fmt.Sprintf("Search results for '%s' (page %d):\n\n", a.Query, page))
 		for i, r := range results {
-			sb.WriteString(fmt.Sprintf("%d. %s\n   URL: %s\n   %s\n\n", i+1, r.Title, r.URL, r.Snippet))
-			fmt.Printf("%d. %s\n", i+1, r.Title)
+			sb.WriteString(fmt.Sprintf("%d. %s\n   URL: %s\n   %s\n\n", offset+i+1, r.Title, r.URL, r.Snippet))
+			fmt.Printf("%d. %s\n", offset+i+1, r.Title)
 		}
+		sb.WriteString(fmt.Sprintf("For more results, call web_search again with page %d.", page+1))
 		return sb.String()
 
 	case "fetch_url":
 		var a tools.FetchURLArgs
-		json.Unmarshal([]byte(args), &a)
+		if msg := parseToolArgs(args, &a); msg != "" {
+			return msg
+		}
 		fmt.Printf("\033[90mFetching: %s\033[0m\n", a.URL)
 
-		content, err := c.web.FetchPage(a.URL)
+		markdown := a.Format == "markdown" || (a.Format == "" && c.cfg.FetchMarkdown)
+		content, err := c.web.FetchPageAs(a.URL, markdown)
+		if errors.Is(err, web.ErrRateLimited) {
+			fmt.Printf("\033[33m⚠ Web rate limit reached\033[0m\n")
+			return "RATE LIMITED: too many web requests this minute. Do not retry now; continue with what you have or try later."
+		}
 		if err != nil {
 			return fmt.Sprintf("Fetch failed: %v", err)
 		}
@@ -1251,7 +3160,9 @@ DO NOT run other commands. Execute the first TODO item NOW.`, result.ExitCode, s
 
 	case "screenshot":
 		var a tools.ScreenshotArgs
-		json.Unmarshal([]byte(args), &a)
+		if msg := parseToolArgs(args, &a); msg != "" {
+			return msg
+		}
 		fmt.Printf("\033[90mCapturing screenshot...\033[0m\n")
 
 		if !c.confirmTool("screenshot", "Capture screenshot?") {
@@ -1269,14 +3180,18 @@ DO NOT run other commands. Execute the first TODO item NOW.`, result.ExitCode, s
 
 	case "git_diff":
 		var a tools.GitDiffArgs
-		json.Unmarshal([]byte(args), &a)
+		if msg := parseToolArgs(args, &a); msg != "" {
+			return msg
+		}
 		result := c.exec.GitDiff(a.Staged)
 		// Output already streamed by executor
 		return result.String()
 
 	case "git_add":
 		var a tools.GitAddArgs
-		json.Unmarshal([]byte(args), &a)
+		if msg := parseToolArgs(args, &a); msg != "" {
+			return msg
+		}
 		if len(a.Files) > 0 {
 			fmt.Printf("\033[90mStaging: %v\033[0m\n", a.Files)
 		} else {
@@ -1292,12 +3207,18 @@ DO NOT run other commands. Execute the first TODO item NOW.`, result.ExitCode, s
 
 	case "git_commit":
 		var a tools.GitCommitArgs
-		json.Unmarshal([]byte(args), &a)
+		if msg := parseToolArgs(args, &a); msg != "" {
+			return msg
+		}
 		bump := a.Bump
 		if bump == "" {
 			bump = "patch"
 		}
-		fmt.Printf("\033[90mMessage: %s (bump: %s)\033[0m\n", a.Message, bump)
+		if preview, err := c.exec.PreviewCommit(a.Message, bump); err == nil {
+			fmt.Printf("\033[90m%s\033[0m\n", preview)
+		} else {
+			fmt.Printf("\033[90mMessage: %s (bump: %s)\033[0m\n", a.Message, bump)
+		}
 
 		if !c.confirmTool("git_commit", fmt.Sprintf("Create commit: %s", a.Message)) {
 			return "OPERATION FAILED: User declined to commit. No commit was created."
@@ -1317,7 +3238,9 @@ DO NOT run other commands. Execute the first TODO item NOW.`, result.ExitCode, s
 
 	case "git_log":
 		var a tools.GitLogArgs
-		json.Unmarshal([]byte(args), &a)
+		if msg := parseToolArgs(args, &a); msg != "" {
+			return msg
+		}
 		count := a.Count
 		if count <= 0 {
 			count = 10
@@ -1326,13 +3249,43 @@ DO NOT run other commands. Execute the first TODO item NOW.`, result.ExitCode, s
 		// Output already streamed by executor
 		return result.String()
 
+	case "git_blame":
+		var a tools.GitBlameArgs
+		if msg := parseToolArgs(args, &a); msg != "" {
+			return msg
+		}
+		if a.StartLine > 0 || a.EndLine > 0 {
+			fmt.Printf("\033[90mBlaming: %s (%s)\033[0m\n", a.Path, lineRange(a.StartLine, a.EndLine))
+		} else {
+			fmt.Printf("\033[90mBlaming: %s\033[0m\n", a.Path)
+		}
+		blame, err := c.exec.GitBlame(a.Path, a.StartLine, a.EndLine)
+		if err != nil {
+			return fmt.Sprintf("Failed to blame file: %v", err)
+		}
+		return fmt.Sprintf("Blame of %s (line commit date author | content):\n```\n%s```", a.Path, blame)
+
 	case "list_files":
 		var a tools.ListFilesArgs
-		json.Unmarshal([]byte(args), &a)
+		if msg := parseToolArgs(args, &a); msg != "" {
+			return msg
+		}
 		result := c.exec.ListFiles(a.Pattern)
 		// Output already streamed by executor
 		return result.String()
 
+	case "tree":
+		var a tools.TreeArgs
+		if msg := parseToolArgs(args, &a); msg != "" {
+			return msg
+		}
+		out, err := c.exec.Tree(a.Path, a.Depth)
+		if err != nil {
+			return fmt.Sprintf("Error: %v", err)
+		}
+		fmt.Print(out)
+		return out
+
 	case "get_version":
 		v, err := c.exec.GetVersion()
 		if err != nil {
@@ -1341,9 +3294,37 @@ DO NOT run other commands. Execute the first TODO item NOW.`, result.ExitCode, s
 		fmt.Printf("Version: %s\n", v.String())
 		return fmt.Sprintf("Current version: %s", v.String())
 
+	case "get_context":
+		return c.contextSummary()
+
+	case "add_todo":
+		var a tools.AddTodoArgs
+		if msg := parseToolArgs(args, &a); msg != "" {
+			return msg
+		}
+		c.todoFile.AddTodo(a.Content)
+		c.history.AddTodo(a.Content, "added")
+		fmt.Printf("\033[90mAdded todo: %s\033[0m\n", a.Content)
+		return fmt.Sprintf("Todo added: %s", a.Content)
+
+	case "complete_todo":
+		var a tools.CompleteTodoArgs
+		if msg := parseToolArgs(args, &a); msg != "" {
+			return msg
+		}
+		content := c.todoFile.CompleteByContent(a.Todo)
+		if content == "" {
+			return fmt.Sprintf("No open todo matches %q. Use get_context to see pending todos.", a.Todo)
+		}
+		c.history.AddTodo(content, "completed")
+		fmt.Printf("\033[32m✓ Completed todo: %s\033[0m\n", content)
+		return fmt.Sprintf("Todo completed: %s", content)
+
 	case "set_version":
 		var a tools.SetVersionArgs
-		json.Unmarshal([]byte(args), &a)
+		if msg := parseToolArgs(args, &a); msg != "" {
+			return msg
+		}
 		fmt.Printf("\033[90mSetting version to: %s\033[0m\n", a.Version)
 
 		if !c.confirmTool("set_version", fmt.Sprintf("Set version to %s?", a.Version)) {
@@ -1361,19 +3342,82 @@ DO NOT run other commands. Execute the first TODO item NOW.`, result.ExitCode, s
 	}
 }
 
+// contextSummary describes the current plan and pending todos for the
+// get_context tool, so the model can check its own state on demand
+func (c *Chat) contextSummary() string {
+	var sb strings.Builder
+
+	if plan.Exists(c.exec.WorkDir()) {
+		if p, err := plan.Load(c.exec.WorkDir()); err == nil {
+			sb.WriteString("CURRENT PLAN\n")
+			sb.WriteString(p.Summary())
+		}
+	}
+	if sb.Len() == 0 {
+		sb.WriteString("No active plan.\n")
+	}
+
+	pending := c.todoFile.GetPending()
+	if len(pending) == 0 {
+		sb.WriteString("\nNo pending todos.")
+	} else {
+		sb.WriteString("\nPENDING TODOS\n")
+		for i, item := range pending {
+			sb.WriteString(fmt.Sprintf("%d. %s\n", i+1, item.Content))
+		}
+	}
+
+	fmt.Printf("\033[90mPlan and %d pending todo(s) provided\033[0m\n", len(pending))
+	return sb.String()
+}
+
 func (c *Chat) handleWriteFile(path, content, fileType string) string {
 	fmt.Printf("\033[90mPath: %s\033[0m\n", path)
 	fmt.Printf("\033[90mContent: %d bytes\033[0m\n", len(content))
 
-	lines := strings.Split(content, "\n")
-	if len(lines) > 10 {
-		preview := lines[:10]
-		fmt.Printf("\033[90m%s\n... (%d more lines)\033[0m\n", strings.Join(preview, "\n"), len(lines)-10)
+	// Existing files get a diff preview; new files the first lines
+	old, readErr := c.exec.ReadFileFull(path)
+	var hunks []diff.Hunk
+	if readErr == nil && !strings.HasPrefix(old, executor.ImagePrefix) {
+		hunks = diff.Hunks(old, content, 3)
+		if len(hunks) == 0 {
+			fmt.Printf("\033[90m(no changes)\033[0m\n")
+			return fmt.Sprintf("No changes: %s already has this content.", path)
+		}
+		printHunks(hunks)
 	} else {
-		fmt.Printf("\033[90m%s\033[0m\n", content)
+		lines := strings.Split(content, "\n")
+		if len(lines) > 10 {
+			preview := lines[:10]
+			fmt.Printf("\033[90m%s\n... (%d more lines)\033[0m\n", strings.Join(preview, "\n"), len(lines)-10)
+		} else {
+			fmt.Printf("\033[90m%s\033[0m\n", content)
+		}
 	}
 
-	if !c.confirmTool("write_file", fmt.Sprintf("Write %s to %s (%d bytes)?", fileType, path, len(content))) {
+	partial := ""
+	if len(hunks) > 1 && c.canAsk("write_file") {
+		accept, ok := c.selectHunks(fileType, path, hunks)
+		if !ok {
+			return fmt.Sprintf("OPERATION FAILED: User declined to write %s. The file was NOT created or modified.", fileType)
+		}
+		var taken, rejected []string
+		for i, take := range accept {
+			if take {
+				taken = append(taken, strconv.Itoa(i+1))
+			} else {
+				rejected = append(rejected, fmt.Sprintf("%d (%s)", i+1, strings.TrimPrefix(hunks[i].Header(), "@@ ")))
+			}
+		}
+		if len(taken) == 0 {
+			return fmt.Sprintf("OPERATION FAILED: User rejected every change to %s. The file was NOT modified.", path)
+		}
+		if len(rejected) > 0 {
+			content = diff.Apply(old, hunks, accept)
+			partial = fmt.Sprintf(" PARTIALLY APPLIED: the user accepted hunks %s of %d and rejected %s. The file does not contain the rejected changes; read it before editing it again.",
+				strings.Join(taken, ", "), len(hunks), strings.Join(rejected, ", "))
+		}
+	} else if !c.confirmTool("write_file", fmt.Sprintf("Write %s to %s (%d bytes)?", fileType, path, len(content))) {
 		return fmt.Sprintf("OPERATION FAILED: User declined to write %s. The file was NOT created or modified.", fileType)
 	}
 
@@ -1388,73 +3432,619 @@ func (c *Chat) handleWriteFile(path, content, fileType string) string {
 	c.changelog.AddEntry("Changed", desc, []string{path})
 	c.history.AddChange(desc, []string{path})
 
-	return fmt.Sprintf("Successfully wrote %d bytes to %s", len(content), path)
+	// The code changed, so previously failing commands may now succeed
+	c.cmdFailures = nil
+
+	formatted := ""
+	if c.cfg.FormatOnWrite {
+		if note := c.formatFile(path); note != "" {
+			formatted = " (" + note + "; read the file before editing it again)"
+		}
+	}
+
+	return fmt.Sprintf("Successfully wrote %d bytes to %s%s%s", len(content), path, formatted, partial)
+}
+
+// maxDiffPreviewLines caps the diff shown before a write
+const maxDiffPreviewLines = 80
+
+// printHunks shows a colored, numbered diff preview
+func printHunks(hunks []diff.Hunk) {
+	shown := 0
+	for i, h := range hunks {
+		if shown >= maxDiffPreviewLines {
+			fmt.Printf("\033[90m... (%d more hunks)\033[0m\n", len(hunks)-i)
+			return
+		}
+		fmt.Printf("\033[36m[%d/%d] %s\033[0m\n", i+1, len(hunks), h.Header())
+		for _, l := range strings.Split(strings.TrimSuffix(h.String(), "\n"), "\n")[1:] {
+			switch l[0] {
+			case '+':
+				fmt.Printf("\033[32m%s\033[0m\n", l)
+			case '-':
+				fmt.Printf("\033[31m%s\033[0m\n", l)
+			default:
+				fmt.Printf("\033[90m%s\033[0m\n", l)
+			}
+			shown++
+		}
+	}
+}
+
+// canAsk reports whether a confirmation for toolName would actually prompt
+// the user (interactive, not auto-approved or decided by a saved permission
+// or batch answer)
+func (c *Chat) canAsk(toolName string) bool {
+	return c.rl != nil && !c.autoExec && c.batchDecision == batchNone &&
+		c.cfg.GetToolPermission(toolName) == config.PermissionAsk
+}
+
+// selectHunks confirms a multi-hunk write: all hunks, none, or a per-hunk
+// selection. ok is false if the write was declined.
+func (c *Chat) selectHunks(fileType, path string, hunks []diff.Hunk) (accept []bool, ok bool) {
+	fmt.Println()
+	fmt.Printf("\033[33m╭─ Write %s to %s (%d changes)?\033[0m\n", fileType, path, len(hunks))
+	fmt.Printf("\033[33m│ (y)es all, (n)o, (s)elect changes one by one%s\033[0m\n", c.timeoutHint())
+	fmt.Printf("\033[33m╰─▶ \033[0m")
+	os.Stdout.Sync()
+
+	line, timedOut, err := c.readConfirmation()
+	accept = make([]bool, len(hunks))
+	if timedOut {
+		if !c.timeoutDecision() {
+			return nil, false
+		}
+		for i := range accept {
+			accept[i] = true
+		}
+		return accept, true
+	}
+	if err != nil {
+		fmt.Println("\033[31m✗ Declined (read error)\033[0m")
+		return nil, false
+	}
+
+	switch strings.ToLower(strings.TrimSpace(line)) {
+	case "y", "yes":
+		for i := range accept {
+			accept[i] = true
+		}
+		fmt.Println("\033[32m✓ Approved\033[0m")
+		return accept, true
+	case "s", "select":
+	default:
+		fmt.Println("\033[31m✗ Declined\033[0m")
+		return nil, false
+	}
+
+	for i, h := range hunks {
+		added, removed := h.Stats()
+		fmt.Printf("\033[33m[%d/%d] %s (+%d -%d) apply? (y/n)%s \033[0m", i+1, len(hunks), h.Header(), added, removed, c.timeoutHint())
+		os.Stdout.Sync()
+		line, timedOut, err := c.readConfirmation()
+		if timedOut {
+			// The timeout default decides this and the remaining changes
+			approve := c.timeoutDecision()
+			for j := i; j < len(hunks); j++ {
+				accept[j] = approve
+			}
+			break
+		}
+		if err != nil {
+			break
+		}
+		answer := strings.ToLower(strings.TrimSpace(line))
+		accept[i] = answer == "y" || answer == "yes"
+	}
+	return accept, true
+}
+
+// confirmTool asks for permission to execute a tool with options:
+// y = yes (once), n = no, a = always allow this tool
+// Returns true if the tool should be executed
+func (c *Chat) confirmTool(toolName, prompt string) bool {
+	// Read-only tools never prompt
+	if tools.IsReadOnly(toolName) {
+		return true
+	}
+
+	// Check if autoExec is enabled
+	if c.autoExec {
+		return true
+	}
+
+	// Check saved permission for this tool
+	perm := c.cfg.GetToolPermission(toolName)
+	switch perm {
+	case config.PermissionAlways:
+		fmt.Printf("\033[32m✓ Auto-approved: %s (permission: always)\033[0m\n", toolName)
+		return true
+	case config.PermissionNever:
+		fmt.Printf("\033[31m✗ Auto-denied: %s (permission: never)\033[0m\n", toolName)
+		return false
+	}
+
+	// Honor a decision already made for the whole tool batch
+	switch c.batchDecision {
+	case batchApproved:
+		fmt.Printf("\033[32m✓ Approved: %s (batch)\033[0m\n", toolName)
+		return true
+	case batchDenied:
+		fmt.Printf("\033[31m✗ Declined: %s (batch)\033[0m\n", toolName)
+		return false
+	}
+
+	// In non-interactive mode, apply the configured default
+	if c.rl == nil {
+		return c.confirmNonInteractive(toolName, prompt)
+	}
+
+	// Show the prompt with options
+	fmt.Println() // Ensure we're on a new line
+	fmt.Printf("\033[33m╭─ %s\033[0m\n", prompt)
+	fmt.Printf("\033[33m│ (y)es once, (n)o, (a)lways allow %s, (!) never allow%s\033[0m\n", toolName, c.timeoutHint())
+	fmt.Printf("\033[33m╰─▶ \033[0m")
+	os.Stdout.Sync() // Flush output before reading
+
+	line, timedOut, err := c.readConfirmation()
+	if timedOut {
+		return c.timeoutDecision()
+	}
+	if err != nil {
+		fmt.Println("\033[31m✗ Declined (read error)\033[0m")
+		return false
+	}
+
+	line = strings.ToLower(strings.TrimSpace(line))
+
+	switch line {
+	case "y", "yes":
+		fmt.Println("\033[32m✓ Approved\033[0m")
+		return true
+	case "a", "always":
+		fmt.Printf("\033[32m✓ Approved (saving 'always' for %s)\033[0m\n", toolName)
+		c.cfg.SetToolPermission(toolName, config.PermissionAlways)
+		if err := c.cfg.Save(); err != nil {
+			fmt.Printf("\033[33mWarning: could not save config: %v\033[0m\n", err)
+		}
+		return true
+	case "!", "never":
+		fmt.Printf("\033[31m✗ Denied (saving 'never' for %s)\033[0m\n", toolName)
+		c.cfg.SetToolPermission(toolName, config.PermissionNever)
+		if err := c.cfg.Save(); err != nil {
+			fmt.Printf("\033[33mWarning: could not save config: %v\033[0m\n", err)
+		}
+		return false
+	default:
+		fmt.Println("\033[31m✗ Declined\033[0m")
+		return false
+	}
+}
+
+// readConfirmation reads the answer to a confirmation prompt, giving up
+// after confirm_timeout seconds (if set). On timeout the pending Readline is
+// unblocked with an empty line so it can't swallow the next input.
+func (c *Chat) readConfirmation() (line string, timedOut bool, err error) {
+	timeout := time.Duration(c.cfg.ConfirmTimeout) * time.Second
+	if timeout <= 0 {
+		line, err = c.rl.Readline()
+		return line, false, err
+	}
+
+	type answer struct {
+		line string
+		err  error
+	}
+	answers := make(chan answer, 1)
+	go func() {
+		line, err := c.rl.Readline()
+		answers <- answer{line, err}
+	}()
+
+	select {
+	case a := <-answers:
+		return a.line, false, a.err
+	case <-time.After(timeout):
+	}
+
+	if c.stdin == nil {
+		// Readline reads the terminal itself: the best we can do is feed
+		// it a line and hope it returns
+		c.rl.WriteStdin([]byte("\n"))
+		select {
+		case <-answers:
+		case <-time.After(time.Second):
+		}
+	} else {
+		// Stop the read and wait for it, so no reader is left behind to
+		// take the user's next line
+		c.stdin.interrupt()
+		<-answers
+		c.stdin.drain()
+	}
+	fmt.Println()
+	return "", true, nil
+}
+
+// timeoutHint describes the confirmation timeout for prompts, or "" if
+// there is none
+func (c *Chat) timeoutHint() string {
+	if c.cfg.ConfirmTimeout <= 0 {
+		return ""
+	}
+	action := "declines"
+	if c.cfg.GetConfirmDefault() == config.ConfirmApprove {
+		action = "approves"
+	}
+	return fmt.Sprintf(" - auto-%s in %ds", action, c.cfg.ConfirmTimeout)
+}
+
+// timeoutDecision applies confirm_default to an unanswered confirmation:
+// "approve" approves, anything else declines
+func (c *Chat) timeoutDecision() bool {
+	if c.cfg.GetConfirmDefault() == config.ConfirmApprove {
+		fmt.Printf("\033[32m✓ Approved (no answer in %ds)\033[0m\n", c.cfg.ConfirmTimeout)
+		return true
+	}
+	fmt.Printf("\033[31m✗ Declined (no answer in %ds)\033[0m\n", c.cfg.ConfirmTimeout)
+	return false
+}
+
+// handleDebugCommand shows the latest debug log or toggles debug logging
+func (c *Chat) handleDebugCommand(args []string) {
+	if len(args) == 0 {
+		state := "off"
+		if c.client.DebugEnabled() {
+			state = "on"
+		}
+		fmt.Printf("Debug logging: %s\n", state)
+		fmt.Println("Usage: /debug last     - show the most recent request/response log")
+		fmt.Println("       /debug on|off   - toggle debug logging")
+		return
+	}
+
+	switch args[0] {
+	case "last":
+		path, err := c.client.LatestDebugFile()
+		if err != nil {
+			fmt.Printf("\033[31m%v\033[0m\n", err)
+			return
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Printf("\033[31mError reading %s: %v\033[0m\n", path, err)
+			return
+		}
+		fmt.Printf("\033[90m%s\033[0m\n", path)
+		fmt.Println(string(data))
+
+	case "on":
+		c.client.SetDebugDir(c.exec.WorkDir())
+		fmt.Println("Debug logging enabled (.aicli/debug/)")
+
+	case "off":
+		c.client.DisableDebug()
+		fmt.Println("Debug logging disabled")
+
+	default:
+		fmt.Println("Unknown subcommand. Use: /debug [last|on|off]")
+	}
+}
+
+// pullModel downloads a model through Ollama with a progress line, then
+// shows the refreshed model list
+func (c *Chat) pullModel(name string) {
+	if !c.cfg.IsOllamaEndpoint() {
+		fmt.Println("\033[31m✗ /pull is only supported for Ollama endpoints\033[0m")
+		return
+	}
+
+	fmt.Printf("Pulling %s...\n", name)
+	lastStatus := ""
+	err := c.client.PullModel(name, func(p client.PullProgress) {
+		if p.Total > 0 {
+			pct := float64(p.Completed) * 100 / float64(p.Total)
+			fmt.Printf("\r\033[K\033[90m%s %5.1f%% (%d/%d MB)\033[0m",
+				p.Status, pct, p.Completed>>20, p.Total>>20)
+		} else if p.Status != lastStatus {
+			if lastStatus != "" {
+				fmt.Println()
+			}
+			fmt.Printf("\r\033[K\033[90m%s\033[0m", p.Status)
+		}
+		lastStatus = p.Status
+		os.Stdout.Sync()
+	})
+	fmt.Println()
+	if err != nil {
+		fmt.Printf("\033[31m✗ %v\033[0m\n", err)
+		return
+	}
+	fmt.Printf("\033[32m✓ Pulled %s\033[0m\n", name)
+
+	models, err := c.client.ListModels()
+	if err != nil {
+		fmt.Printf("Error fetching models: %v\n", err)
+		return
+	}
+	fmt.Println("Available models:")
+	for _, m := range models {
+		fmt.Printf("    %s\n", m)
+	}
+}
+
+// parseToolArgs decodes a tool call's JSON arguments into v. On failure it
+// returns a message for the model quoting the error and the raw arguments,
+// so it can correct itself instead of the tool running with zero values.
+func parseToolArgs(args string, v interface{}) string {
+	if strings.TrimSpace(args) == "" {
+		args = "{}"
+	}
+	if err := json.Unmarshal([]byte(args), v); err != nil {
+		fmt.Printf("\033[31m✗ Could not parse arguments: %v\033[0m\n", err)
+		hint := ""
+		if strings.Contains(err.Error(), "unexpected end of JSON input") {
+			hint = " The arguments were cut off (the response was truncated); send shorter arguments, e.g. write large files in parts."
+		}
+		return fmt.Sprintf("could not parse arguments: %v; received: %s\nThe tool was NOT run.%s Call it again with valid JSON arguments.", err, truncate(args, 500), hint)
+	}
+	return ""
+}
+
+// confirmBatch shows a summary of a multi-tool response and lets the user
+// approve or deny it as a unit. Choosing (p)er-tool falls back to the
+// normal per-tool prompts. Only active when batch_confirm is enabled.
+func (c *Chat) confirmBatch(calls []tools.ToolCall) {
+	c.batchDecision = batchNone
+	if !c.cfg.BatchConfirm || c.autoExec || c.rl == nil || len(calls) < 2 {
+		return
+	}
+
+	summary, needsConfirm := summarizeToolBatch(calls)
+	if !needsConfirm {
+		return // Read-only batch - nothing to approve
+	}
+
+	fmt.Println()
+	fmt.Printf("\033[33m╭─ About to: %s\033[0m\n", summary)
+	fmt.Printf("\033[33m│ (y)es to all, (n)o to all, (p)er-tool review%s\033[0m\n", c.timeoutHint())
+	fmt.Printf("\033[33m╰─▶ \033[0m")
+	os.Stdout.Sync()
+
+	line, timedOut, err := c.readConfirmation()
+	if timedOut {
+		// Apply the timeout default to the whole batch rather than timing
+		// out on every tool in turn
+		if c.timeoutDecision() {
+			c.batchDecision = batchApproved
+		} else {
+			c.batchDecision = batchDenied
+		}
+		return
+	}
+	if err != nil {
+		return // Fall back to per-tool prompts
+	}
+
+	switch strings.ToLower(strings.TrimSpace(line)) {
+	case "y", "yes":
+		c.batchDecision = batchApproved
+	case "n", "no":
+		c.batchDecision = batchDenied
+	}
 }
 
-// confirmTool asks for permission to execute a tool with options:
-// y = yes (once), n = no, a = always allow this tool
-// Returns true if the tool should be executed
-func (c *Chat) confirmTool(toolName, prompt string) bool {
-	// Check if autoExec is enabled
-	if c.autoExec {
+// confirmNonInteractive answers a confirmation when there is no terminal,
+// following the confirm_default policy (decline unless configured)
+func (c *Chat) confirmNonInteractive(toolName, prompt string) bool {
+	fmt.Printf("\033[33m%s\033[0m\n", prompt)
+	switch c.cfg.GetConfirmDefault() {
+	case config.ConfirmApprove:
+		fmt.Println("\033[32m✓ Approved (confirm_default: approve)\033[0m")
 		return true
+	case config.ConfirmApproveReadOnly:
+		fmt.Println("\033[31m✗ Declined (not read-only; confirm_default: approve-read-only)\033[0m")
+		return false
 	}
+	fmt.Println("\033[31m✗ Declined (non-interactive mode, use -auto or --confirm-default)\033[0m")
+	return false
+}
 
-	// Check saved permission for this tool
-	perm := c.cfg.GetToolPermission(toolName)
-	switch perm {
-	case config.PermissionAlways:
-		fmt.Printf("\033[32m✓ Auto-approved: %s (permission: always)\033[0m\n", toolName)
+// confirmCommand confirms a run_command. Without a terminal, the
+// approve-read-only default lets inspection commands through; everything
+// else goes through confirmTool.
+func (c *Chat) confirmCommand(command string) bool {
+	if c.rl == nil && !c.autoExec &&
+		c.cfg.GetConfirmDefault() == config.ConfirmApproveReadOnly &&
+		c.cfg.GetToolPermission("run_command") != config.PermissionNever &&
+		readOnlyCommand(command) {
+		fmt.Println("\033[32m✓ Approved (read-only command)\033[0m")
 		return true
-	case config.PermissionNever:
-		fmt.Printf("\033[31m✗ Auto-denied: %s (permission: never)\033[0m\n", toolName)
-		return false
 	}
+	return c.confirmTool("run_command", fmt.Sprintf("Execute command: %s", command))
+}
 
-	// In non-interactive mode, decline
-	if c.rl == nil {
-		fmt.Printf("\033[33m%s\033[0m\n", prompt)
-		fmt.Println("\033[31m✗ Declined (non-interactive mode, use -auto flag)\033[0m")
+// readOnlySpec is what a read-only command may be given. Anything else,
+// such as git branch -D or go env -w, makes the command ask for confirmation.
+type readOnlySpec struct {
+	short  string   // Single-letter flags, alone or combined ("-la")
+	long   []string // Whole flags ("--stat", "-json"), alone or with "=value"
+	noArgs bool     // Only flags: an argument would make it change something
+}
+
+// digits lets numeric flags such as head -20 or git log -5 through
+const digits = "0123456789"
+
+// readOnlyCommands are the commands (or command + subcommand) that only
+// inspect, with the flags that keep them that way. Flags that run other
+// programs (rg --pre, go vet -vettool, git diff --ext-diff) or write files
+// (tree -o, git diff --output) are left out.
+var readOnlyCommands = map[string]readOnlySpec{
+	"ls":   {short: "alhRtrS1dFAsuci", long: []string{"--all", "--almost-all", "--human-readable", "--recursive", "--reverse", "--directory", "--classify", "--size", "--color", "--sort"}},
+	"cat":  {short: "nbAEsTv", long: []string{"--number", "--show-all"}},
+	"head": {short: "ncqv" + digits, long: []string{"--lines", "--bytes", "--quiet"}},
+	"tail": {short: "ncqv" + digits, long: []string{"--lines", "--bytes", "--quiet"}},
+	"wc":   {short: "lwcmL", long: []string{"--lines", "--words", "--bytes", "--chars", "--max-line-length"}},
+	"grep": {short: "rRinlLcvwxEFPHhoqsabefmABC" + digits, long: []string{
+		"--recursive", "--ignore-case", "--line-number", "--files-with-matches", "--files-without-match",
+		"--count", "--invert-match", "--word-regexp", "--line-regexp", "--extended-regexp", "--fixed-strings",
+		"--perl-regexp", "--regexp", "--file", "--max-count", "--include", "--exclude", "--exclude-dir",
+		"--context", "--after-context", "--before-context", "--color", "--only-matching", "--no-filename", "--with-filename"}},
+	"rg": {short: "inlcvwxFUSsHNLoeguABCm" + digits, long: []string{
+		"--ignore-case", "--smart-case", "--case-sensitive", "--line-number", "--no-line-number", "--files",
+		"--files-with-matches", "--files-without-match", "--count", "--invert-match", "--word-regexp",
+		"--line-regexp", "--fixed-strings", "--regexp", "--glob", "--iglob", "--type", "--type-not", "--hidden",
+		"--no-ignore", "--follow", "--max-count", "--max-depth", "--context", "--after-context",
+		"--before-context", "--only-matching", "--multiline", "--json", "--color", "--heading", "--no-heading"}},
+	"find": {long: []string{
+		"-name", "-iname", "-path", "-ipath", "-regex", "-iregex", "-type", "-maxdepth", "-mindepth",
+		"-size", "-mtime", "-mmin", "-newer", "-empty", "-perm", "-user", "-group", "-links", "-prune",
+		"-not", "-a", "-o", "-and", "-or", "-print", "-print0", "-depth", "-follow", "-xdev"}},
+	"tree":       {short: "adfLiIP" + digits, long: []string{"--dirsfirst", "--noreport", "--gitignore"}},
+	"pwd":        {short: "LP", noArgs: true},
+	"file":       {short: "bLi", long: []string{"--brief", "--mime"}},
+	"stat":       {short: "Lc", long: []string{"--format", "--dereference"}},
+	"du":         {short: "shacdkmLx" + digits, long: []string{"--summarize", "--human-readable", "--all", "--max-depth"}},
+	"diff":       {short: "uqrNwBbiyaEc" + digits, long: []string{"--brief", "--unified", "--recursive", "--new-file", "--ignore-all-space", "--ignore-case", "--side-by-side", "--color"}},
+	"which":      {short: "a"},
+	"echo":       {short: "neE"},
+	"git status": {short: "sbuz", long: []string{"--short", "--branch", "--porcelain", "--untracked-files", "--ignored"}},
+	"git diff": {short: "wbRU" + digits, long: []string{
+		"--stat", "--cached", "--staged", "--name-only", "--name-status", "--numstat", "--shortstat",
+		"--word-diff", "--color", "--no-color", "--ignore-all-space", "--unified", "--check"}},
+	"git log": {short: "pn" + digits, long: []string{
+		"--oneline", "--graph", "--stat", "--patch", "--max-count", "--since", "--until", "--author",
+		"--grep", "--all", "--decorate", "--format", "--pretty", "--name-only", "--name-status",
+		"--follow", "--reverse", "--no-merges", "--date", "--abbrev-commit"}},
+	"git show":   {short: "s", long: []string{"--stat", "--name-only", "--name-status", "--oneline", "--format", "--pretty", "--no-patch", "--color"}},
+	"git branch": {short: "avr", long: []string{"--list", "--all", "--remotes", "--verbose", "--show-current", "--contains", "--merged", "--no-merged"}, noArgs: true},
+	"git blame":  {short: "wsMCeL" + digits, long: []string{"--porcelain", "--line-porcelain"}},
+	"go vet":     {long: []string{"-json"}},
+	"go list":    {long: []string{"-m", "-json", "-f", "-deps", "-test", "-e", "-find"}},
+	"go version": {long: []string{"-m", "-v"}},
+	"go env":     {long: []string{"-json"}},
+	"go doc":     {long: []string{"-all", "-src", "-short", "-u", "-c", "-cmd"}},
+}
+
+// shellUnquote removes the quoting and escapes the shell would remove, so
+// "--pre" and \-\-pre are checked like --pre
+var shellUnquote = strings.NewReplacer(`"`, "", `'`, "", `\`, "")
+
+// readOnlyCommand reports whether a shell command is a single read-only
+// command: one of readOnlyCommands given only its allowed flags. Anything
+// with redirection, pipes, chaining or substitution is rejected.
+func readOnlyCommand(command string) bool {
+	command = strings.TrimSpace(command)
+	if command == "" || strings.ContainsAny(command, ";&|<>`$\n") {
 		return false
 	}
+	args := strings.Fields(command)
+	for i, a := range args {
+		args[i] = shellUnquote.Replace(a)
+	}
 
-	// Show the prompt with options
-	fmt.Println() // Ensure we're on a new line
-	fmt.Printf("\033[33m╭─ %s\033[0m\n", prompt)
-	fmt.Printf("\033[33m│ (y)es once, (n)o, (a)lways allow %s, (!) never allow\033[0m\n", toolName)
-	fmt.Printf("\033[33m╰─▶ \033[0m")
-	os.Stdout.Sync() // Flush output before reading
+	spec, ok := readOnlySpec{}, false
+	if len(args) > 1 {
+		spec, ok = readOnlyCommands[args[0]+" "+args[1]]
+		if ok {
+			args = args[2:]
+		}
+	}
+	if !ok {
+		if spec, ok = readOnlyCommands[args[0]]; !ok {
+			return false
+		}
+		args = args[1:]
+	}
 
-	line, err := c.rl.Readline()
-	if err != nil {
-		fmt.Println("\033[31m✗ Declined (read error)\033[0m")
+	flagsDone := false
+	for _, a := range args {
+		switch {
+		case flagsDone || a == "-" || !strings.HasPrefix(a, "-"):
+			if spec.noArgs {
+				return false
+			}
+		case a == "--":
+			flagsDone = true
+		case !spec.allows(a):
+			return false
+		}
+	}
+	return true
+}
+
+// allows reports whether flag is on the spec's list
+func (spec readOnlySpec) allows(flag string) bool {
+	name, _, _ := strings.Cut(flag, "=")
+	if slices.Contains(spec.long, name) {
+		return true
+	}
+	if strings.HasPrefix(flag, "--") || strings.Contains(flag, "=") || spec.short == "" {
 		return false
 	}
+	for _, r := range flag[1:] {
+		if !strings.ContainsRune(spec.short, r) {
+			return false
+		}
+	}
+	return true
+}
 
-	line = strings.ToLower(strings.TrimSpace(line))
+// batchActions describes how each tool is summarized in a batch confirmation
+// (singular and plural phrasing). Whether a tool needs confirmation comes
+// from tools.ReadOnlyTools.
+var batchActions = map[string]struct {
+	one, many string
+}{
+	"write_file":          {"write 1 file", "write %d files"},
+	"write_doc":           {"write 1 doc", "write %d docs"},
+	"run_command":         {"run 1 command", "run %d commands"},
+	"run_command_tracked": {"run 1 tracked command", "run %d tracked commands"},
+	"project_command":     {"run 1 project command", "run %d project commands"},
+	"lint":                {"run the linter", "run the linter %d times"},
+	"git_add":             {"stage files", "stage files %d times"},
+	"git_commit":          {"create 1 commit", "create %d commits"},
+	"screenshot":          {"take 1 screenshot", "take %d screenshots"},
+	"set_version":         {"set the version", "set the version %d times"},
+	"read_file":           {"read 1 file", "read %d files"},
+	"web_search":          {"run 1 web search", "run %d web searches"},
+	"fetch_url":           {"fetch 1 URL", "fetch %d URLs"},
+	"get_context":         {"check the plan", "check the plan %d times"},
+	"add_todo":            {"add 1 todo", "add %d todos"},
+	"complete_todo":       {"complete 1 todo", "complete %d todos"},
+}
 
-	switch line {
-	case "y", "yes":
-		fmt.Println("\033[32m✓ Approved\033[0m")
-		return true
-	case "a", "always":
-		fmt.Printf("\033[32m✓ Approved (saving 'always' for %s)\033[0m\n", toolName)
-		c.cfg.SetToolPermission(toolName, config.PermissionAlways)
-		if err := c.cfg.Save(); err != nil {
-			fmt.Printf("\033[33mWarning: could not save config: %v\033[0m\n", err)
+// summarizeToolBatch renders a one-line description of a batch of tool calls,
+// e.g. "write 3 files, run 1 command". The boolean reports whether any call
+// in the batch would normally require confirmation.
+func summarizeToolBatch(calls []tools.ToolCall) (string, bool) {
+	counts := make(map[string]int)
+	var order []string
+	needsConfirm := false
+	for _, tc := range calls {
+		name := tc.Function.Name
+		if counts[name] == 0 {
+			order = append(order, name)
 		}
-		return true
-	case "!", "never":
-		fmt.Printf("\033[31m✗ Denied (saving 'never' for %s)\033[0m\n", toolName)
-		c.cfg.SetToolPermission(toolName, config.PermissionNever)
-		if err := c.cfg.Save(); err != nil {
-			fmt.Printf("\033[33mWarning: could not save config: %v\033[0m\n", err)
+		counts[name]++
+		if !tools.IsReadOnly(name) {
+			needsConfirm = true
+		}
+	}
+
+	parts := make([]string, 0, len(order))
+	for _, name := range order {
+		action, ok := batchActions[name]
+		switch {
+		case !ok:
+			parts = append(parts, fmt.Sprintf("call %s x%d", name, counts[name]))
+		case counts[name] == 1:
+			parts = append(parts, action.one)
+		default:
+			parts = append(parts, fmt.Sprintf(action.many, counts[name]))
 		}
-		return false
-	default:
-		fmt.Println("\033[31m✗ Declined\033[0m")
-		return false
 	}
+	return strings.Join(parts, ", "), needsConfirm
 }
 
 // confirm is a simple yes/no confirmation (for backward compatibility)
@@ -1468,30 +4058,57 @@ Commands:
   /help, /h        Show this help
   /quit, /q        Exit the chat
   /clear, /new     Clear conversation history
+  /context         Show how full the context is and which messages /compact would replace
+  /compact         Summarize older messages so a long conversation fits the context
   /file <path>     Add file content as context
   /files <paths>   Add multiple files as context
   /cd <dir>        Change working directory
+  /languages [redetect]  Show the detected project languages (redetect re-scans the directory)
   /run <cmd>       Execute a shell command directly
-  /git <cmd>       Git commands (status, diff, log, add, commit)
+  /build, /test     Run the project's configured (or language default) command
+  /lint            Run the configured or detected linter and list findings
+  /format [path]   Format a file, or all changed files, with its formatter
+  /git <cmd>       Git commands (status, diff, log, add, commit [--dry-run])
   /version         Show current project version
   /auto            Toggle auto-execute mode
   /permissions     View/manage tool permissions
   /todos           View/manage persistent todos
   /changelog       View/add changelog entries
   /history [n]     View recent project history
+  /history search <term>  Find past prompts across sessions and re-send one
+  /reset [target]  Clear project state after confirmation: history, todos, changelog (unreleased), debug, plan (default: all)
+  /export-changelog [path]  Export a Keep a Changelog file (default CHANGELOG.keepachangelog.md)
+  /why             Ask the model why it made its last tool call
+  /memory ...      Project notes added to every system prompt (list, add <text>, rm <n>)
   /plan <goal>     Create an implementation plan using best model
   /plan status     Show current plan progress
   /plan next       Execute next plan step with exec model
   /plan run        Execute all remaining plan steps
   /plan retry      Retry the last failed step
   /plan reset      Clear the current plan
-  /search <query>  Search the web
+  /plan-edit ...   Edit plan steps (remove <id>, tier <id> <tier>, move <id> <pos>)
+  /resume-plan     Continue an interrupted plan from the first pending step
+  /plan-regen      Replan the unfinished steps from the current project state
+  /search <query>  Search the web (/search more for the next results)
   /screenshot      Capture a screenshot
-  /sessions        List recorded sessions
+  /open <target>   Open a file or URL with the default application
+  /raw <text>      Send text alone (no history/system prompt/tools), print the raw response
+  /tools [on|off]  Enable or disable tools (off = plain chat)
+  /image <path>    Attach an image to your next message (vision models)
+  /capabilities [probe]  Show (or re-probe) what the model supports: tools, images, embeddings
+  /think [level]   Show or set reasoning effort (low, medium, high, default)
+  /sessions        List recorded sessions (export [file] writes one as pretty JSON)
+  /edit-session [file]  Open a session file (default: the current one) in $VISUAL/$EDITOR
+  /diff-sessions <a> <b>  Compare two sessions step by step, marking where they diverged
+  /disk            Show disk usage of .aicli (sessions, debug, caches, backups)
   /playback <file> Replay a session
+  /replay-into <file>  Load a session's full conversation into this chat and continue it
   /config          Show current configuration
   /models          List available models
   /model [name]    Show or switch current model
+  /pull <model>    Download a model (Ollama only)
+  /debug ...       Show the last debug log (last) or toggle logging (on|off)
+  /macro ...       Save and replay input sequences (save <name> [n], run <name> [args], rm <name>)
 
 Tool Permissions:
   When a tool wants to execute, you'll be prompted with options:
@@ -1535,7 +4152,7 @@ Configuration:
   Auto-exec:    %v
   Session:      %s
 `, c.cfg.APIEndpoint, c.cfg.Model, c.cfg.GetPlanModel(), c.cfg.GetExecModel(),
-		c.cfg.MaxTokens, c.cfg.Temperature,
+		c.cfg.GetMaxTokens(), c.cfg.Temperature,
 		c.exec.WorkDir(), v.String(), c.autoExec, c.recorder.SessionPath())
 }
 
@@ -1613,10 +4230,10 @@ func extractErrorSummary(output, command string) string {
 
 // getFixCommand returns a specific command to run to fix the error
 // Returns the command and a boolean indicating if it's a concrete command (vs template)
-func getFixCommand(output string) (string, bool) {
+func getFixCommand(output, workDir, remoteURL string) (string, bool) {
 	// Go-specific fixes
 	if strings.Contains(output, "go.mod file not found") {
-		return "go mod init myproject", true
+		return "go mod init " + lang.GoModuleName(workDir, remoteURL), true
 	}
 	if strings.Contains(output, "missing go.sum entry") {
 		return "go mod tidy", true
@@ -1630,10 +4247,18 @@ func getFixCommand(output string) (string, bool) {
 		return "pip install <missing-module>", false
 	}
 
+	if strings.Contains(output, "could not find a pyproject.toml") {
+		return "poetry init -n --name " + lang.ProjectName(workDir), true
+	}
+
 	// Node fixes
 	if strings.Contains(output, "Cannot find module") {
 		return "npm install", true
 	}
+	if strings.Contains(output, "package.json") && strings.Contains(output, "ENOENT") {
+		// npm init -y names the package after the directory
+		return "npm init -y", true
+	}
 
 	return "", false
 }
@@ -1727,6 +4352,10 @@ Usage:
   /plan run             Execute all remaining steps
   /plan retry           Retry the last failed step
   /plan reset           Clear the current plan
+  /plan-edit remove <id>            Drop a step
+  /plan-edit tier <id> <tier>       Change a step's model tier
+  /plan-edit move <id> <position>   Reorder a step
+  /plan-regen                       Replan unfinished steps (completed steps are kept)
 
 Plan mode uses two models:
   Planning model  — Best reasoning model for analysis and planning
@@ -1749,6 +4378,71 @@ func (c *Chat) createPlan(goal string) {
 	// Build the planning prompt
 	userPrompt := plan.BuildPlanningPrompt(goal, fileList, fileContents)
 
+	resp, ok := c.requestPlan(userPrompt)
+	if !ok {
+		return
+	}
+
+	// Build the plan
+	p := plan.BuildFromResponse(goal, resp)
+
+	// Save it
+	if err := p.Save(c.exec.WorkDir()); err != nil {
+		fmt.Printf("\033[31mFailed to save plan: %v\033[0m\n", err)
+		return
+	}
+
+	// Display the plan
+	fmt.Printf("\n\033[32mPlan created with %d steps\033[0m\n\n", len(p.Steps))
+	c.displayPlan(p)
+
+	fmt.Printf("\n\033[36mUse /plan next to execute step by step, or /plan run to execute all.\033[0m\n")
+
+	c.recorder.RecordUser(fmt.Sprintf("[Plan created: %s (%d steps)]", goal, len(p.Steps)))
+	c.history.AddRequest(fmt.Sprintf("[Plan] %s", goal))
+}
+
+// regeneratePlan re-reads the project and asks the planning model to replan
+// the unfinished steps, keeping completed steps and their history
+func (c *Chat) regeneratePlan() {
+	p, err := plan.Load(c.exec.WorkDir())
+	if err != nil {
+		fmt.Println("No active plan. Use /plan <goal> to create one.")
+		return
+	}
+	if p.IsComplete() {
+		fmt.Printf("\033[32mAll %d steps completed - nothing to regenerate.\033[0m\n", len(p.Steps))
+		return
+	}
+
+	fmt.Printf("\033[36mRegenerating remaining steps with %s...\033[0m\n", c.cfg.GetPlanModel())
+	userPrompt := plan.BuildRegenPrompt(p, c.gatherFileList(), c.gatherKeyFiles())
+
+	resp, ok := c.requestPlan(userPrompt)
+	if !ok {
+		return
+	}
+
+	before := len(p.Steps)
+	p.Regenerate(resp)
+	if err := p.Save(c.exec.WorkDir()); err != nil {
+		fmt.Printf("\033[31mFailed to save plan: %v\033[0m\n", err)
+		return
+	}
+
+	_, completed, _, _, pending := p.Progress()
+	fmt.Printf("\n\033[32mPlan regenerated: %d completed steps kept, %d new steps (was %d steps)\033[0m\n\n", completed, pending, before)
+	c.displayPlan(p)
+
+	c.recorder.RecordUser(fmt.Sprintf("[Plan regenerated: %s (%d steps)]", p.Goal, len(p.Steps)))
+	c.history.AddRequest(fmt.Sprintf("[Plan regenerated] %s", p.Goal))
+}
+
+// requestPlan sends a planning prompt to the planning model and parses the
+// JSON plan it returns. Errors are printed; ok is false on failure.
+func (c *Chat) requestPlan(userPrompt string) (*plan.PlanResponse, bool) {
+	planModel := c.cfg.GetPlanModel()
+
 	// Create a planning client with the best model, tools disabled (we want JSON output)
 	planClient := c.client.WithModel(planModel)
 	planClient.SetUseTools(false)
@@ -1770,7 +4464,7 @@ func (c *Chat) createPlan(goal string) {
 
 	if err != nil {
 		fmt.Printf("\033[31mPlan generation failed: %v\033[0m\n", err)
-		return
+		return nil, false
 	}
 
 	// Parse the response
@@ -1778,26 +4472,9 @@ func (c *Chat) createPlan(goal string) {
 	if err != nil {
 		fmt.Printf("\033[31mFailed to parse plan: %v\033[0m\n", err)
 		fmt.Printf("\033[90mRaw response:\n%s\033[0m\n", result.Content)
-		return
-	}
-
-	// Build the plan
-	p := plan.BuildFromResponse(goal, resp)
-
-	// Save it
-	if err := p.Save(c.exec.WorkDir()); err != nil {
-		fmt.Printf("\033[31mFailed to save plan: %v\033[0m\n", err)
-		return
+		return nil, false
 	}
-
-	// Display the plan
-	fmt.Printf("\n\033[32mPlan created with %d steps\033[0m\n\n", len(p.Steps))
-	c.displayPlan(p)
-
-	fmt.Printf("\n\033[36mUse /plan next to execute step by step, or /plan run to execute all.\033[0m\n")
-
-	c.recorder.RecordUser(fmt.Sprintf("[Plan created: %s (%d steps)]", goal, len(p.Steps)))
-	c.history.AddRequest(fmt.Sprintf("[Plan] %s", goal))
+	return resp, true
 }
 
 // executePlanNext executes the next pending step in the plan
@@ -1852,6 +4529,110 @@ func (c *Chat) executePlanAll() {
 	fmt.Printf("\033[0m\n")
 }
 
+// handlePlanEditCommand edits the saved plan before (or between) execution:
+// remove a step, change its tier, or move it to a new position
+func (c *Chat) handlePlanEditCommand(args []string) {
+	usage := func() {
+		fmt.Println("Usage: /plan-edit remove <id>")
+		fmt.Println("       /plan-edit tier <id> <premium|standard|economy>")
+		fmt.Println("       /plan-edit move <id> <position>")
+	}
+	if len(args) < 2 {
+		usage()
+		return
+	}
+
+	p, err := plan.Load(c.exec.WorkDir())
+	if err != nil {
+		fmt.Println("No active plan. Use /plan <goal> to create one.")
+		return
+	}
+
+	var id int
+	if _, err := fmt.Sscanf(args[1], "%d", &id); err != nil {
+		fmt.Printf("Invalid step id: %s\n", args[1])
+		return
+	}
+
+	switch args[0] {
+	case "remove", "rm":
+		err = p.RemoveStep(id)
+	case "tier":
+		if len(args) < 3 {
+			usage()
+			return
+		}
+		tier, ok := plan.ParseTier(args[2])
+		if !ok {
+			fmt.Printf("Unknown tier: %s (use premium, standard, or economy)\n", args[2])
+			return
+		}
+		err = p.SetTier(id, tier)
+	case "move", "mv":
+		if len(args) < 3 {
+			usage()
+			return
+		}
+		var pos int
+		if _, scanErr := fmt.Sscanf(args[2], "%d", &pos); scanErr != nil {
+			fmt.Printf("Invalid position: %s\n", args[2])
+			return
+		}
+		err = p.MoveStep(id, pos)
+	default:
+		usage()
+		return
+	}
+
+	if err != nil {
+		fmt.Printf("\033[31mError: %v\033[0m\n", err)
+		return
+	}
+	if err := p.Save(c.exec.WorkDir()); err != nil {
+		fmt.Printf("\033[31mFailed to save plan: %v\033[0m\n", err)
+		return
+	}
+	c.displayPlan(p)
+}
+
+// resumePlan continues an interrupted plan from its first pending step
+func (c *Chat) resumePlan() {
+	p, err := plan.Load(c.exec.WorkDir())
+	if err != nil {
+		fmt.Println("No saved plan to resume. Use /plan <goal> to create one.")
+		return
+	}
+
+	if reset := p.ResetInterrupted(); reset > 0 {
+		fmt.Printf("\033[33mResetting %d interrupted step(s) to pending\033[0m\n", reset)
+		if err := p.Save(c.exec.WorkDir()); err != nil {
+			fmt.Printf("\033[31mFailed to save plan: %v\033[0m\n", err)
+			return
+		}
+	}
+
+	total, completed, failed, _, pending := p.Progress()
+	fmt.Printf("\033[36mResuming plan: %s\033[0m\n", p.Goal)
+	fmt.Printf("Progress: %d/%d done", completed, total)
+	if failed > 0 {
+		fmt.Printf(", %d failed", failed)
+	}
+	fmt.Printf(", %d pending\n", pending)
+
+	step := p.NextPending()
+	if step == nil {
+		if p.IsComplete() {
+			fmt.Printf("\033[32mPlan already complete - nothing to resume.\033[0m\n")
+		} else {
+			fmt.Println("No pending steps. Use /plan retry for failed steps.")
+		}
+		return
+	}
+
+	fmt.Printf("Continuing from step %d: %s\n", step.ID, step.Title)
+	c.executePlanAll()
+}
+
 // retryFailedStep retries the first failed step
 func (c *Chat) retryFailedStep() {
 	p, err := plan.Load(c.exec.WorkDir())
@@ -1877,33 +4658,58 @@ func (c *Chat) retryFailedStep() {
 	fmt.Println("No failed steps to retry.")
 }
 
-// executePlanStep runs a single plan step using the execution model
+// executePlanStep runs a single plan step using the exec model, or the
+// model configured for its tier. If the step fails, it is retried once on
+// the next-higher tier's model.
 func (c *Chat) executePlanStep(p *plan.Plan, step *plan.Step) {
-	execModel := c.cfg.GetExecModel()
+	tier := step.ModelTier
+	model := c.cfg.GetTierModel(string(tier))
+	next := plan.NextTier(tier)
+	nextModel := c.cfg.GetEscalationModel(string(next))
 
 	fmt.Printf("\n\033[36m--- Step %d/%d: %s ---\033[0m\n", step.ID, len(p.Steps), step.Title)
-	fmt.Printf("\033[90mModel: %s | Tier: %s\033[0m\n", execModel, step.ModelTier)
+	fmt.Printf("\033[90mModel: %s | Tier: %s\033[0m\n", model, tier)
 
 	// Mark in-progress and save
 	p.MarkInProgress(step.ID)
 	p.Save(c.exec.WorkDir())
 
-	// Save original model and switch to exec model
+	// Save original model; each attempt switches to its tier's model
 	origModel := c.cfg.Model
-	c.cfg.Model = execModel
 	defer func() { c.cfg.Model = origModel }()
 
-	// Clear conversation history for a fresh step context
-	c.client.ClearHistory()
+	// Show live plan progress alongside the spinner on interactive terminals
+	if term.IsTerminal(int(os.Stdout.Fd())) {
+		c.planProgress = p.ProgressLine(step)
+		defer func() { c.planProgress = "" }()
+	}
 
 	// Build the step execution prompt
 	prompt := plan.GetStepExecutionPrompt(step, p.Goal, p.Analysis)
+	stepID := step.ID
 
 	// Record what we're doing
-	c.recorder.RecordUser(fmt.Sprintf("[Plan Step %d: %s]", step.ID, step.Title))
-
-	// Execute with a turn limit to prevent infinite loops
-	c.sendMessageLimited(prompt, 15)
+	c.recorder.RecordUser(fmt.Sprintf("[Plan Step %d: %s]", stepID, step.Title))
+
+	ok, reason := c.runPlanStepAttempt(model, prompt)
+	result := "Executed"
+
+	// Escalate once to the next tier if this tier's model failed
+	var escalatedTo plan.ModelTier
+	if !ok {
+		if next != "" && nextModel != model {
+			fmt.Printf("\n\033[33mStep %d failed on %s (%s) - escalating to %s tier (%s)\033[0m\n", stepID, model, reason, next, nextModel)
+			c.recorder.RecordUser(fmt.Sprintf("[Plan Step %d: escalated %s -> %s]", stepID, tier, next))
+			escalatedTo = next
+			firstReason := reason
+			ok, reason = c.runPlanStepAttempt(nextModel, prompt)
+			if ok {
+				result = fmt.Sprintf("Executed after escalating %s -> %s (%s failed: %s)", tier, next, tier, firstReason)
+			} else {
+				reason = fmt.Sprintf("Failed on %s (%s) and after escalating to %s (%s)", tier, firstReason, next, reason)
+			}
+		}
+	}
 
 	// Reload plan (sendMessage might have modified files)
 	p, err := plan.Load(c.exec.WorkDir())
@@ -1912,25 +4718,45 @@ func (c *Chat) executePlanStep(p *plan.Plan, step *plan.Step) {
 		return
 	}
 
-	// Mark completed (we assume success unless the user says otherwise)
-	p.MarkCompleted(step.ID, "Executed")
+	if escalatedTo != "" {
+		p.SetEscalation(stepID, escalatedTo)
+	}
+	if ok {
+		p.MarkCompleted(stepID, result)
+	} else {
+		p.MarkFailed(stepID, reason)
+	}
 	p.Save(c.exec.WorkDir())
 
 	total, completed, _, _, pending := p.Progress()
-	fmt.Printf("\n\033[32mStep %d completed (%d/%d done, %d remaining)\033[0m\n", step.ID, completed, total, pending)
+	if ok {
+		fmt.Printf("\n\033[32mStep %d completed (%d/%d done, %d remaining)\033[0m\n", stepID, completed, total, pending)
+	} else {
+		fmt.Printf("\n\033[31mStep %d failed: %s (%d/%d done, %d remaining)\033[0m\n", stepID, reason, completed, total, pending)
+	}
+}
+
+// runPlanStepAttempt executes a step prompt on the given model with a fresh
+// conversation and a turn limit to prevent infinite loops
+func (c *Chat) runPlanStepAttempt(model, prompt string) (bool, string) {
+	c.cfg.Model = model
+	c.client.ClearHistory()
+	return c.sendMessageLimited(prompt, 15)
 }
 
 // sendMessageLimited is like sendMessage but stops after maxTurns tool-call rounds
-// to prevent infinite loops during plan step execution
-func (c *Chat) sendMessageLimited(msg string, maxTurns int) {
+// to prevent infinite loops during plan step execution.
+// Returns false with a reason if the step did not finish cleanly (request error,
+// interruption, turn limit, or a command failure that was never recovered).
+func (c *Chat) sendMessageLimited(msg string, maxTurns int) (bool, string) {
 	tokenCount := 0
-	fmt.Print("\033[90mThinking... (Esc to interrupt)\033[0m")
+	fmt.Print(c.planThinking(0))
 	os.Stdout.Sync()
 
 	result, interrupted := c.streamWithInterrupt(func(ctx context.Context) (*client.ChatResult, error) {
 		return c.client.ChatWithContext(ctx, msg, true, func(token string) {
 			tokenCount++
-			fmt.Printf("\r\033[K\033[90mThinking... [%d tokens] (Esc to interrupt)\033[0m", tokenCount)
+			fmt.Print("\r\033[K" + c.planThinking(tokenCount))
 			os.Stdout.Sync()
 		})
 	})
@@ -1939,8 +4765,8 @@ func (c *Chat) sendMessageLimited(msg string, maxTurns int) {
 	os.Stdout.Sync()
 
 	if result == nil {
-		fmt.Printf("\033[31mError: failed to get response\033[0m\n")
-		return
+		c.printRequestError()
+		return false, "failed to get response"
 	}
 
 	if interrupted {
@@ -1949,11 +4775,11 @@ func (c *Chat) sendMessageLimited(msg string, maxTurns int) {
 			c.recorder.RecordAssistant(result.Content + " [interrupted]")
 		}
 		fmt.Println()
-		return
+		return false, "interrupted"
 	}
 
 	// Parse text-based tool calls from content
-	textToolCalls, cleanedContent := client.ParseToolCallsFromText(result.Content)
+	textToolCalls, cleanedContent := c.parseTextToolCalls(result.Content)
 	if len(textToolCalls) > 0 {
 		result.ToolCalls = append(result.ToolCalls, textToolCalls...)
 		result.Content = cleanedContent
@@ -1970,12 +4796,15 @@ func (c *Chat) sendMessageLimited(msg string, maxTurns int) {
 	}
 
 	turn := 0
+	lastFailure := "" // Error summary of the most recent unrecovered command failure
 	for len(result.ToolCalls) > 0 && turn < maxTurns {
 		turn++
+		c.confirmBatch(result.ToolCalls)
 		for _, tc := range result.ToolCalls {
 			c.recorder.RecordToolCall(tc.Function.Name, tc.Function.Arguments)
+			start := time.Now()
 			toolResult := c.executeTool(tc)
-			c.recorder.RecordToolResult(tc.Function.Name, toolResult)
+			c.recordToolResult(tc, toolResult, time.Since(start))
 
 			if strings.HasPrefix(toolResult, executor.ImagePrefix) {
 				base64Image := strings.TrimPrefix(toolResult, executor.ImagePrefix)
@@ -1987,24 +4816,29 @@ func (c *Chat) sendMessageLimited(msg string, maxTurns int) {
 			}
 
 			if strings.Contains(toolResult, "COMMAND FAILED") {
+				lastFailure = failureSummary(toolResult)
 				break
 			}
+			if strings.HasPrefix(toolResult, "Command succeeded") {
+				lastFailure = ""
+			}
 		}
+		c.batchDecision = batchNone
 
 		tokenCount = 0
-		fmt.Print("\033[90mThinking... (Esc to interrupt)\033[0m")
+		fmt.Print(c.planThinking(0))
 		os.Stdout.Sync()
 		result, interrupted = c.streamWithInterrupt(func(ctx context.Context) (*client.ChatResult, error) {
 			return c.client.ContinueWithToolResultsContext(ctx, true, func(token string) {
 				tokenCount++
-				fmt.Printf("\r\033[K\033[90mThinking... [%d tokens] (Esc to interrupt)\033[0m", tokenCount)
+				fmt.Print("\r\033[K" + c.planThinking(tokenCount))
 				os.Stdout.Sync()
 			})
 		})
 		fmt.Print("\r\033[K")
 		if result == nil {
-			fmt.Printf("\033[31mError: failed to get response\033[0m\n")
-			return
+			c.printRequestError()
+			return false, "failed to get response"
 		}
 		if interrupted {
 			if result.Content != "" {
@@ -2012,11 +4846,11 @@ func (c *Chat) sendMessageLimited(msg string, maxTurns int) {
 				c.recorder.RecordAssistant(result.Content + " [interrupted]")
 			}
 			fmt.Println()
-			return
+			return false, "interrupted"
 		}
 
 		// Parse text-based tool calls from continuation
-		textToolCalls, cleanedContent = client.ParseToolCallsFromText(result.Content)
+		textToolCalls, cleanedContent = c.parseTextToolCalls(result.Content)
 		if len(textToolCalls) > 0 {
 			result.ToolCalls = append(result.ToolCalls, textToolCalls...)
 			result.Content = cleanedContent
@@ -2031,7 +4865,38 @@ func (c *Chat) sendMessageLimited(msg string, maxTurns int) {
 
 	if turn >= maxTurns {
 		fmt.Printf("\033[33m[Step reached %d turn limit, moving on]\033[0m\n", maxTurns)
+		if len(result.ToolCalls) > 0 {
+			return false, fmt.Sprintf("reached %d turn limit", maxTurns)
+		}
+	}
+
+	if lastFailure != "" {
+		return false, lastFailure
+	}
+	return true, ""
+}
+
+// planThinking renders the thinking spinner for plan execution, prefixed
+// with the live plan progress (e.g. "[2/7] Implementing parser...")
+func (c *Chat) planThinking(tokens int) string {
+	prefix := ""
+	if c.planProgress != "" {
+		prefix = "\033[36m" + c.planProgress + "\033[90m | "
+	}
+	if tokens == 0 {
+		return "\033[90m" + prefix + "Thinking... (Esc to interrupt)\033[0m"
+	}
+	return fmt.Sprintf("\033[90m%sThinking... [%d tokens] (Esc to interrupt)\033[0m", prefix, tokens)
+}
+
+// failureSummary extracts the "Error Summary" line from a COMMAND FAILED tool result
+func failureSummary(toolResult string) string {
+	for _, line := range strings.Split(toolResult, "\n") {
+		if strings.HasPrefix(line, "Error Summary: ") {
+			return strings.TrimPrefix(line, "Error Summary: ")
+		}
 	}
+	return "command failed"
 }
 
 // showPlanStatus displays the current plan state
@@ -2100,6 +4965,40 @@ func (c *Chat) gatherFileList() string {
 	return "(unable to list files)"
 }
 
+// gatherRecentFiles returns up to max recently touched files, combining
+// uncommitted git changes with files from the unreleased changelog, minus
+// those excluded by .aicliignore
+func (c *Chat) gatherRecentFiles(max int) []string {
+	seen := make(map[string]bool)
+	var files []string
+	candidates := append(c.exec.ChangedFiles(), c.changelog.RecentFiles()...)
+	for _, f := range candidates {
+		if len(files) >= max {
+			break
+		}
+		if f == "" || seen[f] || c.exec.Ignored(f) {
+			continue
+		}
+		seen[f] = true
+		files = append(files, f)
+	}
+	return files
+}
+
+// formatRecentFiles renders the recent-files list as a message prefix
+func formatRecentFiles(files []string) string {
+	if len(files) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("Recently changed files (work in progress):\n")
+	for _, f := range files {
+		sb.WriteString(fmt.Sprintf("- %s\n", f))
+	}
+	sb.WriteString("\n")
+	return sb.String()
+}
+
 // gatherKeyFiles reads important project files for context
 func (c *Chat) gatherKeyFiles() string {
 	var sb strings.Builder
@@ -2120,6 +5019,9 @@ func (c *Chat) gatherKeyFiles() string {
 		if filesRead >= maxFiles {
 			break
 		}
+		if c.exec.Ignored(f) {
+			continue
+		}
 
 		content, err := c.exec.ReadFile(f)
 		if err != nil {
@@ -0,0 +1,1919 @@
+package chat
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chzyer/readline"
+
+	"aicli/internal/client"
+	"aicli/internal/config"
+	"aicli/internal/diff"
+	"aicli/internal/executor"
+	"aicli/internal/lang"
+	"aicli/internal/plan"
+	"aicli/internal/session"
+	"aicli/internal/tools"
+	"aicli/internal/web"
+)
+
+func TestReadOnlyCommand(t *testing.T) {
+	tests := []struct {
+		command string
+		want    bool
+	}{
+		{"ls -la", true},
+		{"cat main.go", true},
+		{"head -20 README.md", true},
+		{"grep -rn TODO internal", true},
+		{"rg -i --glob=*.go parse", true},
+		{"find . -name '*.go' -type f", true},
+		{"git status --short", true},
+		{"git diff --stat HEAD~1", true},
+		{"git log --oneline -n 5", true},
+		{"git branch -a", true},
+		{"git branch --show-current", true},
+		{"go vet ./...", true},
+		{"go env GOPATH", true},
+		{"go list -m all", true},
+
+		{"", false},
+		{"rm -rf build", false},
+		{"ls; rm x", false},
+		{"cat a > b", false},
+		{"git branch -D feature", false},
+		{"git branch -m old new", false},
+		{"git branch feature", false},
+		{"git diff --output=patch.txt", false},
+		{"git diff --ext-diff", false},
+		{"git -C /tmp status", false},
+		{"go env -w GOFLAGS=-mod=mod", false},
+		{"go vet -vettool=/tmp/bin ./...", false},
+		{"rg --pre ./script pattern", false},
+		{`rg "--pre" ./script pattern`, false},
+		{`rg \-\-pre ./script pattern`, false},
+		{"rg -z pattern", false},
+		{"find . -exec rm {} +", false},
+		{"find . -delete", false},
+		{"tree -o out.txt", false},
+		{"sort -o out.txt in.txt", false},
+	}
+	for _, tt := range tests {
+		if got := readOnlyCommand(tt.command); got != tt.want {
+			t.Errorf("readOnlyCommand(%q) = %v, want %v", tt.command, got, tt.want)
+		}
+	}
+}
+
+func TestRunStatsDeclines(t *testing.T) {
+	type call struct{ name, args, result string }
+	declined := call{"run_command", `{"command":"rm -rf build"}`, "OPERATION FAILED: User declined to execute command. The command was NOT run."}
+	failed := call{"run_command", `{"command":"go test ./..."}`, "COMMAND FAILED (exit 1)"}
+	passed := call{"run_command", `{"command":"go test ./..."}`, "Command succeeded"}
+	tests := []struct {
+		name          string
+		calls         []call
+		wantDeclined  int
+		wantFailures  int
+		wantFailedAny bool
+		wantFailedUnr bool
+		wantSummary   string
+	}{
+		{"declined only", []call{declined}, 1, 0, false, false, "0 files written, 0 commands run, 0 failures, 1 declined"},
+		{"declined and failed", []call{declined, failed}, 1, 1, true, true, "0 files written, 1 command run, 1 failure, 1 declined"},
+		{"failed then fixed", []call{failed, passed}, 0, 1, true, false, "0 files written, 2 commands run, 1 failure"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var s RunStats
+			for _, c := range tt.calls {
+				s.record(c.name, c.args, c.result)
+			}
+			if s.Declined != tt.wantDeclined || s.Failures != tt.wantFailures {
+				t.Errorf("declined %d, failures %d; want %d, %d", s.Declined, s.Failures, tt.wantDeclined, tt.wantFailures)
+			}
+			if got := s.Failed(config.FailOnAny); got != tt.wantFailedAny {
+				t.Errorf("Failed(any) = %v, want %v", got, tt.wantFailedAny)
+			}
+			if got := s.Failed(config.FailOnUnrecovered); got != tt.wantFailedUnr {
+				t.Errorf("Failed(unrecovered) = %v, want %v", got, tt.wantFailedUnr)
+			}
+			if got := s.String(); got != tt.wantSummary {
+				t.Errorf("String() = %q, want %q", got, tt.wantSummary)
+			}
+		})
+	}
+}
+
+func TestLineRange(t *testing.T) {
+	tests := []struct {
+		start, end int
+		want       string
+	}{
+		{10, 20, "lines 10-20"},
+		{0, 20, "lines 1-20"},
+		{50, 0, "from line 50"},
+		{0, 0, "from line 1"},
+	}
+	for _, tt := range tests {
+		if got := lineRange(tt.start, tt.end); got != tt.want {
+			t.Errorf("lineRange(%d, %d) = %q, want %q", tt.start, tt.end, got, tt.want)
+		}
+	}
+}
+
+func TestGetHistoryPath(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	workDir := t.TempDir()
+	explicit := filepath.Join(t.TempDir(), "x", "hist")
+	tests := []struct {
+		name string
+		cfg  config.Config
+		want string
+	}{
+		{"default", config.Config{}, filepath.Join(home, ".config", "aicli", "history")},
+		{"project", config.Config{ProjectHistory: true}, filepath.Join(workDir, ".aicli", "history")},
+		{"explicit wins", config.Config{HistoryFile: explicit, ProjectHistory: true}, explicit},
+		{"tilde", config.Config{HistoryFile: "~/h/aicli"}, filepath.Join(home, "h", "aicli")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := getHistoryPath(&tt.cfg, workDir)
+			if got != tt.want {
+				t.Errorf("getHistoryPath = %q, want %q", got, tt.want)
+			}
+			if _, err := os.Stat(filepath.Dir(got)); err != nil {
+				t.Errorf("history directory not created: %v", err)
+			}
+		})
+	}
+}
+
+func TestTrimHistoryFile(t *testing.T) {
+	tests := []struct {
+		name     string
+		lines    int
+		maxLines int
+		want     []string
+	}{
+		{"under cap", 3, 5, []string{"1", "2", "3"}},
+		{"at cap", 3, 3, []string{"1", "2", "3"}},
+		{"over cap", 5, 2, []string{"4", "5"}},
+		{"no cap", 5, 0, []string{"1", "2", "3", "4", "5"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "history")
+			var sb strings.Builder
+			for i := 1; i <= tt.lines; i++ {
+				sb.WriteString(strconv.Itoa(i) + "\n")
+			}
+			os.WriteFile(path, []byte(sb.String()), 0600)
+			if err := trimHistoryFile(path, tt.maxLines); err != nil {
+				t.Fatal(err)
+			}
+			data, _ := os.ReadFile(path)
+			if got := strings.Fields(string(data)); strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("history = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	if err := trimHistoryFile(filepath.Join(t.TempDir(), "missing"), 10); err != nil {
+		t.Errorf("missing file: %v", err)
+	}
+}
+
+func TestParseTextToolCallsWithToolsOff(t *testing.T) {
+	text := "Let me look.\n<tool_call>{\"name\": \"read_file\", \"arguments\": {\"path\": \"main.go\"}}</tool_call>"
+	tests := []struct {
+		noTools   bool
+		wantCalls int
+	}{
+		{false, 1},
+		{true, 0},
+	}
+	for _, tt := range tests {
+		c := &Chat{noTools: tt.noTools}
+		calls, content := c.parseTextToolCalls(text)
+		if len(calls) != tt.wantCalls {
+			t.Errorf("noTools=%v: %d tool calls, want %d", tt.noTools, len(calls), tt.wantCalls)
+		}
+		if tt.noTools && content != text {
+			t.Errorf("noTools: content changed to %q", content)
+		}
+	}
+}
+
+func TestRunSingleStopsAtMaxSteps(t *testing.T) {
+	tests := []struct {
+		maxSteps     int
+		wantRequests int
+		wantLimit    int
+	}{
+		{1, 2, 1},
+		{3, 4, 3},
+		{0, 6, 0}, // No cap: the model stops calling tools on its own
+	}
+	for _, tt := range tests {
+		t.Run(strconv.Itoa(tt.maxSteps), func(t *testing.T) {
+			requests := 0
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				w.Header().Set("Content-Type", "text/event-stream")
+				if requests > 5 {
+					fmt.Fprint(w, "data: "+`{"choices":[{"delta":{"content":"done"},"finish_reason":"stop"}]}`+"\n\ndata: [DONE]\n\n")
+					return
+				}
+				call := fmt.Sprintf(`{"index":0,"id":"call_%d","type":"function","function":{"name":"list_files","arguments":"{\"pattern\":\".\"}"}}`, requests)
+				fmt.Fprint(w, "data: "+`{"choices":[{"delta":{"tool_calls":[`+call+`]}}]}`+"\n\n"+
+					"data: "+`{"choices":[{"delta":{},"finish_reason":"tool_calls"}]}`+"\n\ndata: [DONE]\n\n")
+			}))
+			defer srv.Close()
+
+			c := newTestChat(t, &config.Config{APIEndpoint: srv.URL + "/v1", Model: "test", NoUpdateCheck: true})
+			c.SetMaxSteps(tt.maxSteps)
+			if err := c.RunSingle("look around"); err != nil {
+				t.Fatal(err)
+			}
+			if requests != tt.wantRequests || c.Stats().StepLimit != tt.wantLimit {
+				t.Errorf("%d requests, step limit %d; want %d requests, limit %d",
+					requests, c.Stats().StepLimit, tt.wantRequests, tt.wantLimit)
+			}
+			if got := c.Stats().Failed(config.FailOnAny); got != (tt.wantLimit > 0) {
+				t.Errorf("Failed = %v, want %v", got, tt.wantLimit > 0)
+			}
+		})
+	}
+}
+
+func TestRunStatsStepLimit(t *testing.T) {
+	tests := []struct {
+		stats RunStats
+		want  string
+	}{
+		{RunStats{CommandsRun: 2}, "0 files written, 2 commands run, 0 failures"},
+		{RunStats{CommandsRun: 5, StepLimit: 5}, "0 files written, 5 commands run, 0 failures, stopped at the 5-step limit"},
+	}
+	for _, tt := range tests {
+		if got := tt.stats.String(); got != tt.want {
+			t.Errorf("String() = %q, want %q", got, tt.want)
+		}
+	}
+}
+
+func TestContextBar(t *testing.T) {
+	tests := []struct {
+		used, window int
+		want         string
+	}{
+		{0, 1000, "[" + strings.Repeat(".", 30) + "]   0%"},
+		{500, 1000, "[" + strings.Repeat("#", 15) + strings.Repeat(".", 15) + "]  50%"},
+		{999, 1000, "[" + strings.Repeat("#", 29) + ".]  99%"},
+		{1000, 1000, "[" + strings.Repeat("#", 30) + "] 100%"},
+		{2500, 1000, "[" + strings.Repeat("#", 30) + "] 250%"},
+		{100, 0, ""},
+	}
+	for _, tt := range tests {
+		if got := contextBar(tt.used, tt.window); got != tt.want {
+			t.Errorf("contextBar(%d, %d) = %q, want %q", tt.used, tt.window, got, tt.want)
+		}
+	}
+}
+
+// newTestChat creates a chat in a fresh project directory with its own
+// home, so config, history and .aicli files stay out of the source tree
+func newTestChat(t *testing.T, cfg *config.Config) *Chat {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	t.Chdir(t.TempDir())
+	c, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(c.closeReadline)
+	return c
+}
+
+func TestIsMacroCommand(t *testing.T) {
+	tests := []struct {
+		line string
+		want bool
+	}{
+		{"/macro", true},
+		{"/macro list", true},
+		{"/macro run deploy prod", true},
+		{"/macros", false},
+		{"/macro-list", false},
+		{"/model", false},
+		{"tell me about /macro", false},
+	}
+	for _, tt := range tests {
+		if got := isMacroCommand(tt.line); got != tt.want {
+			t.Errorf("isMacroCommand(%q) = %v, want %v", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestExpandMacro(t *testing.T) {
+	tests := []struct {
+		name    string
+		lines   []string
+		args    []string
+		want    []string
+		wantErr bool
+	}{
+		{"no placeholders", []string{"/build", "/test"}, []string{"x"}, []string{"/build", "/test"}, false},
+		{"numbered", []string{"/cd {1}", "fix {2} in {1}"}, []string{"api", "the tests"}, []string{"/cd api", "fix the tests in api"}, false},
+		{"all args", []string{"/run go test {*}"}, []string{"-run", "TestX", "./..."}, []string{"/run go test -run TestX ./..."}, false},
+		{"all args, none given", []string{"/run make {*}"}, nil, []string{"/run make "}, false},
+		{"repeated", []string{"{1}{1}"}, []string{"ab"}, []string{"abab"}, false},
+		{"missing argument", []string{"/cd {2}"}, []string{"a"}, []string{"/cd {2}"}, true},
+		{"zero is not an argument", []string{"{0}"}, []string{"a"}, []string{"{0}"}, true},
+		{"not a placeholder", []string{"{x} {}"}, nil, []string{"{x} {}"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := expandMacro(tt.lines, tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("expandMacro = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMacroSaveAndRun(t *testing.T) {
+	c := newTestChat(t, &config.Config{APIEndpoint: "http://127.0.0.1:1/v1", Model: "test", NoUpdateCheck: true})
+
+	c.recentInputs = []string{"/tools on", "/tools {1}"}
+	if c.handleMacroCommand([]string{"save", "plain", "1"}) {
+		t.Fatal("save quit the chat")
+	}
+	if got := c.cfg.Macros["plain"]; len(got) != 1 || got[0] != "/tools {1}" {
+		t.Fatalf("saved %q, want the last input only", got)
+	}
+	saved, err := os.ReadFile(config.LocalConfigPath())
+	if err != nil || !strings.Contains(string(saved), `"/tools {1}"`) {
+		t.Fatalf("macro not written to the config: %v", err)
+	}
+
+	// A missing argument runs nothing
+	c.handleMacroCommand([]string{"run", "plain"})
+	if c.noTools {
+		t.Fatal("ran a macro with a missing argument")
+	}
+	c.handleMacroCommand([]string{"run", "plain", "off"})
+	if !c.noTools {
+		t.Error("/macro run plain off did not turn tools off")
+	}
+
+	c.cfg.Macros["bye"] = []string{"/macro run plain on", "/quit", "/tools on"}
+	if !c.handleMacroCommand([]string{"run", "bye"}) {
+		t.Error("/quit in a macro did not quit")
+	}
+	if !c.noTools {
+		t.Error("ran a nested macro or a line after /quit")
+	}
+
+	c.handleMacroCommand([]string{"rm", "plain"})
+	if _, ok := c.cfg.Macros["plain"]; ok {
+		t.Error("/macro rm kept the macro")
+	}
+}
+
+func TestShutdownOnSignal(t *testing.T) {
+	statuses := make(chan string, 4)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var summary completionSummary
+		json.NewDecoder(r.Body).Decode(&summary)
+		statuses <- summary.Status
+	}))
+	defer srv.Close()
+
+	// Non-interactive: readline's own goroutine races with Close
+	t.Setenv("HOME", t.TempDir())
+	t.Chdir(t.TempDir())
+	c, err := NewNonInteractive(&config.Config{APIEndpoint: "http://127.0.0.1:1/v1", Model: "test", NotifyWebhook: srv.URL}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exits := make(chan int, 1)
+	c.exit = func(code int) { exits <- code }
+
+	stop := c.handleSignals()
+	if inner := c.handleSignals(); inner == nil {
+		t.Fatal("nested handleSignals returned no stop func")
+	} else {
+		inner() // Must not unregister the outer handler
+	}
+	self, _ := os.FindProcess(os.Getpid())
+	if err := self.Signal(os.Interrupt); err != nil {
+		stop()
+		t.Skipf("can't signal this process: %v", err)
+	}
+
+	select {
+	case code := <-exits:
+		if code != 130 {
+			t.Errorf("exit code = %d, want 130", code)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("shutdown did not run")
+	}
+	stop()
+	if c.stopSignals != nil {
+		t.Error("stop left the handler registered")
+	}
+
+	if got := <-statuses; got != "interrupted" {
+		t.Errorf("notified %q, want interrupted", got)
+	}
+	s, err := session.LoadSession(c.recorder.SessionPath())
+	if err != nil {
+		t.Fatalf("session not flushed: %v", err)
+	}
+	if s.ProjectDir == "" {
+		t.Error("flushed session has no header")
+	}
+}
+
+// toolCallOf builds a tool call with the given name and JSON arguments
+func toolCallOf(name, args string) tools.ToolCall {
+	tc := tools.ToolCall{ID: "call_" + name, Type: "function"}
+	tc.Function.Name = name
+	tc.Function.Arguments = args
+	return tc
+}
+
+func TestSummarizeToolBatch(t *testing.T) {
+	tests := []struct {
+		name         string
+		calls        []string
+		want         string
+		needsConfirm bool
+	}{
+		{"mixed", []string{"write_file", "write_file", "run_command", "write_file"}, "write 3 files, run 1 command", true},
+		{"read-only", []string{"read_file", "read_file", "web_search"}, "read 2 files, run 1 web search", false},
+		{"one write among reads", []string{"read_file", "git_commit"}, "read 1 file, create 1 commit", true},
+		{"unknown tool", []string{"mystery", "mystery"}, "call mystery x2", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls []tools.ToolCall
+			for _, name := range tt.calls {
+				calls = append(calls, toolCallOf(name, "{}"))
+			}
+			got, needsConfirm := summarizeToolBatch(calls)
+			if got != tt.want || needsConfirm != tt.needsConfirm {
+				t.Errorf("summarizeToolBatch = %q, %v; want %q, %v", got, needsConfirm, tt.want, tt.needsConfirm)
+			}
+		})
+	}
+}
+
+func TestConfirmToolHonorsBatchDecision(t *testing.T) {
+	tests := []struct {
+		name     string
+		decision int
+		perm     string
+		want     bool
+	}{
+		{"batch approved", batchApproved, "", true},
+		{"batch denied", batchDenied, "", false},
+		{"no batch decision, no terminal", batchNone, "", false},
+		{"never beats batch approval", batchApproved, config.PermissionNever, false},
+		{"always beats batch denial", batchDenied, config.PermissionAlways, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{}
+			if tt.perm != "" {
+				cfg.ToolPermissions = map[string]string{"write_file": tt.perm}
+			}
+			c := &Chat{cfg: cfg, batchDecision: tt.decision}
+			if got := c.confirmTool("write_file", "Write main.go"); got != tt.want {
+				t.Errorf("confirmTool = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfirmBatchNeedsTerminal(t *testing.T) {
+	calls := []tools.ToolCall{toolCallOf("write_file", "{}"), toolCallOf("run_command", "{}")}
+	c := &Chat{cfg: &config.Config{BatchConfirm: true}, batchDecision: batchApproved}
+	c.confirmBatch(calls)
+	if c.batchDecision != batchNone {
+		t.Errorf("batchDecision = %d without a terminal, want batchNone", c.batchDecision)
+	}
+}
+
+func TestBuildWhyQuestion(t *testing.T) {
+	long := `{"content":"` + strings.Repeat("x", 600) + `"}`
+	tests := []struct {
+		tool, args string
+		want       string
+		notWant    string
+	}{
+		{"read_file", `{"path":"main.go"}`, `you just ran read_file with arguments {"path":"main.go"}`, ""},
+		{"list_files", "{}", "you just ran list_files. What", ""},
+		{"list_files", "  ", "you just ran list_files. What", ""},
+		{"write_file", long, "with arguments " + long[:500] + "...", long[:501]},
+	}
+	for _, tt := range tests {
+		got := buildWhyQuestion(tt.tool, tt.args)
+		if !strings.Contains(got, tt.want) || !strings.Contains(got, "Do not call any tools") {
+			t.Errorf("buildWhyQuestion(%s) = %q, want it to contain %q", tt.tool, got, tt.want)
+		}
+		if tt.notWant != "" && strings.Contains(got, tt.notWant) {
+			t.Errorf("buildWhyQuestion(%s) did not truncate the arguments", tt.tool)
+		}
+	}
+}
+
+func TestEnsureGitignoreFirstSessionOnly(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      config.Config
+		existing bool // .aicli already exists
+		want     bool // .gitignore written
+	}{
+		{"first session", config.Config{}, false, true},
+		{"later session", config.Config{}, true, false},
+		{"disabled", config.Config{DisableGitignore: true}, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if tt.existing {
+				os.Mkdir(filepath.Join(dir, ".aicli"), 0755)
+			}
+			ensureGitignore(&tt.cfg, dir)
+			_, err := os.Stat(filepath.Join(dir, ".gitignore"))
+			if got := err == nil; got != tt.want {
+				t.Errorf(".gitignore written = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatRecentFiles(t *testing.T) {
+	tests := []struct {
+		files []string
+		want  string
+	}{
+		{nil, ""},
+		{[]string{"main.go"}, "Recently changed files (work in progress):\n- main.go\n\n"},
+		{[]string{"a.go", "b/c.go"}, "Recently changed files (work in progress):\n- a.go\n- b/c.go\n\n"},
+	}
+	for _, tt := range tests {
+		if got := formatRecentFiles(tt.files); got != tt.want {
+			t.Errorf("formatRecentFiles(%q) = %q, want %q", tt.files, got, tt.want)
+		}
+	}
+}
+
+func TestGatherRecentFiles(t *testing.T) {
+	dir := t.TempDir()
+	changelog := session.NewChangelogFile(dir)
+	changelog.AddEntry("Added", "the parser", []string{"parser.go", "lexer.go"})
+	changelog.AddEntry("Fixed", "a crash", []string{"parser.go"})
+	c := &Chat{exec: executor.New(dir), changelog: changelog}
+
+	tests := []struct {
+		max  int
+		want int
+	}{
+		{1, 1},
+		{5, 2}, // parser.go is listed once
+	}
+	for _, tt := range tests {
+		got := c.gatherRecentFiles(tt.max)
+		if len(got) != tt.want {
+			t.Errorf("gatherRecentFiles(%d) = %q, want %d files", tt.max, got, tt.want)
+		}
+		seen := make(map[string]bool)
+		for _, f := range got {
+			if seen[f] {
+				t.Errorf("gatherRecentFiles(%d) repeats %s", tt.max, f)
+			}
+			seen[f] = true
+		}
+	}
+}
+
+func TestPlanEditCommandSaves(t *testing.T) {
+	dir := t.TempDir()
+	p := plan.New("goal", "")
+	for _, title := range []string{"A", "B", "C"} {
+		p.AddStep(title, "", plan.TierStandard, nil)
+	}
+	if err := p.Save(dir); err != nil {
+		t.Fatal(err)
+	}
+	c := &Chat{cfg: &config.Config{}, exec: executor.New(dir)}
+
+	c.handlePlanEditCommand([]string{"move", "3", "1"})
+	c.handlePlanEditCommand([]string{"tier", "1", "economy"})
+	c.handlePlanEditCommand([]string{"remove", "2"})
+	c.handlePlanEditCommand([]string{"tier", "1", "ultra"}) // rejected
+	c.handlePlanEditCommand([]string{"remove", "x"})        // rejected
+
+	saved, err := plan.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, s := range saved.Steps {
+		got = append(got, fmt.Sprintf("%d:%s:%s", s.ID, s.Title, s.ModelTier))
+	}
+	if want := "1:C:economy 2:B:standard"; strings.Join(got, " ") != want {
+		t.Errorf("saved steps = %q, want %q", got, want)
+	}
+}
+
+func TestPlanThinking(t *testing.T) {
+	tests := []struct {
+		progress string
+		tokens   int
+		want     string
+	}{
+		{"", 0, "Thinking... (Esc to interrupt)"},
+		{"", 12, "Thinking... [12 tokens] (Esc to interrupt)"},
+		{"[2/7] Parser...", 0, "[2/7] Parser...\033[90m | Thinking... (Esc to interrupt)"},
+		{"[2/7] Parser...", 5, "[2/7] Parser...\033[90m | Thinking... [5 tokens] (Esc to interrupt)"},
+	}
+	for _, tt := range tests {
+		c := &Chat{planProgress: tt.progress}
+		if got := c.planThinking(tt.tokens); !strings.Contains(got, tt.want) {
+			t.Errorf("planThinking(%d) with progress %q = %q, want it to contain %q", tt.tokens, tt.progress, got, tt.want)
+		}
+	}
+}
+
+func TestParseToolArgs(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     string
+		wantMsg  []string // substrings of the returned message; none means success
+		wantPath string
+	}{
+		{"valid", `{"path":"a.go"}`, nil, "a.go"},
+		{"empty means no arguments", "  ", nil, ""},
+		{"malformed", `{"path":'a.go'}`, []string{"could not parse arguments:", `received: {"path":'a.go'}`, "NOT run"}, ""},
+		{"wrong type", `{"path":42}`, []string{"could not parse arguments:", "received: {\"path\":42}"}, ""},
+		{"cut off", `{"path":"a.go","content":"pack`, []string{"unexpected end of JSON input", "truncated"}, ""},
+	}
+	for _, tt := range tests {
+		var a tools.WriteFileArgs
+		msg := parseToolArgs(tt.args, &a)
+		if len(tt.wantMsg) == 0 && msg != "" {
+			t.Errorf("%s: parseToolArgs() = %q, want success", tt.name, msg)
+		}
+		for _, want := range tt.wantMsg {
+			if !strings.Contains(msg, want) {
+				t.Errorf("%s: parseToolArgs() = %q, want it to contain %q", tt.name, msg, want)
+			}
+		}
+		if len(tt.wantMsg) == 0 && a.Path != tt.wantPath {
+			t.Errorf("%s: path = %q, want %q", tt.name, a.Path, tt.wantPath)
+		}
+	}
+}
+
+func TestExecuteToolMalformedArgs(t *testing.T) {
+	c := newTestChat(t, &config.Config{})
+	calls := []tools.ToolCall{
+		toolCallOf("write_file", `{"path":"out.txt","content":"hi",}`),
+		toolCallOf("read_file", `{"path": README.md}`),
+		toolCallOf("run_command", `{"command":"ls" "timeout":5}`),
+		toolCallOf("git_log", `{count:10}`),
+		toolCallOf("list_files", `["*.go"]`),
+	}
+	for _, tc := range calls {
+		got := c.executeTool(tc)
+		if !strings.Contains(got, "could not parse arguments") || !strings.Contains(got, "received: "+tc.Function.Arguments) {
+			t.Errorf("%s: result = %q, want the parse error and the arguments received", tc.Function.Name, got)
+		}
+	}
+	if _, err := os.Stat("out.txt"); !os.IsNotExist(err) {
+		t.Errorf("write_file ran with malformed arguments (stat err = %v)", err)
+	}
+}
+
+func TestGetContextTool(t *testing.T) {
+	c := newTestChat(t, &config.Config{})
+	if !tools.IsReadOnly("get_context") {
+		t.Error("get_context should be read-only (no confirmation)")
+	}
+
+	got := c.executeTool(toolCallOf("get_context", `{}`))
+	if !strings.Contains(got, "No active plan.") || !strings.Contains(got, "No pending todos.") {
+		t.Errorf("empty context = %q", got)
+	}
+
+	p := plan.New("ship the parser", "")
+	p.AddStep("Write lexer", "", plan.TierStandard, nil)
+	p.AddStep("Write parser", "", plan.TierStandard, nil)
+	p.MarkCompleted(1, "done")
+	if err := p.Save(c.exec.WorkDir()); err != nil {
+		t.Fatal(err)
+	}
+	c.todoFile.AddTodo("add parser tests")
+	c.todoFile.AddTodo("update README")
+	c.todoFile.Complete(1) // todos are a stack: index 1 is "add parser tests"
+
+	got = c.executeTool(toolCallOf("get_context", `{}`))
+	want := "CURRENT PLAN\nGoal: ship the parser\nProgress: 1/2 completed\n1. [completed] Write lexer\n2. [pending] Write parser\n" +
+		"\nPENDING TODOS\n1. update README\n"
+	if got != want {
+		t.Errorf("context = %q, want %q", got, want)
+	}
+}
+
+func TestRepeatedFixSuggestions(t *testing.T) {
+	c := newTestChat(t, &config.Config{})
+	c.autoExec = true
+
+	// Each command differs (so the per-command breaker never trips) but fails
+	// the same way, suggesting the same fix every time
+	fail := func(i int) string {
+		cmd := fmt.Sprintf(`echo "go: no matching versions for query" >&2; exit 1 # try %d`, i)
+		return c.executeTool(toolCallOf("run_command", fmt.Sprintf(`{"command":%q}`, cmd)))
+	}
+	for i := 1; i <= maxFixSuggestions; i++ {
+		got := fail(i)
+		if !strings.Contains(got, "REQUIRED TODO STACK") || !strings.Contains(got, "1. Check the command") {
+			t.Fatalf("failure %d: result = %q, want the fix todo", i, got)
+		}
+		if pending := c.todoFile.GetBlocking(); len(pending) != 1 {
+			t.Errorf("failure %d: %d fix todos pending, want the one fix, not duplicates", i, len(pending))
+		}
+	}
+
+	got := fail(maxFixSuggestions + 1)
+	if !strings.Contains(got, fmt.Sprintf("already been tried %d times", maxFixSuggestions)) || !strings.Contains(got, "ask how to proceed") {
+		t.Errorf("result past the cap = %q, want an ask-the-user escalation", got)
+	}
+	if pending := c.todoFile.GetBlocking(); len(pending) != 0 {
+		t.Errorf("fix todos after escalating = %v, want none", pending)
+	}
+
+	// A success with nothing left to fix starts the count afresh
+	c.executeTool(toolCallOf("run_command", `{"command":"true"}`))
+	if got := fail(99); !strings.Contains(got, "REQUIRED TODO STACK") {
+		t.Errorf("result after a success = %q, want the fix suggested again", got)
+	}
+}
+
+func TestRunCommandCompletesOnlyItsTodo(t *testing.T) {
+	c := newTestChat(t, &config.Config{})
+	c.autoExec = true
+	c.pushTodo("Then re-run: echo ok && echo again", "echo ok && echo again")
+	c.pushTodo("Run: echo ok", "echo ok")
+
+	// "echo" is a substring of both; only an exact match completes a todo
+	c.executeTool(toolCallOf("run_command", `{"command":"echo"}`))
+	if got := len(c.todoFile.GetBlocking()); got != 2 {
+		t.Fatalf("%d todos open after an unrelated command, want 2", got)
+	}
+	c.executeTool(toolCallOf("run_command", `{"command":"echo ok"}`))
+	if got := c.todoFile.GetBlocking(); len(got) != 1 || got[0].Command != "echo ok && echo again" {
+		t.Errorf("todos open = %v, want only the re-run", got)
+	}
+}
+
+func TestTodoIndex(t *testing.T) {
+	c := newTestChat(t, &config.Config{})
+	c.todoFile.AddTodo("third")
+	c.todoFile.AddTodo("second")
+	c.todoFile.AddTodo("first")
+
+	tests := []struct {
+		arg   string
+		index int
+		ok    bool
+	}{
+		{"1", 0, true},
+		{"3", 2, true},
+		{"0", 0, false},
+		{"4", 0, false},
+		{"-1", 0, false},
+		{"two", 0, false},
+	}
+	for _, tt := range tests {
+		index, ok := c.todoIndex(tt.arg)
+		if ok != tt.ok || (ok && index != tt.index) {
+			t.Errorf("todoIndex(%q) = %d, %v, want %d, %v", tt.arg, index, ok, tt.index, tt.ok)
+		}
+	}
+}
+
+func TestTodosDoneAndRemove(t *testing.T) {
+	c := newTestChat(t, &config.Config{})
+	c.todoFile.AddTodo("third")
+	c.todoFile.AddTodo("second")
+	c.todoFile.AddTodo("first")
+
+	c.handleTodosCommand([]string{"done", "2"})
+	c.handleTodosCommand([]string{"rm", "1"})
+	c.handleTodosCommand([]string{"done", "9"}) // out of range, ignored
+	c.handleTodosCommand([]string{"rm"})        // usage, ignored
+
+	var got []string
+	for _, item := range session.NewTodoFile(c.exec.WorkDir()).GetAll() {
+		got = append(got, item.Content+":"+item.Status)
+	}
+	if want := "third:pending second:completed"; strings.Join(got, " ") != want {
+		t.Errorf("saved todos = %q, want %q", got, want)
+	}
+
+	history, err := os.ReadFile(filepath.Join(c.exec.WorkDir(), "HISTORY.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(history), "second") || !strings.Contains(string(history), "completed") {
+		t.Errorf("HISTORY.md = %q, want the completed todo logged", history)
+	}
+	if strings.Contains(string(history), "first") {
+		t.Errorf("HISTORY.md = %q, removed todos should not be logged as completed", history)
+	}
+}
+
+func TestGetTodoPromptOnlyBlocking(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(c *Chat)
+		want  string // "" means no prefix
+	}{
+		{"no todos", func(c *Chat) {}, ""},
+		{"regular todo", func(c *Chat) {
+			c.handleTodosCommand([]string{"add", "write", "the", "docs"})
+		}, ""},
+		{"blocking todo", func(c *Chat) {
+			c.pushTodo("Run: go mod tidy", "go mod tidy")
+		}, "Run: go mod tidy"},
+		{"regular todo added after a blocking one", func(c *Chat) {
+			c.pushTodo("Run: go mod tidy", "go mod tidy")
+			c.todoFile.AddTodo("write the docs")
+		}, "Run: go mod tidy"},
+		{"blocking todo completed", func(c *Chat) {
+			c.pushTodo("Run: go mod tidy", "go mod tidy")
+			c.todoFile.AddTodo("write the docs")
+			c.todoFile.CompleteCommand("go mod tidy")
+		}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestChat(t, &config.Config{})
+			tt.setup(c)
+			got := c.getTodoPrompt()
+			if tt.want == "" && got != "" {
+				t.Errorf("getTodoPrompt() = %q, want no prefix", got)
+			}
+			if tt.want != "" && (!strings.Contains(got, "BLOCKING TODO") || !strings.Contains(got, tt.want)) {
+				t.Errorf("getTodoPrompt() = %q, want a blocking prefix for %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegularTodoNoNudge(t *testing.T) {
+	c := newTestChat(t, &config.Config{UserInterrupts: true})
+	c.autoExec = true
+	c.todoFile.AddTodo("Run: echo later")
+
+	c.executeTool(toolCallOf("run_command", `{"command":"true"}`))
+	if !c.client.IsNewConversation() {
+		t.Error("a regular todo triggered a user-interrupt nudge")
+	}
+
+	c.pushTodo("Then re-run: echo fixed", "echo fixed")
+	c.executeTool(toolCallOf("run_command", `{"command":"true"}`))
+	if c.client.IsNewConversation() {
+		t.Error("a blocking todo did not trigger a nudge")
+	}
+}
+
+func TestSendMessageBlockingTodoPrefix(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(c *Chat)
+		want  bool
+	}{
+		{"no todos", func(c *Chat) {}, false},
+		{"regular todo", func(c *Chat) { c.todoFile.AddTodo("write the docs") }, false},
+		{"blocking todo", func(c *Chat) { c.pushTodo("Run: go mod tidy", "go mod tidy") }, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sent string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var req struct {
+					Messages []struct {
+						Role    string `json:"role"`
+						Content string `json:"content"`
+					} `json:"messages"`
+				}
+				json.NewDecoder(r.Body).Decode(&req)
+				if n := len(req.Messages); n > 0 {
+					sent = req.Messages[n-1].Content
+				}
+				w.Header().Set("Content-Type", "text/event-stream")
+				fmt.Fprint(w, "data: "+`{"choices":[{"delta":{"content":"ok"},"finish_reason":"stop"}]}`+"\n\ndata: [DONE]\n\n")
+			}))
+			defer srv.Close()
+
+			c := newTestChat(t, &config.Config{APIEndpoint: srv.URL + "/v1", Model: "test", NoUpdateCheck: true})
+			tt.setup(c)
+			if err := c.RunSingle("add a flag"); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasSuffix(sent, "add a flag") {
+				t.Fatalf("sent %q, want the user message last", sent)
+			}
+			if got := strings.Contains(sent, "BLOCKING TODO") && strings.Contains(sent, "Run: go mod tidy"); got != tt.want {
+				t.Errorf("sent %q; blocking prefix = %v, want %v", sent, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandPrompt(t *testing.T) {
+	tests := []struct {
+		tmpl      string
+		want      string
+		wantLooks int // Git status lookups
+	}{
+		{"{cyan}>>> {reset}", "\033[36m>>> \033[0m", 0},
+		{"{model}> ", "qwen3> ", 0},
+		{"{dir} {model} $ ", "myproj qwen3 $ ", 0},
+		{"{green}{model}{reset}> ", "\033[32mqwen3\033[0m> ", 0},
+		{"{gray}{red}{yellow}{cyan}", "\033[90m\033[31m\033[33m\033[36m", 0},
+		{"({branch}) ", "(main) ", 1},
+		{"{unknown} {model}", "{unknown} qwen3", 0},
+		{"plain> ", "plain> ", 0},
+	}
+	for _, tt := range tests {
+		looks := 0
+		got := expandPrompt(tt.tmpl, "qwen3", "/src/myproj", func() (string, bool) {
+			looks++
+			return "main", false
+		})
+		if got != tt.want {
+			t.Errorf("expandPrompt(%q) = %q, want %q", tt.tmpl, got, tt.want)
+		}
+		if looks != tt.wantLooks {
+			t.Errorf("expandPrompt(%q) looked up git status %d times, want %d", tt.tmpl, looks, tt.wantLooks)
+		}
+	}
+}
+
+func TestGitPromptStatus(t *testing.T) {
+	tests := []struct {
+		branch string
+		dirty  bool
+		want   string
+	}{
+		{"", false, ""},
+		{"", true, ""},
+		{"main", false, "\033[90m[main]\033[0m "},
+		{"feature/x", true, "\033[90m[feature/x*]\033[0m "},
+	}
+	for _, tt := range tests {
+		if got := gitPromptStatus(tt.branch, tt.dirty); got != tt.want {
+			t.Errorf("gitPromptStatus(%q, %v) = %q, want %q", tt.branch, tt.dirty, got, tt.want)
+		}
+		got := expandPrompt("{branch}{dirty}|{git}", "m", "/p", func() (string, bool) { return tt.branch, tt.dirty })
+		mark := ""
+		if tt.dirty {
+			mark = "*"
+		}
+		if want := tt.branch + mark + "|" + tt.want; got != want {
+			t.Errorf("expandPrompt for %q dirty=%v = %q, want %q", tt.branch, tt.dirty, got, want)
+		}
+	}
+}
+
+func TestPromptGitStatusCached(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	c := newTestChat(t, &config.Config{Prompt: "{git}> "})
+	if got := c.renderPrompt(); got != "> " {
+		t.Errorf("outside a repository: prompt = %q, want no git status", got)
+	}
+
+	dir := c.exec.WorkDir()
+	git := func(args ...string) {
+		t.Helper()
+		args = append([]string{"-C", dir, "-c", "user.name=Tester", "-c", "user.email=t@example.com"}, args...)
+		if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, out)
+		}
+	}
+	git("init", "-q", "-b", "main")
+	os.WriteFile(filepath.Join(dir, "a.go"), []byte("package a\n"), 0644)
+	git("add", "-A") // including the files the chat created
+	git("commit", "-q", "-m", "init")
+
+	if got := c.renderPrompt(); got != "> " {
+		t.Errorf("prompt = %q, want the cached status until a refresh", got)
+	}
+	c.gitFresh = false
+	if got := c.renderPrompt(); got != "\033[90m[main]\033[0m > " {
+		t.Errorf("clean tree: prompt = %q", got)
+	}
+
+	os.WriteFile(filepath.Join(dir, "a.go"), []byte("package a // edited\n"), 0644)
+	c.processInput("/cd .") // a command that may change the tree refreshes it
+	if got := c.renderPrompt(); got != "\033[90m[main*]\033[0m > " {
+		t.Errorf("dirty tree: prompt = %q", got)
+	}
+}
+
+func TestAlternativeChoice(t *testing.T) {
+	tests := []struct {
+		line string
+		want int
+	}{
+		{"", 1},
+		{"2", 2},
+		{" 3 ", 3},
+		{"4", 1},
+		{"0", 1},
+		{"second", 1},
+	}
+	for _, tt := range tests {
+		if got := alternativeChoice(tt.line, 3); got != tt.want {
+			t.Errorf("alternativeChoice(%q, 3) = %d, want %d", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestToolResultCappedInHistoryOnly(t *testing.T) {
+	var toolMessage string
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		var req struct {
+			Messages []struct {
+				Role    string `json:"role"`
+				Content string `json:"content"`
+			} `json:"messages"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		for _, m := range req.Messages {
+			if m.Role == "tool" {
+				toolMessage = m.Content
+			}
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		if requests > 1 {
+			fmt.Fprint(w, "data: "+`{"choices":[{"delta":{"content":"done"},"finish_reason":"stop"}]}`+"\n\ndata: [DONE]\n\n")
+			return
+		}
+		call := `{"index":0,"id":"call_1","type":"function","function":{"name":"read_file","arguments":"{\"path\":\"big.txt\"}"}}`
+		fmt.Fprint(w, "data: "+`{"choices":[{"delta":{"tool_calls":[`+call+`]}}]}`+"\n\n"+
+			"data: "+`{"choices":[{"delta":{},"finish_reason":"tool_calls"}]}`+"\n\ndata: [DONE]\n\n")
+	}))
+	defer srv.Close()
+
+	c := newTestChat(t, &config.Config{APIEndpoint: srv.URL + "/v1", Model: "test", NoUpdateCheck: true, MaxToolResult: 500})
+	big := strings.Repeat("0123456789\n", 400) + "THE END"
+	os.WriteFile("big.txt", []byte(big), 0644)
+	if err := c.RunSingle("read big.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(toolMessage, "characters omitted") || len(toolMessage) > 600 || !strings.Contains(toolMessage, "THE END") {
+		t.Errorf("tool message sent to the model (%d characters) = %q, want the capped head and tail", len(toolMessage), truncate(toolMessage, 200))
+	}
+	s, err := session.LoadSession(c.recorder.SessionPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorded := ""
+	for _, e := range s.Entries {
+		if e.Type == "tool_result" {
+			recorded = e.Content
+		}
+	}
+	if !strings.Contains(recorded, big) || strings.Contains(recorded, "characters omitted") {
+		t.Errorf("recorded tool result has %d characters, want the full %d", len(recorded), len(big))
+	}
+}
+
+func TestConfirmToolReadOnlyClassification(t *testing.T) {
+	for _, tool := range tools.GetTools() {
+		name := tool.Function.Name
+		// Permissions, a batch denial and no terminal would all refuse a
+		// mutating tool; read-only tools never get that far
+		c := &Chat{cfg: &config.Config{ToolPermissions: map[string]string{name: config.PermissionNever}}, batchDecision: batchDenied}
+		if got := c.confirmTool(name, "run it?"); got != tools.IsReadOnly(name) {
+			t.Errorf("%s with permission never: confirmTool = %v, want %v", name, got, tools.IsReadOnly(name))
+		}
+		if tools.IsReadOnly(name) {
+			continue
+		}
+		c = &Chat{cfg: &config.Config{ToolPermissions: map[string]string{name: config.PermissionAlways}}, batchDecision: batchDenied}
+		if !c.confirmTool(name, "run it?") {
+			t.Errorf("%s with permission always: confirmTool = false", name)
+		}
+	}
+}
+
+func TestNotifyOnCompletion(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: "+`{"choices":[{"delta":{"content":"All tests pass."},"finish_reason":"stop"}]}`+"\n\ndata: [DONE]\n\n")
+	}))
+	defer srv.Close()
+
+	var posted completionSummary
+	var contentType string
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType = r.Header.Get("Content-Type")
+		json.NewDecoder(r.Body).Decode(&posted)
+	}))
+	defer hook.Close()
+
+	c := newTestChat(t, &config.Config{
+		APIEndpoint:   srv.URL + "/v1",
+		Model:         "test",
+		NoUpdateCheck: true,
+		NotifyCommand: `cat > notify.json; printf '%s|%s' "$AICLI_STATUS" "$AICLI_SUMMARY" > notify.env`,
+		NotifyWebhook: hook.URL,
+	})
+	if err := c.RunSingle("run the tests"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := completionSummary{
+		Event:   "session_complete",
+		Status:  "completed",
+		Project: c.exec.WorkDir(),
+		Model:   "test",
+		Summary: "All tests pass.",
+		Session: c.recorder.SessionPath(),
+	}
+	check := func(source string, got completionSummary) {
+		t.Helper()
+		if got.Timestamp.IsZero() {
+			t.Errorf("%s: no timestamp", source)
+		}
+		got.Timestamp = time.Time{}
+		if got != want {
+			t.Errorf("%s payload = %+v, want %+v", source, got, want)
+		}
+	}
+	check("webhook", posted)
+	if contentType != "application/json" {
+		t.Errorf("webhook Content-Type = %q", contentType)
+	}
+
+	var fromCommand completionSummary
+	data, err := os.ReadFile("notify.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &fromCommand); err != nil {
+		t.Fatalf("notify command stdin %q: %v", data, err)
+	}
+	check("command", fromCommand)
+	if env, _ := os.ReadFile("notify.env"); string(env) != "completed|All tests pass." {
+		t.Errorf("notify command environment = %q", env)
+	}
+}
+
+func TestMemoryCommand(t *testing.T) {
+	c := newTestChat(t, &config.Config{NoUpdateCheck: true})
+	entries := func() []string { return session.NewMemoryFile(c.exec.WorkDir()).Entries() }
+
+	c.handleCommand("/memory add we use pnpm, not npm")
+	c.handleCommand("/memory add API base is /v2")
+	if got := entries(); len(got) != 2 || got[0] != "we use pnpm, not npm" || got[1] != "API base is /v2" {
+		t.Fatalf("after add, memory = %q", got)
+	}
+
+	c.handleCommand("/memory rm 1")
+	c.handleCommand("/memory rm 5")
+	if got := entries(); len(got) != 1 || got[0] != "API base is /v2" {
+		t.Errorf("after rm, memory = %q", got)
+	}
+	if todos := c.todoFile.GetAll(); len(todos) != 0 {
+		t.Errorf("memory leaked into todos: %v", todos)
+	}
+}
+
+func TestNewWebSearchUsesConfig(t *testing.T) {
+	var got http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		fmt.Fprint(w, "ok")
+	}))
+	defer srv.Close()
+
+	cfg := &config.Config{WebUserAgent: "mybot/1.0", WebHeaders: map[string]string{"Accept-Language": "de-DE"}}
+	if _, err := newWebSearch(cfg).FetchPage(srv.URL); err != nil {
+		t.Fatal(err)
+	}
+	if got.Get("User-Agent") != "mybot/1.0" || got.Get("Accept-Language") != "de-DE" {
+		t.Errorf("request headers = %v", got)
+	}
+}
+
+func TestFetchURLFormat(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<p>See <a href="/next">next</a>.</p>`)
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name          string
+		fetchMarkdown bool
+		format        string
+		wantLink      bool
+	}{
+		{"default text", false, "", false},
+		{"format arg", false, "markdown", true},
+		{"fetch_markdown config", true, "", true},
+		{"format arg overrides config", true, "text", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Chat{cfg: &config.Config{FetchMarkdown: tt.fetchMarkdown}, web: web.NewSearch()}
+			args, _ := json.Marshal(map[string]string{"url": srv.URL, "format": tt.format})
+			got := c.executeTool(toolCallOf("fetch_url", string(args)))
+			if gotLink := strings.Contains(got, "[next]("+srv.URL+"/next)"); gotLink != tt.wantLink {
+				t.Errorf("link kept = %v, want %v:\n%s", gotLink, tt.wantLink, got)
+			}
+		})
+	}
+}
+
+func TestImageCommandResolvesInProject(t *testing.T) {
+	c := newTestChat(t, &config.Config{NoUpdateCheck: true})
+	if err := os.WriteFile(filepath.Join(c.exec.WorkDir(), "shot.png"), []byte("\x89PNG\r\n\x1a\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(t.TempDir())
+
+	c.handleCommand("/image shot.png")
+	c.handleCommand("/image missing.png")
+	if got := c.client.PendingImages(); got != 1 {
+		t.Errorf("PendingImages = %d, want 1", got)
+	}
+}
+
+func TestExecuteToolTruncatedArgs(t *testing.T) {
+	c := newTestChat(t, &config.Config{NoUpdateCheck: true})
+	c.autoExec = true
+	os.WriteFile("notes.txt", []byte("remember the milk"), 0644)
+
+	// A repaired write would save a cut-off file, so it is refused
+	write := toolCallOf("write_file", `{"path":"main.go","content":"package ma"}`)
+	write.Repaired = true
+	if got := c.executeTool(write); !strings.Contains(got, "ARGUMENTS TRUNCATED for write_file") {
+		t.Errorf("repaired write_file = %q", got)
+	}
+	if _, err := os.Stat("main.go"); err == nil {
+		t.Error("write_file ran with repaired arguments")
+	}
+
+	// A repaired lookup is safe to run
+	read := toolCallOf("read_file", `{"path":"notes.txt"}`)
+	read.Repaired = true
+	if got := c.executeTool(read); !strings.Contains(got, "remember the milk") {
+		t.Errorf("repaired read_file = %q", got)
+	}
+
+	// Unrepaired cut-off arguments point at the truncation; other
+	// malformed arguments don't
+	got := c.executeTool(toolCallOf("write_file", `{"path":"main.go","content":tr`))
+	if !strings.Contains(got, "The tool was NOT run. The arguments were cut off") {
+		t.Errorf("cut-off arguments = %q", got)
+	}
+	got = c.executeTool(toolCallOf("write_file", `{"path" "main.go"}`))
+	if !strings.Contains(got, "The tool was NOT run") || strings.Contains(got, "were cut off") {
+		t.Errorf("malformed arguments = %q", got)
+	}
+}
+
+func TestRawCommandSendsTextVerbatim(t *testing.T) {
+	var messages []map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Messages []map[string]string `json:"messages"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		messages = req.Messages
+		fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"pong"}}]}`)
+	}))
+	defer srv.Close()
+
+	c := newTestChat(t, &config.Config{APIEndpoint: srv.URL + "/v1", Model: "test", SystemPrompt: "You are helpful.", NoUpdateCheck: true})
+	c.handleCommand("/raw   say  {\"hi\"} ")
+	if len(messages) != 1 || messages[0]["role"] != "user" || messages[0]["content"] != `say  {"hi"}` {
+		t.Errorf("messages sent = %v, want only the raw text", messages)
+	}
+	if !c.client.IsNewConversation() {
+		t.Error("/raw added to the conversation")
+	}
+}
+
+func TestProjectCommandUsesConfig(t *testing.T) {
+	c := newTestChat(t, &config.Config{NoUpdateCheck: true, Commands: map[string]string{"test": "echo configured-tests"}})
+	c.autoExec = true
+	os.WriteFile("go.mod", []byte("module x\n"), 0644)
+
+	if got := c.executeTool(toolCallOf("project_command", `{"action":"test"}`)); !strings.Contains(got, "configured-tests") {
+		t.Errorf("project_command test = %q, want the configured command's output", got)
+	}
+	command, source, err := c.projectCommand("build")
+	if err != nil || command != "go build ./..." || source != "go default" {
+		t.Errorf("projectCommand(build) = %q, %q, %v; want the Go default", command, source, err)
+	}
+}
+
+func TestLintTool(t *testing.T) {
+	tests := []struct {
+		name    string
+		command string
+		want    []string
+	}{
+		{"clean", "echo ok", []string{"LINT PASSED: echo ok reported no issues."}},
+		{"findings", `printf 'main.go:3:2: x declared and not used\nutil.go:9: missing return\n'; exit 1`,
+			[]string{"reported 2 issue(s):", "- main.go:3:2: x declared and not used", "- util.go:9: missing return"}},
+		{"failure without findings", "echo 'lint: command not found' >&2; exit 127", []string{"exited with code 127", "command not found"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestChat(t, &config.Config{NoUpdateCheck: true, Commands: map[string]string{"lint": tt.command}})
+			c.autoExec = true
+			got := c.executeTool(toolCallOf("lint", `{}`))
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("lint result lacks %q:\n%s", want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestGetFixCommandNames(t *testing.T) {
+	tests := []struct {
+		name, output, remote, want string
+	}{
+		{"go module from remote", "go: go.mod file not found in current directory or any parent directory", "https://github.com/glenn/widget.git", "go mod init github.com/glenn/widget"},
+		{"go module from directory", "go: go.mod file not found in current directory or any parent directory", "", "go mod init my-widget"},
+		{"poetry project", "Poetry could not find a pyproject.toml file in /src/My Widget or its parents", "", "poetry init -n --name my-widget"},
+		{"npm project", "npm ERR! enoent ENOENT: no such file or directory, open '/src/My Widget/package.json'", "", "npm init -y"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, concrete := getFixCommand(tt.output, "/src/My Widget", tt.remote)
+			if got != tt.want || !concrete {
+				t.Errorf("getFixCommand = %q, %v; want %q", got, concrete, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommandCircuitBreaker(t *testing.T) {
+	c := newTestChat(t, &config.Config{NoUpdateCheck: true})
+	c.autoExec = true
+	run := func(cmd string) string {
+		return c.executeTool(toolCallOf("run_command", fmt.Sprintf(`{"command":%q}`, cmd)))
+	}
+	runs := func() int {
+		data, _ := os.ReadFile("runs.txt")
+		return strings.Count(string(data), "\n")
+	}
+	failing := "echo run >> runs.txt; echo 'make: *** [all] Error 2' >&2; exit 2"
+
+	for i := 1; i < maxCommandFailures; i++ {
+		if got := run(failing); strings.Contains(got, "a different approach is needed") {
+			t.Fatalf("failure %d tripped the breaker early: %q", i, got)
+		}
+	}
+	got := run(failing)
+	if !strings.Contains(got, fmt.Sprintf("This command has failed %d times; a different approach is needed", maxCommandFailures)) {
+		t.Fatalf("failure %d = %q, want the breaker to trip", maxCommandFailures, got)
+	}
+	if pending := c.todoFile.GetBlocking(); len(pending) != 0 {
+		t.Errorf("re-run todos after tripping = %v, want none", pending)
+	}
+
+	// Tripped: the command is refused without running, other commands aren't
+	if got := run(failing); !strings.HasPrefix(got, "NOT RUN:") || runs() != maxCommandFailures {
+		t.Errorf("after tripping: %q, %d runs; want NOT RUN and %d runs", got, runs(), maxCommandFailures)
+	}
+	if got := run("echo other"); strings.HasPrefix(got, "NOT RUN:") {
+		t.Errorf("a different command was refused: %q", got)
+	}
+
+	// Changing a file resets the breaker
+	c.handleWriteFile("fix.txt", "fixed\n", "file")
+	if run(failing); runs() != maxCommandFailures+1 {
+		t.Errorf("after a file change the command ran %d times, want %d", runs(), maxCommandFailures+1)
+	}
+
+	// A success clears the count for that command
+	c.cmdFailures = map[string]int{"true": maxCommandFailures - 1}
+	run("true")
+	if n := c.cmdFailures["true"]; n != 0 {
+		t.Errorf("failures after a success = %d, want 0", n)
+	}
+}
+
+func TestTruncationNote(t *testing.T) {
+	tests := []struct {
+		name      string
+		result    *client.ChatResult
+		maxTokens int
+		want      string
+	}{
+		{"finished", &client.ChatResult{FinishReason: "stop"}, 4096, ""},
+		{"tool calls", &client.ChatResult{FinishReason: "tool_calls"}, 4096, ""},
+		{"no result", nil, 4096, ""},
+		{"cut at a known limit", &client.ChatResult{FinishReason: "length"}, 4096,
+			`[truncated] The response hit the 4096 token limit. Type "continue" to have the model pick up where it stopped.`},
+		{"cut without a limit", &client.ChatResult{FinishReason: "length"}, 0,
+			`[truncated] The response hit the response token limit. Type "continue" to have the model pick up where it stopped.`},
+	}
+	for _, tt := range tests {
+		if got := truncationNote(tt.result, tt.maxTokens); got != tt.want {
+			t.Errorf("%s: truncationNote = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestThinkCommand(t *testing.T) {
+	c := newTestChat(t, &config.Config{NoUpdateCheck: true})
+	steps := []struct {
+		cmd, want string
+	}{
+		{"/think HIGH", "high"},
+		{"/think extreme", "high"},
+		{"/think", "high"},
+		{"/think low", "low"},
+		{"/think default", ""},
+	}
+	for _, step := range steps {
+		c.handleCommand(step.cmd)
+		if got := c.cfg.GetReasoningEffort(); got != step.want {
+			t.Errorf("after %q effort = %q, want %q", step.cmd, got, step.want)
+		}
+	}
+}
+
+// scriptConfirmations replaces c's readline with one that reads answers,
+// one per line
+func scriptConfirmations(t *testing.T, c *Chat, answers ...string) {
+	t.Helper()
+	c.closeReadline()
+	rl, err := readline.NewEx(&readline.Config{
+		Stdin:          io.NopCloser(strings.NewReader(strings.Join(answers, "\n") + "\n")),
+		Stdout:         io.Discard,
+		Stderr:         io.Discard,
+		FuncIsTerminal: func() bool { return false },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { rl.Close() })
+	c.rl = rl
+}
+
+func TestWriteFileHunkSelection(t *testing.T) {
+	// Three changes far enough apart to be separate hunks
+	var oldLines, newLines []string
+	for i := 1; i <= 30; i++ {
+		line := fmt.Sprintf("line %d", i)
+		oldLines = append(oldLines, line)
+		if i == 2 || i == 15 || i == 28 {
+			line = fmt.Sprintf("LINE %d", i)
+		}
+		newLines = append(newLines, line)
+	}
+	old := strings.Join(oldLines, "\n") + "\n"
+	content := strings.Join(newLines, "\n") + "\n"
+	if n := len(diff.Hunks(old, content, 3)); n != 3 {
+		t.Fatalf("fixture has %d hunks, want 3", n)
+	}
+
+	tests := []struct {
+		name       string
+		answers    []string
+		wantLines  []int // Lines changed in the written file
+		wantResult string
+	}{
+		{"all", []string{"y"}, []int{2, 15, 28}, "Successfully wrote"},
+		{"none", []string{"n"}, nil, "OPERATION FAILED: User declined"},
+		{"select some", []string{"s", "y", "n", "y"}, []int{2, 28}, "PARTIALLY APPLIED: the user accepted hunks 1, 3 of 3 and rejected 2 (-12,7 +12,7 @@)"},
+		{"select one", []string{"s", "n", "yes", "n"}, []int{15}, "PARTIALLY APPLIED: the user accepted hunks 2 of 3"},
+		{"select all", []string{"s", "y", "y", "y"}, []int{2, 15, 28}, "Successfully wrote"},
+		{"select none", []string{"s", "n", "n", "n"}, nil, "OPERATION FAILED: User rejected every change"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestChat(t, &config.Config{NoUpdateCheck: true})
+			os.WriteFile("notes.txt", []byte(old), 0644)
+			scriptConfirmations(t, c, tt.answers...)
+
+			got := c.handleWriteFile("notes.txt", content, "file")
+			if !strings.Contains(got, tt.wantResult) {
+				t.Errorf("result = %q, want %q", got, tt.wantResult)
+			}
+			if tt.wantResult == "Successfully wrote" && strings.Contains(got, "PARTIALLY") {
+				t.Errorf("full write reported as partial: %q", got)
+			}
+
+			want := append([]string(nil), oldLines...)
+			for _, n := range tt.wantLines {
+				want[n-1] = fmt.Sprintf("LINE %d", n)
+			}
+			data, _ := os.ReadFile("notes.txt")
+			if string(data) != strings.Join(want, "\n")+"\n" {
+				t.Errorf("file has changes other than lines %v:\n%s", tt.wantLines, data)
+			}
+		})
+	}
+}
+
+func TestPlanRegenCommand(t *testing.T) {
+	var prompt string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Messages []struct {
+				Content string `json:"content"`
+			} `json:"messages"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		prompt = req.Messages[len(req.Messages)-1].Content
+		planJSON := `{"analysis":"updated","steps":[{"title":"Port the client","description":"use v2","model_tier":"standard"}]}`
+		reply, _ := json.Marshal(map[string]interface{}{
+			"choices": []interface{}{map[string]interface{}{
+				"message":       map[string]string{"role": "assistant", "content": planJSON},
+				"finish_reason": "stop",
+			}},
+		})
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(reply)
+	}))
+	defer srv.Close()
+
+	c := newTestChat(t, &config.Config{APIEndpoint: srv.URL + "/v1", Model: "test", NoUpdateCheck: true})
+	p := plan.New("ship v2", "original")
+	for _, title := range []string{"Scaffold", "Write the client", "Document"} {
+		p.AddStep(title, "", plan.TierStandard, nil)
+	}
+	p.Steps[0].Status = "completed"
+	p.Steps[1].Status = "failed"
+	if err := p.Save(c.exec.WorkDir()); err != nil {
+		t.Fatal(err)
+	}
+
+	c.handleCommand("/plan-regen")
+	if !strings.Contains(prompt, "- Scaffold\n") || !strings.Contains(prompt, "- [failed] Write the client") {
+		t.Errorf("regen prompt lacks the plan's state:\n%s", prompt)
+	}
+	got, err := plan.Load(c.exec.WorkDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var steps []string
+	for _, s := range got.Steps {
+		steps = append(steps, s.Title+":"+s.Status)
+	}
+	if strings.Join(steps, " ") != "Scaffold:completed Port the client:pending" {
+		t.Errorf("saved steps = %v", steps)
+	}
+}
+
+func TestSessionsExport(t *testing.T) {
+	c := newTestChat(t, &config.Config{NoUpdateCheck: true})
+	c.recorder.RecordUser("hello")
+	c.recorder.RecordAssistant("hi")
+
+	c.handleCommand("/sessions export")
+	name := strings.TrimSuffix(filepath.Base(c.recorder.SessionPath()), ".jsonl") + ".json"
+	data, err := os.ReadFile(filepath.Join(c.exec.WorkDir(), ".aicli", "exports", name))
+	if err != nil {
+		t.Fatalf("current session not exported: %v", err)
+	}
+	var s session.Session
+	if err := json.Unmarshal(data, &s); err != nil {
+		t.Fatalf("export is not a single JSON document: %v", err)
+	}
+	if len(s.Entries) != 2 || s.Entries[1].Content != "hi" {
+		t.Errorf("exported entries = %+v", s.Entries)
+	}
+}
+
+func TestTreeTool(t *testing.T) {
+	c := newTestChat(t, &config.Config{NoUpdateCheck: true})
+	os.MkdirAll("pkg/sub", 0755)
+	os.WriteFile("pkg/sub/a.go", []byte("package sub"), 0644)
+
+	if got, want := c.executeTool(toolCallOf("tree", `{"path":"pkg","depth":1}`)), "pkg/\n└── sub/\n"; got != want {
+		t.Errorf("tree depth 1 = %q, want %q", got, want)
+	}
+	if got := c.executeTool(toolCallOf("tree", `{"path":"pkg"}`)); !strings.Contains(got, "a.go") {
+		t.Errorf("tree with default depth = %q, want a.go listed", got)
+	}
+	if got := c.executeTool(toolCallOf("tree", `{"path":"nope"}`)); !strings.HasPrefix(got, "Error:") {
+		t.Errorf("tree of a missing path = %q, want an error", got)
+	}
+}
+
+func TestLanguageDetectionInvalidated(t *testing.T) {
+	c := newTestChat(t, &config.Config{NoUpdateCheck: true})
+	c.autoExec = true
+	dir := c.exec.WorkDir()
+	detected := func(dir string) []lang.Language { return lang.ProjectLanguages(dir) }
+
+	if got := detected(dir); len(got) != 0 {
+		t.Fatalf("empty project detected as %v", got)
+	}
+	os.WriteFile("go.mod", []byte("module demo\n"), 0644)
+	c.handleCommand("/languages redetect")
+	if got := detected(dir); len(got) != 1 || got[0] != lang.LangGo {
+		t.Errorf("after /languages redetect = %v, want [go]", got)
+	}
+
+	// A tool that may change the project drops the detection; reads don't
+	os.WriteFile("Cargo.toml", nil, 0644)
+	for _, tc := range []tools.ToolCall{toolCallOf("read_file", `{"path":"go.mod"}`), toolCallOf("list_files", `{"pattern":"*"}`)} {
+		c.recordToolResult(tc, c.executeTool(tc), 0)
+	}
+	if got := detected(dir); len(got) != 1 {
+		t.Errorf("after read-only tools = %v, want the cached [go]", got)
+	}
+	tc := toolCallOf("write_file", `{"path":"notes.txt","content":"x"}`)
+	c.recordToolResult(tc, c.executeTool(tc), 0)
+	if got := detected(dir); len(got) != 2 {
+		t.Errorf("after write_file = %v, want go and rust", got)
+	}
+
+	// /cd re-detects the directory it changes to
+	os.Mkdir("web", 0755)
+	sub := filepath.Join(dir, "web")
+	detected(sub)
+	os.WriteFile(filepath.Join(sub, "package.json"), []byte("{}"), 0644)
+	c.handleCommand("/cd web")
+	if got := detected(sub); len(got) != 1 || got[0] != lang.LangNode {
+		t.Errorf("after /cd = %v, want [node]", got)
+	}
+}
+
+func TestResetCommand(t *testing.T) {
+	// populate gives every reset target something to clear, with a
+	// released changelog section that must survive
+	populate := func(t *testing.T, c *Chat) {
+		t.Helper()
+		c.history.AddRequest("add a login page")
+		c.history.AddChange("added login", []string{"login.go"})
+		c.todoFile.AddTodo("write tests")
+		c.changelog.AddEntry("added", "first feature", nil)
+		c.changelog.Release("1.0.0")
+		c.changelog.AddEntry("fixed", "a bug", nil)
+		debugDir := filepath.Join(c.exec.WorkDir(), ".aicli", "debug")
+		os.MkdirAll(debugDir, 0755)
+		os.WriteFile(filepath.Join(debugDir, "request_1.json"), []byte("{}"), 0644)
+		os.WriteFile(filepath.Join(debugDir, "notes.txt"), []byte("keep"), 0644)
+		if err := plan.New("ship it", "").Save(c.exec.WorkDir()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	debugLogs := func(c *Chat) int {
+		matches, _ := filepath.Glob(filepath.Join(c.exec.WorkDir(), ".aicli", "debug", "*.json"))
+		return len(matches)
+	}
+	cleared := func(c *Chat) map[string]bool {
+		unreleased := 0
+		for _, e := range c.changelog.GetRecent(10) {
+			if e.Description == "a bug" {
+				unreleased++
+			}
+		}
+		return map[string]bool{
+			"history":   c.history.Len() == 0,
+			"todos":     len(c.todoFile.GetAll()) == 0,
+			"changelog": unreleased == 0,
+			"debug":     debugLogs(c) == 0,
+			"plan":      !plan.Exists(c.exec.WorkDir()),
+		}
+	}
+
+	tests := []struct {
+		command string
+		answer  string
+		want    []string
+	}{
+		{"/reset", "y", resetTargets},
+		{"/reset todos", "y", []string{"todos"}},
+		{"/reset history plan", "y", []string{"history", "plan"}},
+		{"/reset changelog", "y", []string{"changelog"}},
+		{"/reset debug debug", "y", []string{"debug"}},
+		{"/reset", "n", nil},
+		{"/reset todos bogus", "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.command+" "+tt.answer, func(t *testing.T) {
+			c := newTestChat(t, &config.Config{NoUpdateCheck: true})
+			populate(t, c)
+			scriptConfirmations(t, c, tt.answer)
+
+			c.handleCommand(tt.command)
+			got := cleared(c)
+			for _, target := range resetTargets {
+				if want := slices.Contains(tt.want, target); got[target] != want {
+					t.Errorf("%s cleared = %v, want %v", target, got[target], want)
+				}
+			}
+			if _, err := os.Stat(filepath.Join(c.exec.WorkDir(), ".aicli", "debug", "notes.txt")); err != nil {
+				t.Errorf("non-log debug file removed: %v", err)
+			}
+			if !slices.ContainsFunc(c.changelog.GetRecent(10), func(e session.ChangelogEntry) bool { return e.Description == "first feature" }) {
+				t.Error("released changelog entry removed")
+			}
+		})
+	}
+}
+
+func TestTrackedCommandChangelog(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	c := newTestChat(t, &config.Config{NoUpdateCheck: true})
+	c.autoExec = true
+	dir := c.exec.WorkDir()
+	git := func(args ...string) {
+		t.Helper()
+		args = append([]string{"-C", dir, "-c", "user.name=Tester", "-c", "user.email=t@example.com"}, args...)
+		if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, out)
+		}
+	}
+	git("init", "-q", "-b", "main")
+	os.WriteFile(filepath.Join(dir, "a.go"), []byte("package a\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "b.go"), []byte("package a\n"), 0644)
+	git("add", "-A")
+	git("commit", "-q", "-m", "init")
+
+	changed := func() []session.ChangelogEntry {
+		var entries []session.ChangelogEntry
+		for _, e := range c.changelog.GetRecent(10) {
+			if e.Type == "Changed" {
+				entries = append(entries, e)
+			}
+		}
+		return entries
+	}
+
+	c.executeTool(toolCallOf("run_command_tracked", `{"command":"cat a.go"}`))
+	if got := changed(); len(got) != 0 {
+		t.Fatalf("read-only command logged %+v", got)
+	}
+
+	result := c.executeTool(toolCallOf("run_command_tracked", `{"command":"echo '// edited' >> a.go && rm b.go && touch c.go"}`))
+	if !strings.Contains(result, "Files changed by the command (3)") {
+		t.Errorf("result = %q", result)
+	}
+	got := changed()
+	if len(got) != 1 {
+		t.Fatalf("changelog has %d Changed entries, want 1: %+v", len(got), got)
+	}
+	if got[0].Description != "Ran `echo '// edited' >> a.go && rm b.go && touch c.go`" {
+		t.Errorf("description = %q", got[0].Description)
+	}
+	if files := strings.Join(got[0].Files, " "); files != "a.go b.go c.go" {
+		t.Errorf("files = %q, want a.go b.go c.go", files)
+	}
+	if recent := c.history.GetRecent(1); len(recent) != 1 || !strings.Contains(recent[0].Description, "Ran `echo") {
+		t.Errorf("history = %+v, want the change recorded", recent)
+	}
+}
+
+func TestHistorySearchResend(t *testing.T) {
+	tests := []struct {
+		answer string
+		want   string
+	}{
+		{"2", "run the unit tests"},
+		{"1", "run the linter"},
+		{"", ""},
+		{"3", ""},
+		{"first", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.answer, func(t *testing.T) {
+			c := newTestChat(t, &config.Config{NoUpdateCheck: true})
+			session.NewRecorder(c.exec.WorkDir()).RecordUser("run the unit tests")
+			time.Sleep(10 * time.Millisecond)
+			rec := session.NewRecorder(c.exec.WorkDir())
+			rec.RecordUser("run the linter")
+			rec.RecordUser("deploy it")
+			scriptConfirmations(t, c, tt.answer)
+
+			c.handleCommand("/history search run the")
+			if c.followUpInput != tt.want {
+				t.Errorf("follow-up input = %q, want %q", c.followUpInput, tt.want)
+			}
+		})
+	}
+}
+
+func TestEditorCommand(t *testing.T) {
+	tests := []struct {
+		visual, editor string
+		want           string
+	}{
+		{"", "", "vi s.jsonl"},
+		{"", "nano", "nano s.jsonl"},
+		{"code --wait", "nano", "code --wait s.jsonl"},
+		{"  ", "emacs -nw", "emacs -nw s.jsonl"},
+	}
+	for _, tt := range tests {
+		cmd := editorCommand(tt.visual, tt.editor, "s.jsonl")
+		if got := strings.Join(cmd.Args, " "); got != tt.want {
+			t.Errorf("editorCommand(%q, %q) = %q, want %q", tt.visual, tt.editor, got, tt.want)
+		}
+	}
+}
+
+func TestEditSessionCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script as the editor")
+	}
+	c := newTestChat(t, &config.Config{NoUpdateCheck: true})
+	dir := c.exec.WorkDir()
+	editor := filepath.Join(t.TempDir(), "editor")
+	opened := filepath.Join(t.TempDir(), "opened")
+	os.WriteFile(editor, []byte("#!/bin/sh\necho \"$@\" >> "+opened+"\n"), 0755)
+	t.Setenv("VISUAL", "")
+	t.Setenv("EDITOR", editor+" --wait")
+	lastOpened := func() string {
+		data, _ := os.ReadFile(opened)
+		lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+		return lines[len(lines)-1]
+	}
+
+	c.handleCommand("/edit-session")
+	if _, err := os.Stat(opened); err == nil {
+		t.Fatal("editor launched for a session with nothing recorded")
+	}
+
+	c.recorder.RecordUser("hello")
+	c.handleCommand("/edit-session")
+	if got, want := lastOpened(), "--wait "+c.recorder.SessionPath(); got != want {
+		t.Errorf("editor opened %q, want %q", got, want)
+	}
+
+	os.WriteFile(filepath.Join(dir, ".aicli", "session_old.jsonl"), []byte("{}\n"), 0644)
+	c.handleCommand("/edit-session session_old.jsonl")
+	if got, want := lastOpened(), "--wait "+filepath.Join(dir, ".aicli", "session_old.jsonl"); got != want {
+		t.Errorf("editor opened %q, want %q", got, want)
+	}
+
+	c.handleCommand("/edit-session missing.jsonl")
+	if got := lastOpened(); strings.Contains(got, "missing.jsonl") {
+		t.Error("editor launched for a missing session file")
+	}
+}
+
+func TestPrintNotices(t *testing.T) {
+	c := newTestChat(t, &config.Config{NoUpdateCheck: true})
+	c.printNotices() // No notices set
+
+	notices := make(chan string, 2)
+	c.SetNotices(notices)
+	c.printNotices() // Nothing arrived yet: returns without waiting
+	if c.notices == nil {
+		t.Fatal("notices dropped before the check finished")
+	}
+
+	notices <- "update available"
+	close(notices)
+	c.printNotices()
+	if c.notices != nil {
+		t.Error("notices kept after the channel closed")
+	}
+}
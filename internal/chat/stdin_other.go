@@ -0,0 +1,20 @@
+//go:build !unix
+
+package chat
+
+import "os"
+
+// interruptibleStdin is not available on this platform: readline reads
+// the terminal itself and a timed-out confirmation falls back to feeding
+// it an empty line
+type interruptibleStdin struct{}
+
+func newInterruptibleStdin() *interruptibleStdin { return nil }
+
+func (s *interruptibleStdin) Read(p []byte) (int, error) { return os.Stdin.Read(p) }
+
+func (s *interruptibleStdin) Close() error { return nil }
+
+func (s *interruptibleStdin) interrupt() {}
+
+func (s *interruptibleStdin) drain() {}
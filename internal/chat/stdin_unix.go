@@ -0,0 +1,80 @@
+//go:build unix
+
+package chat
+
+import (
+	"os"
+
+	"github.com/chzyer/readline"
+	"golang.org/x/sys/unix"
+)
+
+// interruptibleStdin is the terminal input given to readline. A Readline
+// waiting on it can be stopped without consuming input: Read polls the
+// terminal together with a wake-up pipe and only reads once input is
+// there, so nothing is left blocked on the terminal to swallow the user's
+// next line.
+type interruptibleStdin struct {
+	in           *os.File
+	wakeR, wakeW *os.File
+}
+
+// newInterruptibleStdin wraps os.Stdin, or returns nil if it can't
+func newInterruptibleStdin() *interruptibleStdin {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil
+	}
+	return &interruptibleStdin{in: os.Stdin, wakeR: r, wakeW: w}
+}
+
+// Read waits for input or an interrupt. An interrupt reads as Ctrl-C, so
+// Readline returns readline.ErrInterrupt with the partial line discarded.
+func (s *interruptibleStdin) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	fds := []unix.PollFd{
+		{Fd: int32(s.in.Fd()), Events: unix.POLLIN},
+		{Fd: int32(s.wakeR.Fd()), Events: unix.POLLIN},
+	}
+	for {
+		if _, err := unix.Poll(fds, -1); err != nil {
+			if err == unix.EINTR {
+				continue
+			}
+			return 0, err
+		}
+		if fds[1].Revents&unix.POLLIN != 0 {
+			s.wakeR.Read(make([]byte, 1))
+			p[0] = readline.CharInterrupt
+			return 1, nil
+		}
+		if fds[0].Revents != 0 {
+			return s.in.Read(p)
+		}
+	}
+}
+
+// Close leaves the terminal open: it belongs to the process, not readline
+func (s *interruptibleStdin) Close() error {
+	return nil
+}
+
+// interrupt stops a pending Read
+func (s *interruptibleStdin) interrupt() {
+	s.wakeW.Write([]byte{0})
+}
+
+// drain discards an interrupt that arrived after the read it was meant
+// for had already finished, so it doesn't cancel the next prompt
+func (s *interruptibleStdin) drain() {
+	fds := []unix.PollFd{{Fd: int32(s.wakeR.Fd()), Events: unix.POLLIN}}
+	buf := make([]byte, 16)
+	for {
+		if n, err := unix.Poll(fds, 0); err != nil || n == 0 || fds[0].Revents&unix.POLLIN == 0 {
+			return
+		}
+		s.wakeR.Read(buf)
+	}
+}
@@ -0,0 +1,70 @@
+//go:build unix
+
+package chat
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/chzyer/readline"
+)
+
+func TestInterruptibleStdin(t *testing.T) {
+	in, out, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+	defer out.Close()
+	s := newInterruptibleStdin()
+	if s == nil {
+		t.Fatal("newInterruptibleStdin returned nil")
+	}
+	s.in = in
+
+	tests := []struct {
+		name    string
+		typed   string
+		wake    bool
+		wantBuf string
+	}{
+		{"input", "y\n", false, "y\n"},
+		{"interrupt while idle", "", true, string(rune(readline.CharInterrupt))},
+		{"interrupt wins over pending input", "n\n", true, string(rune(readline.CharInterrupt))},
+		{"pending input survives the interrupt", "", false, "n\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.typed != "" {
+				out.Write([]byte(tt.typed))
+			}
+			if tt.wake {
+				s.interrupt()
+			}
+			got := make(chan string, 1)
+			go func() {
+				buf := make([]byte, 16)
+				n, _ := s.Read(buf)
+				got <- string(buf[:n])
+			}()
+			select {
+			case g := <-got:
+				if g != tt.wantBuf {
+					t.Errorf("Read = %q, want %q", g, tt.wantBuf)
+				}
+			case <-time.After(2 * time.Second):
+				t.Fatal("Read did not return")
+			}
+		})
+	}
+
+	// A stale interrupt is dropped by drain
+	s.interrupt()
+	s.drain()
+	out.Write([]byte("x"))
+	buf := make([]byte, 4)
+	if n, _ := s.Read(buf); string(buf[:n]) != "x" {
+		t.Errorf("Read after drain = %q, want %q", buf[:n], "x")
+	}
+}
@@ -0,0 +1,95 @@
+package client
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"aicli/internal/session"
+)
+
+// responseCacheKey returns the content-addressed cache key for a request, or
+// "" if caching doesn't apply. Only deterministic (temperature 0) requests
+// are cached, and only when response_cache is enabled. The key covers the
+// endpoint, model, messages, tools and parameters, so any change misses.
+func (c *Client) responseCacheKey(req ChatRequest) string {
+	req.Stream = false // Streaming doesn't change the completion
+	return c.cacheKeyOf(req)
+}
+
+// ollamaCacheKey is responseCacheKey for a native Ollama request
+func (c *Client) ollamaCacheKey(req OllamaChatRequest) string {
+	req.Stream = false
+	return c.cacheKeyOf(req)
+}
+
+// cacheKeyOf hashes a request with the endpoint, or returns "" when caching
+// is off
+func (c *Client) cacheKeyOf(req any) string {
+	if !c.cfg.ResponseCache || c.cfg.Temperature != 0 || c.workDir == "" {
+		return ""
+	}
+	data, err := json.Marshal(req)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(append([]byte(c.cfg.APIEndpoint+"\n"), data...))
+	return hex.EncodeToString(sum[:])
+}
+
+// responseCachePath returns the cache file for a key
+func (c *Client) responseCachePath(key string) string {
+	return filepath.Join(c.workDir, ".aicli", "respcache", key+".json")
+}
+
+// loadCachedResponse returns the cached result for a key, or nil on a miss.
+// On a hit the content is passed to onToken so streaming callers still see
+// it, and the assistant message is added to history as for a live response.
+func (c *Client) loadCachedResponse(key string, onToken func(string)) *ChatResult {
+	if key == "" {
+		return nil
+	}
+	data, err := os.ReadFile(c.responseCachePath(key))
+	if err != nil {
+		return nil
+	}
+	var result ChatResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil
+	}
+	c.logDebug("cache-hit", data)
+
+	if onToken != nil && result.Content != "" {
+		onToken(result.Content)
+	}
+	msg := Message{
+		Role:    "assistant",
+		Content: result.Content,
+	}
+	if len(result.ToolCalls) > 0 {
+		msg.ToolCalls = result.ToolCalls
+	}
+	c.history = append(c.history, msg)
+	return &result
+}
+
+// storeCachedResponse saves a completed result under a key. Interrupted or
+// empty results are not cached.
+func (c *Client) storeCachedResponse(key string, result *ChatResult) {
+	if key == "" || result == nil || result.FinishReason == "interrupted" {
+		return
+	}
+	if result.Content == "" && len(result.ToolCalls) == 0 {
+		return
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		return
+	}
+	path := c.responseCachePath(key)
+	os.MkdirAll(filepath.Dir(path), 0755)
+	// Atomic, as concurrent runs may store the same key
+	session.WriteFileAtomic(path, data, 0644)
+}
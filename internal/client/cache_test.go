@@ -0,0 +1,118 @@
+package client
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"aicli/internal/config"
+)
+
+func TestResponseCache(t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"cached answer"},"finish_reason":"stop"}]}`))
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name         string
+		first        func(cfg *config.Config) string // edits cfg, returns the message
+		second       func(cfg *config.Config) string
+		wantRequests int
+	}{
+		{
+			name:         "identical request at temperature 0",
+			first:        func(cfg *config.Config) string { return "hello" },
+			second:       func(cfg *config.Config) string { return "hello" },
+			wantRequests: 1,
+		},
+		{
+			name:         "temperature above 0",
+			first:        func(cfg *config.Config) string { cfg.Temperature = 0.7; return "hello" },
+			second:       func(cfg *config.Config) string { cfg.Temperature = 0.7; return "hello" },
+			wantRequests: 2,
+		},
+		{
+			name:         "cache disabled",
+			first:        func(cfg *config.Config) string { cfg.ResponseCache = false; return "hello" },
+			second:       func(cfg *config.Config) string { cfg.ResponseCache = false; return "hello" },
+			wantRequests: 2,
+		},
+		{
+			name:         "different model",
+			first:        func(cfg *config.Config) string { return "hello" },
+			second:       func(cfg *config.Config) string { cfg.Model = "other"; return "hello" },
+			wantRequests: 2,
+		},
+		{
+			name:         "different message",
+			first:        func(cfg *config.Config) string { return "hello" },
+			second:       func(cfg *config.Config) string { return "goodbye" },
+			wantRequests: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests = 0
+			dir := t.TempDir()
+			for _, send := range []func(*config.Config) string{tt.first, tt.second} {
+				cfg := &config.Config{APIEndpoint: srv.URL + "/v1", Model: "test", ResponseCache: true}
+				msg := send(cfg)
+				c := NewWithDebug(cfg, dir)
+				result, err := c.Chat(msg, false, nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if result.Content != "cached answer" {
+					t.Errorf("content = %q", result.Content)
+				}
+				if last := c.history[len(c.history)-1]; last.Role != "assistant" || last.Content != "cached answer" {
+					t.Errorf("last history message = %+v, want the answer", last)
+				}
+			}
+			if requests != tt.wantRequests {
+				t.Errorf("server got %d requests, want %d", requests, tt.wantRequests)
+			}
+		})
+	}
+}
+
+func TestCachedResponseStreamsToken(t *testing.T) {
+	c := NewWithDebug(&config.Config{APIEndpoint: "http://localhost:1/v1", Model: "test", ResponseCache: true}, t.TempDir())
+	key := c.responseCacheKey(ChatRequest{Model: "test"})
+	c.storeCachedResponse(key, &ChatResult{Content: "hi", FinishReason: "stop"})
+
+	var tokens []string
+	result := c.loadCachedResponse(key, func(s string) { tokens = append(tokens, s) })
+	if result == nil || result.Content != "hi" || len(tokens) != 1 || tokens[0] != "hi" {
+		t.Errorf("cached result %+v with tokens %q, want \"hi\" passed to onToken", result, tokens)
+	}
+}
+
+func TestStoreCachedResponseSkips(t *testing.T) {
+	tests := []struct {
+		name   string
+		result *ChatResult
+	}{
+		{"nil", nil},
+		{"interrupted", &ChatResult{Content: "partial answ", FinishReason: "interrupted"}},
+		{"empty", &ChatResult{FinishReason: "stop"}},
+	}
+	for _, tt := range tests {
+		dir := t.TempDir()
+		c := NewWithDebug(&config.Config{APIEndpoint: "http://localhost:1/v1", Model: "test", ResponseCache: true}, dir)
+		key := c.responseCacheKey(ChatRequest{Model: "test"})
+		c.storeCachedResponse(key, tt.result)
+		if _, err := os.Stat(filepath.Join(dir, ".aicli", "respcache", key+".json")); !os.IsNotExist(err) {
+			t.Errorf("%s: result was cached (stat err = %v)", tt.name, err)
+		}
+		if c.loadCachedResponse(key, nil) != nil {
+			t.Errorf("%s: loadCachedResponse() hit", tt.name)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"aicli/internal/session"
+	"aicli/internal/tools"
+)
+
+// Tool calling support found by a probe
+const (
+	ToolCallsNative = "native" // Structured tool_calls in the response
+	ToolCallsText   = "text"   // Tool calls written as JSON in the content
+	ToolCallsNone   = "none"   // Tools rejected or ignored
+)
+
+// Capabilities is what a model on an endpoint was found to support
+type Capabilities struct {
+	Model      string    `json:"model"`
+	Endpoint   string    `json:"endpoint"`
+	ToolCalls  string    `json:"tool_calls"` // ToolCallsNative, ToolCallsText or ToolCallsNone
+	Vision     bool      `json:"vision"`
+	Embeddings bool      `json:"embeddings"`
+	ProbedAt   time.Time `json:"probed_at"`
+}
+
+// probeToolName is the tool the model is asked to call. It must be a known
+// tool so text tool calls are recognized, and take no arguments.
+const probeToolName = "get_version"
+
+// probeMaxTokens leaves room for reasoning models, which think before
+// calling the tool and would otherwise be cut off and look tool-less
+const probeMaxTokens = 2048
+
+// noToolsTTL is how long a "tool calls: none" result is trusted. It may
+// come from a passing server problem or a model that was being reloaded,
+// so unlike positive results it is probed again once it expires.
+const noToolsTTL = 24 * time.Hour
+
+// probeImage is a 1x1 transparent PNG
+const probeImage = "iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAYAAAAfFcSJAAAADUlEQVR42mNkYPhfDwAChwGA60e6kgAAAABJRU5ErkJggg=="
+
+// ProbeCapabilities sends tiny requests to find out whether the current
+// model calls tools (natively or as text), accepts images and serves
+// embeddings. Only a failure to reach the endpoint at all, or a server error
+// on the tool calling probe, is an error.
+func (c *Client) ProbeCapabilities() (*Capabilities, error) {
+	caps := &Capabilities{
+		Model:    c.cfg.Model,
+		Endpoint: c.cfg.APIEndpoint,
+		ProbedAt: time.Now(),
+	}
+
+	var probeTools []tools.Tool
+	for _, t := range tools.GetTools() {
+		if t.Function.Name == probeToolName {
+			probeTools = append(probeTools, t)
+		}
+	}
+	status, body, err := c.postJSON("/chat/completions", ChatRequest{
+		Model:     c.cfg.Model,
+		Messages:  []Message{{Role: "user", Content: "Call the " + probeToolName + " tool now. Do not reply with text."}},
+		Tools:     probeTools,
+		MaxTokens: probeMaxTokens,
+	}, "probe-tools")
+	if err != nil {
+		return nil, err
+	}
+	// A busy or failing server says nothing about the model; don't let it
+	// be cached as a model without tools
+	if status == http.StatusTooManyRequests || status >= 500 {
+		return nil, fmt.Errorf("server returned %d", status)
+	}
+	caps.ToolCalls = classifyToolProbe(status, body)
+
+	status, _, err = c.postJSON("/chat/completions", ChatRequest{
+		Model:     c.cfg.Model,
+		Messages:  []Message{{Role: "user", Content: "Reply with OK.", Images: []string{probeImage}}},
+		MaxTokens: 5,
+	}, "probe-vision")
+	caps.Vision = err == nil && status == http.StatusOK
+
+	status, _, err = c.postJSON("/embeddings", map[string]interface{}{
+		"model": c.cfg.Model,
+		"input": "ok",
+	}, "probe-embeddings")
+	caps.Embeddings = err == nil && status == http.StatusOK
+
+	return caps, nil
+}
+
+// classifyToolProbe interprets the response to the tool calling probe
+func classifyToolProbe(status int, body []byte) string {
+	if status != http.StatusOK {
+		return ToolCallsNone
+	}
+	var resp ChatResponse
+	if err := json.Unmarshal(body, &resp); err != nil || len(resp.Choices) == 0 {
+		return ToolCallsNone
+	}
+	msg := resp.Choices[0].Message
+	for _, tc := range msg.ToolCalls {
+		if tc.Function.Name == probeToolName {
+			return ToolCallsNative
+		}
+	}
+	calls, _ := ParseToolCallsFromText(msg.Content)
+	for _, tc := range calls {
+		if tc.Function.Name == probeToolName {
+			return ToolCallsText
+		}
+	}
+	return ToolCallsNone
+}
+
+// Summary describes the capabilities in one line
+func (caps *Capabilities) Summary() string {
+	yesNo := func(b bool) string {
+		if b {
+			return "yes"
+		}
+		return "no"
+	}
+	return fmt.Sprintf("tool calls: %s, images: %s, embeddings: %s (probed %s)",
+		caps.ToolCalls, yesNo(caps.Vision), yesNo(caps.Embeddings), caps.ProbedAt.Format("2006-01-02 15:04"))
+}
+
+// capabilitiesPath is the per-project probe cache
+func capabilitiesPath(workDir string) string {
+	return filepath.Join(workDir, ".aicli", "capabilities.json")
+}
+
+// capabilitiesKey identifies a model on an endpoint in the cache
+func capabilitiesKey(endpoint, model string) string {
+	return strings.TrimRight(endpoint, "/") + " " + model
+}
+
+// LoadCapabilities returns the cached probe result for model on endpoint. A
+// result without tool calls counts only until it is noToolsTTL old.
+func LoadCapabilities(workDir, endpoint, model string) (*Capabilities, bool) {
+	cache := loadCapabilityCache(workDir)
+	caps, ok := cache[capabilitiesKey(endpoint, model)]
+	if !ok || caps == nil || (caps.ToolCalls == ToolCallsNone && time.Since(caps.ProbedAt) > noToolsTTL) {
+		return nil, false
+	}
+	return caps, true
+}
+
+// SaveCapabilities adds a probe result to the cache
+func SaveCapabilities(workDir string, caps *Capabilities) error {
+	cache := loadCapabilityCache(workDir)
+	cache[capabilitiesKey(caps.Endpoint, caps.Model)] = caps
+
+	data, err := json.MarshalIndent(cache, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(capabilitiesPath(workDir)), 0755); err != nil {
+		return err
+	}
+	return session.WriteFileAtomic(capabilitiesPath(workDir), data, 0644)
+}
+
+// loadCapabilityCache reads the cache; a missing or corrupt file is empty
+func loadCapabilityCache(workDir string) map[string]*Capabilities {
+	cache := make(map[string]*Capabilities)
+	if data, err := os.ReadFile(capabilitiesPath(workDir)); err == nil {
+		json.Unmarshal(data, &cache)
+	}
+	if cache == nil {
+		cache = make(map[string]*Capabilities)
+	}
+	return cache
+}
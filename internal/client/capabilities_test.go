@@ -0,0 +1,37 @@
+package client
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLoadCapabilities(t *testing.T) {
+	const endpoint, model = "http://localhost:11434/v1", "qwen3"
+	tests := []struct {
+		name      string
+		toolCalls string
+		age       time.Duration
+		want      bool
+	}{
+		{"native", ToolCallsNative, time.Hour, true},
+		{"old native", ToolCallsNative, 30 * 24 * time.Hour, true},
+		{"text", ToolCallsText, 30 * 24 * time.Hour, true},
+		{"recent none", ToolCallsNone, time.Hour, true},
+		{"expired none", ToolCallsNone, noToolsTTL + time.Hour, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			err := SaveCapabilities(dir, &Capabilities{Model: model, Endpoint: endpoint, ToolCalls: tt.toolCalls, ProbedAt: time.Now().Add(-tt.age)})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, got := LoadCapabilities(dir, endpoint+"/", model); got != tt.want {
+				t.Errorf("cached = %v, want %v", got, tt.want)
+			}
+			if _, got := LoadCapabilities(dir, endpoint, "other"); got {
+				t.Error("another model is cached")
+			}
+		})
+	}
+}
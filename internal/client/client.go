@@ -12,11 +12,15 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"aicli/internal/config"
 	"aicli/internal/lang"
+	"aicli/internal/logging"
+	"aicli/internal/session"
 	"aicli/internal/tools"
 )
 
@@ -77,10 +81,11 @@ func extractJSON(s string, start int) (string, int) {
 
 // knownToolNames contains all valid tool names for raw format parsing
 var knownToolNames = []string{
-	"run_command", "write_file", "write_doc", "read_file",
+	"run_command", "run_command_tracked", "project_command", "lint", "write_file", "write_doc", "read_file",
 	"web_search", "fetch_url", "screenshot",
-	"git_status", "git_diff", "git_add", "git_commit", "git_log",
-	"list_files", "get_version", "set_version",
+	"git_status", "git_diff", "git_add", "git_commit", "git_log", "git_blame",
+	"list_files", "tree", "get_version", "set_version", "get_context",
+	"add_todo", "complete_todo",
 }
 
 // ParseToolCallsFromText extracts tool calls from text output
@@ -231,6 +236,66 @@ func ParseToolCallsFromText(text string) ([]tools.ToolCall, string) {
 	return toolCalls, cleanedText
 }
 
+// fencedBlock matches a markdown code block: its language tag and body
+var fencedBlock = regexp.MustCompile("(?s)```([\\w+-]*)[ \t]*\n(.*?)```")
+
+// shellFences are the code block languages of shell commands
+var shellFences = map[string]bool{"bash": true, "sh": true, "shell": true, "zsh": true, "console": true}
+
+// HasFencedAction reports whether a markdown code block in text holds a tool
+// call (JSON or tool_name{...} naming a known tool) or a shell command -
+// what a model that can't call tools writes instead. Other code blocks,
+// such as example code in an explanation, don't count.
+func HasFencedAction(text string) bool {
+	for _, m := range fencedBlock.FindAllStringSubmatch(text, -1) {
+		lang, body := strings.ToLower(m[1]), strings.TrimSpace(m[2])
+		if shellFences[lang] || (lang == "" && isPromptedCommand(body)) || namesKnownTool(body) {
+			return true
+		}
+	}
+	return false
+}
+
+// isPromptedCommand reports whether every line of a block is a "$ command"
+func isPromptedCommand(body string) bool {
+	if body == "" {
+		return false
+	}
+	for _, line := range strings.Split(body, "\n") {
+		if !strings.HasPrefix(strings.TrimSpace(line), "$ ") {
+			return false
+		}
+	}
+	return true
+}
+
+// namesKnownTool reports whether a block is a tool call for a known tool:
+// {"name": ...}, {"tool": ...}, {"function": {"name": ...}} or tool_name{...}
+func namesKnownTool(body string) bool {
+	var call struct {
+		Name     string          `json:"name"`
+		Tool     string          `json:"tool"`
+		Function json.RawMessage `json:"function"`
+	}
+	if jsonStr, _ := extractJSON(body, 0); jsonStr != "" && json.Unmarshal([]byte(jsonStr), &call) == nil {
+		var function struct {
+			Name string `json:"name"`
+		}
+		json.Unmarshal(call.Function, &function)
+		for _, name := range []string{call.Name, call.Tool, function.Name} {
+			if name != "" && slices.Contains(knownToolNames, name) {
+				return true
+			}
+		}
+	}
+	for _, name := range knownToolNames {
+		if rest, ok := strings.CutPrefix(body, name); ok && strings.HasPrefix(strings.TrimSpace(rest), "{") {
+			return true
+		}
+	}
+	return false
+}
+
 type Message struct {
 	Role       string           `json:"role"`
 	Content    string           `json:"content"` // No omitempty - required for tool role messages
@@ -240,32 +305,14 @@ type Message struct {
 }
 
 type ChatRequest struct {
-	Model       string        `json:"model"`
-	Messages    []Message     `json:"messages"`
-	Tools       []tools.Tool  `json:"tools,omitempty"`
-	MaxTokens   int           `json:"max_tokens,omitempty"`
-	Temperature float64       `json:"temperature,omitempty"`
-	Stream      bool          `json:"stream"`
-}
-
-// OllamaChatRequest is for the native /api/chat endpoint (supports images)
-type OllamaChatRequest struct {
-	Model    string    `json:"model"`
-	Messages []Message `json:"messages"`
-	Stream   bool      `json:"stream"`
-}
-
-// OllamaChatResponse is the response from native /api/chat endpoint
-type OllamaChatResponse struct {
-	Model     string `json:"model"`
-	CreatedAt string `json:"created_at"`
-	Message   struct {
-		Role    string `json:"role"`
-		Content string `json:"content"`
-	} `json:"message"`
-	Done         bool   `json:"done"`
-	DoneReason   string `json:"done_reason,omitempty"`
-	TotalDuration int64 `json:"total_duration,omitempty"`
+	Model           string       `json:"model"`
+	Messages        []Message    `json:"messages"`
+	Tools           []tools.Tool `json:"tools,omitempty"`
+	MaxTokens       int          `json:"max_tokens,omitempty"`
+	Temperature     float64      `json:"temperature,omitempty"`
+	ReasoningEffort string       `json:"reasoning_effort,omitempty"`
+	N               int          `json:"n,omitempty"`
+	Stream          bool         `json:"stream"`
 }
 
 // hasImages checks if any message in history contains images
@@ -307,6 +354,8 @@ type ChatResult struct {
 	Content      string
 	ToolCalls    []tools.ToolCall
 	FinishReason string
+	Alternatives []ChatResult `json:",omitempty"` // All choices when n > 1 (the first is the default)
+	Compacted    int          `json:",omitempty"` // Messages summarized away to fit the context before this response
 }
 
 type Client struct {
@@ -317,6 +366,8 @@ type Client struct {
 	debugDir   string
 	workDir    string
 	requestNum int
+
+	pendingImages []string // Base64 images for the next user message (AttachImage)
 }
 
 type ModelsResponse struct {
@@ -389,14 +440,82 @@ func (c *Client) SetDebugDir(workDir string) {
 	os.MkdirAll(c.debugDir, 0755)
 }
 
+// DisableDebug stops writing debug files
+func (c *Client) DisableDebug() {
+	c.debugDir = ""
+}
+
+// DebugEnabled reports whether debug files are being written
+func (c *Client) DebugEnabled() bool {
+	return c.debugDir != ""
+}
+
+// LatestDebugFile returns the path of the most recently written debug file
+// in the work dir's .aicli/debug directory
+func (c *Client) LatestDebugFile() (string, error) {
+	dir := filepath.Join(c.workDir, ".aicli", "debug")
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", fmt.Errorf("no debug logs: %w", err)
+	}
+
+	var latest string
+	var latestTime time.Time
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		// Names start with a timestamp and request number, so they break ties
+		if latest == "" || info.ModTime().After(latestTime) ||
+			(info.ModTime().Equal(latestTime) && entry.Name() > filepath.Base(latest)) {
+			latest = filepath.Join(dir, entry.Name())
+			latestTime = info.ModTime()
+		}
+	}
+	if latest == "" {
+		return "", fmt.Errorf("no debug logs in %s", dir)
+	}
+	return latest, nil
+}
+
+// ClearDebugLogs deletes the request/response logs in the work dir's
+// .aicli/debug directory and returns how many were removed
+func (c *Client) ClearDebugLogs() (int, error) {
+	dir := filepath.Join(c.workDir, ".aicli", "debug")
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	removed := 0
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, entry.Name())); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
 // logDebug writes request/response data to debug files
 func (c *Client) logDebug(prefix string, data []byte) {
 	if c.debugDir == "" {
 		return
 	}
 
+	// The process ID keeps concurrent runs from overwriting each other's
+	// files for the same second and request number
 	timestamp := time.Now().Format("20060102_150405")
-	filename := fmt.Sprintf("%s_%03d_%s.json", timestamp, c.requestNum, prefix)
+	filename := fmt.Sprintf("%s_%d_%03d_%s.json", timestamp, os.Getpid(), c.requestNum, prefix)
 	filepath := filepath.Join(c.debugDir, filename)
 
 	// Pretty-print JSON if possible
@@ -409,7 +528,7 @@ func (c *Client) logDebug(prefix string, data []byte) {
 }
 
 func (c *Client) ListModels() ([]string, error) {
-	endpoint := strings.TrimSuffix(c.cfg.APIEndpoint, "/") + "/models"
+	endpoint := c.cfg.OpenAIBaseURL() + "/models"
 	httpReq, err := http.NewRequest("GET", endpoint, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
@@ -427,7 +546,7 @@ func (c *Client) ListModels() ([]string, error) {
 
 	if resp.StatusCode != http.StatusOK {
 		bodyBytes, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API error %d: %s", resp.StatusCode, string(bodyBytes))
+		return nil, newAPIError(resp.StatusCode, bodyBytes)
 	}
 
 	var modelsResp ModelsResponse
@@ -459,9 +578,7 @@ func (c *Client) ListRunningModels() ([]string, error) {
 	}
 	// Convert OpenAI-compatible endpoint to Ollama native endpoint
 	// e.g., http://localhost:11434/v1 -> http://localhost:11434/api/ps
-	baseEndpoint := strings.TrimSuffix(c.cfg.APIEndpoint, "/")
-	baseEndpoint = strings.TrimSuffix(baseEndpoint, "/v1")
-	endpoint := baseEndpoint + "/api/ps"
+	endpoint := c.cfg.OllamaBaseURL() + "/api/ps"
 
 	httpReq, err := http.NewRequest("GET", endpoint, nil)
 	if err != nil {
@@ -480,7 +597,7 @@ func (c *Client) ListRunningModels() ([]string, error) {
 
 	if resp.StatusCode != http.StatusOK {
 		bodyBytes, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API error %d: %s", resp.StatusCode, string(bodyBytes))
+		return nil, newAPIError(resp.StatusCode, bodyBytes)
 	}
 
 	var runningResp RunningModelsResponse
@@ -516,9 +633,7 @@ func (c *Client) LoadModel(modelName string, keepAlive string) error {
 		return fmt.Errorf("not an Ollama endpoint")
 	}
 	// Use Ollama's generate endpoint with empty prompt to trigger load
-	baseEndpoint := strings.TrimSuffix(c.cfg.APIEndpoint, "/")
-	baseEndpoint = strings.TrimSuffix(baseEndpoint, "/v1")
-	endpoint := baseEndpoint + "/api/generate"
+	endpoint := c.cfg.OllamaBaseURL() + "/api/generate"
 
 	payload := map[string]interface{}{
 		"model":      modelName,
@@ -549,12 +664,120 @@ func (c *Client) LoadModel(modelName string, keepAlive string) error {
 
 	if resp.StatusCode != http.StatusOK {
 		bodyBytes, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("API error %d: %s", resp.StatusCode, string(bodyBytes))
+		return newAPIError(resp.StatusCode, bodyBytes)
 	}
 
 	return nil
 }
 
+// PullProgress is one status update from Ollama's streamed /api/pull
+type PullProgress struct {
+	Status    string `json:"status"`
+	Digest    string `json:"digest,omitempty"`
+	Total     int64  `json:"total,omitempty"`
+	Completed int64  `json:"completed,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// PullModel downloads a model via Ollama's /api/pull, calling onProgress for
+// each streamed status update. Only supported on Ollama endpoints.
+func (c *Client) PullModel(modelName string, onProgress func(PullProgress)) error {
+	if !c.cfg.IsOllamaEndpoint() {
+		return fmt.Errorf("not an Ollama endpoint")
+	}
+	endpoint := c.cfg.OllamaBaseURL() + "/api/pull"
+
+	payload := map[string]interface{}{
+		"model":  modelName,
+		"stream": true,
+	}
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequest("POST", endpoint, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	if c.cfg.APIKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+c.cfg.APIKey)
+	}
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("failed to pull model: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return newAPIError(resp.StatusCode, bodyBytes)
+	}
+
+	return readPullProgress(resp.Body, onProgress)
+}
+
+// readPullProgress parses the newline-delimited JSON status stream of a pull.
+// An "error" field in the stream is returned as an error.
+func readPullProgress(body io.Reader, onProgress func(PullProgress)) error {
+	scanner := bufio.NewScanner(body)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var p PullProgress
+		if err := json.Unmarshal([]byte(line), &p); err != nil {
+			continue
+		}
+		if p.Error != "" {
+			return fmt.Errorf("pull failed: %s", p.Error)
+		}
+		if onProgress != nil {
+			onProgress(p)
+		}
+	}
+	return scanner.Err()
+}
+
+// resultFromChoices converts a non-streaming response into a result using the
+// first choice. With several choices, all of them are kept as Alternatives.
+func resultFromChoices(chatResp *ChatResponse) *ChatResult {
+	result := &ChatResult{}
+	for _, choice := range chatResp.Choices {
+		result.Alternatives = append(result.Alternatives, ChatResult{
+			Content:      choice.Message.Content,
+			ToolCalls:    choice.Message.ToolCalls,
+			FinishReason: choice.FinishReason,
+		})
+	}
+	if len(result.Alternatives) > 0 {
+		first := result.Alternatives[0]
+		result.Content = first.Content
+		result.ToolCalls = first.ToolCalls
+		result.FinishReason = first.FinishReason
+	}
+	if len(result.Alternatives) < 2 {
+		result.Alternatives = nil
+	}
+	return result
+}
+
+// SelectAlternative makes the given alternative the assistant's reply,
+// replacing the default first choice at the end of history
+func (c *Client) SelectAlternative(alt ChatResult) {
+	for i := len(c.history) - 1; i >= 0; i-- {
+		if c.history[i].Role == "assistant" {
+			c.history[i].Content = alt.Content
+			c.history[i].ToolCalls = alt.ToolCalls
+			return
+		}
+	}
+}
+
 // WithModel creates a new client that uses a different model but shares the
 // same HTTP client and config (except model). History is reset.
 func (c *Client) WithModel(model string) *Client {
@@ -579,6 +802,26 @@ func (c *Client) SetUseTools(use bool) {
 	c.useTools = use
 }
 
+// UsesTools reports whether tool definitions are sent with requests
+func (c *Client) UsesTools() bool {
+	return c.useTools
+}
+
+// ResetUseTools restores the default: native tools if the model supports them
+func (c *Client) ResetUseTools() {
+	c.useTools = modelSupportsNativeTools(c.cfg.Model)
+}
+
+// IsNewConversation returns true if no user/assistant messages have been sent yet
+func (c *Client) IsNewConversation() bool {
+	for _, msg := range c.history {
+		if msg.Role != "system" {
+			return false
+		}
+	}
+	return true
+}
+
 func (c *Client) ClearHistory() {
 	c.history = make([]Message, 0)
 }
@@ -621,6 +864,156 @@ func (c *Client) RestoreHistory(entries []struct {
 	}
 }
 
+// HistoryFromEntries rebuilds a valid message list from recorded session
+// entries. With nativeTools, each tool call is attached to an assistant
+// message (the preceding one, if only tool results came since) with a
+// generated id, and its result becomes the matching "tool" message; calls
+// without a recorded result are dropped and results without a call become
+// notes. Without native tools, results are user messages as in a live
+// text tool-calling session.
+func HistoryFromEntries(entries []session.Entry, nativeTools bool) []Message {
+	var history []Message
+	pending := map[int][]string{} // history index of an assistant message -> unanswered call ids by position
+	nextID := 0
+
+	// owner returns the assistant message that the current tool call
+	// belongs to: the last assistant message if only tool results follow it
+	owner := func() int {
+		i := len(history) - 1
+		for i >= 0 && history[i].Role == "tool" {
+			i--
+		}
+		if i >= 0 && history[i].Role == "assistant" {
+			return i
+		}
+		return -1
+	}
+
+	for _, e := range entries {
+		switch e.Type {
+		case "user", "assistant":
+			history = dropUnanswered(history, pending)
+			history = append(history, Message{Role: e.Type, Content: e.Content})
+
+		case "tool_call":
+			if !nativeTools {
+				continue
+			}
+			i := owner()
+			if i < 0 {
+				history = append(history, Message{Role: "assistant"})
+				i = len(history) - 1
+			}
+			var tc tools.ToolCall
+			tc.Index = len(history[i].ToolCalls)
+			tc.ID = fmt.Sprintf("call_replay_%d", nextID)
+			tc.Type = "function"
+			tc.Function.Name = e.ToolName
+			tc.Function.Arguments = e.ToolArgs
+			nextID++
+			history[i].ToolCalls = append(history[i].ToolCalls, tc)
+			pending[i] = append(pending[i], tc.ID)
+
+		case "tool_result":
+			if !nativeTools {
+				history = append(history, Message{Role: "user", Content: fmt.Sprintf("[Tool Result]:\n%s", e.Content)})
+				continue
+			}
+			// Answer the oldest unanswered call to this tool
+			i := owner()
+			id := ""
+			if i >= 0 {
+				for k, callID := range pending[i] {
+					if callID != "" && toolCallName(history[i].ToolCalls, callID) == e.ToolName {
+						id = callID
+						pending[i][k] = ""
+						break
+					}
+				}
+			}
+			if id == "" {
+				history = dropUnanswered(history, pending)
+				history = append(history, Message{Role: "user", Content: fmt.Sprintf("[Previous tool result from %s]: %s", e.ToolName, e.Content)})
+				continue
+			}
+			history = append(history, Message{Role: "tool", Content: e.Content, ToolCallID: id})
+		}
+	}
+	return dropUnanswered(history, pending)
+}
+
+// toolCallName returns the name of the call with the given id
+func toolCallName(calls []tools.ToolCall, id string) string {
+	for _, tc := range calls {
+		if tc.ID == id {
+			return tc.Function.Name
+		}
+	}
+	return ""
+}
+
+// dropUnanswered removes tool calls that never got a result (APIs reject
+// a tool call without its result) and clears the pending set. An assistant
+// message left with neither content nor calls is removed.
+func dropUnanswered(history []Message, pending map[int][]string) []Message {
+	for i, ids := range pending {
+		unanswered := map[string]bool{}
+		for _, id := range ids {
+			if id != "" {
+				unanswered[id] = true
+			}
+		}
+		if len(unanswered) > 0 {
+			var kept []tools.ToolCall
+			for _, tc := range history[i].ToolCalls {
+				if !unanswered[tc.ID] {
+					kept = append(kept, tc)
+				}
+			}
+			history[i].ToolCalls = kept
+		}
+		delete(pending, i)
+	}
+	// Pending indexes are only ever the latest assistant turn, so removing
+	// an empty one can't shift another pending index
+	if n := len(history); n > 0 && history[n-1].Role == "assistant" &&
+		history[n-1].Content == "" && len(history[n-1].ToolCalls) == 0 {
+		history = history[:n-1]
+	}
+	return history
+}
+
+// LoadHistory replaces the conversation with one rebuilt from recorded
+// session entries (see HistoryFromEntries). Returns the message count.
+func (c *Client) LoadHistory(entries []session.Entry) int {
+	c.ClearHistory()
+	c.AddSystemPrompt()
+	restored := HistoryFromEntries(entries, c.useTools)
+	c.history = append(c.history, restored...)
+	return len(restored)
+}
+
+// capToolResult keeps the head and tail of a tool result longer than max
+// characters, replacing the middle with an omitted marker. max <= 0 means
+// no cap.
+func capToolResult(s string, max int) string {
+	if max <= 0 || len(s) <= max {
+		return s
+	}
+	head := max * 2 / 3
+	tail := max - head
+	// Don't split UTF-8 sequences
+	for head > 0 && !utf8.RuneStart(s[head]) {
+		head--
+	}
+	tailStart := len(s) - tail
+	for tailStart < len(s) && !utf8.RuneStart(s[tailStart]) {
+		tailStart++
+	}
+	omitted := tailStart - head
+	return fmt.Sprintf("%s\n\n... [%d characters omitted] ...\n\n%s", s[:head], omitted, s[tailStart:])
+}
+
 // truncateForContext shortens content to avoid context overflow
 func truncateForContext(s string, maxLen int) string {
 	if len(s) <= maxLen {
@@ -630,16 +1023,10 @@ func truncateForContext(s string, maxLen int) string {
 }
 
 func (c *Client) AddSystemPrompt() {
-	if c.cfg.SystemPrompt != "" && len(c.history) == 0 {
-		prompt := c.cfg.SystemPrompt
-
-		// Always add language-specific error handling rules
-		if c.workDir != "" {
-			langs := lang.DetectMultipleLanguages(c.workDir)
-			rules := lang.GetErrorRules(langs) // Returns LangUnknown rules if no langs detected
-			prompt += "\n\n" + rules
-		}
-
+	if len(c.history) > 0 {
+		return
+	}
+	if prompt := SystemPrompt(c.cfg, c.workDir); prompt != "" {
 		c.history = append(c.history, Message{
 			Role:    "system",
 			Content: prompt,
@@ -647,18 +1034,59 @@ func (c *Client) AddSystemPrompt() {
 	}
 }
 
+// SystemPrompt assembles the system message sent for a project in workDir:
+// the configured prompt, the error handling rules for the languages
+// detected there, and the project memory. Returns "" when no system
+// message is sent.
+func SystemPrompt(cfg *config.Config, workDir string) string {
+	// Without a system prompt (empty or disabled) nothing is sent: no
+	// language rules or project memory either
+	if cfg.NoSystemPrompt || cfg.SystemPrompt == "" {
+		return ""
+	}
+
+	prompt := cfg.SystemPrompt
+	if workDir == "" {
+		return prompt
+	}
+
+	// Always add language-specific error handling rules
+	langs := lang.ProjectLanguages(workDir)
+	rules := lang.GetErrorRules(langs) // Returns LangUnknown rules if no langs detected
+	prompt += "\n\n" + rules
+
+	// Project memory (.aicli/memory.md) goes with every system prompt
+	if memory := session.NewMemoryFile(workDir).PromptSection(); memory != "" {
+		prompt = strings.TrimSpace(prompt + "\n\n" + memory)
+	}
+	return prompt
+}
+
 func (c *Client) Chat(userMessage string, stream bool, onToken func(string)) (*ChatResult, error) {
 	c.AddSystemPrompt()
 
 	c.history = append(c.history, Message{
 		Role:    "user",
 		Content: userMessage,
+		Images:  c.takePendingImages(),
 	})
 
-	return c.sendRequest(stream, onToken)
+	return c.sendAndContinue(context.Background(), stream, onToken)
+}
+
+// Aside sends a one-shot question using a copy of the current conversation
+// as context. Tools are disabled and neither the question nor the answer is
+// added to the main history.
+func (c *Client) Aside(question string) (*ChatResult, error) {
+	side := c.WithModel(c.cfg.Model)
+	side.useTools = false
+	side.history = append(side.history, c.history...)
+	return side.Chat(question, false, nil)
 }
 
 func (c *Client) AddToolResult(toolCallID, result string) {
+	result = capToolResult(result, c.cfg.GetMaxToolResult())
+
 	// If model doesn't support native tools, send result as user message
 	// so the model understands it's a tool response
 	if !c.useTools {
@@ -710,173 +1138,160 @@ func (c *Client) AddUserInterrupt(message string) {
 }
 
 func (c *Client) ContinueWithToolResults(stream bool, onToken func(string)) (*ChatResult, error) {
-	return c.sendRequest(stream, onToken)
+	return c.sendAndContinue(context.Background(), stream, onToken)
 }
 
 // ChatWithContext sends a chat message with context for cancellation
 func (c *Client) ChatWithContext(ctx context.Context, userMessage string, stream bool, onToken func(string)) (*ChatResult, error) {
+	c.AddSystemPrompt()
+
 	c.history = append(c.history, Message{
 		Role:    "user",
 		Content: userMessage,
+		Images:  c.takePendingImages(),
 	})
-	return c.sendRequestWithContext(ctx, stream, onToken)
+	return c.sendAndContinue(ctx, stream, onToken)
 }
 
 // ContinueWithToolResultsContext continues with tool results with context for cancellation
 func (c *Client) ContinueWithToolResultsContext(ctx context.Context, stream bool, onToken func(string)) (*ChatResult, error) {
-	return c.sendRequestWithContext(ctx, stream, onToken)
+	return c.sendAndContinue(ctx, stream, onToken)
 }
 
-// sendOllamaRequestWithImages sends a request using Ollama's native /api/chat endpoint
-// which properly supports the images field for vision models
-func (c *Client) sendOllamaRequestWithImages(ctx context.Context, stream bool, onToken func(string)) (*ChatResult, error) {
-	req := OllamaChatRequest{
-		Model:    c.cfg.Model,
-		Messages: c.history,
-		Stream:   stream,
-	}
-
-	body, err := json.Marshal(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
-	}
-
-	c.logDebug("ollama-request", body)
-
-	// Use native Ollama API endpoint instead of OpenAI-compatible
-	baseEndpoint := strings.TrimSuffix(c.cfg.APIEndpoint, "/v1")
-	baseEndpoint = strings.TrimSuffix(baseEndpoint, "/")
-	endpoint := baseEndpoint + "/api/chat"
-
-	httpReq, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(body))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	httpReq.Header.Set("Content-Type", "application/json")
+// logRequest records an outgoing chat request in the event log
+func (c *Client) logRequest(stream bool) {
+	logging.Info("request sent", "request", c.requestNum, "model", c.cfg.Model,
+		"messages", len(c.history), "tools", c.useTools, "stream", stream)
+}
 
-	resp, err := c.httpClient.Do(httpReq)
+// logResult records the outcome of a chat request in the event log
+func (c *Client) logResult(result *ChatResult, err error) {
 	if err != nil {
-		if ctx.Err() == context.Canceled {
-			return &ChatResult{FinishReason: "interrupted"}, nil
-		}
-		return nil, fmt.Errorf("failed to send request: %w", err)
+		logging.Error("request failed", "request", c.requestNum, "error", err)
+		return
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		c.logDebug("ollama-error", bodyBytes)
-		return nil, fmt.Errorf("Ollama API error %d: %s", resp.StatusCode, string(bodyBytes))
+	var names []string
+	for _, tc := range result.ToolCalls {
+		names = append(names, tc.Function.Name)
 	}
-
-	var result *ChatResult
-
-	if stream {
-		// Handle streaming response from native Ollama API
-		result, err = c.handleOllamaStreamResponse(ctx, resp.Body, onToken)
-		if err != nil && ctx.Err() != context.Canceled {
-			return nil, err
-		}
-	} else {
-		var ollamaResp OllamaChatResponse
-		respBody, _ := io.ReadAll(resp.Body)
-		c.logDebug("ollama-response", respBody)
-		if err := json.Unmarshal(respBody, &ollamaResp); err != nil {
-			return nil, fmt.Errorf("failed to decode response: %w", err)
-		}
-		result = &ChatResult{
-			Content:      ollamaResp.Message.Content,
-			FinishReason: ollamaResp.DoneReason,
-		}
-	}
-
-	// Add assistant message to history
-	msg := Message{
-		Role:    "assistant",
-		Content: result.Content,
-	}
-	c.history = append(c.history, msg)
-
-	return result, nil
+	logging.Info("response received", "request", c.requestNum, "finish", result.FinishReason,
+		"chars", len(result.Content), "tool_calls", strings.Join(names, ","))
 }
 
-// handleOllamaStreamResponse handles streaming responses from native Ollama API
-func (c *Client) handleOllamaStreamResponse(ctx context.Context, body io.ReadCloser, onToken func(string)) (*ChatResult, error) {
-	done := make(chan struct{})
-	defer close(done)
-
-	go func() {
-		select {
-		case <-ctx.Done():
-			body.Close()
-		case <-done:
-		}
+// maxContinuations caps automatic continuations of one response, so a model
+// that never stops can't loop forever
+const maxContinuations = 3
+
+// continuePrompt asks the model to resume a response cut off by the limit
+const continuePrompt = "Your previous response was cut off by the length limit. Continue exactly where it stopped, without repeating anything already written."
+
+// sendAndContinue sends the conversation and, with auto_continue on, asks
+// the model to continue responses cut off by the token limit
+// (finish_reason "length"), stitching the parts into one assistant message
+// so text tool calls split across parts parse as a whole
+func (c *Client) sendAndContinue(ctx context.Context, stream bool, onToken func(string)) (*ChatResult, error) {
+	start := time.Now()
+	defer func() {
+		logging.Debug("exchange done", "request", c.requestNum, "duration", time.Since(start).Round(time.Millisecond))
 	}()
 
-	scanner := bufio.NewScanner(body)
-	buf := make([]byte, 0, 64*1024)
-	scanner.Buffer(buf, 1024*1024)
-
-	result := &ChatResult{}
-	var contentBuilder strings.Builder
-
-	for scanner.Scan() {
-		select {
-		case <-ctx.Done():
-			result.Content = contentBuilder.String()
-			result.FinishReason = "interrupted"
-			return result, nil
-		default:
+	result, err := c.sendRequestWithContext(ctx, stream, onToken)
+	c.logResult(result, err)
+
+	// With auto_compact, a conversation that outgrew the context is
+	// summarized and the request retried once
+	if err != nil && c.cfg.AutoCompact && IsContextLengthError(err) {
+		removed, compactErr := c.Compact(ctx)
+		if compactErr != nil {
+			logging.Warn("compaction failed", "error", compactErr)
+		} else {
+			logging.Info("history compacted", "messages", removed)
+			result, err = c.sendRequestWithContext(ctx, stream, onToken)
+			c.logResult(result, err)
+			if err == nil {
+				result.Compacted = removed
+			}
 		}
+	}
 
-		line := scanner.Text()
-		if line == "" {
-			continue
+	for i := 0; i < maxContinuations && err == nil && c.cfg.AutoContinue && result.FinishReason == "length"; i++ {
+		// A structured tool call cut off mid-arguments can't be resumed;
+		// drop it. If complete calls remain, run those first instead.
+		var complete []tools.ToolCall
+		for _, tc := range result.ToolCalls {
+			if !tc.Repaired {
+				complete = append(complete, tc)
+			}
 		}
-
-		var chunk OllamaChatResponse
-		if err := json.Unmarshal([]byte(line), &chunk); err != nil {
-			continue
+		if len(complete) > 0 {
+			result.ToolCalls = complete
+			c.history[len(c.history)-1].ToolCalls = complete
+			break
 		}
-
-		if chunk.Message.Content != "" {
-			contentBuilder.WriteString(chunk.Message.Content)
-			if onToken != nil {
-				onToken(chunk.Message.Content)
-			}
+		result.ToolCalls = nil
+		c.history[len(c.history)-1].ToolCalls = nil
+
+		base := len(c.history) - 1 // The truncated assistant message
+		c.AddUserInterrupt(continuePrompt)
+		next, nextErr := c.sendRequestWithContext(ctx, stream, onToken)
+		c.logResult(next, nextErr)
+		if nextErr != nil {
+			// Keep the truncated part rather than losing it
+			c.history = c.history[:base+1]
+			break
 		}
 
-		if chunk.Done {
-			result.FinishReason = chunk.DoneReason
-			break
+		stitched := &ChatResult{
+			Content:      result.Content + next.Content,
+			ToolCalls:    next.ToolCalls,
+			FinishReason: next.FinishReason,
 		}
+		c.history = append(c.history[:base], Message{
+			Role:      "assistant",
+			Content:   stitched.Content,
+			ToolCalls: stitched.ToolCalls,
+		})
+		result = stitched
 	}
-
-	result.Content = contentBuilder.String()
-	return result, scanner.Err()
+	return result, err
 }
 
 func (c *Client) sendRequestWithContext(ctx context.Context, stream bool, onToken func(string)) (*ChatResult, error) {
 	c.requestNum++
+	c.logRequest(stream)
 
-	// If there are images in the messages, use the native Ollama API (Ollama only)
-	if c.hasImages() && c.cfg.IsOllamaEndpoint() {
-		return c.sendOllamaRequestWithImages(ctx, stream, onToken)
+	// Native Ollama mode, or images on an Ollama endpoint (which only the
+	// native API accepts in the images field)
+	if c.cfg.IsNativeOllama() || (c.hasImages() && c.cfg.IsOllamaEndpoint()) {
+		return c.sendOllamaRequest(ctx, stream, onToken)
+	}
+
+	// Multiple choices arrive interleaved when streaming, so request them whole
+	if c.cfg.N > 1 {
+		stream = false
 	}
 
 	req := ChatRequest{
-		Model:       c.cfg.Model,
-		Messages:    c.history,
-		MaxTokens:   c.cfg.MaxTokens,
-		Temperature: c.cfg.Temperature,
-		Stream:      stream,
+		Model:           c.cfg.Model,
+		Messages:        c.history,
+		MaxTokens:       c.cfg.GetMaxTokens(),
+		Temperature:     c.cfg.Temperature,
+		ReasoningEffort: c.cfg.GetReasoningEffort(),
+		Stream:          stream,
+	}
+	if c.cfg.N > 1 {
+		req.N = c.cfg.N
 	}
 
 	if c.useTools {
 		req.Tools = tools.GetTools()
 	}
 
+	cacheKey := c.responseCacheKey(req)
+	if cached := c.loadCachedResponse(cacheKey, onToken); cached != nil {
+		return cached, nil
+	}
+
 	body, err := json.Marshal(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
@@ -884,7 +1299,7 @@ func (c *Client) sendRequestWithContext(ctx context.Context, stream bool, onToke
 
 	c.logDebug("request", body)
 
-	endpoint := strings.TrimSuffix(c.cfg.APIEndpoint, "/") + "/chat/completions"
+	endpoint := c.cfg.OpenAIBaseURL() + "/chat/completions"
 	httpReq, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(body))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
@@ -915,8 +1330,12 @@ func (c *Client) sendRequestWithContext(ctx context.Context, stream bool, onToke
 			resp.Body.Close()
 			return c.sendRequestWithContext(ctx, stream, onToken)
 		}
+		if err := c.imageRejected(resp.StatusCode, errStr); err != nil {
+			return nil, err
+		}
 
-		return nil, fmt.Errorf("API error %d: %s", resp.StatusCode, errStr)
+		logging.Error("api error", "status", resp.StatusCode, "body", truncateForContext(errStr, 500))
+		return nil, newAPIError(resp.StatusCode, bodyBytes)
 	}
 
 	var result *ChatResult
@@ -933,13 +1352,7 @@ func (c *Client) sendRequestWithContext(ctx context.Context, stream bool, onToke
 		if err := json.Unmarshal(respBody, &chatResp); err != nil {
 			return nil, fmt.Errorf("failed to decode response: %w", err)
 		}
-		result = &ChatResult{}
-		if len(chatResp.Choices) > 0 {
-			choice := chatResp.Choices[0]
-			result.Content = choice.Message.Content
-			result.ToolCalls = choice.Message.ToolCalls
-			result.FinishReason = choice.FinishReason
-		}
+		result = resultFromChoices(&chatResp)
 	}
 
 	if resultJSON, err := json.Marshal(result); err == nil {
@@ -954,6 +1367,7 @@ func (c *Client) sendRequestWithContext(ctx context.Context, stream bool, onToke
 		msg.ToolCalls = result.ToolCalls
 	}
 	c.history = append(c.history, msg)
+	c.storeCachedResponse(cacheKey, result)
 
 	return result, nil
 }
@@ -971,15 +1385,13 @@ func (c *Client) handleStreamResponseWithContext(ctx context.Context, body io.Re
 		}
 	}()
 
-	scanner := bufio.NewScanner(body)
-	buf := make([]byte, 0, 64*1024)
-	scanner.Buffer(buf, 1024*1024)
+	events := newSSEScanner(body, c.cfg.GetStreamBufferSize())
 
 	result := &ChatResult{}
 	var contentBuilder strings.Builder
 	toolCallsMap := make(map[int]*tools.ToolCall)
 
-	for scanner.Scan() {
+	for events.Scan() {
 		// Check for cancellation
 		select {
 		case <-ctx.Done():
@@ -992,12 +1404,7 @@ func (c *Client) handleStreamResponseWithContext(ctx context.Context, body io.Re
 		default:
 		}
 
-		line := scanner.Text()
-		if !strings.HasPrefix(line, "data: ") {
-			continue
-		}
-
-		data := strings.TrimPrefix(line, "data: ")
+		data := events.Data()
 		if data == "[DONE]" {
 			break
 		}
@@ -1051,125 +1458,20 @@ func (c *Client) handleStreamResponseWithContext(ctx context.Context, body io.Re
 	for _, tc := range toolCallsMap {
 		result.ToolCalls = append(result.ToolCalls, *tc)
 	}
+	repairToolCalls(result.ToolCalls)
 
-	return result, scanner.Err()
-}
-
-func (c *Client) sendRequest(stream bool, onToken func(string)) (*ChatResult, error) {
-	c.requestNum++
-
-	req := ChatRequest{
-		Model:       c.cfg.Model,
-		Messages:    c.history,
-		MaxTokens:   c.cfg.MaxTokens,
-		Temperature: c.cfg.Temperature,
-		Stream:      stream,
-	}
-
-	if c.useTools {
-		req.Tools = tools.GetTools()
-	}
-
-	body, err := json.Marshal(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
-	}
-
-	// Log the request
-	c.logDebug("request", body)
-
-	endpoint := strings.TrimSuffix(c.cfg.APIEndpoint, "/") + "/chat/completions"
-	httpReq, err := http.NewRequest("POST", endpoint, bytes.NewReader(body))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	httpReq.Header.Set("Content-Type", "application/json")
-	if c.cfg.APIKey != "" {
-		httpReq.Header.Set("Authorization", "Bearer "+c.cfg.APIKey)
-	}
-
-	resp, err := c.httpClient.Do(httpReq)
-	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		c.logDebug("error", bodyBytes)
-
-		// Check if error is due to model not supporting tools
-		errStr := string(bodyBytes)
-		if resp.StatusCode == http.StatusBadRequest &&
-			strings.Contains(errStr, "does not support tools") && c.useTools {
-			// Disable tools and retry
-			c.useTools = false
-			resp.Body.Close()
-			return c.sendRequest(stream, onToken)
-		}
-
-		return nil, fmt.Errorf("API error %d: %s", resp.StatusCode, errStr)
-	}
-
-	var result *ChatResult
-
-	if stream {
-		result, err = c.handleStreamResponse(resp.Body, onToken)
-		if err != nil {
-			return nil, err
-		}
-	} else {
-		var chatResp ChatResponse
-		respBody, _ := io.ReadAll(resp.Body)
-		c.logDebug("response", respBody)
-		if err := json.Unmarshal(respBody, &chatResp); err != nil {
-			return nil, fmt.Errorf("failed to decode response: %w", err)
-		}
-		result = &ChatResult{}
-		if len(chatResp.Choices) > 0 {
-			choice := chatResp.Choices[0]
-			result.Content = choice.Message.Content
-			result.ToolCalls = choice.Message.ToolCalls
-			result.FinishReason = choice.FinishReason
-		}
-	}
-
-	// Log the final result (especially useful for streaming)
-	if resultJSON, err := json.Marshal(result); err == nil {
-		c.logDebug("result", resultJSON)
-	}
-
-	// Add assistant message to history
-	msg := Message{
-		Role:    "assistant",
-		Content: result.Content,
-	}
-	if len(result.ToolCalls) > 0 {
-		msg.ToolCalls = result.ToolCalls
-	}
-	c.history = append(c.history, msg)
-
-	return result, nil
+	return result, events.Err()
 }
 
 func (c *Client) handleStreamResponse(body io.Reader, onToken func(string)) (*ChatResult, error) {
-	scanner := bufio.NewScanner(body)
-	// Increase buffer size for large responses
-	buf := make([]byte, 0, 64*1024)
-	scanner.Buffer(buf, 1024*1024)
+	events := newSSEScanner(body, c.cfg.GetStreamBufferSize())
 
 	result := &ChatResult{}
 	var contentBuilder strings.Builder
 	toolCallsMap := make(map[int]*tools.ToolCall)
 
-	for scanner.Scan() {
-		line := scanner.Text()
-		if !strings.HasPrefix(line, "data: ") {
-			continue
-		}
-
-		data := strings.TrimPrefix(line, "data: ")
+	for events.Scan() {
+		data := events.Data()
 		if data == "[DONE]" {
 			break
 		}
@@ -1219,10 +1521,58 @@ func (c *Client) handleStreamResponse(body io.Reader, onToken func(string)) (*Ch
 	for _, tc := range toolCallsMap {
 		result.ToolCalls = append(result.ToolCalls, *tc)
 	}
+	repairToolCalls(result.ToolCalls)
+
+	return result, events.Err()
+}
+
+// RawRequest sends prompt as the only message - no history, system prompt
+// or tools - without streaming, and returns the HTTP status and the response
+// body exactly as received. For telling prompt problems from server problems.
+func (c *Client) RawRequest(prompt string) (int, []byte, error) {
+	req := ChatRequest{
+		Model:       c.cfg.Model,
+		Messages:    []Message{{Role: "user", Content: prompt}},
+		MaxTokens:   c.cfg.GetMaxTokens(),
+		Temperature: c.cfg.Temperature,
+	}
+	return c.postJSON("/chat/completions", req, "raw")
+}
+
+// postJSON POSTs payload to an OpenAI-compatible API path and returns the
+// status code and body, without interpreting either
+func (c *Client) postJSON(path string, payload interface{}, logPrefix string) (int, []byte, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+	c.logDebug(logPrefix+"-request", body)
+
+	endpoint := c.cfg.OpenAIBaseURL() + path
+	httpReq, err := http.NewRequest("POST", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+	if c.cfg.APIKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+c.cfg.APIKey)
+	}
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
 
-	return result, scanner.Err()
+	respBody, err := io.ReadAll(resp.Body)
+	c.logDebug(logPrefix+"-response", respBody)
+	return resp.StatusCode, respBody, err
 }
 
+// Complete sends a one-shot prompt without tools or history and returns the
+// reply. It always requests a single completion: n (alternatives) only
+// applies to chat, where the user can pick one, so it isn't sent here.
 func (c *Client) Complete(prompt string, stream bool, onToken func(string)) (string, error) {
 	// Temporarily disable tools for simple completion
 	origUseTools := c.useTools
@@ -1233,14 +1583,14 @@ func (c *Client) Complete(prompt string, stream bool, onToken func(string)) (str
 		{Role: "user", Content: prompt},
 	}
 
-	if c.cfg.SystemPrompt != "" {
+	if c.cfg.SystemPrompt != "" && !c.cfg.NoSystemPrompt {
 		messages = append([]Message{{Role: "system", Content: c.cfg.SystemPrompt}}, messages...)
 	}
 
 	req := ChatRequest{
 		Model:       c.cfg.Model,
 		Messages:    messages,
-		MaxTokens:   c.cfg.MaxTokens,
+		MaxTokens:   c.cfg.GetMaxTokens(),
 		Temperature: c.cfg.Temperature,
 		Stream:      stream,
 	}
@@ -1250,7 +1600,7 @@ func (c *Client) Complete(prompt string, stream bool, onToken func(string)) (str
 		return "", fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	endpoint := strings.TrimSuffix(c.cfg.APIEndpoint, "/") + "/chat/completions"
+	endpoint := c.cfg.OpenAIBaseURL() + "/chat/completions"
 	httpReq, err := http.NewRequest("POST", endpoint, bytes.NewReader(body))
 	if err != nil {
 		return "", fmt.Errorf("failed to create request: %w", err)
@@ -1269,7 +1619,7 @@ func (c *Client) Complete(prompt string, stream bool, onToken func(string)) (str
 
 	if resp.StatusCode != http.StatusOK {
 		bodyBytes, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("API error %d: %s", resp.StatusCode, string(bodyBytes))
+		return "", newAPIError(resp.StatusCode, bodyBytes)
 	}
 
 	if stream {
@@ -0,0 +1,789 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"aicli/internal/config"
+	"aicli/internal/session"
+)
+
+func TestHasFencedAction(t *testing.T) {
+	fence := "```"
+	tests := []struct {
+		name  string
+		reply string
+		want  bool
+	}{
+		{"plain text", "The build passes.", false},
+		{"example code", "Use a map:\n" + fence + "go\nm := map[string]int{}\n" + fence, false},
+		{"unrelated JSON", fence + "json\n{\"name\": \"aicli\", \"version\": \"1.0\"}\n" + fence, false},
+		{"shell block", "Run this:\n" + fence + "bash\ngo test ./...\n" + fence, true},
+		{"prompted commands", fence + "\n$ go build\n$ ./aicli\n" + fence, true},
+		{"mixed output is not a command", fence + "\n$ go build\nok\n" + fence, false},
+		{"JSON tool call", fence + "json\n{\"name\": \"write_file\", \"arguments\": {\"path\": \"a.go\"}}\n" + fence, true},
+		{"tool key", fence + "\n{\"tool\": \"read_file\", \"path\": \"a.go\"}\n" + fence, true},
+		{"function object", fence + "\n{\"function\": {\"name\": \"git_status\"}}\n" + fence, true},
+		{"raw tool call", fence + "\nrun_command {\"command\": \"ls\"}\n" + fence, true},
+		{"unknown tool", fence + "json\n{\"name\": \"launch_rocket\"}\n" + fence, false},
+		{"unclosed fence", fence + "bash\ngo test", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := HasFencedAction(tt.reply); got != tt.want {
+				t.Errorf("HasFencedAction(%q) = %v, want %v", tt.reply, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSystemPrompt(t *testing.T) {
+	withMemory := t.TempDir()
+	if err := session.NewMemoryFile(withMemory).Add("we use pnpm, not npm"); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name       string
+		cfg        config.Config
+		workDir    string
+		wantEmpty  bool
+		wantMemory bool
+	}{
+		{"prompt with memory", config.Config{SystemPrompt: "You are helpful."}, withMemory, false, true},
+		{"prompt without memory", config.Config{SystemPrompt: "You are helpful."}, t.TempDir(), false, false},
+		{"empty prompt sends no memory", config.Config{}, withMemory, true, false},
+		{"disabled prompt sends no memory", config.Config{SystemPrompt: "You are helpful.", NoSystemPrompt: true}, withMemory, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SystemPrompt(&tt.cfg, tt.workDir)
+			if (got == "") != tt.wantEmpty {
+				t.Fatalf("SystemPrompt = %q, want empty %v", got, tt.wantEmpty)
+			}
+			if gotMemory := strings.Contains(got, "we use pnpm"); gotMemory != tt.wantMemory {
+				t.Errorf("memory in prompt = %v, want %v", gotMemory, tt.wantMemory)
+			}
+		})
+	}
+}
+
+func TestAutoContinueOnEveryPath(t *testing.T) {
+	send := map[string]func(c *Client) (*ChatResult, error){
+		"Chat": func(c *Client) (*ChatResult, error) { return c.Chat("write a poem", false, nil) },
+		"ChatWithContext": func(c *Client) (*ChatResult, error) {
+			return c.ChatWithContext(context.Background(), "write a poem", false, nil)
+		},
+		"ContinueWithToolResults": func(c *Client) (*ChatResult, error) {
+			c.AddUserInterrupt("write a poem")
+			return c.ContinueWithToolResults(false, nil)
+		},
+		"ContinueWithToolResultsContext": func(c *Client) (*ChatResult, error) {
+			c.AddUserInterrupt("write a poem")
+			return c.ContinueWithToolResultsContext(context.Background(), false, nil)
+		},
+	}
+	tests := []struct {
+		name         string
+		autoContinue bool
+		want         string
+		wantRequests int
+	}{
+		{"auto_continue on", true, "roses are red, violets are blue", 2},
+		{"auto_continue off", false, "roses are red, ", 1},
+	}
+	for _, tt := range tests {
+		for path, fn := range send {
+			t.Run(tt.name+"/"+path, func(t *testing.T) {
+				requests := 0
+				srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					requests++
+					w.Header().Set("Content-Type", "application/json")
+					if requests == 1 {
+						w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"roses are red, "},"finish_reason":"length"}]}`))
+						return
+					}
+					w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"violets are blue"},"finish_reason":"stop"}]}`))
+				}))
+				defer srv.Close()
+
+				c := New(&config.Config{APIEndpoint: srv.URL + "/v1", Model: "test", AutoContinue: tt.autoContinue})
+				result, err := fn(c)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if result.Content != tt.want || requests != tt.wantRequests {
+					t.Errorf("got %q in %d requests, want %q in %d", result.Content, requests, tt.want, tt.wantRequests)
+				}
+			})
+		}
+	}
+}
+
+func TestUseToolsControlsRequestTools(t *testing.T) {
+	tests := []struct {
+		name      string
+		set       func(c *Client)
+		wantTools bool
+	}{
+		{"default", func(c *Client) {}, true},
+		{"tools off", func(c *Client) { c.SetUseTools(false) }, false},
+		{"tools back on", func(c *Client) { c.SetUseTools(false); c.ResetUseTools() }, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var req map[string]any
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				json.NewDecoder(r.Body).Decode(&req)
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"hello"},"finish_reason":"stop"}]}`))
+			}))
+			defer srv.Close()
+
+			c := New(&config.Config{APIEndpoint: srv.URL + "/v1", Model: "test"})
+			tt.set(c)
+			if _, err := c.Chat("hi", false, nil); err != nil {
+				t.Fatal(err)
+			}
+			if _, got := req["tools"]; got != tt.wantTools {
+				t.Errorf("request has tools = %v, want %v", got, tt.wantTools)
+			}
+		})
+	}
+}
+
+func TestAsideLeavesHistoryAlone(t *testing.T) {
+	var req ChatRequest
+	var raw map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &req)
+		json.Unmarshal(body, &raw)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"To see the tests."},"finish_reason":"stop"}]}`))
+	}))
+	defer srv.Close()
+
+	c := New(&config.Config{APIEndpoint: srv.URL + "/v1", Model: "test"})
+	c.history = []Message{{Role: "system", Content: "system"}, {Role: "user", Content: "fix it"}, toolCall("a"), toolResult("a")}
+	before := len(c.history)
+
+	result, err := c.Aside("Why did you read main.go?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Content != "To see the tests." {
+		t.Errorf("answer = %q", result.Content)
+	}
+	if len(c.history) != before {
+		t.Errorf("history grew from %d to %d messages", before, len(c.history))
+	}
+	if n := len(req.Messages); n != before+1 || req.Messages[n-1].Content != "Why did you read main.go?" {
+		t.Errorf("sent %d messages, want the %d of the conversation plus the question", n, before)
+	}
+	if _, ok := raw["tools"]; ok {
+		t.Error("aside request offered tools")
+	}
+}
+
+func TestIsNewConversation(t *testing.T) {
+	tests := []struct {
+		name    string
+		history []Message
+		want    bool
+	}{
+		{"empty", nil, true},
+		{"system only", []Message{{Role: "system", Content: "s"}, {Role: "system", Content: "memory"}}, true},
+		{"user message", []Message{{Role: "system", Content: "s"}, {Role: "user", Content: "hi"}}, false},
+		{"restored reply", []Message{{Role: "assistant", Content: "hello"}}, false},
+	}
+	for _, tt := range tests {
+		c := New(&config.Config{Model: "test"})
+		c.history = tt.history
+		if got := c.IsNewConversation(); got != tt.want {
+			t.Errorf("%s: IsNewConversation() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestReadPullProgress(t *testing.T) {
+	tests := []struct {
+		name    string
+		stream  string
+		want    []PullProgress
+		wantErr string
+	}{
+		{
+			name: "download",
+			stream: `{"status":"pulling manifest"}
+{"status":"pulling abc","digest":"sha256:abc","total":2048,"completed":1024}
+
+not json
+{"status":"pulling abc","digest":"sha256:abc","total":2048,"completed":2048}
+{"status":"success"}
+`,
+			want: []PullProgress{
+				{Status: "pulling manifest"},
+				{Status: "pulling abc", Digest: "sha256:abc", Total: 2048, Completed: 1024},
+				{Status: "pulling abc", Digest: "sha256:abc", Total: 2048, Completed: 2048},
+				{Status: "success"},
+			},
+		},
+		{
+			name:    "error in stream",
+			stream:  "{\"status\":\"pulling manifest\"}\n{\"error\":\"pull model manifest: file does not exist\"}\n{\"status\":\"success\"}\n",
+			want:    []PullProgress{{Status: "pulling manifest"}},
+			wantErr: "pull failed: pull model manifest: file does not exist",
+		},
+	}
+	for _, tt := range tests {
+		var got []PullProgress
+		err := readPullProgress(strings.NewReader(tt.stream), func(p PullProgress) {
+			got = append(got, p)
+		})
+		if (err == nil) != (tt.wantErr == "") || (err != nil && err.Error() != tt.wantErr) {
+			t.Errorf("%s: error = %v, want %q", tt.name, err, tt.wantErr)
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: got %d updates %+v, want %+v", tt.name, len(got), got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s: update %d = %+v, want %+v", tt.name, i, got[i], tt.want[i])
+			}
+		}
+	}
+}
+
+func TestPullModel(t *testing.T) {
+	var path, model string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		var req struct {
+			Model string `json:"model"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		model = req.Model
+		io.WriteString(w, "{\"status\":\"pulling manifest\"}\n{\"status\":\"success\"}\n")
+	}))
+	defer srv.Close()
+
+	c := New(&config.Config{APIEndpoint: srv.URL + "/v1", Model: "test"})
+	var statuses []string
+	if err := c.PullModel("llama3", func(p PullProgress) { statuses = append(statuses, p.Status) }); err != nil {
+		t.Fatal(err)
+	}
+	if path != "/api/pull" || model != "llama3" || strings.Join(statuses, ",") != "pulling manifest,success" {
+		t.Errorf("pulled %q via %s with updates %q", model, path, statuses)
+	}
+
+	cloud := New(&config.Config{APIEndpoint: "https://api.openai.com/v1", Model: "test"})
+	if err := cloud.PullModel("gpt", nil); err == nil || !strings.Contains(err.Error(), "not an Ollama endpoint") {
+		t.Errorf("PullModel on a cloud endpoint: error = %v, want not supported", err)
+	}
+}
+
+func TestLatestDebugFile(t *testing.T) {
+	base := time.Now().Add(-time.Hour)
+	tests := []struct {
+		name    string
+		files   map[string]time.Duration // name -> age offset from base
+		want    string
+		wantErr bool
+	}{
+		{"no debug dir", nil, "", true},
+		{"only other files", map[string]time.Duration{"notes.txt": 0}, "", true},
+		{"newest by time", map[string]time.Duration{
+			"20260101-100000-001-request.json":  0,
+			"20260101-090000-002-response.json": time.Minute, // renamed or copied later
+			"later.txt":                         time.Hour,
+		}, "20260101-090000-002-response.json", false},
+		{"same time breaks ties by name", map[string]time.Duration{
+			"20260101-100000-001-request.json":  0,
+			"20260101-100000-002-response.json": 0,
+		}, "20260101-100000-002-response.json", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workDir := t.TempDir()
+			dir := filepath.Join(workDir, ".aicli", "debug")
+			if tt.files != nil {
+				os.MkdirAll(filepath.Join(dir, "sub.json"), 0755) // directories are skipped
+			}
+			for name, offset := range tt.files {
+				path := filepath.Join(dir, name)
+				os.WriteFile(path, []byte("{}"), 0644)
+				os.Chtimes(path, base.Add(offset), base.Add(offset))
+			}
+
+			c := NewWithDebug(&config.Config{Model: "test"}, workDir)
+			got, err := c.LatestDebugFile()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LatestDebugFile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != filepath.Join(dir, tt.want) {
+				t.Errorf("LatestDebugFile() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestToggleDebug(t *testing.T) {
+	workDir := t.TempDir()
+	c := New(&config.Config{Model: "test"})
+	c.workDir = workDir
+	if c.DebugEnabled() {
+		t.Fatal("debug enabled by default")
+	}
+	c.SetDebugDir(workDir)
+	if !c.DebugEnabled() {
+		t.Error("SetDebugDir did not enable debug files")
+	}
+	c.logDebug("request", []byte(`{"model":"test"}`))
+	if _, err := c.LatestDebugFile(); err != nil {
+		t.Errorf("no debug file written while enabled: %v", err)
+	}
+	c.DisableDebug()
+	if c.DebugEnabled() {
+		t.Error("DisableDebug left debug files on")
+	}
+}
+
+func TestSelectAlternative(t *testing.T) {
+	var req ChatRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&req)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[
+			{"index":0,"message":{"role":"assistant","content":"first"},"finish_reason":"stop"},
+			{"index":1,"message":{"role":"assistant","content":"second"},"finish_reason":"stop"},
+			{"index":2,"message":{"role":"assistant","content":"","tool_calls":[{"id":"c1","type":"function","function":{"name":"list_files","arguments":"{}"}}]},"finish_reason":"tool_calls"}
+		]}`))
+	}))
+	defer srv.Close()
+
+	c := New(&config.Config{APIEndpoint: srv.URL + "/v1", Model: "test", N: 3})
+	result, err := c.Chat("hello", true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.N != 3 || req.Stream {
+		t.Errorf("request n = %d, stream = %v; want 3 choices, not streamed", req.N, req.Stream)
+	}
+	if len(result.Alternatives) != 3 || result.Content != "first" {
+		t.Fatalf("result = %+v, want 3 alternatives defaulting to the first", result)
+	}
+	last := func() Message { return c.history[len(c.history)-1] }
+	if last().Content != "first" {
+		t.Errorf("assistant turn = %q, want the first choice by default", last().Content)
+	}
+
+	c.SelectAlternative(result.Alternatives[2])
+	if m := last(); m.Role != "assistant" || m.Content != "" || len(m.ToolCalls) != 1 || m.ToolCalls[0].ID != "c1" {
+		t.Errorf("assistant turn after picking choice 3 = %+v, want its tool call", m)
+	}
+	c.SelectAlternative(result.Alternatives[1])
+	if m := last(); m.Content != "second" || len(m.ToolCalls) != 0 {
+		t.Errorf("assistant turn after picking choice 2 = %+v", m)
+	}
+	if n := len(c.history); c.history[n-2].Role != "user" {
+		t.Errorf("history = %+v, want the pick to replace the reply, not add one", c.history)
+	}
+}
+
+func TestResultFromChoices(t *testing.T) {
+	var resp ChatResponse
+	json.Unmarshal([]byte(`{"choices":[{"message":{"role":"assistant","content":"only"},"finish_reason":"stop"}]}`), &resp)
+	single := resultFromChoices(&resp)
+	if single.Content != "only" || single.FinishReason != "stop" || single.Alternatives != nil {
+		t.Errorf("one choice: %+v, want no alternatives", single)
+	}
+	if empty := resultFromChoices(&ChatResponse{}); empty.Content != "" || empty.Alternatives != nil {
+		t.Errorf("no choices: %+v", empty)
+	}
+}
+
+func TestCapToolResult(t *testing.T) {
+	long := strings.Repeat("a", 600) + strings.Repeat("m", 300) + strings.Repeat("z", 100)
+	tests := []struct {
+		name     string
+		s        string
+		max      int
+		wantHead string
+		wantTail string
+		omitted  int // 0 means unchanged
+	}{
+		{"under the cap", "short", 100, "", "", 0},
+		{"at the cap", strings.Repeat("x", 100), 100, "", "", 0},
+		{"no cap", long, 0, "", "", 0},
+		{"head and tail kept", long, 300, strings.Repeat("a", 200), strings.Repeat("z", 100), 700},
+		{"multibyte runes kept whole", strings.Repeat("é", 100), 31, strings.Repeat("é", 10), strings.Repeat("é", 5), 170},
+	}
+	for _, tt := range tests {
+		got := capToolResult(tt.s, tt.max)
+		if tt.omitted == 0 {
+			if got != tt.s {
+				t.Errorf("%s: capToolResult() changed the result to %q", tt.name, got)
+			}
+			continue
+		}
+		want := fmt.Sprintf("%s\n\n... [%d characters omitted] ...\n\n%s", tt.wantHead, tt.omitted, tt.wantTail)
+		if got != want {
+			t.Errorf("%s: capToolResult() = %q, want %q", tt.name, got, want)
+		}
+		if !utf8.ValidString(got) {
+			t.Errorf("%s: capToolResult() split a rune", tt.name)
+		}
+	}
+}
+
+func TestAddToolResultCapped(t *testing.T) {
+	big := strings.Repeat("line of output\n", 200) // 3000 characters
+	tests := []struct {
+		name   string
+		max    int
+		capped bool
+	}{
+		{"default cap", 0, false},
+		{"configured cap", 1000, true},
+		{"cap disabled", -1, false},
+	}
+	for _, tt := range tests {
+		c := New(&config.Config{Model: "test", MaxToolResult: tt.max})
+		c.useTools = true
+		c.AddToolResult("call_1", big)
+		got := c.history[len(c.history)-1]
+		if got.Role != "tool" || got.ToolCallID != "call_1" {
+			t.Errorf("%s: history message = %+v", tt.name, got)
+		}
+		if capped := strings.Contains(got.Content, "characters omitted"); capped != tt.capped || (!capped && got.Content != big) {
+			t.Errorf("%s: %d characters in history, capped = %v, want %v", tt.name, len(got.Content), capped, tt.capped)
+		}
+		if tt.capped && len(got.Content) > tt.max+100 {
+			t.Errorf("%s: %d characters in history, want about %d", tt.name, len(got.Content), tt.max)
+		}
+	}
+}
+
+func TestSystemPromptSent(t *testing.T) {
+	workDir := t.TempDir()
+	if err := session.NewMemoryFile(workDir).Add("API base is /v2"); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name       string
+		noPrompt   bool
+		wantSystem bool
+	}{
+		{"system prompt", false, true},
+		{"no_system_prompt", true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sent []Message
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var req ChatRequest
+				json.NewDecoder(r.Body).Decode(&req)
+				sent = req.Messages
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}]}`))
+			}))
+			defer srv.Close()
+
+			cfg := &config.Config{APIEndpoint: srv.URL + "/v1", Model: "test", SystemPrompt: "You are helpful.", NoSystemPrompt: tt.noPrompt}
+			hasSystem := func() bool { return len(sent) > 0 && sent[0].Role == "system" }
+
+			// Complete sends only the configured prompt
+			if _, err := New(cfg).Complete("hello", false, nil); err != nil {
+				t.Fatal(err)
+			}
+			if hasSystem() != tt.wantSystem {
+				t.Errorf("Complete sent system message = %v, want %v", hasSystem(), tt.wantSystem)
+			}
+			if tt.wantSystem && sent[0].Content != "You are helpful." {
+				t.Errorf("Complete system message = %q", sent[0].Content)
+			}
+
+			// Chat adds the project memory to it
+			if _, err := NewWithDebug(cfg, workDir).Chat("hello", false, nil); err != nil {
+				t.Fatal(err)
+			}
+			if hasSystem() != tt.wantSystem {
+				t.Fatalf("Chat sent system message = %v, want %v", hasSystem(), tt.wantSystem)
+			}
+			if tt.wantSystem && !strings.Contains(sent[0].Content, "- API base is /v2") {
+				t.Errorf("Chat system message lacks the project memory:\n%s", sent[0].Content)
+			}
+		})
+	}
+}
+
+func TestRawRequestBypassesConversation(t *testing.T) {
+	var sent map[string]json.RawMessage
+	status := http.StatusOK
+	reply := `{"choices":[{"message":{"role":"assistant","content":"pong"}}],  "x_server":"llama"}`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sent = nil
+		json.NewDecoder(r.Body).Decode(&sent)
+		w.WriteHeader(status)
+		w.Write([]byte(reply))
+	}))
+	defer srv.Close()
+
+	c := NewWithDebug(&config.Config{APIEndpoint: srv.URL + "/v1", Model: "test", SystemPrompt: "You are helpful."}, t.TempDir())
+	if _, err := c.Chat("earlier question", false, nil); err != nil {
+		t.Fatal(err)
+	}
+	historyLen := len(c.history)
+
+	for _, status = range []int{http.StatusOK, http.StatusInternalServerError} {
+		gotStatus, body, err := c.RawRequest("ping")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if gotStatus != status || string(body) != reply {
+			t.Errorf("RawRequest = %d %s, want %d and the body as received", gotStatus, body, status)
+		}
+		if got := string(sent["messages"]); got != `[{"role":"user","content":"ping"}]` {
+			t.Errorf("messages sent = %s, want the lone prompt", got)
+		}
+		if _, ok := sent["tools"]; ok {
+			t.Error("RawRequest sent tools")
+		}
+		if got := string(sent["stream"]); got == "true" {
+			t.Error("RawRequest streamed")
+		}
+	}
+	if len(c.history) != historyLen {
+		t.Errorf("RawRequest changed the history: %d messages, want %d", len(c.history), historyLen)
+	}
+}
+
+func TestMaxTokensCapSent(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  config.Config
+		want string
+	}{
+		{"unlimited is capped", config.Config{}, fmt.Sprint(config.DefaultMaxResponseTokens)},
+		{"configured limit", config.Config{MaxTokens: 512}, "512"},
+		{"cap disabled", config.Config{MaxResponseTokens: -1}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sent map[string]json.RawMessage
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				sent = nil
+				json.NewDecoder(r.Body).Decode(&sent)
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}]}`))
+			}))
+			defer srv.Close()
+
+			cfg := tt.cfg
+			cfg.APIEndpoint, cfg.Model = srv.URL+"/v1", "test"
+			c := New(&cfg)
+			for path, send := range map[string]func() error{
+				"Chat":     func() error { _, err := c.Chat("hi", false, nil); return err },
+				"Complete": func() error { _, err := c.Complete("hi", false, nil); return err },
+			} {
+				if err := send(); err != nil {
+					t.Fatal(err)
+				}
+				if got := string(sent["max_tokens"]); got != tt.want {
+					t.Errorf("%s sent max_tokens %q, want %q", path, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestAutoContinueCap(t *testing.T) {
+	tests := []struct {
+		name         string
+		respond      func(n int, w http.ResponseWriter) // n counts from 0
+		want         string
+		wantRequests int
+		wantReason   string
+	}{
+		{"never finishes", func(n int, w http.ResponseWriter) {
+			fmt.Fprintf(w, `{"choices":[{"message":{"role":"assistant","content":"part %d. "},"finish_reason":"length"}]}`, n)
+		}, "part 0. part 1. part 2. part 3. ", 1 + maxContinuations, "length"},
+		{"continuation fails", func(n int, w http.ResponseWriter) {
+			if n > 0 {
+				http.Error(w, "overloaded", http.StatusServiceUnavailable)
+				return
+			}
+			fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"part 0. "},"finish_reason":"length"}]}`)
+		}, "part 0. ", 2, "length"},
+		{"complete tool call runs first", func(n int, w http.ResponseWriter) {
+			fmt.Fprint(w, `{"choices":[{"message":{"role":"assistant","content":"Reading. ","tool_calls":[{"id":"c1","type":"function","function":{"name":"read_file","arguments":"{\"path\":\"main.go\"}"}}]},"finish_reason":"length"}]}`)
+		}, "Reading. ", 1, "length"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var prompts []string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var req ChatRequest
+				json.NewDecoder(r.Body).Decode(&req)
+				prompts = append(prompts, req.Messages[len(req.Messages)-1].Content)
+				w.Header().Set("Content-Type", "application/json")
+				tt.respond(len(prompts)-1, w)
+			}))
+			defer srv.Close()
+
+			c := New(&config.Config{APIEndpoint: srv.URL + "/v1", Model: "test", AutoContinue: true})
+			result, err := c.Chat("write an essay", false, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Content != tt.want || result.FinishReason != tt.wantReason || len(prompts) != tt.wantRequests {
+				t.Errorf("got %q (%s) in %d requests, want %q (%s) in %d",
+					result.Content, result.FinishReason, len(prompts), tt.want, tt.wantReason, tt.wantRequests)
+			}
+			for i, p := range prompts[1:] {
+				if p != continuePrompt {
+					t.Errorf("request %d asked %q, want the continue prompt", i+2, p)
+				}
+			}
+
+			// The parts are one assistant message; the continue prompts are gone
+			if len(c.history) != 2 || c.history[0].Content != "write an essay" ||
+				c.history[1].Role != "assistant" || c.history[1].Content != tt.want {
+				t.Errorf("history = %+v, want the prompt and one stitched reply", c.history)
+			}
+		})
+	}
+}
+
+// checkToolPairing fails unless every tool call in history is answered by a
+// following "tool" message and every tool message answers an earlier call
+func checkToolPairing(t *testing.T, history []Message) {
+	t.Helper()
+	open := map[string]bool{}
+	for i, m := range history {
+		switch m.Role {
+		case "assistant":
+			for id := range open {
+				t.Errorf("call %s unanswered before message %d", id, i)
+			}
+			open = map[string]bool{}
+			for _, tc := range m.ToolCalls {
+				open[tc.ID] = true
+			}
+		case "tool":
+			if !open[m.ToolCallID] {
+				t.Errorf("message %d answers unknown call %q", i, m.ToolCallID)
+			}
+			delete(open, m.ToolCallID)
+		default:
+			for id := range open {
+				t.Errorf("call %s unanswered before message %d", id, i)
+			}
+			open = map[string]bool{}
+		}
+	}
+	for id := range open {
+		t.Errorf("call %s unanswered at end of history", id)
+	}
+}
+
+func TestHistoryFromEntries(t *testing.T) {
+	entries := []session.Entry{
+		{Type: "user", Content: "what is in this repo?"},
+		{Type: "assistant", Content: "Let me look."},
+		{Type: "tool_call", ToolName: "read_file", ToolArgs: `{"path":"go.mod"}`},
+		{Type: "tool_call", ToolName: "list_files", ToolArgs: `{}`},
+		{Type: "tool_result", ToolName: "list_files", Content: "go.mod\nmain.go"},
+		{Type: "tool_result", ToolName: "read_file", Content: "module demo"},
+		{Type: "tool_call", ToolName: "run_command", ToolArgs: `{"command":"go test"}`},
+		{Type: "assistant", Content: "A small Go module."},
+		{Type: "tool_result", ToolName: "grep", Content: "no matches"},
+		{Type: "tool_call", ToolName: "git_status", ToolArgs: `{}`},
+	}
+
+	t.Run("native tools", func(t *testing.T) {
+		history := HistoryFromEntries(entries, true)
+		checkToolPairing(t, history)
+
+		var roles []string
+		for _, m := range history {
+			roles = append(roles, m.Role)
+		}
+		if got, want := strings.Join(roles, ","), "user,assistant,tool,tool,assistant,user"; got != want {
+			t.Fatalf("roles = %s, want %s", got, want)
+		}
+
+		calls := history[1].ToolCalls
+		if len(calls) != 2 {
+			t.Fatalf("assistant has %d tool calls, want 2 (the unanswered run_command dropped)", len(calls))
+		}
+		if calls[0].Function.Name != "read_file" || calls[0].Function.Arguments != `{"path":"go.mod"}` || calls[0].Type != "function" {
+			t.Errorf("first call = %+v", calls[0])
+		}
+		// Results are matched to their call by tool name, not position
+		byID := map[string]string{}
+		for _, tc := range calls {
+			byID[tc.ID] = tc.Function.Name
+		}
+		if byID[history[2].ToolCallID] != "list_files" || history[2].Content != "go.mod\nmain.go" {
+			t.Errorf("first tool message = %+v, want the list_files result", history[2])
+		}
+		if byID[history[3].ToolCallID] != "read_file" || history[3].Content != "module demo" {
+			t.Errorf("second tool message = %+v, want the read_file result", history[3])
+		}
+
+		// A result without a call becomes a note; a trailing call without a
+		// result leaves no empty assistant message behind
+		last := history[5]
+		if last.Content != "[Previous tool result from grep]: no matches" {
+			t.Errorf("orphan result = %q", last.Content)
+		}
+	})
+
+	t.Run("text tools", func(t *testing.T) {
+		history := HistoryFromEntries(entries, false)
+		for _, m := range history {
+			if len(m.ToolCalls) > 0 || m.Role == "tool" {
+				t.Fatalf("text tool history has native tool message %+v", m)
+			}
+		}
+		if len(history) != 6 {
+			t.Fatalf("got %d messages, want 6: %+v", len(history), history)
+		}
+		if history[2].Role != "user" || history[2].Content != "[Tool Result]:\ngo.mod\nmain.go" {
+			t.Errorf("tool result message = %+v", history[2])
+		}
+	})
+}
+
+func TestLoadHistory(t *testing.T) {
+	c := New(&config.Config{Model: "test"})
+	c.history = append(c.history, Message{Role: "user", Content: "stale conversation"})
+	n := c.LoadHistory([]session.Entry{
+		{Type: "user", Content: "hello"},
+		{Type: "assistant", Content: "hi"},
+	})
+	if n != 2 {
+		t.Errorf("LoadHistory = %d, want 2", n)
+	}
+	history := c.history
+	for _, m := range history {
+		if m.Content == "stale conversation" {
+			t.Error("LoadHistory kept the previous conversation")
+		}
+	}
+	if got := history[len(history)-1]; got.Role != "assistant" || got.Content != "hi" {
+		t.Errorf("last message = %+v", got)
+	}
+}
@@ -0,0 +1,128 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	// compactKeep is how many recent messages Compact keeps verbatim
+	compactKeep = 6
+	// maxCompactMessage caps each message in the transcript to summarize
+	maxCompactMessage = 1500
+	// maxCompactTranscript caps the whole transcript; the middle is dropped
+	maxCompactTranscript = 24000
+)
+
+// compactPrompt asks for the summary that replaces the older messages
+const compactPrompt = `Summarize the conversation below so it can continue without it. Keep the user's goal and requests, decisions made, files created or changed, commands run and their outcome, errors still open and what remains to do. Be concise; use short bullet points.
+
+Conversation:
+`
+
+// ErrNothingToCompact is returned by Compact when the conversation has no
+// older messages to summarize
+var ErrNothingToCompact = errors.New("nothing to compact: the conversation is already short")
+
+// Compact replaces all but the most recent messages with a summary written
+// by the model, so a long conversation fits the context again. The system
+// prompt is kept, and the kept messages never start inside a tool call
+// batch, so tool calls stay with their results. Returns how many messages
+// were replaced.
+func (c *Client) Compact(ctx context.Context) (int, error) {
+	system, rest, cut := splitForCompaction(c.history)
+	if cut <= 0 {
+		return 0, ErrNothingToCompact
+	}
+
+	side := c.WithModel(c.cfg.Model)
+	side.useTools = false
+	side.history = []Message{{Role: "user", Content: compactPrompt + compactTranscript(rest[:cut])}}
+	result, err := side.sendRequestWithContext(ctx, false, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to summarize the conversation: %w", err)
+	}
+	summary := strings.TrimSpace(result.Content)
+	if result.FinishReason == "interrupted" || summary == "" {
+		return 0, errors.New("failed to summarize the conversation: no summary returned")
+	}
+
+	history := append([]Message{}, system...)
+	history = append(history, Message{Role: "user", Content: "Summary of the earlier conversation (compacted to fit the context):\n\n" + summary})
+	if rest[cut].Role != "assistant" {
+		history = append(history, Message{Role: "assistant", Content: "Understood. Continuing from there."})
+	}
+	c.history = append(history, rest[cut:]...)
+	return cut, nil
+}
+
+// splitForCompaction separates the system messages from the rest of the
+// history; rest[:cut] are the messages Compact would replace (none if cut
+// is 0)
+func splitForCompaction(history []Message) (system, rest []Message, cut int) {
+	for _, m := range history {
+		if m.Role == "system" {
+			system = append(system, m)
+		} else {
+			rest = append(rest, m)
+		}
+	}
+	cut = len(rest) - compactKeep
+	for cut > 0 && !compactionBoundary(rest, cut) {
+		cut--
+	}
+	return system, rest, max(cut, 0)
+}
+
+// compactionBoundary reports whether the kept messages can start at
+// rest[i]: at a user message, or at an assistant message once every tool
+// call before it has its result before it. The latter lets a single prompt
+// followed by a long tool loop be compacted.
+func compactionBoundary(rest []Message, i int) bool {
+	switch rest[i].Role {
+	case "user":
+		return true
+	case "assistant":
+		pending := make(map[string]bool)
+		for _, m := range rest[:i] {
+			for _, tc := range m.ToolCalls {
+				pending[tc.ID] = true
+			}
+			if m.Role == "tool" {
+				delete(pending, m.ToolCallID)
+			}
+		}
+		return len(pending) == 0
+	}
+	return false
+}
+
+// compactTranscript renders messages as plain text for summarizing, with
+// long messages and an overlong middle cut
+func compactTranscript(messages []Message) string {
+	var sb strings.Builder
+	for _, m := range messages {
+		if content := strings.TrimSpace(m.Content); content != "" {
+			fmt.Fprintf(&sb, "%s: %s\n", m.Role, truncateForContext(content, maxCompactMessage))
+		}
+		for _, tc := range m.ToolCalls {
+			fmt.Fprintf(&sb, "%s called %s(%s)\n", m.Role, tc.Function.Name, truncateForContext(string(tc.Function.Arguments), 200))
+		}
+	}
+	transcript := sb.String()
+	if len(transcript) <= maxCompactTranscript {
+		return transcript
+	}
+	head := maxCompactTranscript / 3
+	tail := maxCompactTranscript - head
+	return transcript[:head] + "\n... (middle of the conversation omitted) ...\n" + transcript[len(transcript)-tail:]
+}
+
+// IsContextLengthError reports whether err is the server rejecting a request
+// that doesn't fit the model's context
+func IsContextLengthError(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.Kind == ErrorContextLength
+}
@@ -0,0 +1,158 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"aicli/internal/config"
+	"aicli/internal/tools"
+)
+
+// toolCall returns an assistant message calling one tool
+func toolCall(id string) Message {
+	tc := tools.ToolCall{ID: id, Type: "function"}
+	tc.Function.Name = "read_file"
+	tc.Function.Arguments = `{"path":"main.go"}`
+	return Message{Role: "assistant", ToolCalls: []tools.ToolCall{tc}}
+}
+
+// toolResult returns the result message for a tool call
+func toolResult(id string) Message {
+	return Message{Role: "tool", Content: "package main", ToolCallID: id}
+}
+
+// toolLoop returns one prompt followed by n tool call rounds
+func toolLoop(n int) []Message {
+	history := []Message{{Role: "system", Content: "system"}, {Role: "user", Content: "fix the build"}}
+	for i := 0; i < n; i++ {
+		id := string(rune('a' + i))
+		history = append(history, toolCall(id), toolResult(id))
+	}
+	return history
+}
+
+func TestSplitForCompaction(t *testing.T) {
+	// Two parallel calls whose results straddle the default cut
+	parallel := []Message{{Role: "user", Content: "go"}}
+	for i := 0; i < 3; i++ {
+		id := string(rune('a' + i))
+		parallel = append(parallel, toolCall(id), toolResult(id))
+	}
+	both := toolCall("x")
+	both.ToolCalls = append(both.ToolCalls, toolCall("y").ToolCalls...)
+	parallel = append(parallel, both, toolResult("x"), toolResult("y"),
+		Message{Role: "assistant", Content: "done"}, Message{Role: "user", Content: "thanks"},
+		Message{Role: "assistant", Content: "welcome"})
+
+	tests := []struct {
+		name       string
+		history    []Message
+		wantSystem int
+		wantCut    int
+	}{
+		{"short conversation", toolLoop(2), 1, 0},
+		{"cut at a user message", []Message{
+			{Role: "user", Content: "1"}, {Role: "assistant", Content: "1"},
+			{Role: "user", Content: "2"}, {Role: "assistant", Content: "2"},
+			{Role: "user", Content: "3"}, {Role: "assistant", Content: "3"},
+			{Role: "user", Content: "4"}, {Role: "assistant", Content: "4"},
+		}, 0, 2},
+		// One prompt and a long tool loop: rest is user + 10 rounds, the
+		// cut lands on the assistant call 6 messages from the end
+		{"single prompt with a tool loop", toolLoop(10), 1, 15},
+		// rest[7] is the result for y; the cut backs off to the assistant
+		// message that made both calls
+		{"parallel tool calls stay together", parallel, 0, 7},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			system, rest, cut := splitForCompaction(tt.history)
+			if len(system) != tt.wantSystem {
+				t.Errorf("system = %d messages, want %d", len(system), tt.wantSystem)
+			}
+			if cut != tt.wantCut {
+				t.Fatalf("cut = %d, want %d", cut, tt.wantCut)
+			}
+			if cut == 0 {
+				return
+			}
+			// No kept tool result may refer to a replaced call
+			replaced := make(map[string]bool)
+			for _, m := range rest[:cut] {
+				for _, tc := range m.ToolCalls {
+					replaced[tc.ID] = true
+				}
+			}
+			for _, m := range rest[cut:] {
+				if m.Role == "tool" && replaced[m.ToolCallID] {
+					t.Errorf("kept result for %s whose call was replaced", m.ToolCallID)
+				}
+			}
+		})
+	}
+}
+
+func TestContextLengthErrorCompactsAndRetries(t *testing.T) {
+	tests := []struct {
+		name        string
+		autoCompact bool
+		history     []Message
+		limit       int // Messages the server accepts
+		wantErr     bool
+		wantCalls   int
+		wantCompact int
+	}{
+		{"auto_compact off", false, toolLoop(10), 10, true, 1, 0},
+		{"compacts and retries", true, toolLoop(10), 10, false, 3, 15},
+		{"nothing to compact", true, toolLoop(2), 3, true, 1, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls++
+				var req ChatRequest
+				json.NewDecoder(r.Body).Decode(&req)
+				w.Header().Set("Content-Type", "application/json")
+				switch {
+				case strings.HasPrefix(req.Messages[0].Content, compactPrompt):
+					w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"- read main.go"},"finish_reason":"stop"}]}`))
+				case len(req.Messages) > tt.limit:
+					w.WriteHeader(http.StatusBadRequest)
+					w.Write([]byte(`{"error":{"message":"maximum context length exceeded","code":"context_length_exceeded"}}`))
+				default:
+					w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"fixed"},"finish_reason":"stop"}]}`))
+				}
+			}))
+			defer srv.Close()
+
+			c := New(&config.Config{APIEndpoint: srv.URL + "/v1", Model: "test", AutoCompact: tt.autoCompact})
+			c.history = tt.history
+			result, err := c.ContinueWithToolResultsContext(context.Background(), false, nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("requests = %d, want %d", calls, tt.wantCalls)
+			}
+			if err != nil {
+				if !IsContextLengthError(err) {
+					t.Errorf("err = %v, want a context-length error", err)
+				}
+				return
+			}
+			if result.Content != "fixed" || result.Compacted != tt.wantCompact {
+				t.Errorf("result = %q compacted %d, want %q compacted %d", result.Content, result.Compacted, "fixed", tt.wantCompact)
+			}
+			// The kept messages start at an assistant call, so no
+			// acknowledgement is inserted between it and the summary
+			if got := c.history[1].Role + "," + c.history[2].Role; got != "user,assistant" || len(c.history[2].ToolCalls) == 0 {
+				t.Errorf("history after compaction starts %s, want the summary then the kept tool call", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package client
+
+import (
+	"encoding/json"
+	"strings"
+	"unicode/utf8"
+
+	"aicli/internal/tools"
+)
+
+const (
+	// charsPerToken is the rule of thumb behind EstimateTokens
+	charsPerToken = 4
+	// messageOverhead is the tokens a message costs beyond its content
+	// (role and framing)
+	messageOverhead = 4
+	// imageTokens is a rough cost of one attached image
+	imageTokens = 800
+)
+
+// EstimateTokens estimates the tokens in text at about four characters per
+// token. Real counts depend on the model's tokenizer; this is meant for
+// showing how full the context is, not for exact limits.
+func EstimateTokens(text string) int {
+	return (utf8.RuneCountInString(text) + charsPerToken - 1) / charsPerToken
+}
+
+// MessageUsage is one message's estimated share of the context
+type MessageUsage struct {
+	Role    string
+	Tokens  int
+	Preview string // First line of the content, or the tools called
+}
+
+// ContextUsage is an estimate of how much context the next request uses
+type ContextUsage struct {
+	SystemTokens int            // System prompt
+	ToolTokens   int            // Tool definitions, 0 when tools are off
+	Messages     []MessageUsage // The conversation, oldest first, without system messages
+	Compactable  int            // How many of the oldest Messages /compact would replace
+}
+
+// Total returns the estimated tokens of the whole request
+func (u ContextUsage) Total() int {
+	total := u.SystemTokens + u.ToolTokens
+	for _, m := range u.Messages {
+		total += m.Tokens
+	}
+	return total
+}
+
+// ContextUsage estimates the context taken by the system prompt, the tool
+// definitions and each message of the conversation
+func (c *Client) ContextUsage() ContextUsage {
+	system, rest, cut := splitForCompaction(c.history)
+	var u ContextUsage
+	for _, m := range system {
+		u.SystemTokens += messageTokens(m)
+	}
+	if c.useTools {
+		if defs, err := json.Marshal(tools.GetTools()); err == nil {
+			u.ToolTokens = EstimateTokens(string(defs))
+		}
+	}
+	for _, m := range rest {
+		u.Messages = append(u.Messages, MessageUsage{Role: m.Role, Tokens: messageTokens(m), Preview: messagePreview(m)})
+	}
+	u.Compactable = cut
+	return u
+}
+
+// messageTokens estimates the tokens of one message
+func messageTokens(m Message) int {
+	n := messageOverhead + EstimateTokens(m.Content) + len(m.Images)*imageTokens
+	for _, tc := range m.ToolCalls {
+		n += EstimateTokens(tc.Function.Name) + EstimateTokens(string(tc.Function.Arguments))
+	}
+	return n
+}
+
+// messagePreview summarizes a message on one line
+func messagePreview(m Message) string {
+	for _, line := range strings.Split(m.Content, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			return line
+		}
+	}
+	if len(m.ToolCalls) > 0 {
+		names := make([]string, len(m.ToolCalls))
+		for i, tc := range m.ToolCalls {
+			names[i] = tc.Function.Name
+		}
+		return "calls " + strings.Join(names, ", ")
+	}
+	if len(m.Images) > 0 {
+		return "(image)"
+	}
+	return "(empty)"
+}
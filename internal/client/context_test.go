@@ -0,0 +1,90 @@
+package client
+
+import (
+	"testing"
+
+	"aicli/internal/config"
+)
+
+func TestEstimateTokens(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"", 0},
+		{"abc", 1},
+		{"abcd", 1},
+		{"abcde", 2},
+		{"héllo wörld", 3}, // Counted in characters, not bytes
+		{string(make([]byte, 4000)), 1000},
+	}
+	for _, tt := range tests {
+		if got := EstimateTokens(tt.text); got != tt.want {
+			t.Errorf("EstimateTokens(%d chars) = %d, want %d", len(tt.text), got, tt.want)
+		}
+	}
+}
+
+func TestMessageTokensAndPreview(t *testing.T) {
+	tests := []struct {
+		name        string
+		msg         Message
+		wantTokens  int
+		wantPreview string
+	}{
+		{"text", Message{Role: "user", Content: "\n  fix the build\nplease"}, 4 + 6, "fix the build"},
+		{"tool call", toolCall("a"), 4 + 3 + 5, "calls read_file"},
+		{"tool result", toolResult("a"), 4 + 3, "package main"},
+		{"image", Message{Role: "user", Images: []string{"AAAA"}}, 4 + imageTokens, "(image)"},
+		{"empty", Message{Role: "assistant"}, 4, "(empty)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := messageTokens(tt.msg); got != tt.wantTokens {
+				t.Errorf("messageTokens = %d, want %d", got, tt.wantTokens)
+			}
+			if got := messagePreview(tt.msg); got != tt.wantPreview {
+				t.Errorf("messagePreview = %q, want %q", got, tt.wantPreview)
+			}
+		})
+	}
+}
+
+func TestContextUsage(t *testing.T) {
+	tests := []struct {
+		name      string
+		useTools  bool
+		wantTools bool
+	}{
+		{"tools on", true, true},
+		{"tools off", false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(&config.Config{Model: "test"})
+			c.SetUseTools(tt.useTools)
+			c.history = toolLoop(10)
+			u := c.ContextUsage()
+
+			if u.SystemTokens != messageTokens(c.history[0]) {
+				t.Errorf("SystemTokens = %d, want %d", u.SystemTokens, messageTokens(c.history[0]))
+			}
+			if (u.ToolTokens > 0) != tt.wantTools {
+				t.Errorf("ToolTokens = %d, want tools counted: %v", u.ToolTokens, tt.wantTools)
+			}
+			if len(u.Messages) != len(c.history)-1 || u.Messages[0].Role != "user" {
+				t.Fatalf("%d messages starting %q, want %d starting with the user", len(u.Messages), u.Messages[0].Role, len(c.history)-1)
+			}
+			if u.Compactable <= 0 || u.Compactable >= len(u.Messages) {
+				t.Errorf("Compactable = %d of %d", u.Compactable, len(u.Messages))
+			}
+			want := u.SystemTokens + u.ToolTokens
+			for _, m := range c.history[1:] {
+				want += messageTokens(m)
+			}
+			if u.Total() != want {
+				t.Errorf("Total() = %d, want %d", u.Total(), want)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Well-known kinds of API error
+const (
+	ErrorContextLength = "context_length"  // Conversation too long for the model
+	ErrorModelNotFound = "model_not_found" // Unknown or unpulled model
+	ErrorRateLimited   = "rate_limited"    // Too many requests
+	ErrorUnauthorized  = "unauthorized"    // Missing or wrong API key
+)
+
+// APIError is an error response from the API, with the message pulled out
+// of the OpenAI, Ollama or vLLM style error body
+type APIError struct {
+	Status  int
+	Message string // Message from the error body, or the whole body
+	Kind    string // One of the Error* kinds, or "" if not recognized
+	Hint    string // What to do about a well-known error
+	Body    string // Raw response body
+}
+
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("API error %d: %s", e.Status, e.Message)
+	if e.Hint != "" {
+		msg += " (" + e.Hint + ")"
+	}
+	return msg
+}
+
+// errorHints are the hints for each kind of error
+var errorHints = map[string]string{
+	ErrorContextLength: "the conversation no longer fits the model's context; run /compact (or turn on auto_compact) and try again",
+	ErrorModelNotFound: "use /models to list the available models and /model <name> to switch",
+	ErrorRateLimited:   "the server is limiting requests; wait a moment and try again",
+	ErrorUnauthorized:  "check api_key in the config or pass --key",
+}
+
+// newAPIError builds the error for a non-200 response
+func newAPIError(status int, body []byte) *APIError {
+	e := &APIError{Status: status, Body: string(body)}
+	e.Message, e.Kind = parseErrorBody(body)
+	if e.Message == "" {
+		e.Message = truncateForContext(strings.TrimSpace(string(body)), 500)
+	}
+	if e.Message == "" {
+		e.Message = http.StatusText(status)
+	}
+	if e.Kind == "" {
+		e.Kind = classifyError(status, e.Message)
+	}
+	e.Hint = errorHints[e.Kind]
+	return e
+}
+
+// parseErrorBody extracts the message from {"error": {"message": ...}}
+// (OpenAI, llama.cpp), {"error": "..."} (Ollama) or {"message": ...}
+// (vLLM), and the kind when the body carries a recognized code or type
+func parseErrorBody(body []byte) (string, string) {
+	var raw struct {
+		Error   json.RawMessage `json:"error"`
+		Message string          `json:"message"`
+	}
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return "", ""
+	}
+
+	var detail struct {
+		Message string          `json:"message"`
+		Type    string          `json:"type"`
+		Code    json.RawMessage `json:"code"`
+	}
+	var text string
+	switch {
+	case json.Unmarshal(raw.Error, &text) == nil && text != "":
+		return strings.TrimSpace(text), ""
+	case json.Unmarshal(raw.Error, &detail) == nil && detail.Message != "":
+		code := strings.Trim(string(detail.Code), `"`)
+		return strings.TrimSpace(detail.Message), classifyCode(code + " " + detail.Type)
+	}
+	return strings.TrimSpace(raw.Message), ""
+}
+
+// classifyCode maps an error code or type to a kind
+func classifyCode(code string) string {
+	code = strings.ToLower(code)
+	switch {
+	case strings.Contains(code, "context_length") || strings.Contains(code, "context_size"):
+		return ErrorContextLength
+	case strings.Contains(code, "model_not_found"):
+		return ErrorModelNotFound
+	case strings.Contains(code, "rate_limit"):
+		return ErrorRateLimited
+	case strings.Contains(code, "invalid_api_key"):
+		return ErrorUnauthorized
+	}
+	return ""
+}
+
+// contextLengthPhrases are how servers word a context-length error
+var contextLengthPhrases = []string{
+	"context length", "context_length", "maximum context", "context window",
+	"context size", "exceeds the context", "too many tokens", "prompt is too long",
+	"input is too long", "reduce the length",
+}
+
+// classifyError recognizes well-known errors by status and message
+func classifyError(status int, message string) string {
+	lower := strings.ToLower(message)
+	for _, phrase := range contextLengthPhrases {
+		if strings.Contains(lower, phrase) {
+			return ErrorContextLength
+		}
+	}
+	if strings.Contains(lower, "model") &&
+		(strings.Contains(lower, "not found") || strings.Contains(lower, "does not exist") || strings.Contains(lower, "no such model")) {
+		return ErrorModelNotFound
+	}
+	switch status {
+	case http.StatusTooManyRequests:
+		return ErrorRateLimited
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return ErrorUnauthorized
+	}
+	return ""
+}
@@ -0,0 +1,103 @@
+package client
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"aicli/internal/config"
+)
+
+func TestNewAPIError(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		body        string
+		wantMessage string
+		wantKind    string
+	}{
+		{"openai context length", 400, `{"error":{"message":"This model's maximum context length is 8192 tokens.","type":"invalid_request_error","code":"context_length_exceeded"}}`,
+			"This model's maximum context length is 8192 tokens.", ErrorContextLength},
+		{"openai model not found", 404, `{"error":{"message":"The model ` + "`gpt-9`" + ` does not exist","type":"invalid_request_error","code":"model_not_found"}}`,
+			"The model `gpt-9` does not exist", ErrorModelNotFound},
+		{"openai bad key", 401, `{"error":{"message":"Incorrect API key provided.","type":"invalid_request_error","code":"invalid_api_key"}}`,
+			"Incorrect API key provided.", ErrorUnauthorized},
+		{"openai rate limit by type", 429, `{"error":{"message":"Slow down","type":"rate_limit_exceeded","code":null}}`,
+			"Slow down", ErrorRateLimited},
+		{"numeric code", 400, `{"error":{"message":"bad request","code":400}}`, "bad request", ""},
+		{"ollama model not found", 404, `{"error":"model \"llama9\" not found, try pulling it first"}`,
+			`model "llama9" not found, try pulling it first`, ErrorModelNotFound},
+		{"llama.cpp context size", 400, `{"error":{"code":400,"message":"the request exceeds the available context size, try increasing it","type":"exceed_context_size_error"}}`,
+			"the request exceeds the available context size, try increasing it", ErrorContextLength},
+		{"vllm message", 400, `{"object":"error","message":"This model's maximum context length is 4096 tokens. However, you requested 5000 tokens.","type":"BadRequestError","code":400}`,
+			"This model's maximum context length is 4096 tokens. However, you requested 5000 tokens.", ErrorContextLength},
+		{"plain text", 502, "upstream connect error\n", "upstream connect error", ""},
+		{"plain text rate limit", 429, "too many requests", "too many requests", ErrorRateLimited},
+		{"forbidden", 403, `{"error":{"message":"not allowed"}}`, "not allowed", ErrorUnauthorized},
+		{"empty body", 503, "", "Service Unavailable", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newAPIError(tt.status, []byte(tt.body))
+			if e.Message != tt.wantMessage || e.Kind != tt.wantKind {
+				t.Errorf("newAPIError = message %q kind %q, want %q %q", e.Message, e.Kind, tt.wantMessage, tt.wantKind)
+			}
+			if e.Status != tt.status || e.Body != tt.body {
+				t.Errorf("status %d body %q not kept", e.Status, e.Body)
+			}
+			if e.Hint != errorHints[tt.wantKind] {
+				t.Errorf("hint = %q", e.Hint)
+			}
+		})
+	}
+}
+
+func TestAPIErrorString(t *testing.T) {
+	tests := []struct {
+		status int
+		body   string
+		want   string
+	}{
+		{400, `{"error":{"message":"maximum context length exceeded","code":"context_length_exceeded"}}`,
+			"API error 400: maximum context length exceeded (the conversation no longer fits the model's context; run /compact (or turn on auto_compact) and try again)"},
+		{404, `{"error":"model 'x' not found"}`,
+			"API error 404: model 'x' not found (use /models to list the available models and /model <name> to switch)"},
+		{500, `{"error":"boom"}`, "API error 500: boom"},
+	}
+	for _, tt := range tests {
+		if got := newAPIError(tt.status, []byte(tt.body)).Error(); got != tt.want {
+			t.Errorf("Error() = %q, want %q", got, tt.want)
+		}
+	}
+
+	long := strings.Repeat("x", 2000)
+	if msg := newAPIError(500, []byte(long)).Message; len(msg) >= len(long) {
+		t.Errorf("a long unstructured body is kept whole (%d bytes)", len(msg))
+	}
+}
+
+func TestRequestReturnsAPIError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error":{"message":"The model does not exist","code":"model_not_found"}}`))
+	}))
+	defer srv.Close()
+
+	c := New(&config.Config{APIEndpoint: srv.URL + "/v1", Model: "test"})
+	for name, call := range map[string]func() error{
+		"Complete":        func() error { _, err := c.Complete("hi", false, nil); return err },
+		"Complete stream": func() error { _, err := c.Complete("hi", true, func(string) {}); return err },
+	} {
+		err := call()
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) {
+			t.Fatalf("%s: error %v is not an *APIError", name, err)
+		}
+		if apiErr.Kind != ErrorModelNotFound || !strings.Contains(err.Error(), "/models") {
+			t.Errorf("%s: error = %v", name, err)
+		}
+	}
+}
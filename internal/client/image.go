@@ -0,0 +1,106 @@
+package client
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// contentPart is one element of an OpenAI-style multimodal content array
+type contentPart struct {
+	Type     string    `json:"type"`
+	Text     string    `json:"text,omitempty"`
+	ImageURL *imageURL `json:"image_url,omitempty"`
+}
+
+type imageURL struct {
+	URL string `json:"url"`
+}
+
+// multimodalMessage is a Message whose content is a text+image array
+type multimodalMessage struct {
+	Role    string        `json:"role"`
+	Content []contentPart `json:"content"`
+}
+
+// MarshalJSON sends messages with images as OpenAI-style content arrays
+// ({"type":"image_url","image_url":{"url":"data:image/png;base64,..."}}).
+// The native Ollama endpoint uses the images field instead (OllamaChatRequest).
+func (r ChatRequest) MarshalJSON() ([]byte, error) {
+	type plain ChatRequest
+	messages := make([]interface{}, len(r.Messages))
+	for i, msg := range r.Messages {
+		if len(msg.Images) > 0 {
+			messages[i] = toMultimodal(msg)
+		} else {
+			messages[i] = msg
+		}
+	}
+	return json.Marshal(struct {
+		plain
+		Messages []interface{} `json:"messages"`
+	}{plain(r), messages})
+}
+
+// toMultimodal converts a message with base64 images to a content array
+func toMultimodal(msg Message) multimodalMessage {
+	parts := []contentPart{{Type: "text", Text: msg.Content}}
+	for _, img := range msg.Images {
+		parts = append(parts, contentPart{
+			Type:     "image_url",
+			ImageURL: &imageURL{URL: "data:" + imageMimeType(img) + ";base64," + img},
+		})
+	}
+	return multimodalMessage{Role: msg.Role, Content: parts}
+}
+
+// imageMimeType sniffs the type of a base64-encoded image
+func imageMimeType(b64 string) string {
+	head := b64
+	if len(head) > 64 {
+		head = head[:64]
+	}
+	data, _ := base64.StdEncoding.DecodeString(head[:len(head)/4*4])
+	return http.DetectContentType(data)
+}
+
+// AttachImage reads an image file and attaches it to the next user message
+func (c *Client) AttachImage(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if mime := http.DetectContentType(data); !strings.HasPrefix(mime, "image/") {
+		return fmt.Errorf("%s is not an image (%s)", path, mime)
+	}
+	c.pendingImages = append(c.pendingImages, base64.StdEncoding.EncodeToString(data))
+	return nil
+}
+
+// PendingImages returns how many images will go with the next user message
+func (c *Client) PendingImages() int {
+	return len(c.pendingImages)
+}
+
+// takePendingImages returns and clears the images for the next user message
+func (c *Client) takePendingImages() []string {
+	images := c.pendingImages
+	c.pendingImages = nil
+	return images
+}
+
+// imageRejected handles a 400 response to a request with images: the images
+// are dropped from history (so later requests work again) and a clear error
+// is returned. Returns nil if the request had no images.
+func (c *Client) imageRejected(status int, body string) error {
+	if status != http.StatusBadRequest || !c.hasImages() {
+		return nil
+	}
+	for i := range c.history {
+		c.history[i].Images = nil
+	}
+	return fmt.Errorf("model %s rejected the image (it may not support image input): %s", c.cfg.Model, body)
+}
@@ -0,0 +1,190 @@
+package client
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"image"
+	"image/png"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"aicli/internal/config"
+)
+
+// writePNG writes a 1x1 PNG to dir and returns its path and base64 encoding
+func writePNG(t *testing.T, dir string) (string, string) {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 1, 1))); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "shot.png")
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path, base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+// visionServer answers both the OpenAI and native Ollama chat endpoints,
+// recording each request path and body. A non-empty reject is returned as
+// a 400 to requests carrying images.
+func visionServer(t *testing.T, reject string) (*httptest.Server, *[]string, *[]string) {
+	var paths, bodies []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		paths = append(paths, r.URL.Path)
+		bodies = append(bodies, string(body))
+		if reject != "" && strings.Contains(string(body), "base64") {
+			http.Error(w, reject, http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/api/chat" {
+			w.Write([]byte(`{"message":{"role":"assistant","content":"a red square"},"done":true}`))
+			return
+		}
+		w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"a red square"},"finish_reason":"stop"}]}`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &paths, &bodies
+}
+
+// routeTo sends all of c's requests to srv, whatever host the endpoint names
+func routeTo(c *Client, srv *httptest.Server) {
+	target, _ := url.Parse(srv.URL)
+	c.httpClient.Transport = roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		r.URL.Scheme, r.URL.Host = target.Scheme, target.Host
+		return http.DefaultTransport.RoundTrip(r)
+	})
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+func TestMultimodalMessage(t *testing.T) {
+	_, b64 := writePNG(t, t.TempDir())
+	body, err := json.Marshal(ChatRequest{
+		Model: "vision",
+		Messages: []Message{
+			{Role: "user", Content: "hi"},
+			{Role: "user", Content: "what is this?", Images: []string{b64}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got struct {
+		Model    string            `json:"model"`
+		Messages []json.RawMessage `json:"messages"`
+	}
+	if err := json.Unmarshal(body, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Model != "vision" || len(got.Messages) != 2 {
+		t.Fatalf("request = %s", body)
+	}
+
+	var plain Message
+	json.Unmarshal(got.Messages[0], &plain)
+	if plain.Content != "hi" {
+		t.Errorf("text-only message = %s, want plain string content", got.Messages[0])
+	}
+
+	var multi multimodalMessage
+	if err := json.Unmarshal(got.Messages[1], &multi); err != nil {
+		t.Fatalf("image message is not a content array: %s", got.Messages[1])
+	}
+	want := []contentPart{
+		{Type: "text", Text: "what is this?"},
+		{Type: "image_url", ImageURL: &imageURL{URL: "data:image/png;base64," + b64}},
+	}
+	if multi.Role != "user" || len(multi.Content) != len(want) {
+		t.Fatalf("image message = %s", got.Messages[1])
+	}
+	for i := range want {
+		if multi.Content[i].Type != want[i].Type || multi.Content[i].Text != want[i].Text ||
+			(want[i].ImageURL != nil && (multi.Content[i].ImageURL == nil || multi.Content[i].ImageURL.URL != want[i].ImageURL.URL)) {
+			t.Errorf("part %d = %+v, want %+v", i, multi.Content[i], want[i])
+		}
+	}
+}
+
+func TestAttachImage(t *testing.T) {
+	dir := t.TempDir()
+	path, b64 := writePNG(t, dir)
+	notImage := filepath.Join(dir, "notes.txt")
+	os.WriteFile(notImage, []byte("just text"), 0644)
+
+	tests := []struct {
+		name     string
+		endpoint string
+		wantPath string
+		wantIn   string // How the image appears in the request body
+	}{
+		{"OpenAI-compatible endpoint", "https://vision.example.com/v1", "/v1/chat/completions", `"url":"data:image/png;base64,` + b64 + `"`},
+		{"Ollama endpoint uses the native API", "http://localhost:11434/v1", "/api/chat", `"images":["` + b64 + `"]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, paths, bodies := visionServer(t, "")
+			c := New(&config.Config{APIEndpoint: tt.endpoint, Model: "vision"})
+			routeTo(c, srv)
+
+			if err := c.AttachImage(notImage); err == nil || !strings.Contains(err.Error(), "not an image") {
+				t.Errorf("attaching a text file: err = %v", err)
+			}
+			if err := c.AttachImage(filepath.Join(dir, "missing.png")); err == nil {
+				t.Error("attaching a missing file succeeded")
+			}
+			if err := c.AttachImage(path); err != nil {
+				t.Fatal(err)
+			}
+			if c.PendingImages() != 1 {
+				t.Fatalf("PendingImages = %d, want 1", c.PendingImages())
+			}
+
+			if _, err := c.Chat("what is this?", false, nil); err != nil {
+				t.Fatal(err)
+			}
+			if c.PendingImages() != 0 {
+				t.Errorf("PendingImages after sending = %d, want 0", c.PendingImages())
+			}
+			if (*paths)[0] != tt.wantPath || !strings.Contains((*bodies)[0], tt.wantIn) {
+				t.Errorf("request to %s lacks %s:\n%s", (*paths)[0], tt.wantIn, (*bodies)[0])
+			}
+		})
+	}
+}
+
+func TestImageRejected(t *testing.T) {
+	path, _ := writePNG(t, t.TempDir())
+	srv, _, bodies := visionServer(t, "model does not support image input")
+	c := New(&config.Config{APIEndpoint: "https://text-only.example.com/v1", Model: "text-only"})
+	routeTo(c, srv)
+
+	if err := c.AttachImage(path); err != nil {
+		t.Fatal(err)
+	}
+	_, err := c.Chat("what is this?", false, nil)
+	if err == nil || !strings.Contains(err.Error(), "rejected the image") || !strings.Contains(err.Error(), "text-only") {
+		t.Fatalf("err = %v, want a clear image rejection", err)
+	}
+
+	// The images are dropped, so the conversation can go on
+	result, err := c.Chat("describe it in words then", false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Content != "a red square" || strings.Contains((*bodies)[len(*bodies)-1], "base64") {
+		t.Errorf("follow-up = %q, body %s", result.Content, (*bodies)[len(*bodies)-1])
+	}
+}
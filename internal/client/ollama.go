@@ -0,0 +1,285 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"aicli/internal/logging"
+	"aicli/internal/tools"
+)
+
+// OllamaChatRequest is for the native /api/chat endpoint (supports images,
+// native tool calling and model options)
+type OllamaChatRequest struct {
+	Model    string                 `json:"model"`
+	Messages []OllamaMessage        `json:"messages"`
+	Tools    []tools.Tool           `json:"tools,omitempty"`
+	Options  map[string]interface{} `json:"options,omitempty"`
+	Think    interface{}            `json:"think,omitempty"` // true or "low"/"medium"/"high"
+	Stream   bool                   `json:"stream"`
+}
+
+// OllamaMessage is a chat message in the native shape: tool call arguments
+// are JSON objects rather than strings, images are a base64 list, and tool
+// results name their tool instead of a call id
+type OllamaMessage struct {
+	Role      string           `json:"role"`
+	Content   string           `json:"content"`
+	Images    []string         `json:"images,omitempty"`
+	ToolCalls []OllamaToolCall `json:"tool_calls,omitempty"`
+	ToolName  string           `json:"tool_name,omitempty"`
+}
+
+// OllamaToolCall is a native tool call
+type OllamaToolCall struct {
+	Function struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	} `json:"function"`
+}
+
+// OllamaChatResponse is the response from native /api/chat endpoint
+type OllamaChatResponse struct {
+	Model         string        `json:"model"`
+	CreatedAt     string        `json:"created_at"`
+	Message       OllamaMessage `json:"message"`
+	Done          bool          `json:"done"`
+	DoneReason    string        `json:"done_reason,omitempty"`
+	TotalDuration int64         `json:"total_duration,omitempty"`
+}
+
+// toOllamaMessages converts history to the native shape. The native API has
+// no call ids, so each tool result carries the name of the call it answers.
+func toOllamaMessages(history []Message) []OllamaMessage {
+	messages := make([]OllamaMessage, 0, len(history))
+	toolNames := make(map[string]string) // Call id -> tool name
+	for _, msg := range history {
+		om := OllamaMessage{
+			Role:    msg.Role,
+			Content: msg.Content,
+			Images:  msg.Images,
+		}
+		if msg.Role == "tool" {
+			om.ToolName = toolNames[msg.ToolCallID]
+		}
+		for _, tc := range msg.ToolCalls {
+			toolNames[tc.ID] = tc.Function.Name
+			var otc OllamaToolCall
+			otc.Function.Name = tc.Function.Name
+			args := strings.TrimSpace(tc.Function.Arguments)
+			if args == "" || !json.Valid([]byte(args)) {
+				args = "{}"
+			}
+			otc.Function.Arguments = json.RawMessage(args)
+			om.ToolCalls = append(om.ToolCalls, otc)
+		}
+		messages = append(messages, om)
+	}
+	return messages
+}
+
+// fromOllamaToolCalls converts native tool calls to the OpenAI shape used
+// everywhere else, assigning ids (the native API has none)
+func fromOllamaToolCalls(calls []OllamaToolCall, firstIndex int) []tools.ToolCall {
+	var result []tools.ToolCall
+	for i, otc := range calls {
+		var tc tools.ToolCall
+		tc.Index = firstIndex + i
+		tc.ID = fmt.Sprintf("call_%d", firstIndex+i)
+		tc.Type = "function"
+		tc.Function.Name = otc.Function.Name
+		tc.Function.Arguments = string(otc.Function.Arguments)
+		result = append(result, tc)
+	}
+	return result
+}
+
+// ollamaOptions maps the configured parameters to native model options
+func (c *Client) ollamaOptions() map[string]interface{} {
+	options := map[string]interface{}{}
+	if c.cfg.Temperature != 0 {
+		options["temperature"] = c.cfg.Temperature
+	}
+	if maxTokens := c.cfg.GetMaxTokens(); maxTokens > 0 {
+		options["num_predict"] = maxTokens
+	}
+	if len(options) == 0 {
+		return nil
+	}
+	return options
+}
+
+// ollamaThink maps a reasoning effort to Ollama's think parameter. Models
+// with effort levels (gpt-oss) take the level; other thinking models only
+// switch thinking on. Returns nil (omitted) when no effort is set.
+func ollamaThink(effort, model string) interface{} {
+	if effort == "" {
+		return nil
+	}
+	if strings.Contains(strings.ToLower(model), "gpt-oss") {
+		return effort
+	}
+	return true
+}
+
+// sendOllamaRequest sends the conversation to Ollama's native /api/chat
+// endpoint, used in native mode and for images on Ollama endpoints
+func (c *Client) sendOllamaRequest(ctx context.Context, stream bool, onToken func(string)) (*ChatResult, error) {
+	req := OllamaChatRequest{
+		Model:    c.cfg.Model,
+		Messages: toOllamaMessages(c.history),
+		Options:  c.ollamaOptions(),
+		Think:    ollamaThink(c.cfg.GetReasoningEffort(), c.cfg.Model),
+		Stream:   stream,
+	}
+	if c.useTools {
+		req.Tools = tools.GetTools()
+	}
+
+	cacheKey := c.ollamaCacheKey(req)
+	if cached := c.loadCachedResponse(cacheKey, onToken); cached != nil {
+		return cached, nil
+	}
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	c.logDebug("ollama-request", body)
+
+	endpoint := c.cfg.OllamaBaseURL() + "/api/chat"
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+	if c.cfg.APIKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+c.cfg.APIKey)
+	}
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		if ctx.Err() == context.Canceled {
+			return &ChatResult{FinishReason: "interrupted"}, nil
+		}
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		c.logDebug("ollama-error", bodyBytes)
+
+		errStr := string(bodyBytes)
+		if resp.StatusCode == http.StatusBadRequest &&
+			strings.Contains(errStr, "does not support tools") && c.useTools {
+			c.useTools = false
+			resp.Body.Close()
+			return c.sendOllamaRequest(ctx, stream, onToken)
+		}
+		if err := c.imageRejected(resp.StatusCode, errStr); err != nil {
+			return nil, err
+		}
+		logging.Error("api error", "status", resp.StatusCode, "body", truncateForContext(errStr, 500))
+		return nil, newAPIError(resp.StatusCode, bodyBytes)
+	}
+
+	var result *ChatResult
+
+	if stream {
+		// Handle streaming response from native Ollama API
+		result, err = c.handleOllamaStreamResponse(ctx, resp.Body, onToken)
+		if err != nil && ctx.Err() != context.Canceled {
+			return nil, err
+		}
+	} else {
+		var ollamaResp OllamaChatResponse
+		respBody, _ := io.ReadAll(resp.Body)
+		c.logDebug("ollama-response", respBody)
+		if err := json.Unmarshal(respBody, &ollamaResp); err != nil {
+			return nil, fmt.Errorf("failed to decode response: %w", err)
+		}
+		result = &ChatResult{
+			Content:      ollamaResp.Message.Content,
+			ToolCalls:    fromOllamaToolCalls(ollamaResp.Message.ToolCalls, 0),
+			FinishReason: ollamaResp.DoneReason,
+		}
+	}
+
+	// Add assistant message to history
+	msg := Message{
+		Role:      "assistant",
+		Content:   result.Content,
+		ToolCalls: result.ToolCalls,
+	}
+	c.history = append(c.history, msg)
+	c.storeCachedResponse(cacheKey, result)
+
+	return result, nil
+}
+
+// handleOllamaStreamResponse handles streaming responses from native Ollama API
+func (c *Client) handleOllamaStreamResponse(ctx context.Context, body io.ReadCloser, onToken func(string)) (*ChatResult, error) {
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			body.Close()
+		case <-done:
+		}
+	}()
+
+	scanner := newLineScanner(body, c.cfg.GetStreamBufferSize())
+
+	result := &ChatResult{}
+	var contentBuilder strings.Builder
+
+	for scanner.Scan() {
+		select {
+		case <-ctx.Done():
+			result.Content = contentBuilder.String()
+			result.FinishReason = "interrupted"
+			return result, nil
+		default:
+		}
+
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+
+		var chunk OllamaChatResponse
+		if err := json.Unmarshal([]byte(line), &chunk); err != nil {
+			continue
+		}
+
+		if chunk.Message.Content != "" {
+			contentBuilder.WriteString(chunk.Message.Content)
+			if onToken != nil {
+				onToken(chunk.Message.Content)
+			}
+		}
+
+		// Native tool calls arrive complete, not as argument fragments
+		if len(chunk.Message.ToolCalls) > 0 {
+			result.ToolCalls = append(result.ToolCalls, fromOllamaToolCalls(chunk.Message.ToolCalls, len(result.ToolCalls))...)
+		}
+
+		if chunk.Done {
+			result.FinishReason = chunk.DoneReason
+			break
+		}
+	}
+
+	result.Content = contentBuilder.String()
+	return result, scanner.Err()
+}
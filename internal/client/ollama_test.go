@@ -0,0 +1,121 @@
+package client
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"aicli/internal/config"
+	"aicli/internal/tools"
+)
+
+func TestToOllamaMessagesNamesToolResults(t *testing.T) {
+	call := func(id, name string) tools.ToolCall {
+		tc := tools.ToolCall{ID: id, Type: "function"}
+		tc.Function.Name = name
+		tc.Function.Arguments = `{}`
+		return tc
+	}
+	history := []Message{
+		{Role: "user", Content: "status?"},
+		{Role: "assistant", ToolCalls: []tools.ToolCall{call("call_0", "git_status"), call("call_1", "get_version")}},
+		{Role: "tool", Content: "clean", ToolCallID: "call_0"},
+		{Role: "tool", Content: "1.2.0", ToolCallID: "call_1"},
+		{Role: "tool", Content: "orphan", ToolCallID: "call_9"},
+	}
+	tests := []struct {
+		index int
+		want  string
+	}{
+		{0, ""},
+		{1, ""},
+		{2, "git_status"},
+		{3, "get_version"},
+		{4, ""},
+	}
+	messages := toOllamaMessages(history)
+	for _, tt := range tests {
+		if got := messages[tt.index].ToolName; got != tt.want {
+			t.Errorf("message %d tool_name = %q, want %q", tt.index, got, tt.want)
+		}
+	}
+}
+
+func TestOllamaResponseCache(t *testing.T) {
+	tests := []struct {
+		name         string
+		cache        bool
+		wantRequests int
+	}{
+		{"cache on", true, 1},
+		{"cache off", false, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests := 0
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				w.Write([]byte(`{"model":"qwen3","message":{"role":"assistant","content":"hello"},"done":true,"done_reason":"stop"}`))
+			}))
+			defer srv.Close()
+
+			cfg := &config.Config{APIEndpoint: srv.URL + "/api", APIMode: "ollama", Model: "qwen3", ResponseCache: tt.cache}
+			dir := t.TempDir()
+			for i := 0; i < 2; i++ {
+				c := New(cfg)
+				c.workDir = dir
+				result, err := c.Chat("hi", false, nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if result.Content != "hello" {
+					t.Errorf("reply %d = %q, want %q", i, result.Content, "hello")
+				}
+			}
+			if requests != tt.wantRequests {
+				t.Errorf("requests = %d, want %d", requests, tt.wantRequests)
+			}
+		})
+	}
+}
+
+func TestReasoningEffortParams(t *testing.T) {
+	tests := []struct {
+		name      string
+		apiMode   string
+		model     string
+		effort    string
+		wantField string
+		want      string // JSON value sent, "" for omitted
+	}{
+		{"OpenAI API", "openai", "o3-mini", "high", "reasoning_effort", `"high"`},
+		{"OpenAI API without effort", "openai", "o3-mini", "", "reasoning_effort", ""},
+		{"OpenAI API with an unknown effort", "openai", "o3-mini", "max", "reasoning_effort", ""},
+		{"Ollama model with levels", "ollama", "gpt-oss:20b", "low", "think", `"low"`},
+		{"Ollama thinking model", "ollama", "qwen3", "medium", "think", "true"},
+		{"Ollama without effort", "ollama", "qwen3", "", "think", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sent map[string]json.RawMessage
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				json.NewDecoder(r.Body).Decode(&sent)
+				if tt.apiMode == "ollama" {
+					w.Write([]byte(`{"message":{"role":"assistant","content":"hello"},"done":true}`))
+					return
+				}
+				w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"hello"},"finish_reason":"stop"}]}`))
+			}))
+			defer srv.Close()
+
+			c := New(&config.Config{APIEndpoint: srv.URL + "/v1", APIMode: tt.apiMode, Model: tt.model, ReasoningEffort: tt.effort})
+			if _, err := c.Chat("hi", false, nil); err != nil {
+				t.Fatal(err)
+			}
+			if got := string(sent[tt.wantField]); got != tt.want {
+				t.Errorf("%s = %q, want %q", tt.wantField, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package client
+
+import (
+	"encoding/json"
+	"strings"
+
+	"aicli/internal/tools"
+)
+
+// repairToolCalls fixes tool call arguments that were cut off mid-stream and
+// marks them Repaired. Arguments that still aren't valid JSON are left as
+// they are, so the tool is rejected with a parse error instead of running
+// with zero values.
+func repairToolCalls(calls []tools.ToolCall) {
+	for i := range calls {
+		args := calls[i].Function.Arguments
+		if strings.TrimSpace(args) == "" || json.Valid([]byte(args)) {
+			continue
+		}
+		if repaired, ok := RepairJSON(args); ok {
+			calls[i].Function.Arguments = repaired
+			calls[i].Repaired = true
+		}
+	}
+}
+
+// RepairJSON makes a best-effort attempt to complete truncated JSON by
+// closing an open string and any open objects and arrays. If that isn't
+// enough (e.g. the cut fell after a key), it drops the incomplete last
+// member. Returns false if no valid JSON could be produced.
+func RepairJSON(s string) (string, bool) {
+	type cut struct {
+		pos   int
+		stack string
+	}
+	var stack []byte
+	var cuts []cut // Positions of commas, where the input can be cut
+	inString, escaped := false, false
+
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case ch == '\\':
+				escaped = true
+			case ch == '"':
+				inString = false
+			}
+			continue
+		}
+		switch ch {
+		case '"':
+			inString = true
+		case '{', '[':
+			stack = append(stack, ch)
+		case '}', ']':
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		case ',':
+			cuts = append(cuts, cut{i, string(stack)})
+		}
+	}
+
+	// Close an open string (dropping a dangling escape) and open containers
+	candidate := s
+	if inString {
+		if escaped {
+			candidate = candidate[:len(candidate)-1]
+		}
+		candidate += `"`
+	}
+	candidate = strings.TrimRight(candidate, " \t\r\n")
+	switch {
+	case strings.HasSuffix(candidate, ","):
+		candidate = strings.TrimSuffix(candidate, ",")
+	case strings.HasSuffix(candidate, ":"):
+		candidate += "null"
+	}
+	if repaired := candidate + closers(string(stack)); json.Valid([]byte(repaired)) {
+		return repaired, true
+	}
+
+	// Drop incomplete trailing members, one at a time
+	for i := len(cuts) - 1; i >= 0; i-- {
+		repaired := s[:cuts[i].pos] + closers(cuts[i].stack)
+		if json.Valid([]byte(repaired)) {
+			return repaired, true
+		}
+	}
+	return s, false
+}
+
+// closers returns the brackets that close the open containers in stack
+func closers(stack string) string {
+	var sb strings.Builder
+	for i := len(stack) - 1; i >= 0; i-- {
+		if stack[i] == '{' {
+			sb.WriteByte('}')
+		} else {
+			sb.WriteByte(']')
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,85 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"aicli/internal/config"
+)
+
+func TestRepairJSON(t *testing.T) {
+	tests := []struct {
+		name   string
+		in     string
+		want   string
+		wantOK bool
+	}{
+		{"missing closing brace", `{"path":"main.go"`, `{"path":"main.go"}`, true},
+		{"cut inside a string", `{"path":"main.g`, `{"path":"main.g"}`, true},
+		{"cut after an escape", `{"content":"a\`, `{"content":"a"}`, true},
+		{"cut inside an array", `{"files":["a.go","b.g`, `{"files":["a.go","b.g"]}`, true},
+		{"nested containers", `{"a":{"b":[1,2`, `{"a":{"b":[1,2]}}`, true},
+		{"trailing comma", `{"path":"main.go",`, `{"path":"main.go"}`, true},
+		{"cut after a colon", `{"path":`, `{"path":null}`, true},
+		{"cut inside a key drops the member", `{"count":1,"pat`, `{"count":1}`, true},
+		{"cut inside a literal drops the member", `{"count":1,"staged":tru`, `{"count":1}`, true},
+		{"already valid", `{"path":"main.go"}`, `{"path":"main.go"}`, true},
+		{"nothing to keep", `{"staged":tru`, `{"staged":tru`, false},
+		{"not JSON", `path=main.go`, `path=main.go`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := RepairJSON(tt.in)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("RepairJSON(%q) = %q, %v; want %q, %v", tt.in, got, ok, tt.want, tt.wantOK)
+			}
+			if ok && !json.Valid([]byte(got)) {
+				t.Errorf("repaired %q is not valid JSON", got)
+			}
+		})
+	}
+}
+
+func TestTruncatedToolCallStream(t *testing.T) {
+	tests := []struct {
+		name         string
+		chunks       []string // Argument fragments streamed before the cut
+		wantArgs     string
+		wantRepaired bool
+	}{
+		{"complete arguments", []string{`{"path":`, `"main.go"}`}, `{"path":"main.go"}`, false},
+		{"cut mid string", []string{`{"path":"main.go","content":"package ma`}, `{"path":"main.go","content":"package ma"}`, true},
+		{"unrepairable", []string{`{"staged":tr`}, `{"staged":tr`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/event-stream")
+				fmt.Fprint(w, `data: {"choices":[{"delta":{"tool_calls":[{"index":0,"id":"c1","type":"function","function":{"name":"write_file","arguments":""}}]}}]}`+"\n\n")
+				for _, chunk := range tt.chunks {
+					arg, _ := json.Marshal(chunk)
+					fmt.Fprintf(w, `data: {"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":%s}}]}}]}`+"\n\n", arg)
+				}
+				// The stream ends here, without a finish reason
+			}))
+			defer srv.Close()
+
+			c := New(&config.Config{APIEndpoint: srv.URL + "/v1", Model: "test"})
+			result, err := c.Chat("write main.go", true, func(string) {})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(result.ToolCalls) != 1 {
+				t.Fatalf("got %d tool calls, want 1", len(result.ToolCalls))
+			}
+			tc := result.ToolCalls[0]
+			if tc.Function.Arguments != tt.wantArgs || tc.Repaired != tt.wantRepaired {
+				t.Errorf("arguments = %q (repaired %v), want %q (repaired %v)",
+					tc.Function.Arguments, tc.Repaired, tt.wantArgs, tt.wantRepaired)
+			}
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package client
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"strings"
+
+	"aicli/internal/logging"
+)
+
+// minStreamBuffer keeps a misconfigured stream_buffer_size usable
+const minStreamBuffer = 4 * 1024
+
+// lineScanner reads a streamed response line by line, like bufio.Scanner.
+// Lines that fit the buffer are read directly; a longer one (a large tool
+// call's arguments in a single SSE event) is assembled from buffer-sized
+// pieces instead of failing with bufio.ErrTooLong.
+type lineScanner struct {
+	r    *bufio.Reader
+	line string
+	err  error
+}
+
+// newLineScanner reads body with a buffer of size bytes
+func newLineScanner(body io.Reader, size int) *lineScanner {
+	return &lineScanner{r: bufio.NewReaderSize(body, max(size, minStreamBuffer))}
+}
+
+// Scan advances to the next line, without its line ending. It returns
+// false at the end of the body or on a read error, reported by Err.
+func (s *lineScanner) Scan() bool {
+	if s.err != nil {
+		return false
+	}
+	chunk, isPrefix, err := s.r.ReadLine()
+	if err != nil {
+		s.setErr(err)
+		return false
+	}
+	if !isPrefix {
+		s.line = string(chunk)
+		return true
+	}
+
+	// Longer than the buffer: ReadLine's slice is only valid until the next
+	// read, so collect the pieces
+	var sb strings.Builder
+	sb.Write(chunk)
+	for isPrefix {
+		chunk, isPrefix, err = s.r.ReadLine()
+		if err != nil {
+			s.setErr(err)
+			break
+		}
+		sb.Write(chunk)
+	}
+	logging.Debug("stream line longer than buffer", "bytes", sb.Len(), "buffer", s.r.Size())
+	s.line = sb.String()
+	return true
+}
+
+// Text returns the line read by the last Scan
+func (s *lineScanner) Text() string {
+	return s.line
+}
+
+// Err returns the read error that stopped Scan, or nil at the end of the body
+func (s *lineScanner) Err() error {
+	if s.err == io.EOF {
+		return nil
+	}
+	return s.err
+}
+
+func (s *lineScanner) setErr(err error) {
+	if s.err == nil {
+		s.err = err
+	}
+}
+
+// sseScanner reads the events of a server-sent event stream. It follows
+// the SSE framing: lines end in LF, CRLF or CR; lines starting with ":"
+// are comments (keep-alives); fields other than data (event:, id:,
+// retry:) are ignored; and the data lines of an event are joined with
+// newlines until a blank line ends it. Servers that omit the blank line
+// between events are handled too: a data line after one that already
+// holds a complete JSON payload starts a new event.
+type sseScanner struct {
+	lines   *lineScanner
+	pending []string // Lines split off a CR-framed line, not yet handled
+	data    []string // Data lines of the event being read
+	event   string
+}
+
+// newSSEScanner reads body with a line buffer of size bytes
+func newSSEScanner(body io.Reader, size int) *sseScanner {
+	return &sseScanner{lines: newLineScanner(body, size)}
+}
+
+// Scan advances to the next event with data, returning false at the end
+// of the stream or on a read error, reported by Err
+func (s *sseScanner) Scan() bool {
+	for {
+		line, ok := s.nextLine()
+		if !ok {
+			// An event cut off by the end of the stream is still delivered
+			return s.dispatch()
+		}
+
+		if line == "" {
+			if s.dispatch() {
+				return true
+			}
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+
+		field, value, found := strings.Cut(line, ":")
+		if found {
+			value = strings.TrimPrefix(value, " ")
+		}
+		if field != "data" {
+			continue
+		}
+		if len(s.data) > 0 && completePayload(strings.Join(s.data, "\n")) {
+			s.dispatch()
+			s.data = append(s.data, value)
+			return true
+		}
+		s.data = append(s.data, value)
+	}
+}
+
+// Data returns the data of the event read by the last Scan
+func (s *sseScanner) Data() string {
+	return s.event
+}
+
+// Err returns the read error that stopped Scan, or nil at the end of the stream
+func (s *sseScanner) Err() error {
+	return s.lines.Err()
+}
+
+// nextLine returns the next line of the stream, splitting lines that
+// contain bare CRs (which lineScanner doesn't treat as line endings)
+func (s *sseScanner) nextLine() (string, bool) {
+	if len(s.pending) == 0 {
+		if !s.lines.Scan() {
+			return "", false
+		}
+		line := s.lines.Text()
+		if !strings.Contains(line, "\r") {
+			return line, true
+		}
+		s.pending = strings.Split(line, "\r")
+	}
+	line := s.pending[0]
+	s.pending = s.pending[1:]
+	return line, true
+}
+
+// dispatch ends the current event, making its data the result of Scan.
+// It reports false if the event had no data.
+func (s *sseScanner) dispatch() bool {
+	if len(s.data) == 0 {
+		return false
+	}
+	s.event = strings.Join(s.data, "\n")
+	s.data = s.data[:0]
+	return true
+}
+
+// completePayload reports whether data is a whole event payload on its own:
+// valid JSON or the [DONE] sentinel
+func completePayload(data string) bool {
+	return data == "[DONE]" || json.Valid([]byte(data))
+}
@@ -0,0 +1,128 @@
+package client
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// scanLines returns every line a lineScanner reads from body
+func scanLines(body io.Reader, size int) ([]string, error) {
+	var lines []string
+	s := newLineScanner(body, size)
+	for s.Scan() {
+		lines = append(lines, s.Text())
+	}
+	return lines, s.Err()
+}
+
+func TestLineScannerLongLines(t *testing.T) {
+	long := "data: " + strings.Repeat("x", 100*1024)
+	tests := []struct {
+		name string
+		body string
+		size int
+		want []string
+	}{
+		{"short lines", "a\nb\r\nc", 0, []string{"a", "b", "c"}},
+		{"line over the buffer", long + "\nnext\n", minStreamBuffer, []string{long, "next"}},
+		{"over the buffer with CRLF", long + "\r\nnext\r\n", minStreamBuffer, []string{long, "next"}},
+		{"over the buffer at the end", "first\n" + long, minStreamBuffer, []string{"first", long}},
+		{"buffer below the minimum", long + "\n", 16, []string{long}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := scanLines(strings.NewReader(tt.body), tt.size)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("read %d lines, want %d", len(got), len(tt.want))
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("line %d: %d bytes, want %d", i, len(got[i]), len(tt.want[i]))
+				}
+			}
+		})
+	}
+}
+
+// failingReader returns data, then err
+type failingReader struct {
+	data string
+	err  error
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if r.data == "" {
+		return 0, r.err
+	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+func TestLineScannerReadError(t *testing.T) {
+	errBroken := errors.New("connection reset")
+	got, err := scanLines(&failingReader{data: "a\nb\n", err: errBroken}, 0)
+	if strings.Join(got, ",") != "a,b" || !errors.Is(err, errBroken) {
+		t.Errorf("got %q, %v; want a,b and the read error", got, err)
+	}
+}
+
+func TestSSEScannerOversizedEvent(t *testing.T) {
+	args := strings.Repeat(`{\"content\":\"`+strings.Repeat("y", 1000)+`\"}`, 200)
+	payload := `{"choices":[{"delta":{"tool_calls":[{"function":{"arguments":"` + args + `"}}]}}]}`
+	body := "data: " + payload + "\n\ndata: [DONE]\n\n"
+
+	s := newSSEScanner(strings.NewReader(body), minStreamBuffer)
+	var events []string
+	for s.Scan() {
+		events = append(events, s.Data())
+	}
+	if s.Err() != nil {
+		t.Fatal(s.Err())
+	}
+	if len(events) != 2 || events[0] != payload || events[1] != "[DONE]" {
+		t.Errorf("read %d events, want the %d byte payload then [DONE]", len(events), len(payload))
+	}
+}
+
+func TestSSEScannerFraming(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []string
+	}{
+		{"LF", "data: {\"a\":1}\n\ndata: [DONE]\n\n", []string{`{"a":1}`, "[DONE]"}},
+		{"CRLF", "data: {\"a\":1}\r\n\r\ndata: [DONE]\r\n\r\n", []string{`{"a":1}`, "[DONE]"}},
+		{"CR", "data: {\"a\":1}\r\rdata: [DONE]\r\r", []string{`{"a":1}`, "[DONE]"}},
+		{"comments and keep-alives", ": connected\n\ndata: {\"a\":1}\n\n: ping\n\n:\n\ndata: {\"a\":2}\n\n",
+			[]string{`{"a":1}`, `{"a":2}`}},
+		{"comments inside CRLF events", ": ping\r\ndata: {\"a\":1}\r\n: ping\r\n\r\n", []string{`{"a":1}`}},
+		{"other fields", "event: message\r\nid: 7\r\nretry: 1000\r\ndata: {\"a\":1}\r\n\r\nevent: done\r\n\r\n",
+			[]string{`{"a":1}`}},
+		{"multi-line data", "data: {\"a\":\ndata: 1}\n\n", []string{"{\"a\":\n1}"}},
+		{"no space after colon", "data:{\"a\":1}\n\n", []string{`{"a":1}`}},
+		{"no blank line between events", "data: {\"a\":1}\ndata: {\"a\":2}\ndata: [DONE]\n", []string{`{"a":1}`, `{"a":2}`, "[DONE]"}},
+		{"cut off at the end", "data: {\"a\":1}\n\ndata: {\"a\":2}", []string{`{"a":1}`, `{"a":2}`}},
+		{"empty", "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newSSEScanner(strings.NewReader(tt.body), 0)
+			var got []string
+			for s.Scan() {
+				got = append(got, s.Data())
+			}
+			if s.Err() != nil {
+				t.Fatal(s.Err())
+			}
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("events = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,10 +2,12 @@ package config
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/url"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 )
 
@@ -13,6 +15,10 @@ import (
 var AppVersion = "dev"
 
 type Config struct {
+	// Version: config file format, upgraded automatically on load (see
+	// CurrentConfigVersion)
+	Version int `json:"version,omitempty"`
+
 	APIEndpoint  string  `json:"api_endpoint"`
 	APIKey       string  `json:"api_key"`
 	Model        string  `json:"model"`
@@ -37,6 +43,23 @@ type Config struct {
 	// Smarter models (qwen2.5:72b) don't need this; weaker models might
 	UserInterrupts bool `json:"user_interrupts,omitempty"`
 
+	// BatchConfirm: if true, summarize multi-tool responses up front and
+	// approve/deny the whole batch at once instead of prompting per tool
+	BatchConfirm bool `json:"batch_confirm,omitempty"`
+
+	// DisableGitignore: if true, don't add .aicli/ to the project's .gitignore
+	// on --init or when the first session is created
+	DisableGitignore bool `json:"disable_gitignore,omitempty"`
+
+	// RecentFiles: if > 0, include up to this many recently changed files
+	// (from git status and the unreleased changelog) in the first message
+	RecentFiles int `json:"recent_files,omitempty"`
+
+	// ResponseCache: if true, cache completions for deterministic requests
+	// (temperature 0) under .aicli/respcache/ and reuse them for identical
+	// requests. Any change to model, messages or parameters misses the cache.
+	ResponseCache bool `json:"response_cache,omitempty"`
+
 	// PlanModel: model to use for plan generation (best reasoning model)
 	// Defaults to "grok-4" for xAI, or the main model for other providers
 	PlanModel string `json:"plan_model,omitempty"`
@@ -45,8 +68,185 @@ type Config struct {
 	// Defaults to the main configured model
 	ExecModel string `json:"exec_model,omitempty"`
 
+	// TierModels: optional model per plan tier ("premium", "standard", "economy")
+	// Defaults to the exec model; a step escalating to premium without one
+	// retries on the plan model
+	TierModels map[string]string `json:"tier_models,omitempty"`
+
+	// MaxToolResult: maximum characters of a tool result kept in the
+	// conversation history (head and tail are kept, the middle is omitted).
+	// 0 uses the default of 16000; negative disables the cap. Session
+	// recordings always keep the full result.
+	MaxToolResult int `json:"max_tool_result,omitempty"`
+
+	// N: number of alternative completions to request (default 1). When > 1,
+	// interactive mode lets you pick which alternative becomes the reply.
+	N int `json:"n,omitempty"`
+
+	// WebRateLimit: maximum web_search/fetch_url requests per minute. 0 uses
+	// the default of 20; negative disables the limit.
+	WebRateLimit int `json:"web_rate_limit,omitempty"`
+
+	// WebUserAgent: User-Agent for web_search/fetch_url. Defaults to an aicli
+	// identifier, falling back to a browser-like one if a site refuses it.
+	WebUserAgent string `json:"web_user_agent,omitempty"`
+
+	// WebHeaders: extra headers sent with web requests
+	// (e.g. {"Accept-Language": "en-US"})
+	WebHeaders map[string]string `json:"web_headers,omitempty"`
+
+	// FetchMarkdown: fetch_url returns markdown with [text](url) links instead
+	// of plain text unless the model asks for a format
+	FetchMarkdown bool `json:"fetch_markdown,omitempty"`
+
+	// Accessible: screen-reader friendly output - status symbols become words
+	// ("SUCCESS:", "DENIED:", "ERROR:"), no colors or spinners. Also enabled
+	// by --accessible or ACCESSIBLE=1.
+	Accessible bool `json:"accessible,omitempty"`
+
+	// HistoryFile: readline input history file (default
+	// ~/.config/aicli/history). A leading "~/" is expanded.
+	HistoryFile string `json:"history_file,omitempty"`
+
+	// ProjectHistory: keep input history per project in .aicli/history
+	// instead of the shared file (ignored if HistoryFile is set)
+	ProjectHistory bool `json:"project_history,omitempty"`
+
+	// HistoryMaxLines: input history lines kept; the file is trimmed on exit.
+	// 0 uses the default of 1000; negative keeps everything.
+	HistoryMaxLines int `json:"history_max_lines,omitempty"`
+
+	// APIMode: "openai" (default) uses /v1/chat/completions; "ollama" uses
+	// Ollama's native /api/chat (native tool calling and options). Endpoints
+	// ending in /api or /api/chat select "ollama" automatically.
+	APIMode string `json:"api_mode,omitempty"`
+
+	// NoSystemPrompt: send no system message at all - not the system prompt,
+	// language rules or project memory. Also set by --no-system-prompt.
+	NoSystemPrompt bool `json:"no_system_prompt,omitempty"`
+
+	// Commands: how to build/test/run/lint this project. Keys are an action
+	// ("build", "test", "run", "lint") or "<language>.<action>" (e.g.
+	// "go.test"); unset actions fall back to the language's usual command.
+	Commands map[string]string `json:"commands,omitempty"`
+
+	// FormatOnWrite: run the file type's formatter (gofmt, black, rustfmt,
+	// prettier, clang-format) after write_file, if it is installed
+	FormatOnWrite bool `json:"format_on_write,omitempty"`
+
+	// ConfirmDefault: what happens to tool confirmations when there is no
+	// terminal to ask (-p, piped input) and -auto isn't set: "decline"
+	// (default), "approve", or "approve-read-only" (also approves inspection
+	// commands like ls, cat, grep and git status). Also set by
+	// --confirm-default.
+	ConfirmDefault string `json:"confirm_default,omitempty"`
+
+	// ConfirmTimeout: seconds to wait for an answer to a tool confirmation
+	// before applying confirm_default ("approve" approves, anything else
+	// declines). 0 waits forever.
+	ConfirmTimeout int `json:"confirm_timeout,omitempty"`
+
+	// FailOn: when a single-prompt run exits non-zero - "any" (default) tool
+	// failure, or only "unrecovered" failures (a command that failed and then
+	// succeeded later in the run doesn't count). Also set by --fail-on.
+	FailOn string `json:"fail_on,omitempty"`
+
+	// MaxResponseTokens: safety cap on tokens per response, applied when
+	// max_tokens is 0 (unlimited) or higher than the cap, so one runaway
+	// response can't tie up a shared server. 0 uses the default; -1 disables.
+	MaxResponseTokens int `json:"max_response_tokens,omitempty"`
+
+	// AutoContinue: when a response is cut off by the token limit, ask the
+	// model to continue (up to 3 times) and join the parts before running
+	// tool calls
+	AutoContinue bool `json:"auto_continue,omitempty"`
+
+	// ReasoningEffort: how much reasoning models deliberate - "low",
+	// "medium" or "high"; empty leaves it to the model. Sent as
+	// reasoning_effort (OpenAI API) or think (Ollama native API). Also set by
+	// --reasoning-effort and /think.
+	ReasoningEffort string `json:"reasoning_effort,omitempty"`
+
+	// ProbeCapabilities: on startup, probe the model for tool calling, image
+	// and embedding support if it isn't in .aicli/capabilities.json yet
+	// (a few tiny requests). Cached results are always used.
+	ProbeCapabilities bool `json:"probe_capabilities,omitempty"`
+
+	// LogFile: append a leveled, timestamped event log (requests, responses,
+	// tool calls, errors) to this file; "~/" is expanded. Empty disables.
+	// Also set by --log-file.
+	LogFile string `json:"log_file,omitempty"`
+
+	// LogLevel: minimum level written to LogFile - "debug", "info" (default),
+	// "warn" or "error"
+	LogLevel string `json:"log_level,omitempty"`
+
+	// MaxReadBytes: largest text read_file and /file return whole; bigger
+	// files are cut to their head and tail with a notice. 0 uses the
+	// default of 100 KiB; negative reads whole files.
+	MaxReadBytes int `json:"max_read_bytes,omitempty"`
+
+	// SyncManifestVersion: version bumps and set_version also write the
+	// version field of package.json, Cargo.toml and pyproject.toml, leaving
+	// the rest of each file untouched
+	SyncManifestVersion bool `json:"sync_manifest_version,omitempty"`
+
+	// TagVersions: after a git_commit (or /git commit) bumps the version,
+	// create an annotated vX.Y.Z tag, unless that tag already exists
+	TagVersions bool `json:"tag_versions,omitempty"`
+
+	// AutoCompact: when the server rejects a request because the conversation
+	// no longer fits the model's context, summarize the older messages (as
+	// /compact does) and retry once
+	AutoCompact bool `json:"auto_compact,omitempty"`
+
+	// ContextWindow: the model's context size in tokens, which /context
+	// measures the conversation against (default 32768)
+	ContextWindow int `json:"context_window,omitempty"`
+
+	// ProgressStyle: how download progress (--update) is shown - "bar"
+	// (default), "percent" or "none"
+	ProgressStyle string `json:"progress_style,omitempty"`
+
+	// UpdateChannel: releases --update installs from - "stable" (default,
+	// the latest full release) or "prerelease" (the newest release,
+	// pre-releases included). Also set by --update-channel.
+	UpdateChannel string `json:"update_channel,omitempty"`
+
+	// NoUpdateCheck: don't check for a newer aicli release in the
+	// background when an interactive session starts (checked at most daily)
+	NoUpdateCheck bool `json:"no_update_check,omitempty"`
+
+	// GitHubToken: token sent with update checks to raise GitHub's API rate
+	// limit; GITHUB_TOKEN in the environment is used when empty
+	GitHubToken string `json:"github_token,omitempty"`
+
+	// StreamBufferSize: bytes buffered per line of a streamed response
+	// (default 1 MiB). Longer lines still work, read in pieces.
+	StreamBufferSize int `json:"stream_buffer_size,omitempty"`
+
+	// NotifyCommand: shell command run when a session finishes. Receives the
+	// JSON summary on stdin and AICLI_STATUS, AICLI_PROJECT and AICLI_SUMMARY
+	// in the environment (e.g. notify-send "aicli" "$AICLI_STATUS")
+	NotifyCommand string `json:"notify_command,omitempty"`
+
+	// NotifyWebhook: URL that receives the JSON session summary via POST when
+	// a session finishes (e.g. a Slack incoming webhook relay)
+	NotifyWebhook string `json:"notify_webhook,omitempty"`
+
+	// Prompt: template for the interactive input prompt. Tokens: {model},
+	// {branch}, {dirty} ("*" if uncommitted changes), {git} ("[branch*] " or
+	// empty outside a repo), {dir}, and colors {cyan}, {green}, {yellow},
+	// {red}, {gray}, {reset}. Defaults to "{git}{cyan}>>> {reset}".
+	Prompt string `json:"prompt,omitempty"`
+
+	// Macros: named input sequences saved with /macro save and replayed with
+	// /macro run. Lines may use {1}, {2}, ... and {*} as argument placeholders.
+	Macros map[string][]string `json:"macros,omitempty"`
+
 	// Internal: tracks which config file was loaded
 	loadedFrom string
+	migration  string // Set by loadFile when the file was upgraded
 }
 
 // Permission constants
@@ -67,6 +267,54 @@ func (c *Config) GetToolPermission(tool string) string {
 	return PermissionAsk
 }
 
+// Non-interactive confirmation defaults
+const (
+	ConfirmDecline         = "decline"
+	ConfirmApprove         = "approve"
+	ConfirmApproveReadOnly = "approve-read-only"
+)
+
+// GetConfirmDefault returns the non-interactive confirmation policy,
+// falling back to ConfirmDecline for unset or unknown values
+func (c *Config) GetConfirmDefault() string {
+	switch strings.ToLower(strings.TrimSpace(c.ConfirmDefault)) {
+	case ConfirmApprove:
+		return ConfirmApprove
+	case ConfirmApproveReadOnly:
+		return ConfirmApproveReadOnly
+	}
+	return ConfirmDecline
+}
+
+// Exit status policies for FailOn
+const (
+	FailOnAny         = "any"
+	FailOnUnrecovered = "unrecovered"
+)
+
+// GetFailOn returns the exit status policy, defaulting to FailOnAny
+func (c *Config) GetFailOn() string {
+	if strings.ToLower(strings.TrimSpace(c.FailOn)) == FailOnUnrecovered {
+		return FailOnUnrecovered
+	}
+	return FailOnAny
+}
+
+// ReasoningEfforts are the accepted ReasoningEffort values
+var ReasoningEfforts = []string{"low", "medium", "high"}
+
+// GetReasoningEffort returns the normalized reasoning effort, or "" for the
+// model's default (including unknown values)
+func (c *Config) GetReasoningEffort() string {
+	effort := strings.ToLower(strings.TrimSpace(c.ReasoningEffort))
+	for _, e := range ReasoningEfforts {
+		if effort == e {
+			return e
+		}
+	}
+	return ""
+}
+
 // SetToolPermission sets the permission for a tool and saves config
 func (c *Config) SetToolPermission(tool, permission string) {
 	if c.ToolPermissions == nil {
@@ -97,6 +345,179 @@ func (c *Config) GetExecModel() string {
 	return c.Model
 }
 
+// GetTierModel returns the model to use for plan steps of the given tier
+// Falls back to the exec model, like steps without a tier
+func (c *Config) GetTierModel(tier string) string {
+	if m, ok := c.TierModels[tier]; ok && m != "" {
+		return m
+	}
+	return c.GetExecModel()
+}
+
+// GetEscalationModel returns the model a failed step is retried on when it
+// escalates to the given tier
+// Falls back to the plan model for premium and the exec model otherwise
+func (c *Config) GetEscalationModel(tier string) string {
+	if m, ok := c.TierModels[tier]; ok && m != "" {
+		return m
+	}
+	if tier == "premium" {
+		return c.GetPlanModel()
+	}
+	return c.GetExecModel()
+}
+
+// DefaultMaxToolResult is the tool result cap used when MaxToolResult is 0
+const DefaultMaxToolResult = 16000
+
+// GetMaxToolResult returns the tool result size cap in characters, or 0 for
+// no cap
+func (c *Config) GetMaxToolResult() int {
+	switch {
+	case c.MaxToolResult < 0:
+		return 0
+	case c.MaxToolResult == 0:
+		return DefaultMaxToolResult
+	}
+	return c.MaxToolResult
+}
+
+// DefaultMaxResponseTokens is the per-response cap used when
+// MaxResponseTokens is 0
+const DefaultMaxResponseTokens = 16384
+
+// GetMaxTokens returns the max_tokens to send with a request: MaxTokens,
+// limited by the MaxResponseTokens safety cap. 0 means no limit.
+func (c *Config) GetMaxTokens() int {
+	limit := c.MaxResponseTokens
+	switch {
+	case limit < 0:
+		return c.MaxTokens
+	case limit == 0:
+		limit = DefaultMaxResponseTokens
+	}
+	if c.MaxTokens <= 0 || c.MaxTokens > limit {
+		return limit
+	}
+	return c.MaxTokens
+}
+
+// DefaultWebRateLimit is the web requests per minute used when WebRateLimit is 0
+const DefaultWebRateLimit = 20
+
+// GetWebRateLimit returns the web requests allowed per minute, or 0 for no
+// limit
+func (c *Config) GetWebRateLimit() int {
+	switch {
+	case c.WebRateLimit < 0:
+		return 0
+	case c.WebRateLimit == 0:
+		return DefaultWebRateLimit
+	}
+	return c.WebRateLimit
+}
+
+// DefaultHistoryMaxLines is the input history cap used when HistoryMaxLines is 0
+const DefaultHistoryMaxLines = 1000
+
+// GetHistoryMaxLines returns the input history cap, or 0 for no cap
+func (c *Config) GetHistoryMaxLines() int {
+	switch {
+	case c.HistoryMaxLines < 0:
+		return 0
+	case c.HistoryMaxLines == 0:
+		return DefaultHistoryMaxLines
+	}
+	return c.HistoryMaxLines
+}
+
+// DefaultMaxReadBytes is the read_file cap used when MaxReadBytes is 0
+const DefaultMaxReadBytes = 100 * 1024
+
+// GetMaxReadBytes returns the read_file cap, or 0 for no cap
+func (c *Config) GetMaxReadBytes() int {
+	switch {
+	case c.MaxReadBytes < 0:
+		return 0
+	case c.MaxReadBytes == 0:
+		return DefaultMaxReadBytes
+	}
+	return c.MaxReadBytes
+}
+
+// DefaultContextWindow is the context size assumed when ContextWindow is unset
+const DefaultContextWindow = 32768
+
+// GetContextWindow returns the model's context size in tokens
+func (c *Config) GetContextWindow() int {
+	if c.ContextWindow <= 0 {
+		return DefaultContextWindow
+	}
+	return c.ContextWindow
+}
+
+// DefaultStreamBufferSize is the stream buffer used when StreamBufferSize is unset
+const DefaultStreamBufferSize = 1024 * 1024
+
+// GetStreamBufferSize returns the per-line buffer for streamed responses
+func (c *Config) GetStreamBufferSize() int {
+	if c.StreamBufferSize <= 0 {
+		return DefaultStreamBufferSize
+	}
+	return c.StreamBufferSize
+}
+
+// GetUpdateChannel returns the normalized update channel, "stable" if unset
+func (c *Config) GetUpdateChannel() string {
+	if channel := strings.ToLower(strings.TrimSpace(c.UpdateChannel)); channel != "" {
+		return channel
+	}
+	return "stable"
+}
+
+// GetGitHubToken returns the token for GitHub API requests: github_token,
+// or GITHUB_TOKEN from the environment
+func (c *Config) GetGitHubToken() string {
+	if token := strings.TrimSpace(c.GitHubToken); token != "" {
+		return token
+	}
+	return strings.TrimSpace(os.Getenv("GITHUB_TOKEN"))
+}
+
+// IsNativeOllama reports whether chat requests use Ollama's native /api/chat
+func (c *Config) IsNativeOllama() bool {
+	switch strings.ToLower(c.APIMode) {
+	case "ollama":
+		return true
+	case "openai":
+		return false
+	}
+	path := strings.TrimSuffix(c.APIEndpoint, "/")
+	return strings.HasSuffix(path, "/api") || strings.HasSuffix(path, "/api/chat")
+}
+
+// OllamaBaseURL returns the server root for Ollama's native /api/... calls
+// (e.g. http://localhost:11434 for .../v1, .../api or .../api/chat)
+func (c *Config) OllamaBaseURL() string {
+	base := strings.TrimSuffix(c.APIEndpoint, "/")
+	for _, suffix := range []string{"/api/chat", "/api", "/v1"} {
+		if strings.HasSuffix(base, suffix) {
+			return strings.TrimSuffix(base, suffix)
+		}
+	}
+	return base
+}
+
+// OpenAIBaseURL returns the OpenAI-compatible API base (…/v1) for
+// /chat/completions and /models, also when the endpoint is a native one
+func (c *Config) OpenAIBaseURL() string {
+	base := strings.TrimSuffix(c.APIEndpoint, "/")
+	if strings.HasSuffix(base, "/api") || strings.HasSuffix(base, "/api/chat") {
+		return c.OllamaBaseURL() + "/v1"
+	}
+	return base
+}
+
 // IsOllamaEndpoint returns true if the API endpoint looks like an Ollama instance
 // (localhost/private IP on port 11434, or no well-known cloud API domain)
 func (c *Config) IsOllamaEndpoint() bool {
@@ -156,6 +577,7 @@ func (c *Config) ShouldPreloadModel() bool {
 
 func DefaultConfig() *Config {
 	return &Config{
+		Version:     CurrentConfigVersion,
 		APIEndpoint: "http://localhost:11434/v1",
 		APIKey:      "",
 		Model:       "default",
@@ -213,6 +635,7 @@ Available tools:
 - read_file: Read file contents. Args: path
 - run_command: Execute shell commands. Args: command
 - list_files: List files in directory. Args: pattern
+- tree: Show the directory structure. Args: path, depth
 - git_status, git_diff, git_add, git_commit, git_log
 
 Example - To create a file:
@@ -260,12 +683,7 @@ func Load() (*Config, error) {
 	// First check for local config in current directory
 	localPath := LocalConfigPath()
 	if data, err := os.ReadFile(localPath); err == nil {
-		cfg := DefaultConfig()
-		if err := json.Unmarshal(data, cfg); err != nil {
-			return nil, fmt.Errorf("invalid local config: %w", err)
-		}
-		cfg.loadedFrom = localPath
-		return cfg, nil
+		return loadFile(localPath, data)
 	}
 
 	// Fall back to global config
@@ -282,13 +700,120 @@ func Load() (*Config, error) {
 		return nil, err
 	}
 
-	cfg := DefaultConfig()
-	if err := json.Unmarshal(data, cfg); err != nil {
-		return nil, err
+	return loadFile(globalPath, data)
+}
+
+// decodeConfig parses a config file, describing JSON errors by field and
+// line rather than Go type names and byte offsets
+func decodeConfig(data []byte, cfg *Config) error {
+	err := json.Unmarshal(data, cfg)
+	var typeErr *json.UnmarshalTypeError
+	var syntaxErr *json.SyntaxError
+	switch {
+	case errors.As(err, &typeErr):
+		want := typeErr.Type.Kind().String()
+		switch want {
+		case "float64", "int":
+			want = "a number"
+		case "string":
+			want = "a string"
+		case "bool":
+			want = "true or false"
+		case "map", "struct":
+			want = "an object"
+		case "slice":
+			want = "a list"
+		}
+		return fmt.Errorf("line %d: %s must be %s, not %s", lineOf(data, typeErr.Offset), typeErr.Field, want, typeErr.Value)
+	case errors.As(err, &syntaxErr):
+		return fmt.Errorf("line %d: %v", lineOf(data, syntaxErr.Offset), syntaxErr)
 	}
-	cfg.loadedFrom = globalPath
+	return err
+}
 
-	return cfg, nil
+// lineOf returns the 1-based line of a byte offset in data
+func lineOf(data []byte, offset int64) int {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	return strings.Count(string(data[:offset]), "\n") + 1
+}
+
+// Validate checks value ranges, the endpoint URL and enumerated settings,
+// returning every problem found with the setting name and what it accepts
+func (c *Config) Validate() error {
+	var errs []error
+	add := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+	oneOf := func(name, value string, allowed ...string) {
+		if value == "" {
+			return
+		}
+		for _, a := range allowed {
+			if strings.EqualFold(strings.TrimSpace(value), a) {
+				return
+			}
+		}
+		add("%s: %q is not valid; use %s", name, value, orList(allowed))
+	}
+
+	if u, err := url.Parse(c.APIEndpoint); c.APIEndpoint == "" {
+		add("api_endpoint: not set; use a URL like http://localhost:11434/v1")
+	} else if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		add("api_endpoint: %q is not a valid URL; use http(s)://host[:port]/path, e.g. http://localhost:11434/v1", c.APIEndpoint)
+	}
+	if c.Temperature < 0 || c.Temperature > 2 {
+		add("temperature: %g is out of range; use a value from 0 to 2", c.Temperature)
+	}
+	if c.MaxTokens < 0 {
+		add("max_tokens: %d is negative; use 0 for no limit", c.MaxTokens)
+	}
+	if c.N < 0 {
+		add("n: %d is negative; use 1 or more alternatives", c.N)
+	}
+	if c.ConfirmTimeout < 0 {
+		add("confirm_timeout: %d is negative; use 0 to wait forever", c.ConfirmTimeout)
+	}
+	var permTools []string
+	for tool := range c.ToolPermissions {
+		permTools = append(permTools, tool)
+	}
+	sort.Strings(permTools)
+	for _, tool := range permTools {
+		switch perm := c.ToolPermissions[tool]; perm {
+		case PermissionAlways, PermissionAsk, PermissionNever:
+		default:
+			add("tool_permissions.%s: %q is not valid; use %s", tool, perm, orList([]string{PermissionAlways, PermissionAsk, PermissionNever}))
+		}
+	}
+	oneOf("confirm_default", c.ConfirmDefault, ConfirmDecline, ConfirmApprove, ConfirmApproveReadOnly)
+	oneOf("fail_on", c.FailOn, FailOnAny, FailOnUnrecovered)
+	oneOf("reasoning_effort", c.ReasoningEffort, ReasoningEfforts...)
+	oneOf("api_mode", c.APIMode, "openai", "ollama")
+	oneOf("log_level", c.LogLevel, "debug", "info", "warn", "warning", "error")
+	oneOf("progress_style", c.ProgressStyle, "bar", "percent", "none")
+	oneOf("update_channel", c.UpdateChannel, "stable", "prerelease")
+
+	return errors.Join(errs...)
+}
+
+// Warnings returns settings that are valid but won't take effect together,
+// for printing at startup
+func (c *Config) Warnings() []string {
+	var warnings []string
+	if c.N > 1 && c.IsNativeOllama() {
+		warnings = append(warnings, fmt.Sprintf("n: %d alternatives aren't available with api_mode ollama (the native API returns one reply); using 1", c.N))
+	}
+	return warnings
+}
+
+// orList joins values as "a, b or c"
+func orList(values []string) string {
+	if len(values) < 2 {
+		return strings.Join(values, "")
+	}
+	return strings.Join(values[:len(values)-1], ", ") + " or " + values[len(values)-1]
 }
 
 // Save saves config to the local project directory
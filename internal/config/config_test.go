@@ -0,0 +1,268 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTierModels(t *testing.T) {
+	tests := []struct {
+		name           string
+		cfg            Config
+		tier           string
+		wantModel      string
+		wantEscalation string
+	}{
+		{"defaults", Config{Model: "main"}, "premium", "main", "main"},
+		{"premium runs on the exec model", Config{Model: "main", ExecModel: "exec", PlanModel: "plan"}, "premium", "exec", "plan"},
+		{"standard", Config{Model: "main", ExecModel: "exec", PlanModel: "plan"}, "standard", "exec", "exec"},
+		{"configured tier", Config{Model: "main", PlanModel: "plan", TierModels: map[string]string{"premium": "big"}}, "premium", "big", "big"},
+		{"empty tier model", Config{Model: "main", PlanModel: "plan", TierModels: map[string]string{"premium": ""}}, "premium", "main", "plan"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cfg.GetTierModel(tt.tier); got != tt.wantModel {
+				t.Errorf("GetTierModel(%q) = %q, want %q", tt.tier, got, tt.wantModel)
+			}
+			if got := tt.cfg.GetEscalationModel(tt.tier); got != tt.wantEscalation {
+				t.Errorf("GetEscalationModel(%q) = %q, want %q", tt.tier, got, tt.wantEscalation)
+			}
+		})
+	}
+}
+
+func TestLoadFileMigration(t *testing.T) {
+	tests := []struct {
+		name        string
+		file        string
+		wantWritten bool
+		wantConfirm string
+	}{
+		{"current", `{"version": 1, "confirm_default": "approve"}`, false, "approve"},
+		{"v0 already normal", `{"model": "qwen3", "confirm_default": "approve"}`, false, "approve"},
+		{"v0 needing an upgrade", `{"model": "qwen3", "confirm_default": " Approve "}`, true, "approve"},
+		{"v0 permissions", `{"tool_permissions": {"git_commit": "Ask"}}`, true, ""},
+		{"newer version", `{"version": 7, "model": "qwen3"}`, false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.json")
+			if err := os.WriteFile(path, []byte(tt.file), 0600); err != nil {
+				t.Fatal(err)
+			}
+			cfg, err := loadFile(path, []byte(tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantConfirm != "" && cfg.ConfirmDefault != tt.wantConfirm {
+				t.Errorf("confirm_default = %q, want %q", cfg.ConfirmDefault, tt.wantConfirm)
+			}
+			data, _ := os.ReadFile(path)
+			_, backupErr := os.Stat(path + ".bak")
+			if written := string(data) != tt.file; written != tt.wantWritten {
+				t.Errorf("file rewritten = %v, want %v:\n%s", written, tt.wantWritten, data)
+			}
+			if (backupErr == nil) != tt.wantWritten || (cfg.Migration() != "") != tt.wantWritten {
+				t.Errorf("backup %v, migration note %q; want them only for a rewrite", backupErr, cfg.Migration())
+			}
+			if tt.wantWritten && !strings.Contains(string(data), `"version": 1`) {
+				t.Errorf("upgraded file has no version:\n%s", data)
+			}
+		})
+	}
+}
+
+func TestGetHistoryMaxLines(t *testing.T) {
+	tests := []struct {
+		set, want int
+	}{
+		{0, DefaultHistoryMaxLines},
+		{200, 200},
+		{-1, 0},
+	}
+	for _, tt := range tests {
+		c := &Config{HistoryMaxLines: tt.set}
+		if got := c.GetHistoryMaxLines(); got != tt.want {
+			t.Errorf("GetHistoryMaxLines() with %d = %d, want %d", tt.set, got, tt.want)
+		}
+	}
+}
+
+func TestGetWebRateLimit(t *testing.T) {
+	tests := []struct {
+		set, want int
+	}{
+		{0, DefaultWebRateLimit},
+		{5, 5},
+		{-1, 0},
+	}
+	for _, tt := range tests {
+		c := &Config{WebRateLimit: tt.set}
+		if got := c.GetWebRateLimit(); got != tt.want {
+			t.Errorf("GetWebRateLimit() with %d = %d, want %d", tt.set, got, tt.want)
+		}
+	}
+}
+
+func TestGetMaxTokens(t *testing.T) {
+	tests := []struct {
+		name                 string
+		maxTokens, respLimit int
+		want                 int
+	}{
+		{"unlimited gets the default cap", 0, 0, DefaultMaxResponseTokens},
+		{"below the default cap", 4096, 0, 4096},
+		{"above the default cap", 100000, 0, DefaultMaxResponseTokens},
+		{"configured cap", 0, 2048, 2048},
+		{"below a configured cap", 1024, 2048, 1024},
+		{"above a configured cap", 4096, 2048, 2048},
+		{"cap disabled keeps max_tokens", 100000, -1, 100000},
+		{"cap disabled and unlimited", 0, -1, 0},
+	}
+	for _, tt := range tests {
+		c := &Config{MaxTokens: tt.maxTokens, MaxResponseTokens: tt.respLimit}
+		if got := c.GetMaxTokens(); got != tt.want {
+			t.Errorf("%s: GetMaxTokens() = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestGetReasoningEffort(t *testing.T) {
+	tests := []struct {
+		set, want string
+	}{
+		{"", ""},
+		{"low", "low"},
+		{" High ", "high"},
+		{"medium", "medium"},
+		{"extreme", ""},
+	}
+	for _, tt := range tests {
+		c := &Config{ReasoningEffort: tt.set}
+		if got := c.GetReasoningEffort(); got != tt.want {
+			t.Errorf("GetReasoningEffort() with %q = %q, want %q", tt.set, got, tt.want)
+		}
+	}
+}
+
+func TestGetUpdateChannel(t *testing.T) {
+	tests := []struct {
+		set, want string
+	}{
+		{"", "stable"},
+		{"  ", "stable"},
+		{"prerelease", "prerelease"},
+		{" PreRelease ", "prerelease"},
+		{"nightly", "nightly"},
+	}
+	for _, tt := range tests {
+		c := &Config{UpdateChannel: tt.set}
+		if got := c.GetUpdateChannel(); got != tt.want {
+			t.Errorf("GetUpdateChannel() with %q = %q, want %q", tt.set, got, tt.want)
+		}
+	}
+}
+
+func TestGetGitHubToken(t *testing.T) {
+	tests := []struct {
+		config, env, want string
+	}{
+		{"", "", ""},
+		{"", " ghp_env\n", "ghp_env"},
+		{"ghp_config", "ghp_env", "ghp_config"},
+		{"  ", "ghp_env", "ghp_env"},
+	}
+	for _, tt := range tests {
+		t.Setenv("GITHUB_TOKEN", tt.env)
+		c := &Config{GitHubToken: tt.config}
+		if got := c.GetGitHubToken(); got != tt.want {
+			t.Errorf("GetGitHubToken() with config %q, env %q = %q, want %q", tt.config, tt.env, got, tt.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := DefaultConfig().Validate(); err != nil {
+		t.Fatalf("default config is invalid: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(*Config)
+		want   string
+	}{
+		{"no endpoint", func(c *Config) { c.APIEndpoint = "" }, "api_endpoint: not set"},
+		{"endpoint without scheme", func(c *Config) { c.APIEndpoint = "localhost:11434/v1" }, `api_endpoint: "localhost:11434/v1" is not a valid URL`},
+		{"endpoint without host", func(c *Config) { c.APIEndpoint = "http:///v1" }, "is not a valid URL"},
+		{"unsupported scheme", func(c *Config) { c.APIEndpoint = "ftp://host/v1" }, "is not a valid URL"},
+		{"negative temperature", func(c *Config) { c.Temperature = -0.5 }, "temperature: -0.5 is out of range; use a value from 0 to 2"},
+		{"temperature too high", func(c *Config) { c.Temperature = 2.5 }, "temperature: 2.5 is out of range"},
+		{"negative max tokens", func(c *Config) { c.MaxTokens = -1 }, "max_tokens: -1 is negative; use 0 for no limit"},
+		{"negative n", func(c *Config) { c.N = -2 }, "n: -2 is negative"},
+		{"negative confirm timeout", func(c *Config) { c.ConfirmTimeout = -5 }, "confirm_timeout: -5 is negative"},
+		{"unknown permission", func(c *Config) { c.ToolPermissions = map[string]string{"git_push": "sometimes"} },
+			`tool_permissions.git_push: "sometimes" is not valid; use always, ask or never`},
+		{"unknown confirm default", func(c *Config) { c.ConfirmDefault = "maybe" }, `confirm_default: "maybe" is not valid`},
+		{"unknown fail_on", func(c *Config) { c.FailOn = "some" }, `fail_on: "some" is not valid; use any or unrecovered`},
+		{"unknown api mode", func(c *Config) { c.APIMode = "anthropic" }, `api_mode: "anthropic" is not valid; use openai or ollama`},
+		{"unknown log level", func(c *Config) { c.LogLevel = "trace" }, `log_level: "trace" is not valid`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			tt.modify(cfg)
+			err := cfg.Validate()
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Validate() = %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+
+	t.Run("case and space insensitive enums", func(t *testing.T) {
+		cfg := DefaultConfig()
+		cfg.FailOn = " Any "
+		cfg.APIMode = "OpenAI"
+		if err := cfg.Validate(); err != nil {
+			t.Errorf("Validate() = %v", err)
+		}
+	})
+
+	t.Run("every problem reported", func(t *testing.T) {
+		cfg := DefaultConfig()
+		cfg.Temperature = 3
+		cfg.MaxTokens = -1
+		cfg.ToolPermissions = map[string]string{"b": "x", "a": "y"}
+		err := cfg.Validate()
+		if err == nil {
+			t.Fatal("Validate() succeeded")
+		}
+		lines := strings.Split(err.Error(), "\n")
+		if len(lines) != 4 || !strings.HasPrefix(lines[2], "tool_permissions.a") || !strings.HasPrefix(lines[3], "tool_permissions.b") {
+			t.Errorf("Validate() lines = %q, want four with permissions in tool order", lines)
+		}
+	})
+}
+
+func TestDecodeConfigErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		want string
+	}{
+		{"string temperature", "{\n  \"model\": \"m\",\n  \"temperature\": \"hot\"\n}", "line 3: temperature must be a number, not string"},
+		{"numeric model", `{"model": 7}`, "line 1: model must be a string, not number"},
+		{"string bool", "{\n\"auto_continue\": \"yes\"}", "line 2: auto_continue must be true or false, not string"},
+		{"syntax", "{\n  \"model\": \"m\",\n  \"temperature\": 0.7,\n}", "line 4: invalid character '}'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.json")
+			_, err := loadFile(path, []byte(tt.file))
+			if err == nil || !strings.Contains(err.Error(), tt.want) || !strings.Contains(err.Error(), path) {
+				t.Errorf("loadFile error = %v, want one naming %s and containing %q", err, path, tt.want)
+			}
+		})
+	}
+}
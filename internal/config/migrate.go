@@ -0,0 +1,125 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// CurrentConfigVersion is the config file format this version writes.
+// Files with an older (or no) version are upgraded on load; the file is only
+// rewritten when the upgrade changed a value.
+const CurrentConfigVersion = 1
+
+// configMigrations[i] upgrades a config file from version i to i+1. They
+// work on the raw JSON object rather than Config, so they can rename or
+// reshape keys that Config no longer has.
+var configMigrations = []func(raw map[string]interface{}){
+	migrateV0,
+}
+
+// migrateV0 normalizes hand-written enum values ("Always", " approve ") that
+// older versions accepted loosely and validation now rejects
+func migrateV0(raw map[string]interface{}) {
+	for _, key := range []string{"confirm_default", "fail_on", "reasoning_effort", "api_mode", "log_level"} {
+		if s, ok := raw[key].(string); ok {
+			raw[key] = strings.ToLower(strings.TrimSpace(s))
+		}
+	}
+	if perms, ok := raw["tool_permissions"].(map[string]interface{}); ok {
+		for tool, perm := range perms {
+			if s, ok := perm.(string); ok {
+				perms[tool] = strings.ToLower(strings.TrimSpace(s))
+			}
+		}
+	}
+}
+
+// migrateConfig upgrades config file data to CurrentConfigVersion. It
+// returns the data to decode, the version it started from (from equals
+// CurrentConfigVersion for a current file) and whether the upgrade changed
+// any value, which is when the file is worth rewriting. Only keys the user
+// set are written back, so defaults can keep changing between versions.
+// Data that isn't a JSON object is returned as is for decodeConfig to
+// report.
+func migrateConfig(data []byte) ([]byte, int, bool, error) {
+	raw, err := decodeRaw(data)
+	if err != nil || raw == nil {
+		return data, CurrentConfigVersion, false, nil
+	}
+
+	from := 0
+	if v, ok := raw["version"].(json.Number); ok {
+		n, err := v.Int64()
+		if err != nil || n < 0 {
+			return nil, 0, false, fmt.Errorf("version must be a whole number, not %s", v)
+		}
+		from = int(n)
+	}
+	if from >= CurrentConfigVersion {
+		// Current, or written by a newer aicli: leave it alone
+		return data, CurrentConfigVersion, false, nil
+	}
+
+	before, _ := json.Marshal(raw)
+	for v := from; v < CurrentConfigVersion; v++ {
+		configMigrations[v](raw)
+	}
+	after, _ := json.Marshal(raw)
+	if bytes.Equal(before, after) {
+		// Nothing to upgrade: the file decodes as it is
+		return data, from, false, nil
+	}
+	raw["version"] = CurrentConfigVersion
+
+	out, err := json.MarshalIndent(raw, "", "  ")
+	if err != nil {
+		return nil, 0, false, err
+	}
+	return out, from, true, nil
+}
+
+// decodeRaw decodes a JSON object keeping numbers exactly as written
+func decodeRaw(data []byte) (map[string]interface{}, error) {
+	var raw map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	err := dec.Decode(&raw)
+	return raw, err
+}
+
+// loadFile reads and decodes the config file at path, upgrading it in
+// memory if it is from an older version. Only an upgrade that changed a
+// value is written back, keeping the original as <path>.bak; if it can't
+// be written the upgrade is still used for this run.
+func loadFile(path string, data []byte) (*Config, error) {
+	migrated, from, changed, err := migrateConfig(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config %s: %w", path, err)
+	}
+
+	cfg := DefaultConfig()
+	if err := decodeConfig(migrated, cfg); err != nil {
+		return nil, fmt.Errorf("invalid config %s: %w", path, err)
+	}
+	cfg.loadedFrom = path
+
+	if changed {
+		backup := path + ".bak"
+		if err := os.WriteFile(backup, data, 0600); err == nil {
+			if err := os.WriteFile(path, append(migrated, '\n'), 0600); err == nil {
+				cfg.migration = fmt.Sprintf("Upgraded %s from config version %d to %d (original saved as %s)",
+					path, from, CurrentConfigVersion, backup)
+			}
+		}
+	}
+	return cfg, nil
+}
+
+// Migration describes the upgrade applied to the config file on load, or
+// returns "" if it was already current
+func (c *Config) Migration() string {
+	return c.migration
+}
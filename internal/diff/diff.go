@@ -0,0 +1,260 @@
+// Package diff computes line diffs between file versions, split into hunks
+// that can be applied selectively.
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxCells bounds the LCS table (lines of old x lines of new, after common
+// prefix and suffix are trimmed). Larger changes become one replacement.
+const maxCells = 4_000_000
+
+// Line is one line of a diff: Kind is ' ' (unchanged), '-' (removed) or
+// '+' (added). Text keeps its trailing newline, if any.
+type Line struct {
+	Kind byte
+	Text string
+}
+
+// Hunk is a group of nearby changes with surrounding context
+type Hunk struct {
+	OldStart int // 0-based index of the first old line covered
+	Lines    []Line
+
+	newStart int // 0-based index of the first new line, for the header
+}
+
+// OldLines returns how many old lines the hunk covers
+func (h Hunk) OldLines() int {
+	n := 0
+	for _, l := range h.Lines {
+		if l.Kind != '+' {
+			n++
+		}
+	}
+	return n
+}
+
+// NewLines returns how many new lines the hunk produces
+func (h Hunk) NewLines() int {
+	n := 0
+	for _, l := range h.Lines {
+		if l.Kind != '-' {
+			n++
+		}
+	}
+	return n
+}
+
+// Stats returns the number of added and removed lines
+func (h Hunk) Stats() (added, removed int) {
+	for _, l := range h.Lines {
+		switch l.Kind {
+		case '+':
+			added++
+		case '-':
+			removed++
+		}
+	}
+	return added, removed
+}
+
+// Header returns the unified diff header, e.g. "@@ -12,7 +12,9 @@"
+func (h Hunk) Header() string {
+	return fmt.Sprintf("@@ -%d,%d +%d,%d @@", h.OldStart+1, h.OldLines(), h.newStart+1, h.NewLines())
+}
+
+// String renders the hunk in unified diff format
+func (h Hunk) String() string {
+	var sb strings.Builder
+	sb.WriteString(h.Header())
+	sb.WriteString("\n")
+	for _, l := range h.Lines {
+		sb.WriteByte(l.Kind)
+		sb.WriteString(strings.TrimSuffix(l.Text, "\n"))
+		sb.WriteString("\n")
+		if !strings.HasSuffix(l.Text, "\n") {
+			sb.WriteString("\\ No newline at end of file\n")
+		}
+	}
+	return sb.String()
+}
+
+// splitLines splits s into lines that keep their newlines, so joining them
+// restores s exactly
+func splitLines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// Lines returns the line-by-line diff from old to new
+func Lines(old, new string) []Line {
+	a, b := splitLines(old), splitLines(new)
+
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix &&
+		a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var out []Line
+	for _, l := range a[:prefix] {
+		out = append(out, Line{' ', l})
+	}
+	out = append(out, middle(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, l := range a[len(a)-suffix:] {
+		out = append(out, Line{' ', l})
+	}
+	return out
+}
+
+// middle diffs the differing middle sections with a longest common
+// subsequence table
+func middle(a, b []string) []Line {
+	var out []Line
+	if len(a)*len(b) > maxCells {
+		for _, l := range a {
+			out = append(out, Line{'-', l})
+		}
+		for _, l := range b {
+			out = append(out, Line{'+', l})
+		}
+		return out
+	}
+
+	// lcs[i][j] is the LCS length of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			out = append(out, Line{' ', a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			out = append(out, Line{'-', a[i]})
+			i++
+		default:
+			out = append(out, Line{'+', b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		out = append(out, Line{'-', a[i]})
+	}
+	for ; j < len(b); j++ {
+		out = append(out, Line{'+', b[j]})
+	}
+	return out
+}
+
+// Hunks groups the changes from old to new into hunks with up to context
+// unchanged lines around each change. Changes closer than 2*context lines
+// share a hunk. No changes means no hunks.
+func Hunks(old, new string, context int) []Hunk {
+	lines := Lines(old, new)
+
+	var hunks []Hunk
+	oldPos, newPos := 0, 0 // Position before lines[i]
+	for i := 0; i < len(lines); {
+		if lines[i].Kind == ' ' {
+			oldPos++
+			newPos++
+			i++
+			continue
+		}
+
+		// Back up over leading context
+		start := i
+		for start > 0 && i-start < context && lines[start-1].Kind == ' ' {
+			start--
+		}
+		h := Hunk{OldStart: oldPos - (i - start), newStart: newPos - (i - start)}
+
+		// Extend while the next change is within 2*context unchanged lines
+		end := i
+		for end < len(lines) {
+			if lines[end].Kind != ' ' {
+				end++
+				continue
+			}
+			run := end
+			for run < len(lines) && lines[run].Kind == ' ' {
+				run++
+			}
+			if run == len(lines) || run-end > 2*context {
+				end += min(context, run-end)
+				break
+			}
+			end = run
+		}
+
+		h.Lines = append([]Line(nil), lines[start:end]...)
+		hunks = append(hunks, h)
+
+		for _, l := range lines[i:end] {
+			if l.Kind != '+' {
+				oldPos++
+			}
+			if l.Kind != '-' {
+				newPos++
+			}
+		}
+		i = end
+	}
+	return hunks
+}
+
+// Apply applies the accepted hunks (computed from old) to old, leaving the
+// rejected ones as they were. accept[i] selects hunks[i].
+func Apply(old string, hunks []Hunk, accept []bool) string {
+	a := splitLines(old)
+	var sb strings.Builder
+	pos := 0
+	for i, h := range hunks {
+		for ; pos < h.OldStart && pos < len(a); pos++ {
+			sb.WriteString(a[pos])
+		}
+		take := i < len(accept) && accept[i]
+		for _, l := range h.Lines {
+			switch {
+			case l.Kind == ' ':
+				sb.WriteString(l.Text)
+				pos++
+			case l.Kind == '-':
+				if !take {
+					sb.WriteString(l.Text)
+				}
+				pos++
+			case take: // '+'
+				sb.WriteString(l.Text)
+			}
+		}
+	}
+	for ; pos < len(a); pos++ {
+		sb.WriteString(a[pos])
+	}
+	return sb.String()
+}
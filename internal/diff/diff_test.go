@@ -0,0 +1,47 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestApply(t *testing.T) {
+	lines := func(s ...string) string { return strings.Join(s, "\n") + "\n" }
+	old := lines("a", "b", "c", "d", "e", "f", "g", "h", "i", "j", "k", "l")
+	// Inserts at the start, deletes in the middle, replaces at the end
+	new := lines("new", "a", "b", "c", "d", "e", "g", "h", "i", "j", "k", "L")
+
+	hunks := Hunks(old, new, 1)
+	if len(hunks) != 3 {
+		t.Fatalf("got %d hunks, want 3", len(hunks))
+	}
+	tests := []struct {
+		accept []bool
+		want   string
+	}{
+		{[]bool{true, true, true}, new},
+		{[]bool{false, false, false}, old},
+		{nil, old},
+		{[]bool{true, false, false}, lines("new", "a", "b", "c", "d", "e", "f", "g", "h", "i", "j", "k", "l")},
+		{[]bool{false, true, false}, lines("a", "b", "c", "d", "e", "g", "h", "i", "j", "k", "l")},
+		{[]bool{false, false, true}, lines("a", "b", "c", "d", "e", "f", "g", "h", "i", "j", "k", "L")},
+		{[]bool{true, false, true}, lines("new", "a", "b", "c", "d", "e", "f", "g", "h", "i", "j", "k", "L")},
+	}
+	for _, tt := range tests {
+		if got := Apply(old, hunks, tt.accept); got != tt.want {
+			t.Errorf("Apply(%v) = %q, want %q", tt.accept, got, tt.want)
+		}
+	}
+}
+
+func TestApplyWithoutTrailingNewline(t *testing.T) {
+	old := "one\ntwo\nthree"
+	new := "one\n2\nthree"
+	hunks := Hunks(old, new, 3)
+	if got := Apply(old, hunks, []bool{true}); got != new {
+		t.Errorf("accepted = %q, want %q", got, new)
+	}
+	if got := Apply(old, hunks, []bool{false}); got != old {
+		t.Errorf("rejected = %q, want %q", got, old)
+	}
+}
@@ -0,0 +1,122 @@
+package executor
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// blameLine is one line of git blame output
+type blameLine struct {
+	Line    int
+	Commit  string
+	Author  string
+	Date    time.Time
+	Content string
+}
+
+// GitBlame reports the commit, author and date that last changed each
+// line of path, for lines start to end (1-based; end <= 0 means the end
+// of the file). Lines not yet committed show commit 00000000.
+func (e *Executor) GitBlame(path string, start, end int) (string, error) {
+	args, err := blameArgs(path, start, end)
+	if err != nil {
+		return "", err
+	}
+	cmd := exec.Command("git", args...)
+	cmd.Dir = e.workDir
+	out, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+			return "", fmt.Errorf("git blame: %s", strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", fmt.Errorf("git blame: %w", err)
+	}
+
+	lines := parseBlame(string(out))
+	if len(lines) == 0 {
+		return "", fmt.Errorf("%s has no lines to blame", path)
+	}
+	return e.capContent(formatBlame(lines), lines[0].Line), nil
+}
+
+// blameArgs builds the git arguments for GitBlame. A start below 1 is
+// read as 1; a range that ends before it starts is an error.
+func blameArgs(path string, start, end int) ([]string, error) {
+	if strings.TrimSpace(path) == "" {
+		return nil, fmt.Errorf("path is required")
+	}
+	if start < 1 {
+		start = 1
+	}
+	if end > 0 && end < start {
+		return nil, fmt.Errorf("start line %d is after end line %d", start, end)
+	}
+
+	args := []string{"blame", "--line-porcelain"}
+	switch {
+	case end > 0:
+		args = append(args, "-L", fmt.Sprintf("%d,%d", start, end))
+	case start > 1:
+		args = append(args, "-L", fmt.Sprintf("%d,", start))
+	}
+	return append(args, "--", path), nil
+}
+
+// parseBlame reads git blame --line-porcelain output, where each line is
+// a "<commit> <orig> <final> [<count>]" header, key/value lines about the
+// commit, and the content prefixed with a tab
+func parseBlame(out string) []blameLine {
+	var lines []blameLine
+	var cur *blameLine
+	for _, raw := range strings.Split(out, "\n") {
+		if strings.HasPrefix(raw, "\t") {
+			if cur != nil {
+				cur.Content = raw[1:]
+				lines = append(lines, *cur)
+				cur = nil
+			}
+			continue
+		}
+		key, value, _ := strings.Cut(raw, " ")
+		switch {
+		case cur == nil:
+			fields := strings.Fields(raw)
+			if len(fields) < 3 || len(fields[0]) != 40 {
+				continue
+			}
+			n, _ := strconv.Atoi(fields[2])
+			cur = &blameLine{Line: n, Commit: fields[0][:8]}
+		case key == "author":
+			cur.Author = value
+		case key == "author-time":
+			if secs, err := strconv.ParseInt(value, 10, 64); err == nil {
+				cur.Date = time.Unix(secs, 0)
+			}
+		}
+	}
+	return lines
+}
+
+// formatBlame renders one line per blamed line:
+// "<line> <commit> <date> <author> | <content>"
+func formatBlame(lines []blameLine) string {
+	width := len(strconv.Itoa(lines[len(lines)-1].Line))
+	authorWidth := 0
+	for _, l := range lines {
+		authorWidth = max(authorWidth, min(len([]rune(l.Author)), 20))
+	}
+
+	var sb strings.Builder
+	for _, l := range lines {
+		author := l.Author
+		if r := []rune(author); len(r) > 20 {
+			author = string(r[:19]) + "…"
+		}
+		padding := strings.Repeat(" ", authorWidth-len([]rune(author)))
+		fmt.Fprintf(&sb, "%*d %s %s %s%s | %s\n", width, l.Line, l.Commit, l.Date.Format("2006-01-02"), author, padding, l.Content)
+	}
+	return sb.String()
+}
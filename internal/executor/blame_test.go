@@ -0,0 +1,113 @@
+package executor
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBlameArgs(t *testing.T) {
+	tests := []struct {
+		path       string
+		start, end int
+		want       string
+		wantErr    bool
+	}{
+		{"main.go", 0, 0, "blame --line-porcelain -- main.go", false},
+		{"main.go", 1, 0, "blame --line-porcelain -- main.go", false},
+		{"main.go", 10, 0, "blame --line-porcelain -L 10, -- main.go", false},
+		{"main.go", 10, 20, "blame --line-porcelain -L 10,20 -- main.go", false},
+		{"main.go", -5, 3, "blame --line-porcelain -L 1,3 -- main.go", false},
+		{"main.go", 7, 7, "blame --line-porcelain -L 7,7 -- main.go", false},
+		{"-L1,2", 0, 0, "blame --line-porcelain -- -L1,2", false},
+		{"main.go", 20, 10, "", true},
+		{" ", 0, 0, "", true},
+	}
+	for _, tt := range tests {
+		args, err := blameArgs(tt.path, tt.start, tt.end)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("blameArgs(%q, %d, %d) err = %v, wantErr %v", tt.path, tt.start, tt.end, err, tt.wantErr)
+			continue
+		}
+		if got := strings.Join(args, " "); got != tt.want {
+			t.Errorf("blameArgs(%q, %d, %d) = %q, want %q", tt.path, tt.start, tt.end, got, tt.want)
+		}
+	}
+}
+
+func TestParseBlame(t *testing.T) {
+	commit := "1b2c3d4e5f60718293a4b5c6d7e8f90123456789"
+	out := commit + " 1 3 2\n" +
+		"author Ada Lovelace\n" +
+		"author-mail <ada@example.com>\n" +
+		"author-time 1760000000\n" +
+		"summary Add the engine\n" +
+		"filename engine.go\n" +
+		"\tfunc run() {\n" +
+		commit + " 2 4\n" +
+		"author Ada Lovelace\n" +
+		"author-time 1760000000\n" +
+		"filename engine.go\n" +
+		"\t\treturn nil\n"
+	lines := parseBlame(out)
+	if len(lines) != 2 {
+		t.Fatalf("parsed %d lines, want 2", len(lines))
+	}
+	tests := []struct {
+		got, want blameLine
+	}{
+		{lines[0], blameLine{Line: 3, Commit: "1b2c3d4e", Author: "Ada Lovelace", Content: "func run() {"}},
+		{lines[1], blameLine{Line: 4, Commit: "1b2c3d4e", Author: "Ada Lovelace", Content: "\treturn nil"}},
+	}
+	for _, tt := range tests {
+		if tt.got.Date.Unix() != 1760000000 {
+			t.Errorf("line %d date = %v", tt.got.Line, tt.got.Date)
+		}
+		tt.got.Date = tt.want.Date
+		if tt.got != tt.want {
+			t.Errorf("parsed %+v, want %+v", tt.got, tt.want)
+		}
+	}
+}
+
+func TestGitBlameRange(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	git := func(args ...string) {
+		args = append([]string{"-C", dir, "-c", "user.name=Tester", "-c", "user.email=t@example.com"}, args...)
+		if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, out)
+		}
+	}
+	git("init", "-q")
+	os.WriteFile(filepath.Join(dir, "f.txt"), []byte(numberedLines(5)), 0644)
+	git("add", ".")
+	git("commit", "-q", "-m", "init")
+
+	tests := []struct {
+		start, end int
+		first      string
+		lines      int
+	}{
+		{0, 0, "1 ", 5},
+		{2, 3, "2 ", 2},
+		{4, 0, "4 ", 2},
+	}
+	for _, tt := range tests {
+		out, err := New(dir).GitBlame("f.txt", tt.start, tt.end)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows := strings.Split(strings.TrimSuffix(out, "\n"), "\n")
+		if len(rows) != tt.lines || !strings.HasPrefix(rows[0], tt.first) || !strings.Contains(rows[0], " Tester | line 00") {
+			t.Errorf("GitBlame(%d, %d) =\n%s", tt.start, tt.end, out)
+		}
+	}
+	if _, err := New(dir).GitBlame("f.txt", 9, 0); err == nil {
+		t.Error("range past the end: no error")
+	}
+}
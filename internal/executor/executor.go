@@ -3,14 +3,23 @@ package executor
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
+
+	"aicli/internal/diff"
 )
 
 type Result struct {
@@ -22,8 +31,11 @@ type Result struct {
 }
 
 type Executor struct {
-	workDir string
-	timeout time.Duration
+	workDir       string
+	timeout       time.Duration
+	maxReadBytes  int  // ReadFile cap; 0 reads whole files
+	syncManifests bool // SetVersion also updates manifest version fields
+	tagVersions   bool // GitCommitWithVersion also tags the new version
 }
 
 func New(workDir string) *Executor {
@@ -62,6 +74,15 @@ func (e *Executor) CanCreateDirectories() bool {
 	return false
 }
 
+// SetMaxReadBytes caps the text ReadFile and ReadFileLines return; larger
+// content is cut to its head and tail. 0 or less removes the cap.
+func (e *Executor) SetMaxReadBytes(n int) {
+	if n < 0 {
+		n = 0
+	}
+	e.maxReadBytes = n
+}
+
 func (e *Executor) SetWorkDir(dir string) {
 	e.workDir = dir
 }
@@ -186,7 +207,77 @@ func (e *Executor) WriteFile(path, content string) error {
 // ImagePrefix is used to identify base64-encoded image content in read results
 const ImagePrefix = "IMAGE:BASE64:"
 
+// ReadFile returns the content of a text file, or an image as ImagePrefix
+// and base64. Text over the read cap (SetMaxReadBytes) is cut to its head
+// and tail with a notice naming the omitted lines.
 func (e *Executor) ReadFile(path string) (string, error) {
+	content, err := e.ReadFileFull(path)
+	if err != nil || strings.HasPrefix(content, ImagePrefix) {
+		return content, err
+	}
+	return e.capContent(content, 1), nil
+}
+
+// ReadFileLines returns lines start through end (1-based, inclusive) of a
+// text file. end 0 reads to the end of the file. The result is capped like
+// ReadFile.
+func (e *Executor) ReadFileLines(path string, start, end int) (string, error) {
+	content, err := e.ReadFileFull(path)
+	if err != nil {
+		return "", err
+	}
+	if strings.HasPrefix(content, ImagePrefix) {
+		return "", fmt.Errorf("%s is an image; line ranges apply to text files", filepath.Base(path))
+	}
+
+	lines := strings.SplitAfter(content, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if start < 1 {
+		start = 1
+	}
+	if start > len(lines) {
+		return "", fmt.Errorf("start line %d is past the end of the file (%d lines)", start, len(lines))
+	}
+	if end <= 0 || end > len(lines) {
+		end = len(lines)
+	}
+	if start > end {
+		return "", fmt.Errorf("start line %d is after end line %d", start, end)
+	}
+	return e.capContent(strings.Join(lines[start-1:end], ""), start), nil
+}
+
+// capContent cuts content over maxReadBytes to whole lines from its head
+// and tail, with a notice in between. firstLine is the file line content
+// starts at, so the notice can name the omitted lines.
+func (e *Executor) capContent(content string, firstLine int) string {
+	if e.maxReadBytes <= 0 || len(content) <= e.maxReadBytes {
+		return content
+	}
+
+	// Keep about two thirds of the budget for the head, ending on a line break
+	headEnd := e.maxReadBytes * 2 / 3
+	if i := strings.LastIndex(content[:headEnd], "\n"); i >= 0 {
+		headEnd = i + 1
+	}
+	tailStart := len(content) - (e.maxReadBytes - headEnd)
+	if i := strings.Index(content[tailStart:], "\n"); i >= 0 && tailStart+i+1 < len(content) {
+		tailStart += i + 1
+	}
+
+	omittedFrom := firstLine + strings.Count(content[:headEnd], "\n")
+	omittedTo := firstLine + strings.Count(content[:tailStart], "\n") - 1
+	notice := fmt.Sprintf("\n... [%d of %d bytes omitted (lines %d-%d): the file is larger than the %d byte read limit. "+
+		"Use read_file with start_line and end_line to read the omitted part.] ...\n\n",
+		tailStart-headEnd, len(content), omittedFrom, omittedTo, e.maxReadBytes)
+	return content[:headEnd] + notice + content[tailStart:]
+}
+
+// ReadFileFull is ReadFile without the read cap, for callers that do not
+// put the content in front of the model
+func (e *Executor) ReadFileFull(path string) (string, error) {
 	fullPath := path
 	if !filepath.IsAbs(path) {
 		fullPath = filepath.Join(e.workDir, path)
@@ -266,16 +357,231 @@ func (e *Executor) GitBranch() *Result {
 	return e.Run("git branch --show-current")
 }
 
+// CurrentBranch returns the checked-out git branch without printing
+// anything, or "" if the work dir is not a git repository
+func (e *Executor) CurrentBranch() string {
+	out, err := e.runQuiet("git", "branch", "--show-current")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(out)
+}
+
+// Tags returns the repository's git tags, or nil outside a git repository
+func (e *Executor) Tags() []string {
+	out, err := e.runQuiet("git", "tag", "--list")
+	if err != nil {
+		return nil
+	}
+	return strings.Fields(out)
+}
+
+// RemoteURL returns the origin remote URL as configured, or ""
+func (e *Executor) RemoteURL() string {
+	out, err := e.runQuiet("git", "remote", "get-url", "origin")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(out)
+}
+
+// RemoteWebURL returns the web URL of the origin remote (SSH remotes such as
+// git@github.com:user/repo.git become https://github.com/user/repo), or ""
+func (e *Executor) RemoteWebURL() string {
+	url := strings.TrimSuffix(e.RemoteURL(), ".git")
+	if strings.HasPrefix(url, "git@") {
+		url = "https://" + strings.Replace(strings.TrimPrefix(url, "git@"), ":", "/", 1)
+	}
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		return ""
+	}
+	return url
+}
+
+// runQuiet executes a program in the work dir without streaming output to the terminal
+func (e *Executor) runQuiet(name string, args ...string) (string, error) {
+	cmd := exec.Command(name, args...)
+	cmd.Dir = e.workDir
+	out, err := cmd.Output()
+	return string(out), err
+}
+
+// ChangedFiles returns the paths reported by git status (modified, staged,
+// and untracked), or nil if the work dir is not a git repository
+func (e *Executor) ChangedFiles() []string {
+	out, err := e.runQuiet("git", "status", "--porcelain")
+	if err != nil {
+		return nil
+	}
+
+	var files []string
+	for _, line := range strings.Split(out, "\n") {
+		if len(line) < 4 {
+			continue
+		}
+		path := line[3:]
+		// Renames are reported as "old -> new"
+		if idx := strings.Index(path, " -> "); idx >= 0 {
+			path = path[idx+4:]
+		}
+		files = append(files, strings.Trim(path, "\""))
+	}
+	return files
+}
+
+// FileStates snapshots the files git reports as changed or untracked: path
+// to porcelain status plus a hash of the content. Comparing two snapshots
+// with ChangedFileStates finds what happened in between, including further
+// edits to files that were already modified.
+func (e *Executor) FileStates() (map[string]string, error) {
+	out, err := e.runQuiet("git", "status", "--porcelain", "--untracked-files=all")
+	if err != nil {
+		return nil, fmt.Errorf("git status failed (not a git repository?): %w", err)
+	}
+
+	states := make(map[string]string)
+	for _, line := range strings.Split(out, "\n") {
+		if len(line) < 4 {
+			continue
+		}
+		path := line[3:]
+		if idx := strings.Index(path, " -> "); idx >= 0 {
+			path = path[idx+4:]
+		}
+		path = strings.Trim(path, "\"")
+
+		hash := "-" // Deleted
+		if data, err := os.ReadFile(filepath.Join(e.workDir, path)); err == nil {
+			sum := sha256.Sum256(data)
+			hash = hex.EncodeToString(sum[:8])
+		}
+		states[path] = line[:2] + " " + hash
+	}
+	return states, nil
+}
+
+// FileChange is a file whose state differs between two FileStates snapshots
+type FileChange struct {
+	Path   string
+	Status string // Porcelain status afterwards, e.g. "M", "??", "D"; "reverted" if no longer changed
+}
+
+// ChangedFileStates compares two FileStates snapshots, sorted by path
+func ChangedFileStates(before, after map[string]string) []FileChange {
+	var changes []FileChange
+	for path, state := range after {
+		if before[path] != state {
+			changes = append(changes, FileChange{Path: path, Status: strings.TrimSpace(state[:2])})
+		}
+	}
+	for path := range before {
+		if _, ok := after[path]; !ok {
+			changes = append(changes, FileChange{Path: path, Status: "reverted"})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+	return changes
+}
+
+// maxListedFiles caps the files ListFiles returns
+const maxListedFiles = 50
+
+// listedSourceFiles are the names ListFiles matches for a directory
+// pattern: source files by extension plus project and docs files
+var listedSourceFiles = []string{
+	"*.go", "*.py", "*.js", "*.ts", "*.rs", "*.c", "*.cpp", "*.h", "*.md",
+	"go.mod", "go.sum", "package.json", "Cargo.toml", "requirements.txt", "Makefile",
+}
+
+// ListFiles lists up to maxListedFiles files, three levels deep. "*" or "."
+// lists every non-hidden file to show the project structure; a directory
+// (or glob) lists the source, project and docs files under it. Hidden
+// directories (.git, .aicli) are only entered when named as the pattern.
+// Paths excluded by .aicliignore are skipped. An absolute pattern lists
+// absolute paths. The listing is printed as well as returned.
 func (e *Executor) ListFiles(pattern string) *Result {
+	start := time.Now()
 	if pattern == "" {
 		pattern = "."
 	}
-	// If pattern is "*" or ".", list all non-hidden files to show project structure
-	if pattern == "*" || pattern == "." {
-		return e.Run("find . -maxdepth 3 -type f ! -path '*/\\.*' 2>/dev/null | head -50")
+	all := pattern == "*" || pattern == "."
+	abs := filepath.IsAbs(pattern)
+	roots := []string{"."}
+	if !all {
+		glob := pattern
+		if !abs {
+			glob = filepath.Join(e.workDir, pattern)
+		}
+		matches, _ := filepath.Glob(glob)
+		roots = nil
+		for _, m := range matches {
+			if rel, err := filepath.Rel(e.workDir, m); err == nil {
+				roots = append(roots, rel)
+			}
+		}
+	}
+
+	ignore := loadIgnoreRules(e.workDir, IgnoreFile)
+	var files []string
+	for _, root := range roots {
+		filepath.WalkDir(filepath.Join(e.workDir, root), func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil // Unreadable entries are left out
+			}
+			if len(files) >= maxListedFiles {
+				return filepath.SkipAll
+			}
+			rel, _ := filepath.Rel(e.workDir, p)
+			name := d.Name()
+			hidden := strings.HasPrefix(name, ".") && (all || d.IsDir())
+			if rel != root && (ignore.Covers(rel, d.IsDir()) || hidden) {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			relRoot, _ := filepath.Rel(filepath.Join(e.workDir, root), p)
+			if d.IsDir() {
+				if relRoot != "." && strings.Count(filepath.ToSlash(relRoot), "/")+1 >= 3 {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if all || matchesAny(name, listedSourceFiles) {
+				switch {
+				case all:
+					rel = "./" + filepath.ToSlash(rel)
+				case abs:
+					rel = p
+				}
+				files = append(files, rel)
+			}
+			return nil
+		})
+	}
+
+	output := ""
+	if len(files) > 0 {
+		output = strings.Join(files, "\n") + "\n"
+	}
+	fmt.Print(output)
+	return &Result{
+		Command:  "list_files " + pattern,
+		Output:   output,
+		Duration: time.Since(start),
+	}
+}
+
+// matchesAny reports whether name matches one of the glob patterns
+func matchesAny(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := filepath.Match(pattern, name); ok {
+			return true
+		}
 	}
-	// Use find with proper grouping for -o clauses, include config files and docs
-	return e.Run(fmt.Sprintf("find %s -maxdepth 3 -type f \\( -name '*.go' -o -name '*.py' -o -name '*.js' -o -name '*.ts' -o -name '*.rs' -o -name '*.c' -o -name '*.cpp' -o -name '*.h' -o -name '*.md' -o -name 'go.mod' -o -name 'go.sum' -o -name 'package.json' -o -name 'Cargo.toml' -o -name 'requirements.txt' -o -name 'Makefile' \\) 2>/dev/null | head -50", pattern))
+	return false
 }
 
 // ScreenCapture captures the screen or a window
@@ -302,38 +608,156 @@ func (e *Executor) ScreenCapture(outputPath string, interactive bool) *Result {
 	return result
 }
 
+// FormatFile runs formatter (name and arguments) on path, relative to the
+// working directory. Returns the changes the formatter made.
+func (e *Executor) FormatFile(path string, formatter []string) ([]diff.Hunk, error) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(e.workDir, path)
+	}
+	before, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	args := append(append([]string{}, formatter[1:]...), path)
+	cmd := exec.Command(formatter[0], args...)
+	cmd.Dir = e.workDir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("%s failed: %s", formatter[0], strings.TrimSpace(string(out)))
+	}
+
+	after, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return diff.Hunks(string(before), string(after), 1), nil
+}
+
+// OpenCommand returns the platform opener invocation for target on goos
+func OpenCommand(goos, target string) (string, []string) {
+	switch goos {
+	case "darwin":
+		return "open", []string{target}
+	case "windows":
+		// Not "cmd /c start": cmd would interpret &, ^ and % in URLs
+		return "rundll32", []string{"url.dll,FileProtocolHandler", target}
+	default:
+		return "xdg-open", []string{target}
+	}
+}
+
+// Open opens a URL or a local file (relative to the working directory) with
+// the platform's default application, without waiting for it to exit
+func (e *Executor) Open(target string) error {
+	if !strings.Contains(target, "://") && !strings.HasPrefix(target, "mailto:") {
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(e.workDir, target)
+		}
+		if _, err := os.Stat(target); err != nil {
+			return fmt.Errorf("no such file: %s", target)
+		}
+	}
+
+	name, args := OpenCommand(runtime.GOOS, target)
+	cmd := exec.Command(name, args...)
+	cmd.Dir = e.workDir
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("%s failed: %w", name, err)
+	}
+	go cmd.Wait()
+	return nil
+}
+
 // Version management
 type Version struct {
 	Major int
 	Minor int
 	Patch int
+	Pre   string // Pre-release, e.g. "rc.1" in 1.2.3-rc.1
+	Build string // Build metadata, e.g. "abc123" in 1.2.3+abc123
 }
 
 func (v Version) String() string {
-	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.Pre != "" {
+		s += "-" + v.Pre
+	}
+	if v.Build != "" {
+		s += "+" + v.Build
+	}
+	return s
 }
 
+// versionPattern matches x.y.z (minor and patch optional) with an optional
+// v prefix, pre-release and build metadata
+var versionPattern = regexp.MustCompile(`[vV]?(\d+)(?:\.(\d+))?(?:\.(\d+))?(?:-([0-9A-Za-z.-]+))?(?:\+([0-9A-Za-z.-]+))?`)
+
+// ParseVersion reads the first version in s, so "1.2.3", "v1.2.3",
+// "1.2.3-rc.1+abc" and `version = "1.2.3"` all parse. Text without a
+// version is 0.0.0.
 func ParseVersion(s string) Version {
 	var v Version
-	fmt.Sscanf(strings.TrimSpace(s), "%d.%d.%d", &v.Major, &v.Minor, &v.Patch)
+	m := versionPattern.FindStringSubmatch(s)
+	if m == nil {
+		return v
+	}
+	v.Major, _ = strconv.Atoi(m[1])
+	v.Minor, _ = strconv.Atoi(m[2])
+	v.Patch, _ = strconv.Atoi(m[3])
+	v.Pre, v.Build = m[4], m[5]
 	return v
 }
 
+// GetVersion reads the VERSION file. Without one the version comes from
+// the project's manifest (package.json, Cargo.toml or pyproject.toml), and
+// without that it is 0.1.0.
 func (e *Executor) GetVersion() (Version, error) {
 	versionFile := filepath.Join(e.workDir, "VERSION")
 	content, err := os.ReadFile(versionFile)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return Version{0, 1, 0}, nil
+			if s, _, ok := e.manifestVersion(); ok {
+				return ParseVersion(s), nil
+			}
+			return Version{Major: 0, Minor: 1, Patch: 0}, nil
 		}
 		return Version{}, err
 	}
 	return ParseVersion(string(content)), nil
 }
 
+// SetSyncManifests makes SetVersion (and so version bumps) also write the
+// version into package.json, Cargo.toml and pyproject.toml
+func (e *Executor) SetSyncManifests(sync bool) {
+	e.syncManifests = sync
+}
+
+// SetVersion writes the VERSION file and, with SetSyncManifests, the
+// version field of each manifest that has one
 func (e *Executor) SetVersion(v Version) error {
 	versionFile := filepath.Join(e.workDir, "VERSION")
-	return os.WriteFile(versionFile, []byte(v.String()+"\n"), 0644)
+	if err := os.WriteFile(versionFile, []byte(v.String()+"\n"), 0644); err != nil {
+		return err
+	}
+	if !e.syncManifests {
+		return nil
+	}
+	return e.writeManifestVersions(v)
+}
+
+// SetTagVersions makes GitCommitWithVersion create an annotated vX.Y.Z tag
+// after each successful commit
+func (e *Executor) SetTagVersions(tag bool) {
+	e.tagVersions = tag
+}
+
+// versionFiles are the files SetVersion writes
+func (e *Executor) versionFiles() []string {
+	files := []string{"VERSION"}
+	if e.syncManifests {
+		files = append(files, e.versionedManifests()...)
+	}
+	return files
 }
 
 func (e *Executor) BumpVersion(bumpType string) (Version, error) {
@@ -341,7 +765,14 @@ func (e *Executor) BumpVersion(bumpType string) (Version, error) {
 	if err != nil {
 		return v, err
 	}
+	v = v.Bump(bumpType)
+	return v, e.SetVersion(v)
+}
 
+// Bump returns the next version for a "major", "minor" or "patch" (or
+// empty) bump. The result is a release, without pre-release or build parts.
+func (v Version) Bump(bumpType string) Version {
+	v.Pre, v.Build = "", ""
 	switch bumpType {
 	case "major":
 		v.Major++
@@ -353,15 +784,20 @@ func (e *Executor) BumpVersion(bumpType string) (Version, error) {
 	case "patch", "":
 		v.Patch++
 	}
-
-	return v, e.SetVersion(v)
+	return v
 }
 
-// InitVersion creates VERSION file if it doesn't exist
+// InitVersion creates VERSION file if it doesn't exist, starting from the
+// manifest's version if there is one
 func (e *Executor) InitVersion() error {
 	versionFile := filepath.Join(e.workDir, "VERSION")
 	if _, err := os.Stat(versionFile); os.IsNotExist(err) {
-		return e.SetVersion(Version{0, 1, 0})
+		v, err := e.GetVersion()
+		if err != nil {
+			return err
+		}
+		// Only VERSION: the manifests already hold this version
+		return os.WriteFile(versionFile, []byte(v.String()+"\n"), 0644)
 	}
 	return nil
 }
@@ -373,14 +809,118 @@ func (e *Executor) GitCommitWithVersion(message string, bumpType string) *Result
 		return &Result{Error: fmt.Sprintf("Failed to bump version: %v", err), ExitCode: 1}
 	}
 
-	// Stage VERSION file
-	e.GitAdd("VERSION")
+	// Stage VERSION file (and manifests when they carry the version)
+	e.GitAdd(e.versionFiles()...)
 
 	// Include version in commit message
-	fullMessage := fmt.Sprintf("%s (v%s)", message, v.String())
-	fullMessage = strings.ReplaceAll(fullMessage, "'", "'\"'\"'")
+	fullMessage := commitMessage(message, v)
+	quoted := strings.ReplaceAll(fullMessage, "'", "'\"'\"'")
+
+	result := e.Run(fmt.Sprintf("git commit -m '%s'", quoted))
+	if result.Success() && e.tagVersions {
+		note, err := e.tagVersion(v, fullMessage)
+		if err != nil {
+			result.Error = strings.TrimSpace(result.Error + "\n" + err.Error())
+		} else {
+			result.Output = strings.TrimRight(result.Output, "\n") + "\n" + note
+		}
+	}
+	return result
+}
+
+// versionTag is the git tag for a version
+func versionTag(v Version) string {
+	return "v" + v.String()
+}
+
+// tagExists reports whether the repository has the tag
+func (e *Executor) tagExists(tag string) bool {
+	_, err := e.runQuiet("git", "rev-parse", "-q", "--verify", "refs/tags/"+tag)
+	return err == nil
+}
+
+// tagVersion creates an annotated tag for v on HEAD, unless the tag already
+// exists, and describes what it did
+func (e *Executor) tagVersion(v Version, message string) (string, error) {
+	tag := versionTag(v)
+	if e.tagExists(tag) {
+		return fmt.Sprintf("Tag %s already exists; not tagged", tag), nil
+	}
+	if out, err := e.runQuiet("git", "tag", "-a", tag, "-m", message); err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			out += string(exitErr.Stderr)
+		}
+		return "", fmt.Errorf("failed to create tag %s: %s", tag, strings.TrimSpace(out))
+	}
+	return fmt.Sprintf("Tagged %s", tag), nil
+}
+
+// commitMessage is the message GitCommitWithVersion commits with
+func commitMessage(message string, v Version) string {
+	return fmt.Sprintf("%s (v%s)", message, v.String())
+}
+
+// CommitPreview is what GitCommitWithVersion would commit
+type CommitPreview struct {
+	Staged  []string // Staged changes as git status --porcelain lines
+	Files   []string // Version files bumped and staged: VERSION and synced manifests
+	From    Version  // Current version
+	To      Version  // Version after the bump
+	Message string   // Full commit message
+	Tag     string   // Tag created after the commit, or "" for none
+	TagNote string   // Why no tag is created although tagging is on
+}
+
+// PreviewCommit describes what GitCommitWithVersion(message, bumpType)
+// would commit, without bumping, staging or committing anything
+func (e *Executor) PreviewCommit(message, bumpType string) (*CommitPreview, error) {
+	out, err := e.runQuiet("git", "status", "--porcelain")
+	if err != nil {
+		return nil, fmt.Errorf("git status failed (not a git repository?): %w", err)
+	}
+	v, err := e.GetVersion()
+	if err != nil {
+		return nil, err
+	}
+
+	p := &CommitPreview{Files: e.versionFiles(), From: v, To: v.Bump(bumpType)}
+	p.Message = commitMessage(message, p.To)
+	if e.tagVersions {
+		if tag := versionTag(p.To); e.tagExists(tag) {
+			p.TagNote = fmt.Sprintf("Tag %s already exists; it would not be created", tag)
+		} else {
+			p.Tag = tag
+		}
+	}
+	for _, line := range strings.Split(out, "\n") {
+		// The first column is the index status; ' ' is unstaged, '?' untracked
+		if len(line) < 4 || line[0] == ' ' || line[0] == '?' {
+			continue
+		}
+		p.Staged = append(p.Staged, line)
+	}
+	return p, nil
+}
 
-	return e.Run(fmt.Sprintf("git commit -m '%s'", fullMessage))
+// String renders the preview for display before confirmation
+func (p *CommitPreview) String() string {
+	var sb strings.Builder
+	if len(p.Staged) == 0 {
+		sb.WriteString(fmt.Sprintf("Nothing staged: only the %s bump would be committed\n", strings.Join(p.Files, ", ")))
+	} else {
+		sb.WriteString("Staged changes:\n")
+		for _, line := range p.Staged {
+			sb.WriteString("  " + line + "\n")
+		}
+	}
+	sb.WriteString(fmt.Sprintf("%s: %s -> %s (staged with the commit)\n", strings.Join(p.Files, ", "), p.From, p.To))
+	if p.Tag != "" {
+		sb.WriteString(fmt.Sprintf("Tag: %s (annotated, after the commit)\n", p.Tag))
+	} else if p.TagNote != "" {
+		sb.WriteString(p.TagNote + "\n")
+	}
+	sb.WriteString(fmt.Sprintf("Message: %s", p.Message))
+	return sb.String()
 }
 
 func (r *Result) String() string {
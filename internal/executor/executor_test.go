@@ -0,0 +1,539 @@
+package executor
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestOpenCommand(t *testing.T) {
+	tests := []struct {
+		goos   string
+		target string
+		want   string
+	}{
+		{"darwin", "https://example.com", "open https://example.com"},
+		{"linux", "docs/index.html", "xdg-open docs/index.html"},
+		{"windows", "https://example.com/?a=1&b=2", "rundll32 url.dll,FileProtocolHandler https://example.com/?a=1&b=2"},
+		{"windows", `C:\proj\report.pdf`, `rundll32 url.dll,FileProtocolHandler C:\proj\report.pdf`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.goos+" "+tt.target, func(t *testing.T) {
+			name, args := OpenCommand(tt.goos, tt.target)
+			if got := name + " " + strings.Join(args, " "); got != tt.want {
+				t.Errorf("OpenCommand = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatFile(t *testing.T) {
+	if _, err := exec.LookPath("gofmt"); err != nil {
+		t.Skip("gofmt not installed")
+	}
+	tests := []struct {
+		name        string
+		content     string
+		wantHeaders []string
+	}{
+		{"already formatted", "package main\n\nfunc main() {}\n", nil},
+		{"one change", "package main\n\nfunc main()  {}\n", []string{"@@ -2,2 +2,2 @@"}},
+		{"two changes", "package main\n\nimport \"fmt\"\n\nfunc a()  {}\n\nfunc b() {}\n\nfunc c() {}\n\nfunc main()  { fmt.Println() }\n",
+			[]string{"@@ -4,3 +4,3 @@", "@@ -10,2 +10,2 @@"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			hunks, err := New(dir).FormatFile("main.go", []string{"gofmt", "-w"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var headers []string
+			for _, h := range hunks {
+				headers = append(headers, h.Header())
+			}
+			if strings.Join(headers, " ") != strings.Join(tt.wantHeaders, " ") {
+				t.Errorf("hunks = %v, want %v", headers, tt.wantHeaders)
+			}
+		})
+	}
+}
+
+func TestListFilesSkipsHiddenDirectories(t *testing.T) {
+	dir := t.TempDir()
+	for _, f := range []string{"main.go", "cmd/app/main.go", ".git/hooks/pre-commit.go", "cmd/.cache/gen.go", ".github/tools/check.go", ".golangci.yml"} {
+		path := filepath.Join(dir, f)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		os.WriteFile(path, []byte("x"), 0644)
+	}
+	tests := []struct {
+		pattern string
+		want    string
+	}{
+		{".", "./cmd/app/main.go ./main.go"},
+		{"cmd", "cmd/app/main.go"},
+		{"c*", "cmd/app/main.go"},
+		{".github", ".github/tools/check.go"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			got := strings.Join(strings.Fields(New(dir).ListFiles(tt.pattern).Output), " ")
+			if got != tt.want {
+				t.Errorf("ListFiles(%q) = %q, want %q", tt.pattern, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPreviewCommitListsVersionFiles(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	tests := []struct {
+		sync bool
+		want string
+	}{
+		{false, "VERSION: 1.2.3 -> 1.2.4"},
+		{true, "VERSION, package.json: 1.2.3 -> 1.2.4"},
+	}
+	for _, tt := range tests {
+		dir := t.TempDir()
+		if out, err := exec.Command("git", "-C", dir, "init", "-q").CombinedOutput(); err != nil {
+			t.Fatalf("git init: %v: %s", err, out)
+		}
+		os.WriteFile(filepath.Join(dir, "VERSION"), []byte("1.2.3\n"), 0644)
+		os.WriteFile(filepath.Join(dir, "package.json"), []byte(`{"version": "1.2.3"}`), 0644)
+		e := New(dir)
+		e.SetSyncManifests(tt.sync)
+		p, err := e.PreviewCommit("release", "patch")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := p.String(); !strings.Contains(got, tt.want) {
+			t.Errorf("sync=%v: preview = %q, want it to contain %q", tt.sync, got, tt.want)
+		}
+	}
+}
+
+func TestCommitPreviewString(t *testing.T) {
+	from, to := Version{Major: 1, Minor: 2, Patch: 3}, Version{Major: 1, Minor: 3}
+	tests := []struct {
+		name    string
+		preview CommitPreview
+		want    []string
+	}{
+		{"nothing staged", CommitPreview{Files: []string{"VERSION"}, From: from, To: to, Message: "Add x (v1.3.0)"},
+			[]string{"Nothing staged: only the VERSION bump", "VERSION: 1.2.3 -> 1.3.0", "Message: Add x (v1.3.0)"}},
+		{"staged and tagged", CommitPreview{Staged: []string{"M  main.go"}, Files: []string{"VERSION"}, From: from, To: to, Message: "m", Tag: "v1.3.0"},
+			[]string{"Staged changes:\n  M  main.go", "Tag: v1.3.0 (annotated"}},
+		{"tag exists", CommitPreview{Files: []string{"VERSION"}, From: from, To: to, TagNote: "Tag v1.3.0 already exists; it would not be created"},
+			[]string{"Tag v1.3.0 already exists"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.preview.String()
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("preview = %q, want it to contain %q", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestPreviewCommitStagedOnly(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	if out, err := exec.Command("git", "-C", dir, "init", "-q").CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v: %s", err, out)
+	}
+	os.WriteFile(filepath.Join(dir, "VERSION"), []byte("0.4.1\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "staged.go"), []byte("package x\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "untracked.go"), []byte("package x\n"), 0644)
+	if out, err := exec.Command("git", "-C", dir, "add", "staged.go").CombinedOutput(); err != nil {
+		t.Fatalf("git add: %v: %s", err, out)
+	}
+
+	p, err := New(dir).PreviewCommit("Add x", "minor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Staged) != 1 || !strings.HasSuffix(p.Staged[0], "staged.go") {
+		t.Errorf("Staged = %q, want only staged.go", p.Staged)
+	}
+	if p.To.String() != "0.5.0" || p.Message != "Add x (v0.5.0)" {
+		t.Errorf("preview to %s with %q, want 0.5.0 with %q", p.To, p.Message, "Add x (v0.5.0)")
+	}
+	// Nothing was bumped or committed
+	if data, _ := os.ReadFile(filepath.Join(dir, "VERSION")); string(data) != "0.4.1\n" {
+		t.Errorf("VERSION changed to %q", data)
+	}
+}
+
+func TestChangedFileStates(t *testing.T) {
+	tests := []struct {
+		name          string
+		before, after map[string]string
+		want          string
+	}{
+		{"unchanged", map[string]string{"a.go": " M 1"}, map[string]string{"a.go": " M 1"}, ""},
+		{"edited again", map[string]string{"a.go": " M 1"}, map[string]string{"a.go": " M 2"}, "a.go:M"},
+		{"new file", nil, map[string]string{"c.go": "?? 3"}, "c.go:??"},
+		{"reverted", map[string]string{"a.go": " M 1"}, nil, "a.go:reverted"},
+		{"sorted", nil, map[string]string{"z.go": "?? 1", "b.go": " D -"}, "b.go:D z.go:??"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, c := range ChangedFileStates(tt.before, tt.after) {
+				got = append(got, c.Path+":"+c.Status)
+			}
+			if strings.Join(got, " ") != tt.want {
+				t.Errorf("changes = %q, want %q", strings.Join(got, " "), tt.want)
+			}
+		})
+	}
+}
+
+func TestFileStatesAroundCommand(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	git := func(args ...string) {
+		args = append([]string{"-C", dir, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)
+		if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, out)
+		}
+	}
+	git("init", "-q")
+	for _, f := range []string{"a.go", "b.go", "keep.go"} {
+		os.WriteFile(filepath.Join(dir, f), []byte("package x\n"), 0644)
+	}
+	git("add", ".")
+	git("commit", "-q", "-m", "init")
+	os.WriteFile(filepath.Join(dir, "a.go"), []byte("package x // dirty\n"), 0644)
+
+	e := New(dir)
+	before, err := e.FileStates()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := e.Run("echo '// more' >> a.go && echo '// edit' >> b.go && mkdir -p gen && echo 'package gen' > gen/c.go"); r.Error != "" {
+		t.Fatalf("command failed: %s", r.Error)
+	}
+	after, err := e.FileStates()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, c := range ChangedFileStates(before, after) {
+		got = append(got, c.Path+":"+c.Status)
+	}
+	if want := "a.go:M b.go:M gen/c.go:??"; strings.Join(got, " ") != want {
+		t.Errorf("changes = %q, want %q", strings.Join(got, " "), want)
+	}
+}
+
+// numberedLines returns "line 001\n" through "line <n>\n"
+func numberedLines(n int) string {
+	var sb strings.Builder
+	for i := 1; i <= n; i++ {
+		fmt.Fprintf(&sb, "line %03d\n", i)
+	}
+	return sb.String()
+}
+
+func TestReadFileLines(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "f.txt"), []byte(numberedLines(10)), 0644)
+	os.WriteFile(filepath.Join(dir, "img.png"), []byte("\x89PNG"), 0644)
+	tests := []struct {
+		path       string
+		start, end int
+		want       string
+		wantErr    string
+	}{
+		{"f.txt", 3, 4, "line 003\nline 004\n", ""},
+		{"f.txt", 9, 0, "line 009\nline 010\n", ""},
+		{"f.txt", 0, 1, "line 001\n", ""},
+		{"f.txt", 10, 99, "line 010\n", ""},
+		{"f.txt", 11, 0, "", "past the end of the file (10 lines)"},
+		{"f.txt", 5, 4, "", "after end line"},
+		{"img.png", 1, 2, "", "is an image"},
+	}
+	for _, tt := range tests {
+		got, err := New(dir).ReadFileLines(tt.path, tt.start, tt.end)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ReadFileLines(%s, %d, %d) err = %v, want %q", tt.path, tt.start, tt.end, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ReadFileLines(%s, %d, %d) = %q, %v, want %q", tt.path, tt.start, tt.end, got, err, tt.want)
+		}
+	}
+}
+
+func TestCapContent(t *testing.T) {
+	tests := []struct {
+		name      string
+		content   string
+		firstLine int
+		max       int
+		omitted   string // Lines named in the notice, "" if not capped
+	}{
+		{"under cap", numberedLines(10), 1, 1000, ""},
+		{"no cap", numberedLines(500), 1, 0, ""},
+		{"capped", numberedLines(100), 1, 180, "lines 14-94"},
+		{"capped range", numberedLines(100), 41, 180, "lines 54-134"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := New(t.TempDir())
+			e.SetMaxReadBytes(tt.max)
+			got := e.capContent(tt.content, tt.firstLine)
+			if tt.omitted == "" {
+				if got != tt.content {
+					t.Errorf("content changed under the cap")
+				}
+				return
+			}
+			head, tail, ok := strings.Cut(got, "\n... [")
+			if !ok {
+				t.Fatalf("no notice in %q", got)
+			}
+			if !strings.HasPrefix(head, "line 001\n") || !strings.HasSuffix(tail, "line 100\n") {
+				t.Errorf("head or tail lost: %q", got)
+			}
+			if !strings.Contains(tail, "("+tt.omitted+")") || !strings.Contains(tail, "start_line and end_line") {
+				t.Errorf("notice = %q, want it to name %s and point at range reads", tail, tt.omitted)
+			}
+			_, rest, _ := strings.Cut(tail, "] ...\n\n")
+			if kept := len(head) + 1 + len(rest); kept > tt.max {
+				t.Errorf("kept %d bytes, over the %d byte cap", kept, tt.max)
+			}
+		})
+	}
+}
+
+func TestListFilesIgnoreAndAbsolutePatterns(t *testing.T) {
+	dir := t.TempDir()
+	other := t.TempDir()
+	for _, f := range []string{"main.go", "gen/api.pb.go", "vendor/x/x.go", "pkg/a.go", "pkg/a_gen.go"} {
+		path := filepath.Join(dir, f)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		os.WriteFile(path, []byte("x"), 0644)
+	}
+	os.WriteFile(filepath.Join(dir, IgnoreFile), []byte("# generated\ngen/\nvendor\n*_gen.go\n"), 0644)
+	os.MkdirAll(filepath.Join(other, "lib"), 0755)
+	os.WriteFile(filepath.Join(other, "lib", "util.go"), []byte("x"), 0644)
+
+	tests := []struct {
+		pattern string
+		want    string
+	}{
+		{".", "./main.go ./pkg/a.go"},
+		{"pkg", "pkg/a.go"},
+		{"gen", ""}, // Excluded even when named
+		{filepath.Join(dir, "pkg"), filepath.Join(dir, "pkg", "a.go")},
+		{filepath.Join(other, "*"), filepath.Join(other, "lib", "util.go")},
+		{filepath.Join(other, "missing", "*.go"), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			got := strings.Join(strings.Fields(New(dir).ListFiles(tt.pattern).Output), " ")
+			if got != tt.want {
+				t.Errorf("ListFiles(%q) = %q, want %q", tt.pattern, got, tt.want)
+			}
+		})
+	}
+}
+
+// gitRepo creates a git repository in a temp directory, returning it and a
+// function that runs git there (failing the test on error)
+func gitRepo(t *testing.T) (string, func(args ...string)) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := t.TempDir()
+	git := func(args ...string) {
+		t.Helper()
+		args = append([]string{"-C", dir, "-c", "user.name=Tester", "-c", "user.email=t@example.com"}, args...)
+		if out, err := exec.Command("git", args...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, out)
+		}
+	}
+	git("init", "-q")
+	return dir, git
+}
+
+func TestChangedFiles(t *testing.T) {
+	dir, git := gitRepo(t)
+	for _, f := range []string{"kept.go", "edited.go", "old name.go", "staged.go"} {
+		os.WriteFile(filepath.Join(dir, f), []byte("package x // "+f+"\n"), 0644)
+	}
+	git("add", ".")
+	git("commit", "-q", "-m", "init")
+
+	if got := New(dir).ChangedFiles(); len(got) != 0 {
+		t.Errorf("clean tree: ChangedFiles() = %q", got)
+	}
+	os.WriteFile(filepath.Join(dir, "edited.go"), []byte("package x // changed\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "staged.go"), []byte("package x // changed\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "new.go"), []byte("package x\n"), 0644)
+	git("add", "staged.go")
+	git("mv", "old name.go", "new name.go")
+
+	got := New(dir).ChangedFiles()
+	sort.Strings(got)
+	if want := "edited.go|new name.go|new.go|staged.go"; strings.Join(got, "|") != want {
+		t.Errorf("ChangedFiles() = %q, want %q", got, want)
+	}
+	if got := New(t.TempDir()).ChangedFiles(); got != nil {
+		t.Errorf("outside a repository: ChangedFiles() = %q, want nil", got)
+	}
+}
+
+func TestRemoteWebURLAndTags(t *testing.T) {
+	tests := []struct {
+		remote string
+		want   string
+	}{
+		{"git@github.com:example/proj.git", "https://github.com/example/proj"},
+		{"https://gitlab.com/example/proj.git", "https://gitlab.com/example/proj"},
+		{"http://git.local/proj", "http://git.local/proj"},
+		{"/srv/git/proj.git", ""},
+	}
+	for _, tt := range tests {
+		dir, git := gitRepo(t)
+		git("remote", "add", "origin", tt.remote)
+		if got := New(dir).RemoteWebURL(); got != tt.want {
+			t.Errorf("RemoteWebURL() for %s = %q, want %q", tt.remote, got, tt.want)
+		}
+	}
+
+	dir, git := gitRepo(t)
+	if got := New(dir).RemoteWebURL(); got != "" {
+		t.Errorf("RemoteWebURL() without a remote = %q", got)
+	}
+	os.WriteFile(filepath.Join(dir, "a"), []byte("a"), 0644)
+	git("add", "a")
+	git("commit", "-q", "-m", "init")
+	git("tag", "v1.0.0")
+	git("tag", "1.1.0")
+	if got := strings.Join(New(dir).Tags(), " "); got != "1.1.0 v1.0.0" {
+		t.Errorf("Tags() = %q", got)
+	}
+	if got := New(t.TempDir()).Tags(); got != nil {
+		t.Errorf("Tags() outside a repository = %q, want nil", got)
+	}
+}
+
+func TestRemoteURL(t *testing.T) {
+	dir, git := gitRepo(t)
+	if got := New(dir).RemoteURL(); got != "" {
+		t.Errorf("RemoteURL without a remote = %q", got)
+	}
+	git("remote", "add", "origin", "git@github.com:glenn/widget.git")
+	if got := New(dir).RemoteURL(); got != "git@github.com:glenn/widget.git" {
+		t.Errorf("RemoteURL = %q", got)
+	}
+}
+
+func TestGitCommitWithVersionTags(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	for _, k := range []string{"GIT_AUTHOR_NAME", "GIT_COMMITTER_NAME"} {
+		t.Setenv(k, "Tester")
+	}
+	for _, k := range []string{"GIT_AUTHOR_EMAIL", "GIT_COMMITTER_EMAIL"} {
+		t.Setenv(k, "t@example.com")
+	}
+
+	tests := []struct {
+		name        string
+		tagVersions bool
+		existing    bool // v1.2.4 already tags the initial commit
+		wantNote    string
+		wantPreview string
+	}{
+		{"tagging off", false, false, "", ""},
+		{"tag created", true, false, "Tagged v1.2.4", "Tag: v1.2.4 (annotated, after the commit)"},
+		{"tag exists", true, true, "Tag v1.2.4 already exists; not tagged", "Tag v1.2.4 already exists; it would not be created"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			git := func(args ...string) string {
+				t.Helper()
+				out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput()
+				if err != nil {
+					t.Fatalf("git %v: %v: %s", args, err, out)
+				}
+				return strings.TrimSpace(string(out))
+			}
+			git("init", "-q")
+			os.WriteFile(filepath.Join(dir, "VERSION"), []byte("1.2.3\n"), 0644)
+			git("add", "VERSION")
+			git("commit", "-q", "-m", "init")
+			initial := git("rev-parse", "HEAD")
+			if tt.existing {
+				git("tag", "v1.2.4")
+			}
+
+			e := New(dir)
+			e.SetTagVersions(tt.tagVersions)
+			p, err := e.PreviewCommit("Add x", "patch")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if preview := p.String(); tt.wantPreview != "" && !strings.Contains(preview, tt.wantPreview) {
+				t.Errorf("preview = %q, want it to contain %q", preview, tt.wantPreview)
+			}
+
+			result := e.GitCommitWithVersion("Add x", "patch")
+			if !result.Success() {
+				t.Fatalf("commit failed: %s %s", result.Output, result.Error)
+			}
+			if tt.wantNote != "" && !strings.HasSuffix(strings.TrimSpace(result.Output), tt.wantNote) {
+				t.Errorf("output = %q, want it to end with %q", result.Output, tt.wantNote)
+			}
+
+			tags := git("tag", "--list")
+			switch {
+			case !tt.tagVersions:
+				if tags != "" {
+					t.Errorf("tags = %q, want none", tags)
+				}
+			case tt.existing:
+				if target := git("rev-parse", "v1.2.4^{commit}"); target != initial {
+					t.Errorf("existing tag moved to %s", target)
+				}
+			default:
+				if kind := git("cat-file", "-t", "v1.2.4"); kind != "tag" {
+					t.Errorf("v1.2.4 is a %s, want an annotated tag", kind)
+				}
+				if target, head := git("rev-parse", "v1.2.4^{commit}"), git("rev-parse", "HEAD"); target != head {
+					t.Errorf("tag points at %s, want HEAD %s", target, head)
+				}
+				if msg := git("tag", "-l", "--format=%(contents:subject)", "v1.2.4"); msg != "Add x (v1.2.4)" {
+					t.Errorf("tag message = %q", msg)
+				}
+			}
+		})
+	}
+}
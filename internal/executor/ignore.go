@@ -0,0 +1,136 @@
+package executor
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// IgnoreFile lists paths, in .gitignore syntax, that aicli's file listing
+// and context gathering skip in addition to .gitignore
+const IgnoreFile = ".aicliignore"
+
+// ignoreRule is one pattern from a .gitignore file
+type ignoreRule struct {
+	pattern  string
+	negate   bool // "!pattern" re-includes a path
+	dirOnly  bool // "pattern/" matches directories only
+	anchored bool // Contains a slash, so matches the path from the root
+}
+
+// ignoreRules is an ordered list of gitignore patterns; later rules win
+type ignoreRules []ignoreRule
+
+// loadIgnoreRules reads gitignore-style patterns from the named files in
+// dir. Missing files are skipped.
+func loadIgnoreRules(dir string, names ...string) ignoreRules {
+	var rules ignoreRules
+	for _, name := range names {
+		f, err := os.Open(filepath.Join(dir, name))
+		if err != nil {
+			continue
+		}
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			if rule, ok := parseIgnoreRule(scanner.Text()); ok {
+				rules = append(rules, rule)
+			}
+		}
+		f.Close()
+	}
+	return rules
+}
+
+// parseIgnoreRule parses one gitignore line; blank lines and comments are
+// not rules
+func parseIgnoreRule(line string) (ignoreRule, bool) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false
+	}
+	var r ignoreRule
+	if strings.HasPrefix(line, "!") {
+		r.negate = true
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		r.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	line = strings.TrimPrefix(line, "**/")
+	if strings.Contains(line, "/") {
+		r.anchored = true
+		line = strings.TrimPrefix(line, "/")
+	}
+	if line == "" {
+		return ignoreRule{}, false
+	}
+	r.pattern = line
+	return r, true
+}
+
+// Ignored reports whether rel (slash-separated, relative to the directory
+// the rules came from) is ignored
+func (rules ignoreRules) Ignored(rel string, isDir bool) bool {
+	ignored := false
+	for _, r := range rules {
+		if r.dirOnly && !isDir {
+			continue
+		}
+		if r.matches(rel) {
+			ignored = !r.negate
+		}
+	}
+	return ignored
+}
+
+func (r ignoreRule) matches(rel string) bool {
+	if r.anchored {
+		if ok, _ := filepath.Match(r.pattern, rel); ok {
+			return true
+		}
+		// "dir/**" matches everything below dir
+		if prefix, ok := strings.CutSuffix(r.pattern, "/**"); ok {
+			return strings.HasPrefix(rel, prefix+"/")
+		}
+		return false
+	}
+	ok, _ := filepath.Match(r.pattern, filepath.Base(rel))
+	return ok
+}
+
+// Ignored reports whether path (relative to the working directory, or
+// absolute) is excluded by .aicliignore, directly or through a parent
+// directory
+func (e *Executor) Ignored(path string) bool {
+	rules := loadIgnoreRules(e.workDir, IgnoreFile)
+	if len(rules) == 0 {
+		return false
+	}
+	if filepath.IsAbs(path) {
+		rel, err := filepath.Rel(e.workDir, path)
+		if err != nil {
+			return false
+		}
+		path = rel
+	}
+	info, err := os.Stat(filepath.Join(e.workDir, path))
+	return rules.Covers(path, err == nil && info.IsDir())
+}
+
+// Covers is Ignored for a path that may be inside an ignored directory:
+// it also checks each parent. path is relative to the rules' directory.
+func (rules ignoreRules) Covers(path string, isDir bool) bool {
+	rel := filepath.ToSlash(filepath.Clean(path))
+	if len(rules) == 0 || rel == "." || strings.HasPrefix(rel, "../") {
+		return false
+	}
+	parts := strings.Split(rel, "/")
+	for i := 1; i < len(parts); i++ {
+		if rules.Ignored(strings.Join(parts[:i], "/"), true) {
+			return true
+		}
+	}
+	return rules.Ignored(rel, isDir)
+}
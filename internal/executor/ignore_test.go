@@ -0,0 +1,56 @@
+package executor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIgnoreRulesCovers(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, IgnoreFile), []byte("# comment\n\nbuild/\n*.log\n!keep.log\ndocs/**\n/secrets.txt\n"), 0644)
+	rules := loadIgnoreRules(dir, IgnoreFile)
+
+	tests := []struct {
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{"build", true, true},
+		{"build", false, false}, // "build/" only matches directories
+		{"build/out/app", false, true},
+		{"debug.log", false, true},
+		{"logs/debug.log", false, true},
+		{"keep.log", false, false},
+		{"docs/guide.md", false, true},
+		{"docs", true, false},
+		{"secrets.txt", false, true},
+		{"config/secrets.txt", false, false},
+		{"main.go", false, false},
+		{"../outside.log", false, false},
+		{".", true, false},
+	}
+	for _, tt := range tests {
+		if got := rules.Covers(tt.path, tt.isDir); got != tt.want {
+			t.Errorf("Covers(%q, dir=%v) = %v, want %v", tt.path, tt.isDir, got, tt.want)
+		}
+	}
+}
+
+func TestExecutorIgnored(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "tmp"), 0755)
+	e := New(dir)
+	if e.Ignored("tmp/x") {
+		t.Error("ignored without an ignore file")
+	}
+	os.WriteFile(filepath.Join(dir, IgnoreFile), []byte("tmp/\n"), 0644)
+	for _, path := range []string{"tmp", "tmp/x", filepath.Join(dir, "tmp", "x")} {
+		if !e.Ignored(path) {
+			t.Errorf("Ignored(%q) = false, want true", path)
+		}
+	}
+	if e.Ignored("src/tmp.go") {
+		t.Error("Ignored(src/tmp.go) = true, want false")
+	}
+}
@@ -0,0 +1,129 @@
+package executor
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// versionManifests are the manifests a version is read from when there is
+// no VERSION file, in order of preference
+var versionManifests = []string{"package.json", "Cargo.toml", "pyproject.toml"}
+
+// tomlVersionSections are the TOML tables whose version field is the
+// project version
+var tomlVersionSections = map[string][]string{
+	"Cargo.toml":     {"package", "workspace.package"},
+	"pyproject.toml": {"project", "tool.poetry"},
+}
+
+var (
+	jsonVersionField = regexp.MustCompile(`"version"\s*:\s*"([^"]*)"`)
+	tomlVersionField = regexp.MustCompile(`^\s*version\s*=\s*["']([^"']*)["']`)
+	tomlSection      = regexp.MustCompile(`^\s*\[([^\[\]]+)\]`)
+)
+
+// manifestVersion returns the version string from the first manifest in the
+// working directory that has one, and that manifest's name
+func (e *Executor) manifestVersion() (string, string, bool) {
+	for _, name := range versionManifests {
+		data, err := os.ReadFile(filepath.Join(e.workDir, name))
+		if err != nil {
+			continue
+		}
+		if start, end, ok := findManifestVersion(name, data); ok {
+			return string(data[start:end]), name, true
+		}
+	}
+	return "", "", false
+}
+
+// findManifestVersion locates the project version value in a manifest,
+// returning its byte offsets so it can be read or replaced in place
+func findManifestVersion(name string, data []byte) (int, int, bool) {
+	if name == "package.json" {
+		return findJSONVersion(data)
+	}
+	return findTOMLVersion(data, tomlVersionSections[name])
+}
+
+// findJSONVersion finds the top-level "version" string of a package.json.
+// Nested objects can have version keys too, so the match must equal the
+// decoded top-level value.
+func findJSONVersion(data []byte) (int, int, bool) {
+	var pkg struct {
+		Version *string `json:"version"`
+	}
+	if err := json.Unmarshal(data, &pkg); err != nil || pkg.Version == nil {
+		return 0, 0, false
+	}
+	for _, m := range jsonVersionField.FindAllSubmatchIndex(data, -1) {
+		if string(data[m[2]:m[3]]) == *pkg.Version {
+			return m[2], m[3], true
+		}
+	}
+	return 0, 0, false
+}
+
+// findTOMLVersion finds version = "..." in one of the given tables
+func findTOMLVersion(data []byte, sections []string) (int, int, bool) {
+	section := ""
+	offset := 0
+	for _, line := range strings.SplitAfter(string(data), "\n") {
+		if m := tomlSection.FindStringSubmatch(line); m != nil {
+			section = strings.TrimSpace(m[1])
+		} else if m := tomlVersionField.FindStringSubmatchIndex(line); m != nil {
+			for _, s := range sections {
+				if section == s {
+					return offset + m[2], offset + m[3], true
+				}
+			}
+		}
+		offset += len(line)
+	}
+	return 0, 0, false
+}
+
+// versionedManifests returns the manifests in the working directory that
+// have a version field
+func (e *Executor) versionedManifests() []string {
+	var names []string
+	for _, name := range versionManifests {
+		data, err := os.ReadFile(filepath.Join(e.workDir, name))
+		if err != nil {
+			continue
+		}
+		if _, _, ok := findManifestVersion(name, data); ok {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// writeManifestVersions replaces the version value in each manifest that
+// has one, leaving the rest of the file byte for byte as it was
+func (e *Executor) writeManifestVersions(v Version) error {
+	for _, name := range versionManifests {
+		path := filepath.Join(e.workDir, name)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		start, end, ok := findManifestVersion(name, data)
+		if !ok || string(data[start:end]) == v.String() {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		updated := append(append(append([]byte{}, data[:start]...), v.String()...), data[end:]...)
+		if err := os.WriteFile(path, updated, info.Mode().Perm()); err != nil {
+			return fmt.Errorf("failed to update %s: %w", name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package executor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Version
+	}{
+		{"1.2.3\n", Version{Major: 1, Minor: 2, Patch: 3}},
+		{"v1.2.3", Version{Major: 1, Minor: 2, Patch: 3}},
+		{"V2.0", Version{Major: 2}},
+		{"7", Version{Major: 7}},
+		{"1.2.3-rc.1", Version{Major: 1, Minor: 2, Patch: 3, Pre: "rc.1"}},
+		{"1.2.3+build.5", Version{Major: 1, Minor: 2, Patch: 3, Build: "build.5"}},
+		{"1.2.3-beta+abc", Version{Major: 1, Minor: 2, Patch: 3, Pre: "beta", Build: "abc"}},
+		{`version = "0.4.1"`, Version{Minor: 4, Patch: 1}},
+		{"no version here", Version{}},
+	}
+	for _, tt := range tests {
+		if got := ParseVersion(tt.in); got != tt.want {
+			t.Errorf("ParseVersion(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestFindManifestVersion(t *testing.T) {
+	tests := []struct {
+		name   string
+		file   string
+		data   string
+		want   string
+		wantOK bool
+	}{
+		{"package.json", "package.json", `{"name": "x", "version": "1.4.0"}`, "1.4.0", true},
+		{"package.json nested first", "package.json",
+			`{"engines": {"node": {"version": "18.0.0"}}, "version": "2.1.0"}`, "2.1.0", true},
+		{"package.json without version", "package.json", `{"name": "x"}`, "", false},
+		{"Cargo.toml", "Cargo.toml",
+			"[package]\nname = \"x\"\nversion = \"0.3.2\"\n\n[dependencies]\nserde = { version = \"1\" }\n", "0.3.2", true},
+		{"Cargo.toml dependency only", "Cargo.toml",
+			"[dependencies.serde]\nversion = \"1.0.0\"\n", "", false},
+		{"Cargo workspace", "Cargo.toml",
+			"[workspace]\nmembers = [\"a\"]\n\n[workspace.package]\nversion = '3.0.0'\n", "3.0.0", true},
+		{"pyproject", "pyproject.toml",
+			"[build-system]\nrequires = [\"hatchling\"]\n\n[project]\nname = \"x\"\nversion = \"1.0.0b1\"\n", "1.0.0b1", true},
+		{"poetry", "pyproject.toml", "[tool.poetry]\nversion = \"0.9.0\"\n", "0.9.0", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end, ok := findManifestVersion(tt.file, []byte(tt.data))
+			if ok != tt.wantOK {
+				t.Fatalf("found = %v, want %v", ok, tt.wantOK)
+			}
+			if ok && tt.data[start:end] != tt.want {
+				t.Errorf("version = %q, want %q", tt.data[start:end], tt.want)
+			}
+		})
+	}
+}
+
+func TestGetVersionFromManifest(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{"VERSION wins", map[string]string{"VERSION": "v1.0.0\n", "package.json": `{"version": "2.0.0"}`}, "1.0.0"},
+		{"package.json before Cargo.toml", map[string]string{"package.json": `{"version": "2.0.0"}`, "Cargo.toml": "[package]\nversion = \"3.0.0\"\n"}, "2.0.0"},
+		{"Cargo.toml", map[string]string{"Cargo.toml": "[package]\nversion = \"3.0.0-alpha\"\n"}, "3.0.0-alpha"},
+		{"pyproject.toml", map[string]string{"pyproject.toml": "[project]\nversion = \"4.1.0\"\n"}, "4.1.0"},
+		{"nothing", nil, "0.1.0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, data := range tt.files {
+				os.WriteFile(filepath.Join(dir, name), []byte(data), 0644)
+			}
+			v, err := New(dir).GetVersion()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if v.String() != tt.want {
+				t.Errorf("GetVersion() = %s, want %s", v, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetVersionSyncsManifests(t *testing.T) {
+	files := map[string]string{
+		"package.json":   "{\n  \"name\": \"x\",\n  \"version\": \"1.2.3\",\n  \"dependencies\": {\"y\": \"^1.2.3\"}\n}\n",
+		"Cargo.toml":     "[package]\nname = \"x\"\nversion = \"1.2.3\" # keep in sync\n\n[dependencies]\nserde = { version = \"1.2.3\" }\n",
+		"pyproject.toml": "[project]\nname = 'x'\nversion = '1.2.3'\n",
+	}
+	want := map[string]string{
+		"package.json":   "{\n  \"name\": \"x\",\n  \"version\": \"1.3.0\",\n  \"dependencies\": {\"y\": \"^1.2.3\"}\n}\n",
+		"Cargo.toml":     "[package]\nname = \"x\"\nversion = \"1.3.0\" # keep in sync\n\n[dependencies]\nserde = { version = \"1.2.3\" }\n",
+		"pyproject.toml": "[project]\nname = 'x'\nversion = '1.3.0'\n",
+	}
+	tests := []struct {
+		sync bool
+		want map[string]string
+	}{
+		{false, files},
+		{true, want},
+	}
+	for _, tt := range tests {
+		dir := t.TempDir()
+		for name, data := range files {
+			os.WriteFile(filepath.Join(dir, name), []byte(data), 0644)
+		}
+		e := New(dir)
+		e.SetSyncManifests(tt.sync)
+		if _, err := e.BumpVersion("minor"); err != nil {
+			t.Fatal(err)
+		}
+		if data, _ := os.ReadFile(filepath.Join(dir, "VERSION")); string(data) != "1.3.0\n" {
+			t.Errorf("sync=%v: VERSION = %q, want 1.3.0", tt.sync, data)
+		}
+		for name, w := range tt.want {
+			if data, _ := os.ReadFile(filepath.Join(dir, name)); string(data) != w {
+				t.Errorf("sync=%v: %s =\n%s\nwant\n%s", tt.sync, name, data, w)
+			}
+		}
+	}
+}
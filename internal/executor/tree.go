@@ -0,0 +1,136 @@
+package executor
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	// DefaultTreeDepth is how many directory levels Tree shows by default
+	DefaultTreeDepth = 3
+	// MaxTreeDepth caps the depth a caller can ask for
+	MaxTreeDepth = 10
+	// maxTreeNodes caps the entries listed so large trees stay readable
+	maxTreeNodes = 500
+)
+
+// treeNode is a directory entry collected by Tree
+type treeNode struct {
+	name     string
+	isDir    bool
+	children []*treeNode
+}
+
+// Tree renders the directory structure under path (relative to the working
+// directory) as an indented tree, down to depth levels. Hidden entries and
+// anything matched by the root .gitignore or .aicliignore are left out, and
+// the listing stops after maxTreeNodes entries.
+func (e *Executor) Tree(path string, depth int) (string, error) {
+	if path == "" {
+		path = "."
+	}
+	if depth <= 0 {
+		depth = DefaultTreeDepth
+	}
+	if depth > MaxTreeDepth {
+		depth = MaxTreeDepth
+	}
+
+	root := path
+	if !filepath.IsAbs(root) {
+		root = filepath.Join(e.workDir, root)
+	}
+	info, err := os.Stat(root)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("%s is not a directory", path)
+	}
+
+	rules := loadIgnoreRules(e.workDir, ".gitignore", IgnoreFile)
+	top := &treeNode{name: path, isDir: true}
+	nodes := map[string]*treeNode{root: top}
+	count, truncated := 0, false
+
+	err = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || p == root {
+			return nil // Unreadable entries are left out
+		}
+		name := d.Name()
+		if strings.HasPrefix(name, ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if rel, relErr := filepath.Rel(e.workDir, p); relErr == nil && rules.Ignored(filepath.ToSlash(rel), d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if count >= maxTreeNodes {
+			truncated = true
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		node := &treeNode{name: name, isDir: d.IsDir()}
+		parent := nodes[filepath.Dir(p)]
+		parent.children = append(parent.children, node)
+		count++
+
+		if d.IsDir() {
+			rel, _ := filepath.Rel(root, p)
+			if strings.Count(filepath.ToSlash(rel), "/")+1 >= depth {
+				return filepath.SkipDir
+			}
+			nodes[p] = node
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	sb.WriteString(strings.TrimSuffix(path, "/") + "/\n")
+	writeTree(&sb, top, "")
+	if truncated {
+		fmt.Fprintf(&sb, "... (truncated after %d entries; use a subdirectory path or a smaller depth)\n", maxTreeNodes)
+	}
+	return sb.String(), nil
+}
+
+// writeTree writes the children of n, directories first, with box-drawing
+// connectors
+func writeTree(sb *strings.Builder, n *treeNode, indent string) {
+	sort.Slice(n.children, func(i, j int) bool {
+		a, b := n.children[i], n.children[j]
+		if a.isDir != b.isDir {
+			return a.isDir
+		}
+		return a.name < b.name
+	})
+	for i, child := range n.children {
+		connector, next := "├── ", "│   "
+		if i == len(n.children)-1 {
+			connector, next = "└── ", "    "
+		}
+		name := child.name
+		if child.isDir {
+			name += "/"
+		}
+		sb.WriteString(indent + connector + name + "\n")
+		if child.isDir {
+			writeTree(sb, child, indent+next)
+		}
+	}
+}
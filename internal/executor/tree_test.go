@@ -0,0 +1,120 @@
+package executor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// treeFixture creates a nested project with hidden and ignored entries
+func treeFixture(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	files := []string{
+		"main.go", "go.mod", "README.md",
+		"cmd/app/main.go", "cmd/app/flags/flags.go",
+		"internal/util/util.go", "internal/util/deep/er/x.go",
+		"build/out.bin", "debug.log",
+		".git/HEAD", ".env",
+	}
+	for _, f := range files {
+		path := filepath.Join(dir, f)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		os.WriteFile(path, []byte("x"), 0644)
+	}
+	os.WriteFile(filepath.Join(dir, ".gitignore"), []byte("build/\n*.log\n"), 0644)
+	return dir
+}
+
+func TestTree(t *testing.T) {
+	dir := treeFixture(t)
+	tests := []struct {
+		name  string
+		path  string
+		depth int
+		want  string
+	}{
+		{"depth 1", "", 1, `./
+├── cmd/
+├── internal/
+├── README.md
+├── go.mod
+└── main.go
+`},
+		{"default depth", ".", 0, `./
+├── cmd/
+│   └── app/
+│       ├── flags/
+│       └── main.go
+├── internal/
+│   └── util/
+│       ├── deep/
+│       └── util.go
+├── README.md
+├── go.mod
+└── main.go
+`},
+		{"subdirectory", "internal", 10, `internal/
+└── util/
+    ├── deep/
+    │   └── er/
+    │       └── x.go
+    └── util.go
+`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := New(dir).Tree(tt.path, tt.depth)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("Tree(%q, %d) =\n%s\nwant\n%s", tt.path, tt.depth, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTreeDepthCapped(t *testing.T) {
+	dir := t.TempDir()
+	deep := filepath.Join(dir, strings.Repeat("d/", MaxTreeDepth+2))
+	os.MkdirAll(deep, 0755)
+
+	got, err := New(dir).Tree(".", 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(got, "d/"); n != MaxTreeDepth {
+		t.Errorf("tree shows %d levels, want %d:\n%s", n, MaxTreeDepth, got)
+	}
+}
+
+func TestTreeNodeCap(t *testing.T) {
+	dir := t.TempDir()
+	for i := 0; i < maxTreeNodes+20; i++ {
+		os.WriteFile(filepath.Join(dir, fmt.Sprintf("f%04d.txt", i)), nil, 0644)
+	}
+
+	got, err := New(dir).Tree("", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(got, ".txt"); n != maxTreeNodes {
+		t.Errorf("tree lists %d files, want %d", n, maxTreeNodes)
+	}
+	if !strings.Contains(got, "truncated after 500 entries") {
+		t.Errorf("tree has no truncation note:\n%s", got[len(got)-200:])
+	}
+}
+
+func TestTreeErrors(t *testing.T) {
+	dir := treeFixture(t)
+	if _, err := New(dir).Tree("missing", 0); err == nil {
+		t.Error("Tree of a missing path succeeded")
+	}
+	if _, err := New(dir).Tree("main.go", 0); err == nil || !strings.Contains(err.Error(), "not a directory") {
+		t.Errorf("Tree of a file = %v, want a not-a-directory error", err)
+	}
+}
@@ -0,0 +1,84 @@
+package lang
+
+import "fmt"
+
+// CommandActions are the project actions with configurable commands
+var CommandActions = []string{"build", "test", "run", "lint"}
+
+// DefaultCommands are the usual build/test/run commands per language, used
+// when the config doesn't say otherwise. Lint defaults come from
+// DefaultLinter, which picks an installed linter.
+var DefaultCommands = map[Language]map[string]string{
+	LangGo: {
+		"build": "go build ./...",
+		"test":  "go test ./...",
+		"run":   "go run .",
+	},
+	LangPython: {
+		"test": "python3 -m pytest",
+	},
+	LangNode: {
+		"build": "npm run build",
+		"test":  "npm test",
+		"run":   "npm start",
+	},
+	LangRust: {
+		"build": "cargo build",
+		"test":  "cargo test",
+		"run":   "cargo run",
+	},
+	LangJava: {
+		"build": "mvn -q compile",
+		"test":  "mvn -q test",
+	},
+	LangKotlin: {
+		"build": "./gradlew build",
+		"test":  "./gradlew test",
+		"run":   "./gradlew run",
+	},
+	LangCSharp: {
+		"build": "dotnet build",
+		"test":  "dotnet test",
+		"run":   "dotnet run",
+	},
+	LangCpp: {
+		"build": "make",
+		"test":  "make test",
+	},
+	LangRuby: {
+		"test": "bundle exec rake test",
+	},
+	LangPHP: {
+		"test": "vendor/bin/phpunit",
+	},
+	LangSwift: {
+		"build": "swift build",
+		"test":  "swift test",
+		"run":   "swift run",
+	},
+}
+
+// ResolveCommand returns the command for action (build, test, run, lint).
+// Precedence: configured[action] (project-wide), then configured
+// "<language>.<action>" for each detected language, then DefaultCommands.
+// The second return value describes where the command came from.
+func ResolveCommand(action string, configured map[string]string, langs []Language) (string, string, error) {
+	if cmd := configured[action]; cmd != "" {
+		return cmd, "config", nil
+	}
+	for _, l := range langs {
+		if cmd := configured[string(l)+"."+action]; cmd != "" {
+			return cmd, fmt.Sprintf("config (%s)", l), nil
+		}
+	}
+	for _, l := range langs {
+		cmd := DefaultCommands[l][action]
+		if action == "lint" {
+			cmd = DefaultLinter(l)
+		}
+		if cmd != "" {
+			return cmd, fmt.Sprintf("%s default", l), nil
+		}
+	}
+	return "", "", fmt.Errorf("no %s command configured; set \"commands\": {\"%s\": \"...\"} in the config", action, action)
+}
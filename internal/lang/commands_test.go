@@ -0,0 +1,58 @@
+package lang
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolveCommand(t *testing.T) {
+	tests := []struct {
+		name       string
+		action     string
+		configured map[string]string
+		langs      []Language
+		want       string
+		wantErr    bool
+	}{
+		{"configured", "test", map[string]string{"test": "make test"}, []Language{LangGo}, "make test", false},
+		{"configured per language", "build", map[string]string{"go.build": "go build -v ./..."}, []Language{LangGo}, "go build -v ./...", false},
+		{"language default", "test", nil, []Language{LangGo}, "go test ./...", false},
+		{"no language", "build", nil, nil, "", true},
+		{"project-wide beats per language", "test", map[string]string{"go.test": "go test -race ./...", "test": "make check"}, []Language{LangGo}, "make check", false},
+		{"per language beats language default", "test", map[string]string{"go.test": "go test -race ./..."}, []Language{LangGo}, "go test -race ./...", false},
+		{"per language beats another language's default", "test", map[string]string{"python.test": "tox"}, []Language{LangGo, LangPython}, "tox", false},
+		{"first language with a default", "build", nil, []Language{LangPython, LangGo}, "go build ./...", false},
+		{"other actions fall through", "run", map[string]string{"test": "make check"}, []Language{LangGo}, "go run .", false},
+		{"empty configured value is ignored", "test", map[string]string{"test": ""}, []Language{LangGo}, "go test ./...", false},
+		{"no default for the action", "run", nil, []Language{LangPython}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, source, err := ResolveCommand(tt.action, tt.configured, tt.langs)
+			if got != tt.want || (err != nil) != tt.wantErr {
+				t.Errorf("ResolveCommand(%q) = %q, %v; want %q, error %v", tt.action, got, err, tt.want, tt.wantErr)
+			}
+			if err == nil && source == "" {
+				t.Errorf("ResolveCommand(%q) gave no source", tt.action)
+			}
+		})
+	}
+}
+
+func TestForgetLanguages(t *testing.T) {
+	dir := t.TempDir()
+	if langs := ProjectLanguages(dir); len(langs) != 0 {
+		t.Fatalf("empty dir detected as %v", langs)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module x\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if langs := ProjectLanguages(dir); len(langs) != 0 {
+		t.Errorf("cached detection = %v, want none until forgotten", langs)
+	}
+	ForgetLanguages(dir)
+	if langs := ProjectLanguages(dir); len(langs) == 0 || langs[0] != LangGo {
+		t.Errorf("after ForgetLanguages = %v, want [go]", langs)
+	}
+}
@@ -3,6 +3,8 @@ package lang
 import (
 	"os"
 	"path/filepath"
+	"sort"
+	"sync"
 )
 
 // Language represents a detected programming language
@@ -95,5 +97,39 @@ func DetectMultipleLanguages(dir string) []Language {
 		}
 	}
 
+	// Sorted so the system prompt built from these is the same every run
+	sort.Slice(langs, func(i, j int) bool { return langs[i] < langs[j] })
 	return langs
 }
+
+var (
+	detectMu    sync.Mutex
+	detectCache = make(map[string][]Language)
+)
+
+// ProjectLanguages returns the languages detected in dir, detecting them
+// once per directory and reusing the result afterwards. Use
+// ForgetLanguages when the directory may have changed.
+func ProjectLanguages(dir string) []Language {
+	dir = filepath.Clean(dir)
+	detectMu.Lock()
+	defer detectMu.Unlock()
+	if langs, ok := detectCache[dir]; ok {
+		return langs
+	}
+	langs := DetectMultipleLanguages(dir)
+	detectCache[dir] = langs
+	return langs
+}
+
+// ForgetLanguages drops the cached detection for dir, so the next
+// ProjectLanguages call detects again. An empty dir clears the whole cache.
+func ForgetLanguages(dir string) {
+	detectMu.Lock()
+	defer detectMu.Unlock()
+	if dir == "" {
+		detectCache = make(map[string][]Language)
+		return
+	}
+	delete(detectCache, filepath.Clean(dir))
+}
@@ -0,0 +1,58 @@
+package lang
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDetectMultipleLanguages(t *testing.T) {
+	dir := t.TempDir()
+	for _, f := range []string{"package.json", "go.mod", "go.sum", "pyproject.toml", "app.csproj"} {
+		os.WriteFile(filepath.Join(dir, f), nil, 0644)
+	}
+	want := []Language{LangCSharp, LangGo, LangNode, LangPython}
+	// Map iteration order varies, so check the order holds across calls
+	for i := 0; i < 20; i++ {
+		if got := DetectMultipleLanguages(dir); !reflect.DeepEqual(got, want) {
+			t.Fatalf("DetectMultipleLanguages = %v, want %v", got, want)
+		}
+	}
+	if got := DetectMultipleLanguages(t.TempDir()); len(got) != 0 {
+		t.Errorf("empty directory detected %v", got)
+	}
+}
+
+func TestProjectLanguagesCache(t *testing.T) {
+	a, b := t.TempDir(), t.TempDir()
+	os.WriteFile(filepath.Join(a, "go.mod"), nil, 0644)
+	if got := ProjectLanguages(a); !reflect.DeepEqual(got, []Language{LangGo}) {
+		t.Fatalf("ProjectLanguages = %v, want [go]", got)
+	}
+	ProjectLanguages(b)
+
+	// Later calls, under any spelling of the directory, reuse the detection
+	os.Remove(filepath.Join(a, "go.mod"))
+	os.WriteFile(filepath.Join(b, "Cargo.toml"), nil, 0644)
+	for _, dir := range []string{a, a + "/", filepath.Join(a, "sub", "..")} {
+		if got := ProjectLanguages(dir); !reflect.DeepEqual(got, []Language{LangGo}) {
+			t.Errorf("ProjectLanguages(%q) = %v, want the cached [go]", dir, got)
+		}
+	}
+
+	// Forgetting one directory leaves the others cached
+	ForgetLanguages(a + "/")
+	if got := ProjectLanguages(a); len(got) != 0 {
+		t.Errorf("after ForgetLanguages(a) = %v, want none", got)
+	}
+	if got := ProjectLanguages(b); len(got) != 0 {
+		t.Errorf("ProjectLanguages(b) = %v, want the cached empty detection", got)
+	}
+
+	// An empty dir forgets everything
+	ForgetLanguages("")
+	if got := ProjectLanguages(b); !reflect.DeepEqual(got, []Language{LangRust}) {
+		t.Errorf("after ForgetLanguages(\"\") = %v, want [rust]", got)
+	}
+}
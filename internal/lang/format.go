@@ -0,0 +1,42 @@
+package lang
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// formatters maps file extensions to the formatter that rewrites a file in
+// place; the file path is appended to the arguments
+var formatters = map[string][]string{
+	".go":   {"gofmt", "-w"},
+	".py":   {"black", "-q"},
+	".rs":   {"rustfmt"},
+	".js":   {"prettier", "--write", "--log-level", "warn"},
+	".jsx":  {"prettier", "--write", "--log-level", "warn"},
+	".ts":   {"prettier", "--write", "--log-level", "warn"},
+	".tsx":  {"prettier", "--write", "--log-level", "warn"},
+	".css":  {"prettier", "--write", "--log-level", "warn"},
+	".scss": {"prettier", "--write", "--log-level", "warn"},
+	".html": {"prettier", "--write", "--log-level", "warn"},
+	".json": {"prettier", "--write", "--log-level", "warn"},
+	".yaml": {"prettier", "--write", "--log-level", "warn"},
+	".yml":  {"prettier", "--write", "--log-level", "warn"},
+	".c":    {"clang-format", "-i"},
+	".h":    {"clang-format", "-i"},
+	".cpp":  {"clang-format", "-i"},
+	".hpp":  {"clang-format", "-i"},
+}
+
+// FormatterFor returns the formatter command (name and arguments, without
+// the file) for path, or nil if there is none for the file type or it
+// isn't installed
+func FormatterFor(path string) []string {
+	cmd, ok := formatters[strings.ToLower(filepath.Ext(path))]
+	if !ok {
+		return nil
+	}
+	if _, err := lookPath(cmd[0]); err != nil {
+		return nil
+	}
+	return cmd
+}
@@ -0,0 +1,82 @@
+package lang
+
+import (
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// lookPath is exec.LookPath, replaceable for tests
+var lookPath = exec.LookPath
+
+// linters lists the linters to try per language, preferred first. The first
+// one whose binary is installed wins; the last entry is the fallback.
+var linters = map[Language][]struct {
+	binary  string
+	command string
+}{
+	LangGo:     {{"golangci-lint", "golangci-lint run ./..."}, {"go", "go vet ./..."}},
+	LangPython: {{"ruff", "ruff check ."}, {"flake8", "flake8 ."}, {"python3", "python3 -m ruff check ."}},
+	LangNode:   {{"eslint", "eslint ."}, {"npx", "npx eslint ."}},
+	LangRust:   {{"cargo", "cargo clippy"}},
+	LangRuby:   {{"rubocop", "rubocop"}, {"bundle", "bundle exec rubocop"}},
+	LangSwift:  {{"swiftlint", "swiftlint"}},
+	LangKotlin: {{"ktlint", "ktlint"}},
+}
+
+// DefaultLinter returns the linter command for a language: the first
+// installed one from the preference list, else the last (fallback) entry.
+// Returns "" if the language has no known linter.
+func DefaultLinter(l Language) string {
+	candidates := linters[l]
+	if len(candidates) == 0 {
+		return ""
+	}
+	for _, c := range candidates {
+		if _, err := lookPath(c.binary); err == nil {
+			return c.command
+		}
+	}
+	return candidates[len(candidates)-1].command
+}
+
+// LintIssue is one finding parsed from linter output
+type LintIssue struct {
+	File    string
+	Line    int
+	Column  int
+	Message string
+}
+
+func (i LintIssue) String() string {
+	if i.Column > 0 {
+		return fmt.Sprintf("%s:%d:%d: %s", i.File, i.Line, i.Column, i.Message)
+	}
+	return fmt.Sprintf("%s:%d: %s", i.File, i.Line, i.Message)
+}
+
+// lintLineRegex matches the common "file:line[:col]: message" format used
+// by go vet, golangci-lint, ruff, flake8, eslint (unix format) and clippy's
+// "--> file:line:col" location lines
+var lintLineRegex = regexp.MustCompile(`^\s*(?:--> )?([^\s:][^:]*\.[A-Za-z0-9]+):(\d+)(?::(\d+))?:?\s*(.*)$`)
+
+// ParseLintIssues extracts file:line findings from linter output. Lines
+// that don't look like findings (summaries, code excerpts) are skipped.
+func ParseLintIssues(output string) []LintIssue {
+	var issues []LintIssue
+	for _, line := range strings.Split(output, "\n") {
+		m := lintLineRegex.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		issue := LintIssue{File: m[1], Message: strings.TrimSpace(m[4])}
+		issue.Line, _ = strconv.Atoi(m[2])
+		if m[3] != "" {
+			issue.Column, _ = strconv.Atoi(m[3])
+		}
+		issues = append(issues, issue)
+	}
+	return issues
+}
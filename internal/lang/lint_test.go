@@ -0,0 +1,81 @@
+package lang
+
+import (
+	"os/exec"
+	"testing"
+)
+
+func TestDefaultLinter(t *testing.T) {
+	tests := []struct {
+		lang      Language
+		installed []string
+		want      string
+	}{
+		{LangGo, []string{"go", "golangci-lint"}, "golangci-lint run ./..."},
+		{LangGo, []string{"go"}, "go vet ./..."},
+		{LangPython, []string{"ruff", "flake8"}, "ruff check ."},
+		{LangPython, []string{"flake8"}, "flake8 ."},
+		{LangPython, nil, "python3 -m ruff check ."},
+		{LangNode, []string{"eslint"}, "eslint ."},
+		{LangNode, nil, "npx eslint ."},
+		{LangRust, nil, "cargo clippy"},
+		{LangRuby, []string{"bundle"}, "bundle exec rubocop"},
+		{LangJava, []string{"java"}, ""},
+	}
+	defer func(orig func(string) (string, error)) { lookPath = orig }(lookPath)
+	for _, tt := range tests {
+		lookPath = func(binary string) (string, error) {
+			for _, b := range tt.installed {
+				if b == binary {
+					return "/usr/bin/" + b, nil
+				}
+			}
+			return "", exec.ErrNotFound
+		}
+		if got := DefaultLinter(tt.lang); got != tt.want {
+			t.Errorf("DefaultLinter(%s) with %v installed = %q, want %q", tt.lang, tt.installed, got, tt.want)
+		}
+	}
+}
+
+func TestParseLintIssues(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   []LintIssue
+	}{
+		{"go vet", "# example.com/app\n./main.go:12:2: fmt.Printf format %d has arg s of wrong type string\n",
+			[]LintIssue{{"./main.go", 12, 2, "fmt.Printf format %d has arg s of wrong type string"}}},
+		{"ruff", "app/models.py:3:8: F401 [*] `os` imported but unused\nFound 1 error.\n[*] 1 fixable with the `--fix` option.\n",
+			[]LintIssue{{"app/models.py", 3, 8, "F401 [*] `os` imported but unused"}}},
+		{"eslint unix format", "/src/app/index.js:7:5: 'x' is assigned a value but never used. [Error/no-unused-vars]\n\n1 problem\n",
+			[]LintIssue{{"/src/app/index.js", 7, 5, "'x' is assigned a value but never used. [Error/no-unused-vars]"}}},
+		{"clippy location line", "warning: unused variable: `y`\n --> src/main.rs:4:9\n  |\n4 |     let y = 5;\n",
+			[]LintIssue{{"src/main.rs", 4, 9, ""}}},
+		{"line without column", "lib/util.rb:10: warning: mismatched indentations\n",
+			[]LintIssue{{"lib/util.rb", 10, 0, "warning: mismatched indentations"}}},
+		{"no findings", "All checks passed!\n", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issues := ParseLintIssues(tt.output)
+			if len(issues) != len(tt.want) {
+				t.Fatalf("parsed %v, want %v", issues, tt.want)
+			}
+			for i := range issues {
+				if issues[i] != tt.want[i] {
+					t.Errorf("issue %d = %+v, want %+v", i, issues[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestLintIssueString(t *testing.T) {
+	if got := (LintIssue{"main.go", 3, 7, "unused"}).String(); got != "main.go:3:7: unused" {
+		t.Errorf("with column = %q", got)
+	}
+	if got := (LintIssue{"main.go", 3, 0, "unused"}).String(); got != "main.go:3: unused" {
+		t.Errorf("without column = %q", got)
+	}
+}
@@ -0,0 +1,61 @@
+package lang
+
+import (
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var nameInvalidChars = regexp.MustCompile(`[^a-z0-9._-]+`)
+
+// ProjectName derives a package name from the project directory, in the
+// form npm and Python tooling accept ("My Project" -> "my-project")
+func ProjectName(dir string) string {
+	name := strings.ToLower(filepath.Base(filepath.Clean(dir)))
+	name = nameInvalidChars.ReplaceAllString(name, "-")
+	name = strings.Trim(name, "-._")
+	if name == "" || name == "/" {
+		return "myproject"
+	}
+	return name
+}
+
+// GoModuleName infers a Go module path: the git remote's host and path
+// (git@github.com:user/repo.git -> github.com/user/repo), or else the
+// project directory name
+func GoModuleName(dir, remoteURL string) string {
+	if module := moduleFromRemote(remoteURL); module != "" {
+		return module
+	}
+	return ProjectName(dir)
+}
+
+// moduleFromRemote converts a git remote URL to host/path, or ""
+func moduleFromRemote(remote string) string {
+	remote = strings.TrimSuffix(strings.TrimSpace(remote), ".git")
+	if remote == "" {
+		return ""
+	}
+
+	// scp-like syntax: git@host:user/repo
+	if !strings.Contains(remote, "://") {
+		at := strings.Index(remote, "@")
+		colon := strings.Index(remote, ":")
+		if colon < 0 || colon < at {
+			return ""
+		}
+		host := remote[at+1 : colon]
+		path := strings.Trim(remote[colon+1:], "/")
+		if host == "" || path == "" {
+			return ""
+		}
+		return strings.ToLower(host) + "/" + path
+	}
+
+	u, err := url.Parse(remote)
+	if err != nil || u.Host == "" || strings.Trim(u.Path, "/") == "" || u.Scheme == "file" {
+		return ""
+	}
+	return strings.ToLower(u.Hostname()) + "/" + strings.Trim(u.Path, "/")
+}
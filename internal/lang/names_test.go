@@ -0,0 +1,43 @@
+package lang
+
+import "testing"
+
+func TestProjectName(t *testing.T) {
+	tests := []struct {
+		dir, want string
+	}{
+		{"/home/me/widget", "widget"},
+		{"/home/me/My Project/", "my-project"},
+		{"/work/Data_Tools.v2", "data_tools.v2"},
+		{"/work/--weird!!name--", "weird-name"},
+		{"/work/(((", "myproject"},
+		{"/", "myproject"},
+	}
+	for _, tt := range tests {
+		if got := ProjectName(tt.dir); got != tt.want {
+			t.Errorf("ProjectName(%q) = %q, want %q", tt.dir, got, tt.want)
+		}
+	}
+}
+
+func TestGoModuleName(t *testing.T) {
+	tests := []struct {
+		name, dir, remote, want string
+	}{
+		{"scp-like remote", "/src/x", "git@github.com:glenn/aicli.git", "github.com/glenn/aicli"},
+		{"https remote", "/src/x", "https://GitHub.com/glenn/aicli.git\n", "github.com/glenn/aicli"},
+		{"ssh URL with port", "/src/x", "ssh://git@git.example.com:2222/team/tool.git", "git.example.com/team/tool"},
+		{"nested path", "/src/x", "https://gitlab.com/group/sub/repo", "gitlab.com/group/sub/repo"},
+		{"no remote uses the directory", "/src/My Tool", "", "my-tool"},
+		{"file remote uses the directory", "/src/tool", "file:///srv/git/tool.git", "tool"},
+		{"local path remote uses the directory", "/src/tool", "/srv/git/tool.git", "tool"},
+		{"remote without a path uses the directory", "/src/tool", "https://example.com/", "tool"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := GoModuleName(tt.dir, tt.remote); got != tt.want {
+				t.Errorf("GoModuleName(%q, %q) = %q, want %q", tt.dir, tt.remote, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+// Package logging writes a leveled, timestamped event log to a file
+// (log_file in the config). Until Open is called every call is a no-op, so
+// packages can log unconditionally and stdout stays clean.
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+var (
+	mu     sync.RWMutex
+	logger = slog.New(slog.DiscardHandler)
+)
+
+// ParseLevel converts a level name (debug, info, warn, error) to a
+// slog.Level. Empty or unknown names are info.
+func ParseLevel(name string) slog.Level {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	}
+	return slog.LevelInfo
+}
+
+// Open starts logging to path (appending) at the given level and returns a
+// function that stops logging and closes the file
+func Open(path, level string) (func(), error) {
+	if strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = filepath.Join(home, path[2:])
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create log directory: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open log file: %w", err)
+	}
+
+	SetOutput(f, ParseLevel(level))
+	return func() {
+		SetOutput(nil, slog.LevelInfo)
+		f.Close()
+	}, nil
+}
+
+// SetOutput sends log records at or above level to w, or discards them if
+// w is nil
+func SetOutput(w io.Writer, level slog.Level) {
+	mu.Lock()
+	defer mu.Unlock()
+	if w == nil {
+		logger = slog.New(slog.DiscardHandler)
+		return
+	}
+	logger = slog.New(slog.NewTextHandler(w, &slog.HandlerOptions{Level: level}))
+}
+
+func current() *slog.Logger {
+	mu.RLock()
+	defer mu.RUnlock()
+	return logger
+}
+
+// Debug logs a debug event with key/value attributes
+func Debug(msg string, args ...any) {
+	current().Debug(msg, args...)
+}
+
+// Info logs an event with key/value attributes
+func Info(msg string, args ...any) {
+	current().Info(msg, args...)
+}
+
+// Warn logs a warning with key/value attributes
+func Warn(msg string, args ...any) {
+	current().Warn(msg, args...)
+}
+
+// Error logs an error with key/value attributes
+func Error(msg string, args ...any) {
+	current().Error(msg, args...)
+}
@@ -0,0 +1,82 @@
+package logging
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseLevel(t *testing.T) {
+	tests := []struct {
+		name string
+		want slog.Level
+	}{
+		{"debug", slog.LevelDebug},
+		{" DEBUG ", slog.LevelDebug},
+		{"info", slog.LevelInfo},
+		{"warn", slog.LevelWarn},
+		{"warning", slog.LevelWarn},
+		{"error", slog.LevelError},
+		{"", slog.LevelInfo},
+		{"verbose", slog.LevelInfo},
+	}
+	for _, tt := range tests {
+		if got := ParseLevel(tt.name); got != tt.want {
+			t.Errorf("ParseLevel(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestOpenWritesEvents(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "aicli.log")
+	os.MkdirAll(filepath.Dir(path), 0755)
+	os.WriteFile(path, []byte("earlier run\n"), 0644)
+
+	Info("before open") // Discarded
+	closeLog, err := Open(path, "warn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	Debug("too detailed")
+	Info("request sent", "request", 1)
+	Warn("tool failed", "tool", "run_command", "result", "COMMAND FAILED (exit 1)")
+	Error("api error", "status", 500)
+	closeLog()
+	Error("after close") // Discarded
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 3 || lines[0] != "earlier run" {
+		t.Fatalf("log = %q, want the earlier content and two records", data)
+	}
+	for i, want := range []string{
+		`level=WARN msg="tool failed" tool=run_command result="COMMAND FAILED (exit 1)"`,
+		`level=ERROR msg="api error" status=500`,
+	} {
+		line := lines[i+1]
+		if !strings.HasPrefix(line, "time=") || !strings.HasSuffix(line, want) {
+			t.Errorf("record %d = %q, want a timestamp and %q", i+1, line, want)
+		}
+	}
+}
+
+func TestOpenExpandsHome(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	closeLog, err := Open("~/.aicli/aicli.log", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	Info("aicli started")
+	closeLog()
+
+	data, err := os.ReadFile(filepath.Join(home, ".aicli", "aicli.log"))
+	if err != nil || !strings.Contains(string(data), `level=INFO msg="aicli started"`) {
+		t.Errorf("log under home = %q, %v", data, err)
+	}
+}
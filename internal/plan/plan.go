@@ -7,6 +7,8 @@ import (
 	"path/filepath"
 	"strings"
 	"time"
+
+	"aicli/internal/session"
 )
 
 // ModelTier represents the complexity level for a task
@@ -27,6 +29,7 @@ type Step struct {
 	ModelTier   ModelTier  `json:"model_tier"`
 	Files       []string   `json:"files,omitempty"`
 	Result      string     `json:"result,omitempty"`
+	EscalatedTo ModelTier  `json:"escalated_to,omitempty"` // Tier the step was retried on after failing
 	StartedAt   *time.Time `json:"started_at,omitempty"`
 	CompletedAt *time.Time `json:"completed_at,omitempty"`
 }
@@ -76,6 +79,76 @@ func (p *Plan) AddStep(title, description string, tier ModelTier, files []string
 	p.UpdatedAt = time.Now()
 }
 
+// ParseTier converts a tier name to a ModelTier. Unknown names return
+// TierStandard and false.
+func ParseTier(name string) (ModelTier, bool) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "premium":
+		return TierPremium, true
+	case "standard":
+		return TierStandard, true
+	case "economy":
+		return TierEconomy, true
+	}
+	return TierStandard, false
+}
+
+// RemoveStep deletes a step and renumbers the remaining steps
+func (p *Plan) RemoveStep(id int) error {
+	idx := p.stepIndex(id)
+	if idx < 0 {
+		return fmt.Errorf("no step %d", id)
+	}
+	p.Steps = append(p.Steps[:idx], p.Steps[idx+1:]...)
+	p.renumber()
+	return nil
+}
+
+// SetTier changes the model tier of a step
+func (p *Plan) SetTier(id int, tier ModelTier) error {
+	idx := p.stepIndex(id)
+	if idx < 0 {
+		return fmt.Errorf("no step %d", id)
+	}
+	p.Steps[idx].ModelTier = tier
+	p.UpdatedAt = time.Now()
+	return nil
+}
+
+// MoveStep moves a step to a new 1-based position and renumbers all steps
+func (p *Plan) MoveStep(id, pos int) error {
+	idx := p.stepIndex(id)
+	if idx < 0 {
+		return fmt.Errorf("no step %d", id)
+	}
+	if pos < 1 || pos > len(p.Steps) {
+		return fmt.Errorf("position %d out of range (1-%d)", pos, len(p.Steps))
+	}
+	step := p.Steps[idx]
+	p.Steps = append(p.Steps[:idx], p.Steps[idx+1:]...)
+	p.Steps = append(p.Steps[:pos-1], append([]Step{step}, p.Steps[pos-1:]...)...)
+	p.renumber()
+	return nil
+}
+
+// stepIndex returns the slice index of the step with the given ID, or -1
+func (p *Plan) stepIndex(id int) int {
+	for i := range p.Steps {
+		if p.Steps[i].ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// renumber assigns sequential IDs (1..n) to steps in their current order
+func (p *Plan) renumber() {
+	for i := range p.Steps {
+		p.Steps[i].ID = i + 1
+	}
+	p.UpdatedAt = time.Now()
+}
+
 // NextPending returns the first pending step, or nil if none
 func (p *Plan) NextPending() *Step {
 	for i := range p.Steps {
@@ -137,6 +210,43 @@ func (p *Plan) MarkFailed(id int, result string) {
 	}
 }
 
+// ResetInterrupted returns steps left in_progress by an interrupted run to
+// pending so they are picked up again. Returns the number of steps reset.
+func (p *Plan) ResetInterrupted() int {
+	count := 0
+	for i := range p.Steps {
+		if p.Steps[i].Status == "in_progress" {
+			p.Steps[i].Status = "pending"
+			p.Steps[i].StartedAt = nil
+			count++
+		}
+	}
+	if count > 0 {
+		p.UpdatedAt = time.Now()
+	}
+	return count
+}
+
+// SetEscalation records that a step was retried on a higher tier
+func (p *Plan) SetEscalation(id int, tier ModelTier) {
+	if step := p.GetStep(id); step != nil {
+		step.EscalatedTo = tier
+		p.UpdatedAt = time.Now()
+	}
+}
+
+// NextTier returns the tier above t, or "" if t is already the highest
+func NextTier(t ModelTier) ModelTier {
+	switch t {
+	case TierEconomy:
+		return TierStandard
+	case TierPremium:
+		return ""
+	default:
+		return TierPremium
+	}
+}
+
 // Progress returns plan completion stats
 func (p *Plan) Progress() (total, completed, failed, inProgress, pending int) {
 	total = len(p.Steps)
@@ -155,6 +265,38 @@ func (p *Plan) Progress() (total, completed, failed, inProgress, pending int) {
 	return
 }
 
+// ProgressLine returns a compact progress indicator for the step being
+// executed, e.g. "[2/7] Implementing parser...". With no current step it
+// summarizes completion, e.g. "[5/7 done]".
+func (p *Plan) ProgressLine(current *Step) string {
+	total, completed, _, _, _ := p.Progress()
+	if current == nil {
+		return fmt.Sprintf("[%d/%d done]", completed, total)
+	}
+	pos := p.stepIndex(current.ID) + 1
+	if pos == 0 {
+		pos = completed + 1
+	}
+	return fmt.Sprintf("[%d/%d] %s...", pos, total, current.Title)
+}
+
+// Summary returns a compact plain-text view of the plan: the goal,
+// overall progress and one line per step with its status
+func (p *Plan) Summary() string {
+	var sb strings.Builder
+	total, completed, failed, _, _ := p.Progress()
+	sb.WriteString(fmt.Sprintf("Goal: %s\n", p.Goal))
+	sb.WriteString(fmt.Sprintf("Progress: %d/%d completed", completed, total))
+	if failed > 0 {
+		sb.WriteString(fmt.Sprintf(", %d failed", failed))
+	}
+	sb.WriteString("\n")
+	for _, step := range p.Steps {
+		sb.WriteString(fmt.Sprintf("%d. [%s] %s\n", step.ID, step.Status, step.Title))
+	}
+	return sb.String()
+}
+
 // IsComplete returns true if all steps are completed or failed
 func (p *Plan) IsComplete() bool {
 	for _, s := range p.Steps {
@@ -175,13 +317,13 @@ func (p *Plan) Save(workDir string) error {
 	if err != nil {
 		return fmt.Errorf("marshal plan: %w", err)
 	}
-	if err := os.WriteFile(jsonPath, data, 0644); err != nil {
+	if err := session.WriteFileAtomic(jsonPath, data, 0644); err != nil {
 		return fmt.Errorf("write plan.json: %w", err)
 	}
 
 	// Save human-readable markdown
 	mdPath := filepath.Join(workDir, "plan.md")
-	if err := os.WriteFile(mdPath, []byte(p.RenderMarkdown()), 0644); err != nil {
+	if err := session.WriteFileAtomic(mdPath, []byte(p.RenderMarkdown()), 0644); err != nil {
 		return fmt.Errorf("write plan.md: %w", err)
 	}
 
@@ -259,6 +401,9 @@ func (p *Plan) RenderMarkdown() string {
 		sb.WriteString(fmt.Sprintf("### %s Step %d: %s\n\n", statusIcon, step.ID, step.Title))
 		sb.WriteString(fmt.Sprintf("- **Status**: %s\n", step.Status))
 		sb.WriteString(fmt.Sprintf("- **Model Tier**: %s\n", tierLabel))
+		if step.EscalatedTo != "" {
+			sb.WriteString(fmt.Sprintf("- **Escalated To**: %s\n", strings.ToUpper(string(step.EscalatedTo))))
+		}
 		if len(step.Files) > 0 {
 			sb.WriteString(fmt.Sprintf("- **Files**: %s\n", strings.Join(step.Files, ", ")))
 		}
@@ -332,19 +477,91 @@ func BuildFromResponse(goal string, resp *PlanResponse) *Plan {
 	p := New(goal, resp.Analysis)
 
 	for _, s := range resp.Steps {
-		tier := TierStandard
-		switch strings.ToLower(s.ModelTier) {
-		case "premium":
-			tier = TierPremium
-		case "economy":
-			tier = TierEconomy
-		}
+		tier, _ := ParseTier(s.ModelTier)
 		p.AddStep(s.Title, s.Description, tier, s.Files)
 	}
 
 	return p
 }
 
+// Regenerate replaces the unfinished part of the plan with the steps of a
+// regenerated plan. Completed steps (with their results and timestamps) are
+// kept first, in order; pending, failed and in-progress steps are dropped in
+// favor of the new steps, which are numbered after them.
+func (p *Plan) Regenerate(resp *PlanResponse) {
+	var kept []Step
+	for _, s := range p.Steps {
+		if s.Status == "completed" {
+			kept = append(kept, s)
+		}
+	}
+	p.Steps = kept
+	p.renumber()
+
+	if resp.Analysis != "" {
+		p.Analysis = resp.Analysis
+	}
+	for _, s := range resp.Steps {
+		tier, _ := ParseTier(s.ModelTier)
+		p.AddStep(s.Title, s.Description, tier, s.Files)
+	}
+}
+
+// BuildRegenPrompt constructs the prompt asking the planning model to
+// replan what's left of p given the project's current state
+func BuildRegenPrompt(p *Plan, fileList, fileContents string) string {
+	var sb strings.Builder
+
+	sb.WriteString("## Project Structure (current)\n\n")
+	sb.WriteString("```\n")
+	sb.WriteString(fileList)
+	sb.WriteString("\n```\n\n")
+
+	if fileContents != "" {
+		sb.WriteString("## Key Files (current)\n\n")
+		sb.WriteString(fileContents)
+		sb.WriteString("\n\n")
+	}
+
+	sb.WriteString("## Goal\n\n")
+	sb.WriteString(p.Goal)
+	sb.WriteString("\n\n")
+
+	sb.WriteString("## Original Analysis\n\n")
+	sb.WriteString(p.Analysis)
+	sb.WriteString("\n\n")
+
+	var done, left strings.Builder
+	for _, s := range p.Steps {
+		if s.Status == "completed" {
+			fmt.Fprintf(&done, "- %s", s.Title)
+			if s.Result != "" {
+				fmt.Fprintf(&done, " (result: %s)", s.Result)
+			}
+			done.WriteString("\n")
+			continue
+		}
+		fmt.Fprintf(&left, "- [%s] %s: %s\n", s.Status, s.Title, s.Description)
+		if s.Status == "failed" && s.Result != "" {
+			fmt.Fprintf(&left, "  Failed with: %s\n", s.Result)
+		}
+	}
+	if done.Len() > 0 {
+		sb.WriteString("## Completed Steps (already done - do not repeat)\n\n")
+		sb.WriteString(done.String())
+		sb.WriteString("\n")
+	}
+	if left.Len() > 0 {
+		sb.WriteString("## Remaining Steps (from the original plan - may be out of date)\n\n")
+		sb.WriteString(left.String())
+		sb.WriteString("\n")
+	}
+
+	sb.WriteString("The project has drifted from the original analysis. Compare the remaining steps with the current project state and create an updated plan as JSON covering ONLY the work still needed to reach the goal.")
+
+	return sb.String()
+}
+
 // GetPlanningSystemPrompt returns the system prompt for the planning model
 func GetPlanningSystemPrompt() string {
 	return `You are a senior software architect. Your job is to analyze a project and create a concrete implementation plan.
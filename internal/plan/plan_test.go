@@ -0,0 +1,279 @@
+package plan
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testPlan returns a plan with one step per status, in order
+func testPlan(statuses ...string) *Plan {
+	p := New("goal", "analysis")
+	for i, status := range statuses {
+		p.AddStep(string(rune('A'+i)), "", TierStandard, nil)
+		p.Steps[i].Status = status
+	}
+	return p
+}
+
+func TestResumeFromSavedPlan(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []string
+		reset    int
+		wantStep int // 0 means no pending step
+		complete bool
+	}{
+		{"interrupted step reruns", []string{"completed", "in_progress", "pending"}, 1, 2, false},
+		{"first pending after completed", []string{"completed", "completed", "pending", "pending"}, 0, 3, false},
+		{"failed steps are skipped", []string{"completed", "failed", "pending"}, 0, 3, false},
+		{"already complete", []string{"completed", "failed"}, 0, 0, true},
+		{"only failures left", []string{"failed"}, 0, 0, true},
+	}
+	for _, tt := range tests {
+		dir := t.TempDir()
+		if err := testPlan(tt.statuses...).Save(dir); err != nil {
+			t.Fatal(err)
+		}
+		p, err := Load(dir)
+		if err != nil {
+			t.Fatalf("%s: Load: %v", tt.name, err)
+		}
+		if got := p.ResetInterrupted(); got != tt.reset {
+			t.Errorf("%s: ResetInterrupted() = %d, want %d", tt.name, got, tt.reset)
+		}
+		step := p.NextPending()
+		switch {
+		case tt.wantStep == 0 && step != nil:
+			t.Errorf("%s: NextPending() = step %d, want none", tt.name, step.ID)
+		case tt.wantStep != 0 && (step == nil || step.ID != tt.wantStep):
+			t.Errorf("%s: NextPending() = %v, want step %d", tt.name, step, tt.wantStep)
+		}
+		if got := p.IsComplete(); got != tt.complete {
+			t.Errorf("%s: IsComplete() = %v, want %v", tt.name, got, tt.complete)
+		}
+	}
+}
+
+func TestResetInterruptedClearsStart(t *testing.T) {
+	p := testPlan("pending")
+	p.MarkInProgress(1)
+	if p.ResetInterrupted() != 1 || p.Steps[0].Status != "pending" || p.Steps[0].StartedAt != nil {
+		t.Errorf("step after reset = %+v, want pending with no start time", p.Steps[0])
+	}
+	if p.ResetInterrupted() != 0 {
+		t.Error("second ResetInterrupted() reset steps again")
+	}
+}
+
+// titles lists step titles in order, checking IDs run 1..n
+func titles(t *testing.T, p *Plan) string {
+	t.Helper()
+	var s string
+	for i, step := range p.Steps {
+		if step.ID != i+1 {
+			t.Errorf("step %q has ID %d at position %d", step.Title, step.ID, i+1)
+		}
+		s += step.Title
+	}
+	return s
+}
+
+func TestEditSteps(t *testing.T) {
+	tests := []struct {
+		name    string
+		edit    func(p *Plan) error
+		want    string
+		wantErr bool
+	}{
+		{"remove first", func(p *Plan) error { return p.RemoveStep(1) }, "BCD", false},
+		{"remove last", func(p *Plan) error { return p.RemoveStep(4) }, "ABC", false},
+		{"remove missing", func(p *Plan) error { return p.RemoveStep(9) }, "ABCD", true},
+		{"move to front", func(p *Plan) error { return p.MoveStep(3, 1) }, "CABD", false},
+		{"move to end", func(p *Plan) error { return p.MoveStep(1, 4) }, "BCDA", false},
+		{"move in place", func(p *Plan) error { return p.MoveStep(2, 2) }, "ABCD", false},
+		{"move out of range", func(p *Plan) error { return p.MoveStep(1, 5) }, "ABCD", true},
+		{"move to zero", func(p *Plan) error { return p.MoveStep(1, 0) }, "ABCD", true},
+		{"move missing", func(p *Plan) error { return p.MoveStep(7, 1) }, "ABCD", true},
+		{"set tier", func(p *Plan) error { return p.SetTier(2, TierPremium) }, "ABCD", false},
+		{"set tier missing", func(p *Plan) error { return p.SetTier(0, TierPremium) }, "ABCD", true},
+	}
+	for _, tt := range tests {
+		p := testPlan("completed", "pending", "pending", "pending")
+		err := tt.edit(p)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+		if got := titles(t, p); got != tt.want {
+			t.Errorf("%s: steps = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestEditKeepsStepState(t *testing.T) {
+	p := testPlan("completed", "pending", "pending")
+	p.Steps[0].Result = "done"
+	if err := p.SetTier(3, TierEconomy); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.MoveStep(1, 3); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.RemoveStep(1); err != nil {
+		t.Fatal(err)
+	}
+	// Steps were B, C, A after the move; removing B leaves C then A
+	c, a := p.Steps[0], p.Steps[1]
+	if c.Title != "C" || c.ModelTier != TierEconomy || c.Status != "pending" {
+		t.Errorf("step 1 = %+v, want C on economy, pending", c)
+	}
+	if a.Title != "A" || a.Status != "completed" || a.Result != "done" {
+		t.Errorf("step 2 = %+v, want A completed with its result", a)
+	}
+}
+
+func TestParseTier(t *testing.T) {
+	tests := []struct {
+		name string
+		want ModelTier
+		ok   bool
+	}{
+		{"premium", TierPremium, true},
+		{" Economy ", TierEconomy, true},
+		{"STANDARD", TierStandard, true},
+		{"ultra", TierStandard, false},
+	}
+	for _, tt := range tests {
+		if got, ok := ParseTier(tt.name); got != tt.want || ok != tt.ok {
+			t.Errorf("ParseTier(%q) = %q, %v, want %q, %v", tt.name, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestProgressLine(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []string
+		current  int // step ID, 0 for none
+		want     string
+	}{
+		{"first step", []string{"in_progress", "pending", "pending"}, 1, "[1/3] A..."},
+		{"middle step", []string{"completed", "completed", "in_progress", "pending"}, 3, "[3/4] C..."},
+		{"after a failure", []string{"failed", "in_progress"}, 2, "[2/2] B..."},
+		{"no current step", []string{"completed", "failed", "pending"}, 0, "[1/3 done]"},
+		{"empty plan", nil, 0, "[0/0 done]"},
+	}
+	for _, tt := range tests {
+		p := testPlan(tt.statuses...)
+		if got := p.ProgressLine(p.GetStep(tt.current)); got != tt.want {
+			t.Errorf("%s: ProgressLine() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSummary(t *testing.T) {
+	p := testPlan("completed", "failed", "in_progress", "pending")
+	p.Goal = "add a parser"
+	want := "Goal: add a parser\nProgress: 1/4 completed, 1 failed\n1. [completed] A\n2. [failed] B\n3. [in_progress] C\n4. [pending] D\n"
+	if got := p.Summary(); got != want {
+		t.Errorf("Summary() = %q, want %q", got, want)
+	}
+	if got := testPlan("pending").Summary(); !strings.Contains(got, "Progress: 0/1 completed\n") {
+		t.Errorf("Summary() = %q, want no failure count", got)
+	}
+}
+
+func TestSaveReplacesFiles(t *testing.T) {
+	dir := t.TempDir()
+	p := testPlan("pending", "pending")
+	if err := p.Save(dir); err != nil {
+		t.Fatal(err)
+	}
+	jsonPath := filepath.Join(dir, ".aicli", "plan.json")
+	before, _ := os.Stat(jsonPath)
+
+	p.MarkCompleted(1, "done")
+	if err := p.Save(dir); err != nil {
+		t.Fatal(err)
+	}
+	after, _ := os.Stat(jsonPath)
+	if os.SameFile(before, after) {
+		t.Error("plan.json was rewritten in place, not replaced")
+	}
+	loaded, err := Load(dir)
+	if err != nil || loaded.Steps[0].Status != "completed" {
+		t.Fatalf("Load() = %+v, %v", loaded, err)
+	}
+	for _, d := range []string{dir, filepath.Dir(jsonPath)} {
+		entries, _ := os.ReadDir(d)
+		for _, e := range entries {
+			if strings.Contains(e.Name(), ".tmp") {
+				t.Errorf("temp file %s left behind", e.Name())
+			}
+		}
+	}
+}
+
+func TestRegenerateKeepsCompletedSteps(t *testing.T) {
+	p := testPlan("completed", "failed", "completed", "in_progress", "pending")
+	p.Steps[0].Result = "scaffolded"
+	done := time.Now().Add(-time.Hour)
+	p.Steps[2].CompletedAt = &done
+
+	var resp PlanResponse
+	if err := json.Unmarshal([]byte(`{"analysis":"the API moved to v2","steps":[
+		{"title":"X","description":"port to v2","model_tier":"premium","files":["api.go"]},
+		{"title":"Y","description":"update docs","model_tier":"economy"}]}`), &resp); err != nil {
+		t.Fatal(err)
+	}
+	p.Regenerate(&resp)
+
+	if got := titles(t, p); got != "ACXY" {
+		t.Fatalf("steps after regenerating = %s, want the completed A, C then X, Y", got)
+	}
+	if a := p.Steps[0]; a.Status != "completed" || a.Result != "scaffolded" {
+		t.Errorf("completed step A = %+v, want its status and result kept", a)
+	}
+	if c := p.Steps[1]; c.CompletedAt == nil || !c.CompletedAt.Equal(done) {
+		t.Errorf("completed step C lost its completion time: %+v", c)
+	}
+	if x := p.Steps[2]; x.Status != "pending" || x.ModelTier != TierPremium || len(x.Files) != 1 {
+		t.Errorf("new step X = %+v", x)
+	}
+	if p.Analysis != "the API moved to v2" {
+		t.Errorf("analysis = %q, want the regenerated one", p.Analysis)
+	}
+	if next := p.NextPending(); next == nil || next.Title != "X" {
+		t.Errorf("NextPending() = %v, want X", next)
+	}
+
+	// An empty analysis keeps the original
+	p.Regenerate(&PlanResponse{})
+	if p.Analysis != "the API moved to v2" || titles(t, p) != "AC" {
+		t.Errorf("after an empty regeneration: analysis %q, steps %s", p.Analysis, titles(t, p))
+	}
+}
+
+func TestBuildRegenPrompt(t *testing.T) {
+	p := testPlan("completed", "failed", "pending")
+	p.Steps[0].Result = "scaffolded"
+	p.Steps[1].Result = "tests fail"
+	got := BuildRegenPrompt(p, "main.go\napi.go", "")
+
+	for _, want := range []string{
+		"## Project Structure (current)\n\n```\nmain.go\napi.go\n```",
+		"## Completed Steps (already done - do not repeat)\n\n- A (result: scaffolded)\n",
+		"## Remaining Steps (from the original plan - may be out of date)\n\n- [failed] B: \n  Failed with: tests fail\n- [pending] C: \n",
+		"ONLY the work still needed",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("prompt lacks %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "## Key Files") {
+		t.Error("prompt has a key files section without file contents")
+	}
+}
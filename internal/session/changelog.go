@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 	"time"
 )
@@ -22,6 +23,7 @@ type ChangelogFile struct {
 	filePath   string
 	unreleased map[string][]ChangelogEntry // Type -> entries
 	released   []ReleasedSection
+	modTime    time.Time // Of the file when last loaded or saved
 }
 
 type ReleasedSection struct {
@@ -54,33 +56,66 @@ func (cf *ChangelogFile) AddEntry(entryType, description string, files []string)
 		Files:       files,
 	}
 
-	if cf.unreleased[entryType] == nil {
-		cf.unreleased[entryType] = make([]ChangelogEntry, 0)
-	}
-	cf.unreleased[entryType] = append(cf.unreleased[entryType], entry)
-	cf.Save()
+	cf.update(func() {
+		if cf.unreleased[entryType] == nil {
+			cf.unreleased[entryType] = make([]ChangelogEntry, 0)
+		}
+		cf.unreleased[entryType] = append(cf.unreleased[entryType], entry)
+	})
 }
 
 // Release moves all unreleased entries to a new dated section
 func (cf *ChangelogFile) Release(version string) {
-	if len(cf.unreleased) == 0 {
-		return
-	}
+	cf.update(func() {
+		if len(cf.unreleased) == 0 {
+			return
+		}
 
-	dateStr := time.Now().Format("2006-01-02")
-	title := dateStr
-	if version != "" {
-		title = fmt.Sprintf("[%s] - %s", version, dateStr)
-	}
+		dateStr := time.Now().Format("2006-01-02")
+		title := dateStr
+		if version != "" {
+			title = fmt.Sprintf("[%s] - %s", version, dateStr)
+		}
+
+		section := ReleasedSection{
+			Date:    title,
+			Entries: cf.unreleased,
+		}
+
+		cf.released = append([]ReleasedSection{section}, cf.released...)
+		cf.unreleased = make(map[string][]ChangelogEntry)
+	})
+}
 
-	section := ReleasedSection{
-		Date:    title,
-		Entries: cf.unreleased,
+// ClearUnreleased drops the unreleased entries, keeping released sections,
+// and returns how many were removed
+func (cf *ChangelogFile) ClearUnreleased() int {
+	removed := 0
+	cf.update(func() {
+		for _, entries := range cf.unreleased {
+			removed += len(entries)
+		}
+		cf.unreleased = make(map[string][]ChangelogEntry)
+	})
+	if removed > 0 && len(cf.released) == 0 {
+		// write keeps no file for an empty changelog; remove the stale one
+		os.Remove(cf.filePath)
+		cf.modTime = time.Time{}
 	}
+	return removed
+}
 
-	cf.released = append([]ReleasedSection{section}, cf.released...)
-	cf.unreleased = make(map[string][]ChangelogEntry)
-	cf.Save()
+// update applies fn under the CHANGELOG.md lock and saves. If another run
+// changed the file since it was last loaded or saved, it is reloaded first
+// so that run's entries are kept.
+func (cf *ChangelogFile) update(fn func()) {
+	unlock := lockFile(cf.projectDir, "CHANGELOG.md")
+	defer unlock()
+	if !modTime(cf.filePath).Equal(cf.modTime) {
+		cf.Load()
+	}
+	fn()
+	cf.write()
 }
 
 // GetRecent returns the most recent n entries across all types
@@ -114,8 +149,38 @@ func (cf *ChangelogFile) GetRecent(n int) []ChangelogEntry {
 	return all[:n]
 }
 
+// RecentFiles returns the files touched by unreleased entries, newest first
+func (cf *ChangelogFile) RecentFiles() []string {
+	var entries []ChangelogEntry
+	for _, items := range cf.unreleased {
+		entries = append(entries, items...)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Timestamp.After(entries[j].Timestamp)
+	})
+
+	seen := make(map[string]bool)
+	var files []string
+	for _, entry := range entries {
+		for _, f := range entry.Files {
+			if !seen[f] {
+				seen[f] = true
+				files = append(files, f)
+			}
+		}
+	}
+	return files
+}
+
 // Save writes the changelog to CHANGELOG.md
 func (cf *ChangelogFile) Save() error {
+	unlock := lockFile(cf.projectDir, "CHANGELOG.md")
+	defer unlock()
+	return cf.write()
+}
+
+// write renders the changelog to CHANGELOG.md; the caller holds the lock
+func (cf *ChangelogFile) write() error {
 	var sb strings.Builder
 	sb.WriteString("# Changelog\n\n")
 	sb.WriteString("All notable changes to this project will be documented in this file.\n\n")
@@ -143,7 +208,11 @@ func (cf *ChangelogFile) Save() error {
 		return nil // Don't create empty changelog
 	}
 
-	return os.WriteFile(cf.filePath, []byte(sb.String()), 0644)
+	if err := WriteFileAtomic(cf.filePath, []byte(sb.String()), 0644); err != nil {
+		return err
+	}
+	cf.modTime = modTime(cf.filePath)
+	return nil
 }
 
 func writeEntrySection(sb *strings.Builder, entries map[string][]ChangelogEntry) {
@@ -175,6 +244,9 @@ func (cf *ChangelogFile) Load() error {
 		return err
 	}
 	defer file.Close()
+	if info, err := file.Stat(); err == nil {
+		cf.modTime = info.ModTime()
+	}
 
 	cf.unreleased = make(map[string][]ChangelogEntry)
 	cf.released = make([]ReleasedSection, 0)
@@ -250,6 +322,88 @@ func (cf *ChangelogFile) Load() error {
 	return scanner.Err()
 }
 
+// releaseTitleRegex matches released section titles written by Release,
+// e.g. "[1.2.0] - 2024-05-01"
+var releaseTitleRegex = regexp.MustCompile(`^\[([^\]]+)\]\s*-\s*(\S+)$`)
+
+// ExportKeepAChangelog renders the changelog in strict Keep a Changelog
+// format: an [Unreleased] section, one "## [x.y.z] - date" section per
+// versioned release, and a footer of compare links. Released sections
+// without a version can't be linked and are skipped. repoURL is the web URL
+// of the repository (links are omitted if empty); tags are the repository's
+// git tags, used to match "1.2.0" to "v1.2.0" style tag names.
+func (cf *ChangelogFile) ExportKeepAChangelog(repoURL string, tags []string) string {
+	var sb strings.Builder
+	sb.WriteString("# Changelog\n\n")
+	sb.WriteString("All notable changes to this project will be documented in this file.\n\n")
+	sb.WriteString("The format is based on [Keep a Changelog](https://keepachangelog.com/en/1.1.0/),\n")
+	sb.WriteString("and this project adheres to [Semantic Versioning](https://semver.org/spec/v2.0.0.html).\n\n")
+
+	sb.WriteString("## [Unreleased]\n\n")
+	writeKeepAChangelogEntries(&sb, cf.unreleased)
+
+	var versions []string
+	for _, section := range cf.released {
+		m := releaseTitleRegex.FindStringSubmatch(section.Date)
+		if m == nil {
+			continue
+		}
+		versions = append(versions, m[1])
+		sb.WriteString(fmt.Sprintf("## [%s] - %s\n\n", m[1], m[2]))
+		writeKeepAChangelogEntries(&sb, section.Entries)
+	}
+
+	if repoURL == "" {
+		return sb.String()
+	}
+	repoURL = strings.TrimSuffix(repoURL, "/")
+
+	tagFor := func(version string) string {
+		for _, tag := range tags {
+			if tag == "v"+version {
+				return tag
+			}
+		}
+		for _, tag := range tags {
+			if tag == version {
+				return tag
+			}
+		}
+		return "v" + version
+	}
+
+	// Versions are newest first; each compares against the next older one
+	if len(versions) > 0 {
+		sb.WriteString(fmt.Sprintf("[Unreleased]: %s/compare/%s...HEAD\n", repoURL, tagFor(versions[0])))
+	} else {
+		sb.WriteString(fmt.Sprintf("[Unreleased]: %s/commits/HEAD\n", repoURL))
+	}
+	for i, version := range versions {
+		if i+1 < len(versions) {
+			sb.WriteString(fmt.Sprintf("[%s]: %s/compare/%s...%s\n", version, repoURL, tagFor(versions[i+1]), tagFor(version)))
+		} else {
+			sb.WriteString(fmt.Sprintf("[%s]: %s/releases/tag/%s\n", version, repoURL, tagFor(version)))
+		}
+	}
+	return sb.String()
+}
+
+// writeKeepAChangelogEntries writes entry groups in Keep a Changelog order,
+// without the file annotations used in the live CHANGELOG.md
+func writeKeepAChangelogEntries(sb *strings.Builder, entries map[string][]ChangelogEntry) {
+	for _, entryType := range []string{"Added", "Changed", "Removed", "Fixed"} {
+		items := entries[entryType]
+		if len(items) == 0 {
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("### %s\n\n", entryType))
+		for _, item := range items {
+			sb.WriteString(fmt.Sprintf("- %s\n", item.Description))
+		}
+		sb.WriteString("\n")
+	}
+}
+
 // FilePath returns the path to the CHANGELOG.md file
 func (cf *ChangelogFile) FilePath() string {
 	return cf.filePath
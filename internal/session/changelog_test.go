@@ -0,0 +1,70 @@
+package session
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChangelogRecentFiles(t *testing.T) {
+	now := time.Now()
+	cf := NewChangelogFile(t.TempDir())
+	cf.unreleased = map[string][]ChangelogEntry{
+		"Added": {
+			{Timestamp: now.Add(-3 * time.Minute), Description: "old", Files: []string{"a.go", "b.go"}},
+			{Timestamp: now, Description: "newest", Files: []string{"c.go", "a.go"}},
+		},
+		"Fixed": {
+			{Timestamp: now.Add(-time.Minute), Description: "middle", Files: []string{"d.go"}},
+			{Timestamp: now.Add(-2 * time.Minute), Description: "no files"},
+		},
+	}
+	if got, want := strings.Join(cf.RecentFiles(), " "), "c.go a.go d.go b.go"; got != want {
+		t.Errorf("RecentFiles() = %q, want %q", got, want)
+	}
+
+	cf.Release("1.0.0")
+	if got := cf.RecentFiles(); len(got) != 0 {
+		t.Errorf("RecentFiles() after release = %q, want none", got)
+	}
+}
+
+var update = flag.Bool("update", false, "rewrite golden files")
+
+func TestExportKeepAChangelog(t *testing.T) {
+	tests := []struct {
+		name    string
+		repoURL string
+		tags    []string
+		golden  string
+	}{
+		{"with links", "https://github.com/example/proj/", []string{"v1.0.0", "1.1.0", "v1.2.0", "v1.2.0-rc1"}, "keepachangelog.golden"},
+		{"no repository", "", nil, "keepachangelog-nolinks.golden"},
+	}
+	fixture, err := os.ReadFile(filepath.Join("testdata", "CHANGELOG.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			os.WriteFile(filepath.Join(dir, "CHANGELOG.md"), fixture, 0644)
+			got := NewChangelogFile(dir).ExportKeepAChangelog(tt.repoURL, tt.tags)
+
+			golden := filepath.Join("testdata", tt.golden)
+			if *update {
+				os.WriteFile(golden, []byte(got), 0644)
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != string(want) {
+				t.Errorf("export differs from %s:\n%s", golden, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package session
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Step is one thing that happened in a session: a user input, or a tool
+// call together with the first line of its result
+type Step struct {
+	Kind   string // "user" or "tool"
+	Text   string // The input, or the call as name(args)
+	Result string // First line of the tool result
+}
+
+// String renders the step on one line
+func (s Step) String() string {
+	if s.Kind == "user" {
+		return "> " + s.Text
+	}
+	if s.Result == "" {
+		return s.Text
+	}
+	return s.Text + " → " + s.Result
+}
+
+// DiffRow pairs the steps at the same position of the same turn in two
+// sessions. A or B is nil where that session has no such step.
+type DiffRow struct {
+	Turn int // 1-based, counted by user inputs
+	A, B *Step
+	Same bool
+}
+
+// SessionDiff is the step-by-step comparison of two sessions
+type SessionDiff struct {
+	Rows     []DiffRow
+	Diverged int // Index of the first row that differs, -1 if none do
+}
+
+// Turns splits a session into turns, each starting with a user input and
+// followed by the tool calls made for it. Assistant replies are left out:
+// their wording differs between runs even when the actions don't.
+func Turns(s *Session) [][]Step {
+	var turns [][]Step
+	for _, e := range s.Entries {
+		switch e.Type {
+		case "user":
+			turns = append(turns, []Step{{Kind: "user", Text: oneLine(e.Content)}})
+		case "tool_call":
+			if len(turns) == 0 {
+				turns = append(turns, nil)
+			}
+			step := Step{Kind: "tool", Text: fmt.Sprintf("%s(%s)", e.ToolName, oneLine(e.ToolArgs))}
+			turns[len(turns)-1] = append(turns[len(turns)-1], step)
+		case "tool_result":
+			// Results come back in call order, so a result belongs to the
+			// earliest call of the same tool without one
+			if len(turns) == 0 {
+				continue
+			}
+			steps := turns[len(turns)-1]
+			for i := range steps {
+				if steps[i].Kind == "tool" && steps[i].Result == "" && strings.HasPrefix(steps[i].Text, e.ToolName+"(") {
+					steps[i].Result = oneLine(e.Content)
+					break
+				}
+			}
+		}
+	}
+	return turns
+}
+
+// Diff compares two sessions turn by turn, pairing the steps of each turn
+// by position
+func Diff(a, b *Session) *SessionDiff {
+	turnsA, turnsB := Turns(a), Turns(b)
+	d := &SessionDiff{Diverged: -1}
+	for t := 0; t < len(turnsA) || t < len(turnsB); t++ {
+		var stepsA, stepsB []Step
+		if t < len(turnsA) {
+			stepsA = turnsA[t]
+		}
+		if t < len(turnsB) {
+			stepsB = turnsB[t]
+		}
+		for i := 0; i < len(stepsA) || i < len(stepsB); i++ {
+			row := DiffRow{Turn: t + 1}
+			if i < len(stepsA) {
+				row.A = &stepsA[i]
+			}
+			if i < len(stepsB) {
+				row.B = &stepsB[i]
+			}
+			row.Same = row.A != nil && row.B != nil && *row.A == *row.B
+			if !row.Same && d.Diverged < 0 {
+				d.Diverged = len(d.Rows)
+			}
+			d.Rows = append(d.Rows, row)
+		}
+	}
+	return d
+}
+
+// FormatDiff renders a diff as two columns of the given width, headed by
+// the session names. Differing rows are marked with "≠" and the first of
+// them with "▶".
+func FormatDiff(d *SessionDiff, nameA, nameB string, width int) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "  %s │ %s\n", pad(nameA, width), nameB)
+	turn := 0
+	for i, row := range d.Rows {
+		if row.Turn != turn {
+			turn = row.Turn
+			fmt.Fprintf(&sb, "Turn %d\n", turn)
+		}
+		marker := "  "
+		switch {
+		case i == d.Diverged:
+			marker = "▶ "
+		case !row.Same:
+			marker = "≠ "
+		}
+		fmt.Fprintf(&sb, "%s%s │ %s\n", marker, pad(stepText(row.A), width), clip(stepText(row.B), width))
+	}
+	if d.Diverged < 0 {
+		sb.WriteString("The sessions took the same steps.\n")
+	} else {
+		fmt.Fprintf(&sb, "The sessions diverge at turn %d (▶); %d of %d steps differ.\n", d.Rows[d.Diverged].Turn, d.Differences(), len(d.Rows))
+	}
+	return sb.String()
+}
+
+// Differences counts the rows that differ
+func (d *SessionDiff) Differences() int {
+	n := 0
+	for _, row := range d.Rows {
+		if !row.Same {
+			n++
+		}
+	}
+	return n
+}
+
+// stepText renders a step for a diff column, "-" where there is none
+func stepText(s *Step) string {
+	if s == nil {
+		return "-"
+	}
+	return s.String()
+}
+
+// oneLine returns the first non-empty line of s, trimmed
+func oneLine(s string) string {
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			return line
+		}
+	}
+	return ""
+}
+
+// clip shortens s to width characters, ending in "…" when cut
+func clip(s string, width int) string {
+	if utf8.RuneCountInString(s) <= width {
+		return s
+	}
+	if width < 1 {
+		return ""
+	}
+	r := []rune(s)
+	return string(r[:width-1]) + "…"
+}
+
+// pad clips s and pads it with spaces to width characters
+func pad(s string, width int) string {
+	s = clip(s, width)
+	return s + strings.Repeat(" ", width-utf8.RuneCountInString(s))
+}
@@ -0,0 +1,88 @@
+package session
+
+import (
+	"strings"
+	"testing"
+)
+
+// fixture builds a session from its entries
+func fixture(entries ...Entry) *Session {
+	return &Session{Entries: entries}
+}
+
+func user(s string) Entry          { return Entry{Type: "user", Content: s} }
+func reply(s string) Entry         { return Entry{Type: "assistant", Content: s} }
+func call(name, args string) Entry { return Entry{Type: "tool_call", ToolName: name, ToolArgs: args} }
+func result(name, s string) Entry  { return Entry{Type: "tool_result", ToolName: name, Content: s} }
+
+func TestDiff(t *testing.T) {
+	base := []Entry{
+		user("fix the failing test"),
+		call("read_file", `{"path":"a_test.go"}`), result("read_file", "package a\n..."),
+		reply("I see the problem."),
+		call("run_command", `{"command":"go test ./..."}`), result("run_command", "ok\n"),
+		user("commit it"),
+		call("git_commit", `{"message":"Fix test"}`), result("git_commit", "Committed"),
+	}
+	tests := []struct {
+		name     string
+		b        []Entry
+		diverged int // Row index, -1 if the same
+		differ   int
+		rows     int
+	}{
+		{"same steps, different wording", replace(base, 3, reply("Found it.")), -1, 0, 5},
+		{"different tool result", replace(base, 5, result("run_command", "FAIL\n")), 2, 1, 5},
+		{"different call", replace(base, 7, call("git_commit", `{"message":"Fix the test"}`)), 4, 1, 5},
+		{"extra step", append(append([]Entry{}, base[:6]...), call("run_command", `{"command":"go vet ./..."}`), base[6], base[7], base[8]), 3, 1, 6},
+		{"missing turn", base[:6], 3, 2, 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := Diff(fixture(base...), fixture(tt.b...))
+			if d.Diverged != tt.diverged || d.Differences() != tt.differ || len(d.Rows) != tt.rows {
+				t.Errorf("diverged at %d with %d of %d rows differing, want %d with %d of %d",
+					d.Diverged, d.Differences(), len(d.Rows), tt.diverged, tt.differ, tt.rows)
+			}
+		})
+	}
+}
+
+// replace returns a copy of entries with entries[i] replaced
+func replace(entries []Entry, i int, e Entry) []Entry {
+	out := append([]Entry{}, entries...)
+	out[i] = e
+	return out
+}
+
+func TestTurnsPairsResults(t *testing.T) {
+	s := fixture(
+		call("list_files", "{}"), // Before any user input
+		user("look around"),
+		call("read_file", `{"path":"a"}`), call("read_file", `{"path":"b"}`),
+		result("read_file", "A"), result("read_file", "B"),
+	)
+	turns := Turns(s)
+	if len(turns) != 2 {
+		t.Fatalf("%d turns, want 2", len(turns))
+	}
+	got := []string{turns[0][0].String(), turns[1][1].String(), turns[1][2].String()}
+	want := []string{"list_files({})", `read_file({"path":"a"}) → A`, `read_file({"path":"b"}) → B`}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("steps = %q, want %q", got, want)
+	}
+}
+
+func TestFormatDiff(t *testing.T) {
+	a := fixture(user("go"), call("run_command", `{"command":"make"}`), result("run_command", "ok"))
+	b := fixture(user("go"), call("run_command", `{"command":"make"}`), result("run_command", "error: missing target"))
+	out := FormatDiff(Diff(a, b), "first", "second", 60)
+	for _, want := range []string{"first", "second", "Turn 1", "▶ ", "→ error: missing target", "diverge at turn 1"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+	if out := FormatDiff(Diff(a, a), "a", "a", 30); !strings.Contains(out, "same steps") {
+		t.Errorf("identical sessions:\n%s", out)
+	}
+}
@@ -0,0 +1,108 @@
+package session
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DiskUsage is the space one kind of file takes up under .aicli
+type DiskUsage struct {
+	Category string
+	Files    int
+	Bytes    int64
+}
+
+// diskCategories is the order DiskUsageOf reports categories in
+var diskCategories = []string{"sessions", "debug", "cache", "backups", "other"}
+
+// DiskUsageOf walks the project's .aicli directory and totals the files in
+// each category: sessions, debug logs, caches (responses, capabilities),
+// backups (*.bak, *.old) and everything else. A missing directory has no
+// usage.
+func DiskUsageOf(projectDir string) ([]DiskUsage, error) {
+	dir := filepath.Join(projectDir, ".aicli")
+	totals := make(map[string]*DiskUsage)
+	for _, cat := range diskCategories {
+		totals[cat] = &DiskUsage{Category: cat}
+	}
+
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == dir {
+				return filepath.SkipAll
+			}
+			return nil // Unreadable entries are left out
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		rel, _ := filepath.Rel(dir, path)
+		t := totals[diskCategory(filepath.ToSlash(rel))]
+		t.Files++
+		t.Bytes += info.Size()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	usage := make([]DiskUsage, 0, len(diskCategories))
+	for _, cat := range diskCategories {
+		usage = append(usage, *totals[cat])
+	}
+	return usage, nil
+}
+
+// diskCategory classifies a path relative to .aicli
+func diskCategory(rel string) string {
+	name := filepath.Base(rel)
+	ext := filepath.Ext(name)
+	switch {
+	case ext == ".bak" || ext == ".old":
+		return "backups"
+	case strings.HasPrefix(rel, "debug/"):
+		return "debug"
+	case strings.HasPrefix(rel, "respcache/") || name == "capabilities.json":
+		return "cache"
+	case !strings.Contains(rel, "/") && strings.HasPrefix(name, "session_") && (ext == ".jsonl" || ext == ".json"):
+		return "sessions"
+	}
+	return "other"
+}
+
+// FormatDiskUsage renders usage as a table with a total line
+func FormatDiskUsage(usage []DiskUsage) string {
+	var sb strings.Builder
+	var files int
+	var bytes int64
+	for _, u := range usage {
+		fmt.Fprintf(&sb, "  %-10s %10s  %5d files\n", u.Category, FormatBytes(u.Bytes), u.Files)
+		files += u.Files
+		bytes += u.Bytes
+	}
+	fmt.Fprintf(&sb, "  %-10s %10s  %5d files\n", "total", FormatBytes(bytes), files)
+	return sb.String()
+}
+
+// FormatBytes renders a size as B, KB, MB or GB
+func FormatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	value, suffix := float64(n)/unit, "KB"
+	for _, s := range []string{"MB", "GB"} {
+		if value < unit {
+			break
+		}
+		value, suffix = value/unit, s
+	}
+	return fmt.Sprintf("%.1f %s", value, suffix)
+}
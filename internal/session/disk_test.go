@@ -0,0 +1,89 @@
+package session
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiskUsageOf(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]int{
+		"session_1.jsonl":           100,
+		"session_2.json":            50,
+		"debug/request_1.json":      300,
+		"debug/response_1.json":     200,
+		"respcache/ab/cd.json":      400,
+		"capabilities.json":         10,
+		"config.json.bak":           7,
+		"debug/old/session_9.jsonl": 5, // a session name outside the top level is not a session
+		"plan.json":                 20,
+		"todos.md.old":              3,
+		"exports/session_1.json":    60,
+	}
+	for name, size := range files {
+		path := filepath.Join(dir, ".aicli", name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, make([]byte, size), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	usage, err := DiskUsageOf(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []DiskUsage{
+		{"sessions", 2, 150},
+		{"debug", 3, 505},
+		{"cache", 2, 410},
+		{"backups", 2, 10},
+		{"other", 2, 80},
+	}
+	if !reflect.DeepEqual(usage, want) {
+		t.Errorf("DiskUsageOf =\n%+v\nwant\n%+v", usage, want)
+	}
+
+	table := FormatDiskUsage(usage)
+	lines := strings.Split(strings.TrimSuffix(table, "\n"), "\n")
+	if len(lines) != 6 || strings.Join(strings.Fields(lines[5]), " ") != "total 1.1 KB 11 files" {
+		t.Errorf("FormatDiskUsage =\n%s", table)
+	}
+}
+
+func TestDiskUsageOfMissingDir(t *testing.T) {
+	usage, err := DiskUsageOf(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(usage) != len(diskCategories) {
+		t.Fatalf("got %d categories, want %d", len(usage), len(diskCategories))
+	}
+	for _, u := range usage {
+		if u.Files != 0 || u.Bytes != 0 {
+			t.Errorf("%s = %+v, want empty", u.Category, u)
+		}
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	tests := []struct {
+		n    int64
+		want string
+	}{
+		{0, "0 B"},
+		{1023, "1023 B"},
+		{1024, "1.0 KB"},
+		{1536, "1.5 KB"},
+		{5 << 20, "5.0 MB"},
+		{3 << 30, "3.0 GB"},
+		{2048 << 30, "2048.0 GB"},
+	}
+	for _, tt := range tests {
+		if got := FormatBytes(tt.n); got != tt.want {
+			t.Errorf("FormatBytes(%d) = %q, want %q", tt.n, got, tt.want)
+		}
+	}
+}
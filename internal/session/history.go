@@ -5,13 +5,14 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"time"
 )
 
 type HistoryEntry struct {
 	Timestamp   time.Time
-	Type        string // "request", "todo", "change", "commit"
+	Type        string // "request", "todo", "change", "commit", or "text" for a line Load didn't recognize
 	Description string
 	Details     string // Additional details (e.g., file list, todo status)
 }
@@ -20,6 +21,8 @@ type HistoryFile struct {
 	projectDir string
 	filePath   string
 	entries    []HistoryEntry
+	preamble   []string  // Unrecognized lines above the first date, kept verbatim
+	modTime    time.Time // Of the file when last loaded or saved
 }
 
 // NewHistoryFile creates or loads a HISTORY.md file in the project root
@@ -36,23 +39,21 @@ func NewHistoryFile(projectDir string) *HistoryFile {
 
 // AddRequest adds a user request to the history
 func (hf *HistoryFile) AddRequest(request string) {
-	hf.entries = append(hf.entries, HistoryEntry{
+	hf.add(HistoryEntry{
 		Timestamp:   time.Now(),
 		Type:        "request",
 		Description: request,
 	})
-	hf.Save()
 }
 
 // AddTodo adds a todo event to the history
 func (hf *HistoryFile) AddTodo(action, status string) {
-	hf.entries = append(hf.entries, HistoryEntry{
+	hf.add(HistoryEntry{
 		Timestamp:   time.Now(),
 		Type:        "todo",
 		Description: action,
 		Details:     status,
 	})
-	hf.Save()
 }
 
 // AddChange adds a file change to the history
@@ -61,38 +62,50 @@ func (hf *HistoryFile) AddChange(description string, files []string) {
 	if len(files) > 0 {
 		details = strings.Join(files, ", ")
 	}
-	hf.entries = append(hf.entries, HistoryEntry{
+	hf.add(HistoryEntry{
 		Timestamp:   time.Now(),
 		Type:        "change",
 		Description: description,
 		Details:     details,
 	})
-	hf.Save()
 }
 
 // AddCommit adds a git commit to the history
 func (hf *HistoryFile) AddCommit(message, hash string) {
-	hf.entries = append(hf.entries, HistoryEntry{
+	hf.add(HistoryEntry{
 		Timestamp:   time.Now(),
 		Type:        "commit",
 		Description: message,
 		Details:     hash,
 	})
-	hf.Save()
+}
+
+// add appends an entry under the HISTORY.md lock and saves. If another run
+// changed the file since it was last loaded or saved, it is reloaded first
+// so that run's entries are kept.
+func (hf *HistoryFile) add(entry HistoryEntry) {
+	unlock := lockFile(hf.projectDir, "HISTORY.md")
+	defer unlock()
+	if !modTime(hf.filePath).Equal(hf.modTime) {
+		hf.Load()
+	}
+	hf.entries = append(hf.entries, entry)
+	hf.write()
 }
 
 // GetRecent returns the most recent n entries
 func (hf *HistoryFile) GetRecent(n int) []HistoryEntry {
-	if n > len(hf.entries) {
-		n = len(hf.entries)
+	entries := hf.events()
+	if n > len(entries) {
+		n = len(entries)
 	}
 	// Return from end (most recent)
-	start := len(hf.entries) - n
+	start := len(entries) - n
 	if start < 0 {
 		start = 0
 	}
 	result := make([]HistoryEntry, n)
-	copy(result, hf.entries[start:])
+	copy(result, entries[start:])
 	// Reverse for newest first
 	for i, j := 0, len(result)-1; i < j; i, j = i+1, j-1 {
 		result[i], result[j] = result[j], result[i]
@@ -102,13 +115,26 @@ func (hf *HistoryFile) GetRecent(n int) []HistoryEntry {
 
 // Save writes the history to HISTORY.md
 func (hf *HistoryFile) Save() error {
+	unlock := lockFile(hf.projectDir, "HISTORY.md")
+	defer unlock()
+	return hf.write()
+}
+
+// write renders the history to HISTORY.md; the caller holds the lock
+func (hf *HistoryFile) write() error {
 	var sb strings.Builder
 	sb.WriteString("# Project History\n\n")
 	sb.WriteString("Activity log for this project.\n\n")
 
-	if len(hf.entries) == 0 {
+	if len(hf.entries) == 0 && len(hf.preamble) == 0 {
 		return nil // Don't create empty file
 	}
+	for _, line := range hf.preamble {
+		sb.WriteString(line + "\n")
+	}
+	if len(hf.preamble) > 0 {
+		sb.WriteString("\n")
+	}
 
 	// Group by date
 	dateGroups := make(map[string][]HistoryEntry)
@@ -154,38 +180,157 @@ func (hf *HistoryFile) Save() error {
 				}
 			case "commit":
 				if entry.Details != "" {
-					sb.WriteString(fmt.Sprintf("- %s `%s` **Commit** `%s`: %s\n", icon, timeStr, entry.Details[:7], entry.Description))
+					hash := entry.Details
+					if len(hash) > 7 {
+						hash = hash[:7]
+					}
+					sb.WriteString(fmt.Sprintf("- %s `%s` **Commit** `%s`: %s\n", icon, timeStr, hash, entry.Description))
 				} else {
 					sb.WriteString(fmt.Sprintf("- %s `%s` **Commit**: %s\n", icon, timeStr, entry.Description))
 				}
+			case "text":
+				sb.WriteString(entry.Description + "\n")
 			}
 		}
 		sb.WriteString("\n")
 	}
 
-	return os.WriteFile(hf.filePath, []byte(sb.String()), 0644)
+	if err := WriteFileAtomic(hf.filePath, []byte(sb.String()), 0644); err != nil {
+		return err
+	}
+	hf.modTime = modTime(hf.filePath)
+	return nil
 }
 
-// Load reads the history from HISTORY.md
+var (
+	historyDateRegex    = regexp.MustCompile(`^## (\d{4}-\d{2}-\d{2})$`)
+	historyRequestRegex = regexp.MustCompile("^### \\S+ (\\d{2}:\\d{2}) Request$")
+	historyItemRegex    = regexp.MustCompile("^- (\\S) `(\\d{2}:\\d{2})` (.*)$")
+	historyTodoRegex    = regexp.MustCompile(`^\*\*(.*?)\*\* - (.*)$`)
+	historyChangeRegex  = regexp.MustCompile(`^(.*?)(?: \*\(files: (.+)\)\*)?$`)
+	historyCommitRegex  = regexp.MustCompile("^\\*\\*Commit\\*\\*(?: `([^`]+)`)?: (.*)$")
+)
+
+// historyHeader is the title and intro write puts above the dates
+var historyHeader = map[string]bool{"# Project History": true, "Activity log for this project.": true}
+
+// Load reads the history back from HISTORY.md, so entries from earlier and
+// concurrent runs are kept when it is saved. Times have minute precision.
+// Lines it doesn't recognize, such as hand-written notes, are kept verbatim
+// in place.
 func (hf *HistoryFile) Load() error {
 	file, err := os.Open(hf.filePath)
 	if err != nil {
 		if os.IsNotExist(err) {
+			// No file yet, or another run cleared it
+			hf.entries = make([]HistoryEntry, 0)
+			hf.preamble = nil
+			hf.modTime = time.Time{}
 			return nil
 		}
 		return err
 	}
 	defer file.Close()
+	if info, err := file.Stat(); err == nil {
+		hf.modTime = info.ModTime()
+	}
+
+	// The file lists newest first; entries are kept oldest first
+	var entries []HistoryEntry
+	var preamble []string
+	var date string
+	clock := "00:00"          // Of the entry above, for unrecognized lines
+	var request *HistoryEntry // Request whose quoted text is being read
+	at := func(clock string) time.Time {
+		t, _ := time.ParseInLocation("2006-01-02 15:04", date+" "+clock, time.Local)
+		return t
+	}
+	keep := func(line string) {
+		if date == "" {
+			if !historyHeader[line] {
+				preamble = append(preamble, line)
+			}
+			return
+		}
+		entries = append(entries, HistoryEntry{Timestamp: at(clock), Type: "text", Description: line})
+	}
 
-	// For now, we don't parse history back in - it's primarily an output file
-	// The session JSON files contain the authoritative record
 	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
 	for scanner.Scan() {
-		// Just consume the file to validate it's readable
-		_ = scanner.Text()
+		line := scanner.Text()
+
+		if request != nil {
+			switch {
+			case request.Description == "" && strings.HasPrefix(line, "> "):
+				request.Description = strings.TrimPrefix(line, "> ")
+				continue
+			case request.Description != "" && line != "":
+				request.Description += "\n" + line
+				continue
+			case line == "" && request.Description == "":
+				continue
+			}
+			entries = append(entries, *request)
+			request = nil
+		}
+
+		if line == "" {
+			continue
+		}
+		if m := historyDateRegex.FindStringSubmatch(line); m != nil {
+			date, clock = m[1], "00:00"
+			continue
+		}
+		if m := historyRequestRegex.FindStringSubmatch(line); m != nil && date != "" {
+			clock = m[1]
+			request = &HistoryEntry{Timestamp: at(m[1]), Type: "request"}
+			continue
+		}
+		m := historyItemRegex.FindStringSubmatch(line)
+		if m == nil || date == "" {
+			keep(line)
+			continue
+		}
+		entry := HistoryEntry{Timestamp: at(m[2])}
+		switch m[1] {
+		case getIcon("todo"):
+			t := historyTodoRegex.FindStringSubmatch(m[3])
+			if t == nil {
+				keep(line)
+				continue
+			}
+			entry.Type, entry.Details, entry.Description = "todo", t[1], t[2]
+		case getIcon("change"):
+			c := historyChangeRegex.FindStringSubmatch(m[3])
+			entry.Type, entry.Description, entry.Details = "change", c[1], c[2]
+		case getIcon("commit"):
+			c := historyCommitRegex.FindStringSubmatch(m[3])
+			if c == nil {
+				keep(line)
+				continue
+			}
+			entry.Type, entry.Details, entry.Description = "commit", c[1], c[2]
+		default:
+			keep(line)
+			continue
+		}
+		clock = m[2]
+		entries = append(entries, entry)
+	}
+	if request != nil {
+		entries = append(entries, *request)
+	}
+	if err := scanner.Err(); err != nil {
+		return err
 	}
 
-	return scanner.Err()
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+	hf.entries = entries
+	hf.preamble = preamble
+	return nil
 }
 
 // FilePath returns the path to the HISTORY.md file
@@ -193,9 +338,30 @@ func (hf *HistoryFile) FilePath() string {
 	return hf.filePath
 }
 
+// Len returns the number of history entries
+func (hf *HistoryFile) Len() int {
+	return len(hf.events())
+}
+
+// events returns the entries without the unrecognized lines kept from the
+// file
+func (hf *HistoryFile) events() []HistoryEntry {
+	var events []HistoryEntry
+	for _, entry := range hf.entries {
+		if entry.Type != "text" {
+			events = append(events, entry)
+		}
+	}
+	return events
+}
+
 // Clear removes all history entries
 func (hf *HistoryFile) Clear() {
+	unlock := lockFile(hf.projectDir, "HISTORY.md")
+	defer unlock()
 	hf.entries = make([]HistoryEntry, 0)
+	hf.preamble = nil
+	hf.modTime = time.Time{}
 	os.Remove(hf.filePath)
 }
 
@@ -0,0 +1,62 @@
+package session
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHistoryKeepsUnrecognizedLines(t *testing.T) {
+	const header = "# Project History\n\nActivity log for this project.\n\n"
+	tests := []struct {
+		name    string
+		file    string
+		entries int // Recognized entries
+	}{
+		{
+			name:    "generated file",
+			file:    header + "## 2026-10-16\n\n### > 10:05 Request\n\n> add a flag\n\n- * `10:07` Added --max-steps *(files: main.go)*\n- # `10:09` **Commit** `abc1234`: Add --max-steps\n\n",
+			entries: 3,
+		},
+		{
+			name:    "notes between entries",
+			file:    header + "## 2026-10-16\n\n- * `10:07` Added --max-steps\nReviewed with the team, see #12\n- # `10:09` **Commit**: Add --max-steps\n\n",
+			entries: 2,
+		},
+		{
+			name:    "preamble and odd items",
+			file:    header + "Kept by hand since the 1.0 release.\n\n## 2026-10-16\n\n- ? `10:07` unknown icon\n- - `10:08` todo without status\n* a plain bullet\n\n",
+			entries: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "HISTORY.md")
+			if err := os.WriteFile(path, []byte(tt.file), 0644); err != nil {
+				t.Fatal(err)
+			}
+			hf := NewHistoryFile(dir)
+			if got := hf.Len(); got != tt.entries {
+				t.Errorf("Len = %d, want %d", got, tt.entries)
+			}
+			if err := hf.Save(); err != nil {
+				t.Fatal(err)
+			}
+			data, _ := os.ReadFile(path)
+			if got := string(data); got != tt.file {
+				t.Errorf("saved file differs\ngot:\n%s\nwant:\n%s", got, tt.file)
+			}
+
+			// New entries go on top; the existing lines stay
+			hf.AddCommit("Another commit", "")
+			data, _ = os.ReadFile(path)
+			for _, line := range strings.Split(strings.TrimPrefix(tt.file, header), "\n") {
+				if line != "" && !strings.Contains(string(data), line) {
+					t.Errorf("line %q lost after adding an entry", line)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package session
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// lockTimeout is how long lockFile waits for another process. Updates hold
+// the lock for milliseconds, so one still held after this belongs to a
+// stuck process; the update then goes ahead without it.
+const lockTimeout = 5 * time.Second
+
+// lockStaleAge is how old a lock must be before it is taken over from a
+// process that is still running (its PID may have been reused)
+const lockStaleAge = time.Minute
+
+// lockFile serializes updates to a project file shared by concurrent aicli
+// runs (TODOS.md, CHANGELOG.md, HISTORY.md) by exclusively creating
+// .aicli/<name>.lock. It returns the function that releases the lock. A
+// lock left behind by a process that died is taken over. If the lock can't
+// be created at all (e.g. a read-only directory) or stays held, the update
+// goes ahead unguarded.
+func lockFile(projectDir, name string) func() {
+	lockDir := filepath.Join(projectDir, ".aicli")
+	if err := os.MkdirAll(lockDir, 0755); err != nil {
+		return func() {}
+	}
+	lockPath := filepath.Join(lockDir, name+".lock")
+
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			fmt.Fprintf(f, "%d\n", os.Getpid())
+			f.Close()
+			return func() { os.Remove(lockPath) }
+		}
+		if !os.IsExist(err) {
+			return func() {}
+		}
+		if lockIsStale(lockPath) {
+			os.Remove(lockPath)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return func() {}
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// lockIsStale reports whether the lock at path was left behind: its holder
+// is no longer running, or it is older than lockStaleAge. A lock without a
+// PID yet (just created) only goes stale by age.
+func lockIsStale(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false // Released meanwhile
+	}
+	if data, err := os.ReadFile(path); err == nil {
+		if pid, err := strconv.Atoi(strings.TrimSpace(string(data))); err == nil && pid > 0 && !processRunning(pid) {
+			return true
+		}
+	}
+	return time.Since(info.ModTime()) > lockStaleAge
+}
+
+// modTime returns the modification time of path, or the zero time if it
+// doesn't exist. Shared files compare it with the time they last loaded or
+// saved to notice changes made by another run.
+func modTime(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
@@ -0,0 +1,16 @@
+//go:build !unix
+
+package session
+
+import "os"
+
+// processRunning reports whether a process with the given PID exists;
+// finding a process fails on Windows when there is none
+func processRunning(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	p.Release()
+	return true
+}
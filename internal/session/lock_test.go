@@ -0,0 +1,64 @@
+package session
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLockIsStale(t *testing.T) {
+	old := time.Now().Add(-2 * lockStaleAge)
+	tests := []struct {
+		name    string
+		content string // "" with exists false: no lock file
+		exists  bool
+		modTime time.Time
+		want    bool
+	}{
+		{"released", "", false, time.Time{}, false},
+		{"held by this process", fmt.Sprintf("%d\n", os.Getpid()), true, time.Time{}, false},
+		{"holder died", fmt.Sprintf("%d\n", 1<<30), true, time.Time{}, true},
+		{"no PID yet", "", true, time.Time{}, false},
+		{"no PID and old", "", true, old, true},
+		{"held by this process for too long", fmt.Sprintf("%d\n", os.Getpid()), true, old, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "TODOS.md.lock")
+			if tt.exists {
+				if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+					t.Fatal(err)
+				}
+				if !tt.modTime.IsZero() {
+					os.Chtimes(path, tt.modTime, tt.modTime)
+				}
+			}
+			if got := lockIsStale(path); got != tt.want {
+				t.Errorf("lockIsStale = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLockFileTakesOverDeadHolder(t *testing.T) {
+	dir := t.TempDir()
+	lockPath := filepath.Join(dir, ".aicli", "TODOS.md.lock")
+	os.MkdirAll(filepath.Dir(lockPath), 0755)
+	os.WriteFile(lockPath, []byte(fmt.Sprintf("%d\n", 1<<30)), 0644)
+
+	start := time.Now()
+	unlock := lockFile(dir, "TODOS.md")
+	if waited := time.Since(start); waited > time.Second {
+		t.Errorf("waited %v for a lock whose holder died", waited)
+	}
+	data, _ := os.ReadFile(lockPath)
+	if want := fmt.Sprintf("%d\n", os.Getpid()); string(data) != want {
+		t.Errorf("lock holds %q, want %q", data, want)
+	}
+	unlock()
+	if _, err := os.Stat(lockPath); !os.IsNotExist(err) {
+		t.Errorf("lock not released: %v", err)
+	}
+}
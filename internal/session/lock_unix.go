@@ -0,0 +1,11 @@
+//go:build unix
+
+package session
+
+import "syscall"
+
+// processRunning reports whether a process with the given PID exists
+func processRunning(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
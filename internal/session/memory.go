@@ -0,0 +1,103 @@
+package session
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// MemoryFile holds persistent per-project notes (.aicli/memory.md) that are
+// added to the system prompt of every session, e.g. "we use pnpm, not npm"
+type MemoryFile struct {
+	filePath string
+	entries  []string
+}
+
+// NewMemoryFile loads the project's memory file, if any
+func NewMemoryFile(projectDir string) *MemoryFile {
+	mf := &MemoryFile{
+		filePath: filepath.Join(projectDir, ".aicli", "memory.md"),
+	}
+	mf.Load()
+	return mf
+}
+
+// Add appends a note and saves
+func (mf *MemoryFile) Add(text string) error {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return fmt.Errorf("empty memory entry")
+	}
+	mf.entries = append(mf.entries, text)
+	return mf.Save()
+}
+
+// Remove deletes the note at index (0-based) and saves
+func (mf *MemoryFile) Remove(index int) error {
+	if index < 0 || index >= len(mf.entries) {
+		return fmt.Errorf("no memory entry %d", index+1)
+	}
+	mf.entries = append(mf.entries[:index], mf.entries[index+1:]...)
+	return mf.Save()
+}
+
+// Entries returns all notes
+func (mf *MemoryFile) Entries() []string {
+	return mf.entries
+}
+
+// PromptSection renders the notes for the system prompt, or "" if none
+func (mf *MemoryFile) PromptSection() string {
+	if len(mf.entries) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("PROJECT MEMORY (facts to always keep in mind for this project):\n")
+	for _, entry := range mf.entries {
+		sb.WriteString("- " + entry + "\n")
+	}
+	return sb.String()
+}
+
+// Save writes the notes as a markdown list, removing the file when empty
+func (mf *MemoryFile) Save() error {
+	if len(mf.entries) == 0 {
+		os.Remove(mf.filePath)
+		return nil
+	}
+	var sb strings.Builder
+	sb.WriteString("# Project Memory\n\n")
+	for _, entry := range mf.entries {
+		sb.WriteString("- " + entry + "\n")
+	}
+	os.MkdirAll(filepath.Dir(mf.filePath), 0755)
+	return WriteFileAtomic(mf.filePath, []byte(sb.String()), 0644)
+}
+
+// Load reads the notes; every "- " or "* " list item is one note, so the
+// file can also be edited by hand
+func (mf *MemoryFile) Load() error {
+	data, err := os.ReadFile(mf.filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	mf.entries = nil
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "- ") || strings.HasPrefix(line, "* ") {
+			if entry := strings.TrimSpace(line[2:]); entry != "" {
+				mf.entries = append(mf.entries, entry)
+			}
+		}
+	}
+	return nil
+}
+
+// FilePath returns the path to the memory file
+func (mf *MemoryFile) FilePath() string {
+	return mf.filePath
+}
@@ -0,0 +1,75 @@
+package session
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// PromptMatch is a past user prompt found by SearchPrompts
+type PromptMatch struct {
+	Session string // Session file name
+	Time    time.Time
+	Prompt  string
+}
+
+// SearchPrompts finds the user prompts in the project's recorded sessions
+// that contain every word of term (case-insensitive, in any order), newest
+// first. A prompt sent more than once is listed at its latest use, and
+// markers such as "[Added file: x]" are left out. Unreadable sessions are
+// skipped.
+func SearchPrompts(projectDir, term string) ([]PromptMatch, error) {
+	paths, err := ListSessions(projectDir)
+	if err != nil {
+		return nil, err
+	}
+	words := strings.Fields(strings.ToLower(term))
+
+	latest := make(map[string]PromptMatch)
+	for _, path := range paths {
+		s, err := LoadSession(path)
+		if err != nil {
+			continue
+		}
+		for _, e := range s.Entries {
+			prompt := strings.TrimSpace(e.Content)
+			if e.Type != "user" || isMarker(prompt) || !matchesWords(prompt, words) {
+				continue
+			}
+			if prev, ok := latest[prompt]; ok && !e.Timestamp.After(prev.Time) {
+				continue
+			}
+			latest[prompt] = PromptMatch{Session: filepath.Base(path), Time: e.Timestamp, Prompt: prompt}
+		}
+	}
+
+	matches := make([]PromptMatch, 0, len(latest))
+	for _, m := range latest {
+		matches = append(matches, m)
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if !matches[i].Time.Equal(matches[j].Time) {
+			return matches[i].Time.After(matches[j].Time)
+		}
+		return matches[i].Prompt < matches[j].Prompt
+	})
+	return matches, nil
+}
+
+// isMarker reports whether a user entry is a bracketed note recorded by
+// aicli itself rather than something the user typed
+func isMarker(content string) bool {
+	return content == "" || (strings.HasPrefix(content, "[") && strings.HasSuffix(content, "]"))
+}
+
+// matchesWords reports whether s contains every one of the lowercase words
+func matchesWords(s string, words []string) bool {
+	lower := strings.ToLower(s)
+	for _, w := range words {
+		if !strings.Contains(lower, w) {
+			return false
+		}
+	}
+	return true
+}
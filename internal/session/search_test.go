@@ -0,0 +1,67 @@
+package session
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeSession writes an NDJSON session file under dir/.aicli
+func writeSession(t *testing.T, dir, name string, lines ...string) {
+	t.Helper()
+	os.MkdirAll(filepath.Join(dir, ".aicli"), 0755)
+	data := `{"project_dir":"/p","start_time":"2026-10-01T09:00:00Z"}` + "\n" + strings.Join(lines, "\n") + "\n"
+	if err := os.WriteFile(filepath.Join(dir, ".aicli", name), []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSearchPrompts(t *testing.T) {
+	dir := t.TempDir()
+	writeSession(t, dir, "session_1.jsonl",
+		`{"type":"user","content":"Fix the login bug","timestamp":"2026-10-01T09:01:00Z"}`,
+		`{"type":"assistant","content":"Fixed the login bug","timestamp":"2026-10-01T09:02:00Z"}`,
+		`{"type":"user","content":"add tests for login","timestamp":"2026-10-01T09:03:00Z"}`,
+		`{"type":"user","content":"[Added file: login.go]","timestamp":"2026-10-01T09:04:00Z"}`,
+	)
+	writeSession(t, dir, "session_2.jsonl",
+		`{"type":"user","content":"  Fix the login bug  ","timestamp":"2026-10-02T10:00:00Z"}`,
+		`{"type":"user","content":"bug in the LOGIN form","timestamp":"2026-10-02T10:05:00Z"}`,
+		`{"type":"user","content":"deploy","timestamp":"2026-10-02T10:06:00Z"}`,
+	)
+	writeSession(t, dir, "session_3.jsonl", `{"type":"user","content":"login bug bro`)
+	os.WriteFile(filepath.Join(dir, ".aicli", "plan.json"), []byte(`{"entries":[{"type":"user","content":"login bug"}]}`), 0644)
+
+	tests := []struct {
+		term string
+		want []string // "session prompt", newest first
+	}{
+		// Every word must match, in any order and case; a repeated prompt
+		// is listed once at its latest use
+		{"login bug", []string{"session_2.jsonl bug in the LOGIN form", "session_2.jsonl Fix the login bug"}},
+		{"LOGIN", []string{"session_2.jsonl bug in the LOGIN form", "session_2.jsonl Fix the login bug", "session_1.jsonl add tests for login"}},
+		{"tests login", []string{"session_1.jsonl add tests for login"}},
+		{"added file", nil},
+		{"logout", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.term, func(t *testing.T) {
+			matches, err := SearchPrompts(dir, tt.term)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, m := range matches {
+				got = append(got, m.Session+" "+m.Prompt)
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("SearchPrompts(%q) =\n%s\nwant\n%s", tt.term, strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+
+	if matches, err := SearchPrompts(t.TempDir(), "x"); err != nil || len(matches) != 0 {
+		t.Errorf("project without sessions = %v, %v", matches, err)
+	}
+}
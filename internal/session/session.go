@@ -1,11 +1,14 @@
 package session
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -23,19 +26,38 @@ type Session struct {
 	Entries    []Entry   `json:"entries"`
 }
 
+// Recorder appends session entries to a newline-delimited JSON file: a
+// header line (project_dir, start_time) followed by one line per entry, so
+// each record costs one small append however long the session gets
 type Recorder struct {
+	mu         sync.Mutex // Guards session and the file writes
 	session    *Session
 	sessionDir string
 	filePath   string
+	started    bool // Whether the header line has been written
 }
 
+// sessionHeader is the first line of an NDJSON session file
+type sessionHeader struct {
+	ProjectDir string    `json:"project_dir"`
+	StartTime  time.Time `json:"start_time"`
+}
+
+// recorderCount numbers the recorders created by this process
+var recorderCount atomic.Int64
+
 func NewRecorder(projectDir string) *Recorder {
 	sessionDir := filepath.Join(projectDir, ".aicli")
 	os.MkdirAll(sessionDir, 0755)
 
-	// Create session file with timestamp
-	fileName := fmt.Sprintf("session_%s.json", time.Now().Format("20060102_150405"))
-	filePath := filepath.Join(sessionDir, fileName)
+	// Name the file by timestamp and process ID so concurrent runs in the
+	// same project never share a session file, with a sequence number for
+	// further recorders in the same process
+	fileName := fmt.Sprintf("session_%s_%d", time.Now().Format("20060102_150405"), os.Getpid())
+	if n := recorderCount.Add(1); n > 1 {
+		fileName += fmt.Sprintf("_%d", n)
+	}
+	filePath := filepath.Join(sessionDir, fileName+".jsonl")
 
 	return &Recorder{
 		session: &Session{
@@ -48,50 +70,181 @@ func NewRecorder(projectDir string) *Recorder {
 	}
 }
 
+// EnsureGitignore makes sure the project's .gitignore excludes the .aicli
+// directory, creating .gitignore if needed. Returns true if it was modified.
+func EnsureGitignore(projectDir string) (bool, error) {
+	path := filepath.Join(projectDir, ".gitignore")
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		switch strings.TrimSpace(line) {
+		case ".aicli", ".aicli/", "/.aicli", "/.aicli/":
+			return false, nil
+		}
+	}
+
+	content := string(data)
+	if content != "" && !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+	content += ".aicli/\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
 func (r *Recorder) RecordUser(content string) {
-	r.session.Entries = append(r.session.Entries, Entry{
-		Timestamp: time.Now(),
-		Type:      "user",
-		Content:   content,
-	})
-	r.save()
+	r.record(Entry{Type: "user", Content: content})
 }
 
 func (r *Recorder) RecordAssistant(content string) {
-	r.session.Entries = append(r.session.Entries, Entry{
-		Timestamp: time.Now(),
-		Type:      "assistant",
-		Content:   content,
-	})
-	r.save()
+	r.record(Entry{Type: "assistant", Content: content})
 }
 
 func (r *Recorder) RecordToolCall(name, args string) {
-	r.session.Entries = append(r.session.Entries, Entry{
-		Timestamp: time.Now(),
-		Type:      "tool_call",
-		ToolName:  name,
-		ToolArgs:  args,
-	})
-	r.save()
+	r.record(Entry{Type: "tool_call", ToolName: name, ToolArgs: args})
 }
 
 func (r *Recorder) RecordToolResult(name, result string) {
-	r.session.Entries = append(r.session.Entries, Entry{
-		Timestamp: time.Now(),
-		Type:      "tool_result",
-		ToolName:  name,
-		Content:   result,
-	})
-	r.save()
+	r.record(Entry{Type: "tool_result", ToolName: name, Content: result})
+}
+
+// record timestamps and appends an entry, then saves. Safe for concurrent
+// use: entries are appended and written in the same order.
+func (r *Recorder) record(entry Entry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entry.Timestamp = time.Now()
+	r.session.Entries = append(r.session.Entries, entry)
+	r.appendLines(entry)
 }
 
-func (r *Recorder) save() error {
-	data, err := json.MarshalIndent(r.session, "", "  ")
+// appendLines appends an entry to the session file, preceded by the header
+// line the first time. Callers must hold r.mu.
+func (r *Recorder) appendLines(entry Entry) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf) // Encode ends each value with a newline
+	if !r.started {
+		if err := enc.Encode(sessionHeader{r.session.ProjectDir, r.session.StartTime}); err != nil {
+			return err
+		}
+	}
+	if err := enc.Encode(entry); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(r.filePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	r.started = true
+	return nil
+}
+
+// Export writes the session as one pretty-printed JSON document (the
+// format sessions used before NDJSON)
+func (r *Recorder) Export(path string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.session.Export(path)
+}
+
+// Export writes the session as one pretty-printed JSON document
+func (s *Session) Export(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(r.filePath, data, 0644)
+	return WriteFileAtomic(path, data, 0644)
+}
+
+// Flush writes the session file to disk
+// (entries are appended as they are recorded; this only creates the file
+// for a session with no entries yet)
+func (r *Recorder) Flush() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.started {
+		return nil
+	}
+	data, err := json.Marshal(sessionHeader{r.session.ProjectDir, r.session.StartTime})
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(r.filePath, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	r.started = true
+	return nil
+}
+
+// WriteFileAtomic writes data to a temp file in the same directory and
+// renames it over path, so an interrupted write never leaves a partial file
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Chmod(tmpPath, perm); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
+
+// LastAssistant returns the content of the most recent non-empty assistant
+// entry, or "" if none
+func (r *Recorder) LastAssistant() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := len(r.session.Entries) - 1; i >= 0; i-- {
+		entry := r.session.Entries[i]
+		if entry.Type == "assistant" && strings.TrimSpace(entry.Content) != "" {
+			return entry.Content
+		}
+	}
+	return ""
+}
+
+// LastToolCall returns the most recent tool_call entry, or nil if none
+func (r *Recorder) LastToolCall() *Entry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := len(r.session.Entries) - 1; i >= 0; i-- {
+		if r.session.Entries[i].Type == "tool_call" {
+			entry := r.session.Entries[i]
+			return &entry
+		}
+	}
+	return nil
 }
 
 func (r *Recorder) SessionPath() string {
@@ -111,14 +264,18 @@ func ListSessions(projectDir string) ([]string, error) {
 
 	var sessions []string
 	for _, e := range entries {
-		if !e.IsDir() && filepath.Ext(e.Name()) == ".json" {
+		// .aicli also holds plan and cache files; sessions are session_*.json
+		ext := filepath.Ext(e.Name())
+		if !e.IsDir() && strings.HasPrefix(e.Name(), "session_") && (ext == ".jsonl" || ext == ".json") {
 			sessions = append(sessions, filepath.Join(sessionDir, e.Name()))
 		}
 	}
 	return sessions, nil
 }
 
-// LoadSession loads a session file
+// LoadSession loads a session file, either NDJSON (current) or a single
+// JSON document (older sessions and exports). The format is detected from
+// the content, not the extension.
 func LoadSession(path string) (*Session, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
@@ -126,10 +283,41 @@ func LoadSession(path string) (*Session, error) {
 	}
 
 	var session Session
-	if err := json.Unmarshal(data, &session); err != nil {
-		return nil, err
+	if err := json.Unmarshal(data, &session); err == nil {
+		return &session, nil
 	}
-	return &session, nil
+	return parseNDJSON(data)
+}
+
+// parseNDJSON reads a header line followed by entry lines. A truncated last
+// line (from a crash mid-write) is skipped.
+func parseNDJSON(data []byte) (*Session, error) {
+	session := &Session{Entries: make([]Entry, 0)}
+	lines := bytes.Split(data, []byte("\n"))
+	for i, line := range lines {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		if i == 0 {
+			var header sessionHeader
+			if err := json.Unmarshal(line, &header); err != nil {
+				return nil, fmt.Errorf("not a session file: %w", err)
+			}
+			session.ProjectDir = header.ProjectDir
+			session.StartTime = header.StartTime
+			continue
+		}
+		var entry Entry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			if i == len(lines)-1 { // Unterminated last line
+				break
+			}
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		session.Entries = append(session.Entries, entry)
+	}
+	return session, nil
 }
 
 // Playback represents a session ready for replay
@@ -214,8 +402,14 @@ func IsSessionIncomplete(session *Session) bool {
 		return false
 	}
 
+	return LooksLikeNarratedAction(lastAssistant.Content)
+}
+
+// LooksLikeNarratedAction reports whether an assistant reply describes or
+// shows an action (intent phrases, code blocks) instead of performing it
+func LooksLikeNarratedAction(reply string) bool {
 	// Check if it contains intent phrases suggesting incomplete work
-	content := strings.ToLower(lastAssistant.Content)
+	content := strings.ToLower(reply)
 	intentPhrases := []string{
 		"let's create", "let's write", "let's add", "let's update",
 		"let me create", "let me write", "let me add", "let me update",
@@ -230,7 +424,7 @@ func IsSessionIncomplete(session *Session) bool {
 	}
 
 	// Check for markdown code blocks (model showed code instead of writing)
-	if strings.Contains(lastAssistant.Content, "```") {
+	if strings.Contains(reply, "```") {
 		return true
 	}
 
@@ -0,0 +1,359 @@
+package session
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRecorderConcurrentRecords(t *testing.T) {
+	r := NewRecorder(t.TempDir())
+	const writers, perWriter = 8, 50
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWriter; i++ {
+				r.RecordToolCall("run_command", fmt.Sprintf(`{"writer":%d,"i":%d}`, w, i))
+				r.LastToolCall()
+				r.LastAssistant()
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	s, err := LoadSession(r.SessionPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := len(s.Entries); got != writers*perWriter {
+		t.Fatalf("loaded %d entries, want %d", got, writers*perWriter)
+	}
+	// Each writer's entries are on disk in the order it recorded them
+	next := make(map[string]int)
+	for _, e := range s.Entries {
+		var w, i int
+		if _, err := fmt.Sscanf(e.ToolArgs, `{"writer":%d,"i":%d}`, &w, &i); err != nil {
+			t.Fatalf("malformed entry %q: %v", e.ToolArgs, err)
+		}
+		key := fmt.Sprint(w)
+		if i != next[key] {
+			t.Fatalf("writer %d: entry %d after %d", w, i, next[key]-1)
+		}
+		next[key]++
+	}
+}
+
+func TestLoadSession(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		entries int
+	}{
+		{"ndjson", `{"project_dir":"/p","start_time":"2026-10-16T10:00:00Z"}` + "\n" +
+			`{"type":"user","content":"hi","timestamp":"2026-10-16T10:00:01Z"}` + "\n", 1},
+		{"truncated last line", `{"project_dir":"/p","start_time":"2026-10-16T10:00:00Z"}` + "\n" +
+			`{"type":"user","content":"hi","timestamp":"2026-10-16T10:00:01Z"}` + "\n" + `{"type":"assis`, 1},
+		{"single document", `{"project_dir":"/p","start_time":"2026-10-16T10:00:00Z","entries":[` +
+			`{"type":"user","content":"hi","timestamp":"2026-10-16T10:00:01Z"},` +
+			`{"type":"assistant","content":"hello","timestamp":"2026-10-16T10:00:02Z"}]}`, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "session.jsonl")
+			os.WriteFile(path, []byte(tt.data), 0644)
+			s, err := LoadSession(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s.ProjectDir != "/p" || len(s.Entries) != tt.entries {
+				t.Errorf("loaded %q with %d entries, want /p with %d", s.ProjectDir, len(s.Entries), tt.entries)
+			}
+		})
+	}
+}
+
+func TestRecorderLastEntries(t *testing.T) {
+	r := NewRecorder(t.TempDir())
+	if r.LastToolCall() != nil || r.LastAssistant() != "" {
+		t.Fatal("empty recorder returned entries")
+	}
+	r.RecordUser("fix it")
+	r.RecordToolCall("read_file", `{"path":"a.go"}`)
+	r.RecordToolResult("read_file", "package a")
+	r.RecordAssistant("Found it.")
+	r.RecordToolCall("write_file", `{"path":"a.go"}`)
+	r.RecordAssistant("  \n")
+
+	if last := r.LastToolCall(); last == nil || last.ToolName != "write_file" {
+		t.Errorf("LastToolCall = %+v, want write_file", last)
+	}
+	if got := r.LastAssistant(); got != "Found it." {
+		t.Errorf("LastAssistant = %q, want the last non-empty reply", got)
+	}
+}
+
+func TestEnsureGitignore(t *testing.T) {
+	tests := []struct {
+		name      string
+		existing  *string // nil: no .gitignore
+		want      string
+		wantAdded bool
+	}{
+		{"no file", nil, ".aicli/\n", true},
+		{"empty file", ptr(""), ".aicli/\n", true},
+		{"no trailing newline", ptr("bin"), "bin\n.aicli/\n", true},
+		{"other entries", ptr("bin/\n*.log\n"), "bin/\n*.log\n.aicli/\n", true},
+		{"already listed", ptr("bin/\n.aicli/\n"), "bin/\n.aicli/\n", false},
+		{"listed without slash", ptr(".aicli\n"), ".aicli\n", false},
+		{"listed anchored", ptr("  /.aicli/  \n"), "  /.aicli/  \n", false},
+		{"similar name", ptr(".aicli-cache\n"), ".aicli-cache\n.aicli/\n", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, ".gitignore")
+			if tt.existing != nil {
+				os.WriteFile(path, []byte(*tt.existing), 0644)
+			}
+			added, err := EnsureGitignore(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, _ := os.ReadFile(path)
+			if added != tt.wantAdded || string(data) != tt.want {
+				t.Errorf("EnsureGitignore = %v with %q, want %v with %q", added, data, tt.wantAdded, tt.want)
+			}
+		})
+	}
+}
+
+func ptr(s string) *string { return &s }
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "TODOS.md")
+	if err := WriteFileAtomic(path, []byte("old"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteFileAtomic(path, []byte("new"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "new" {
+		t.Errorf("content = %q, want the replacement", data)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0644 {
+		t.Errorf("mode = %v, want 0644", info.Mode().Perm())
+	}
+
+	// A write that can't complete leaves the old file whole
+	blocked := filepath.Join(dir, "blocked")
+	os.MkdirAll(filepath.Join(blocked, "child"), 0755)
+	if err := WriteFileAtomic(blocked, []byte("new"), 0644); err == nil {
+		t.Error("renaming over a non-empty directory succeeded")
+	}
+	if err := WriteFileAtomic(filepath.Join(dir, "missing", "f"), []byte("x"), 0644); err == nil {
+		t.Error("writing into a missing directory succeeded")
+	}
+	assertNoTempFiles(t, dir)
+}
+
+// assertNoTempFiles fails if an atomic write left a temp file in dir
+func assertNoTempFiles(t *testing.T, dir string) {
+	t.Helper()
+	entries, _ := os.ReadDir(dir)
+	for _, e := range entries {
+		if strings.Contains(e.Name(), ".tmp") {
+			t.Errorf("temp file %s left behind", e.Name())
+		}
+	}
+}
+
+// TestProjectFilesWrittenAtomically checks each project file is replaced by
+// rename (a new inode) rather than rewritten in place, where an interrupt
+// could leave it truncated
+func TestProjectFilesWrittenAtomically(t *testing.T) {
+	tests := []struct {
+		name  string
+		file  string
+		write func(dir string, i int)
+	}{
+		{"todos", "TODOS.md", func(dir string, i int) {
+			NewTodoFile(dir).AddTodo(fmt.Sprintf("todo %d", i))
+		}},
+		{"changelog", "CHANGELOG.md", func(dir string, i int) {
+			NewChangelogFile(dir).AddEntry("Added", fmt.Sprintf("entry %d", i), nil)
+		}},
+		{"history", "HISTORY.md", func(dir string, i int) {
+			NewHistoryFile(dir).AddRequest(fmt.Sprintf("request %d", i))
+		}},
+		{"memory", filepath.Join(".aicli", "memory.md"), func(dir string, i int) {
+			NewMemoryFile(dir).Add(fmt.Sprintf("fact %d", i))
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, tt.file)
+			tt.write(dir, 1)
+			before, err := os.Stat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.write(dir, 2)
+			after, err := os.Stat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if os.SameFile(before, after) {
+				t.Errorf("%s was rewritten in place", tt.file)
+			}
+			if data, _ := os.ReadFile(path); !strings.Contains(string(data), "2") {
+				t.Errorf("%s = %q, want the second write", tt.file, data)
+			}
+			assertNoTempFiles(t, filepath.Dir(path))
+		})
+	}
+}
+
+func TestListSessions(t *testing.T) {
+	dir := t.TempDir()
+	if got, err := ListSessions(dir); err != nil || got != nil {
+		t.Fatalf("ListSessions without .aicli = %v, %v", got, err)
+	}
+
+	aicliDir := filepath.Join(dir, ".aicli")
+	if err := os.MkdirAll(filepath.Join(aicliDir, "session_dir.json"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"session_1.jsonl", "session_2.json", "plan.json", "cache.jsonl", "session_3.txt"} {
+		if err := os.WriteFile(filepath.Join(aicliDir, name), []byte("{}"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := ListSessions(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, p := range got {
+		names = append(names, filepath.Base(p))
+	}
+	if strings.Join(names, ",") != "session_1.jsonl,session_2.json" {
+		t.Errorf("ListSessions = %v, want only the session files", names)
+	}
+}
+
+func TestRecorderNDJSONRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	r := NewRecorder(dir)
+	if err := r.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if s, err := LoadSession(r.SessionPath()); err != nil || len(s.Entries) != 0 || s.ProjectDir != dir {
+		t.Fatalf("flushed empty session loaded as %+v, %v", s, err)
+	}
+
+	r.RecordUser("fix the build\nplease")
+	r.RecordToolCall("run_command", `{"command":"go build ./..."}`)
+	r.RecordToolResult("run_command", "ok")
+	r.RecordAssistant("Fixed.")
+
+	data, err := os.ReadFile(r.SessionPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if len(lines) != 5 {
+		t.Fatalf("session file has %d lines, want a header and 4 entries:\n%s", len(lines), data)
+	}
+	for i, line := range lines {
+		if !json.Valid([]byte(line)) {
+			t.Errorf("line %d is not a JSON value: %s", i+1, line)
+		}
+	}
+
+	s, err := LoadSession(r.SessionPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Entry{
+		{Type: "user", Content: "fix the build\nplease"},
+		{Type: "tool_call", ToolName: "run_command", ToolArgs: `{"command":"go build ./..."}`},
+		{Type: "tool_result", ToolName: "run_command", Content: "ok"},
+		{Type: "assistant", Content: "Fixed."},
+	}
+	if len(s.Entries) != len(want) {
+		t.Fatalf("loaded %d entries, want %d", len(s.Entries), len(want))
+	}
+	for i, e := range s.Entries {
+		if e.Timestamp.IsZero() {
+			t.Errorf("entry %d has no timestamp", i)
+		}
+		e.Timestamp = time.Time{}
+		if e != want[i] {
+			t.Errorf("entry %d = %+v, want %+v", i, e, want[i])
+		}
+	}
+
+	// The pretty export is the single-document format and loads the same
+	exported := filepath.Join(dir, "export.json")
+	if err := r.Export(exported); err != nil {
+		t.Fatal(err)
+	}
+	es, err := LoadSession(exported)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(es.Entries) != len(want) || es.Entries[3].Content != "Fixed." || es.ProjectDir != dir {
+		t.Errorf("exported session loaded as %+v", es)
+	}
+}
+
+func TestRecorderAppendsOnly(t *testing.T) {
+	r := NewRecorder(t.TempDir())
+	content := strings.Repeat("x", 1024)
+	r.RecordUser(content)
+	first, err := os.ReadFile(r.SessionPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Recording a long session rewrites nothing already on disk, so each
+	// entry costs the same; marshalling the whole session per entry would
+	// write over 10GB here
+	const n = 5000
+	start := time.Now()
+	for i := 1; i < n; i++ {
+		r.RecordAssistant(content)
+	}
+	if elapsed := time.Since(start); elapsed > 20*time.Second {
+		t.Errorf("recording %d entries took %v", n, elapsed)
+	}
+
+	data, err := os.ReadFile(r.SessionPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(data, first) {
+		t.Error("earlier entries were rewritten")
+	}
+	if got := bytes.Count(data, []byte("\n")); got != n+1 {
+		t.Errorf("session file has %d lines, want %d", got, n+1)
+	}
+	s, err := LoadSession(r.SessionPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Entries) != n {
+		t.Errorf("loaded %d entries, want %d", len(s.Entries), n)
+	}
+}
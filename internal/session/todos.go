@@ -13,6 +13,8 @@ import (
 type TodoItem struct {
 	Content   string
 	Status    string // "pending", "in_progress", "completed"
+	Command   string // Command whose success completes this todo (empty if none)
+	Blocking  bool   // Error-fix todo that must be done before other work
 	CreatedAt time.Time
 }
 
@@ -20,6 +22,7 @@ type TodoFile struct {
 	projectDir string
 	filePath   string
 	items      []TodoItem
+	modTime    time.Time // Of the file when last loaded or saved
 }
 
 // NewTodoFile creates or loads a TODOS.md file in the project root
@@ -36,65 +39,159 @@ func NewTodoFile(projectDir string) *TodoFile {
 
 // AddTodo adds a new pending todo item
 func (tf *TodoFile) AddTodo(content string) {
-	// Don't add duplicates
-	for _, item := range tf.items {
-		if item.Content == content && item.Status != "completed" {
+	tf.AddCommandTodo(content, commandFromContent(content))
+}
+
+// AddCommandTodo adds a new pending todo item that is completed when the
+// given command succeeds
+func (tf *TodoFile) AddCommandTodo(content, command string) {
+	tf.addItem(TodoItem{Content: content, Command: command})
+}
+
+// AddBlockingTodo adds an error-fix todo that must be done before other work.
+// It is completed when the given command (if any) succeeds.
+func (tf *TodoFile) AddBlockingTodo(content, command string) {
+	tf.addItem(TodoItem{Content: content, Command: command, Blocking: true})
+}
+
+// addItem prepends a new pending item unless an identical one is still open
+func (tf *TodoFile) addItem(item TodoItem) {
+	tf.update(func() {
+		// Don't add duplicates
+		for _, existing := range tf.items {
+			if existing.Content == item.Content && existing.Status != "completed" {
+				return
+			}
+		}
+		item.Status = "pending"
+		item.Command = strings.TrimSpace(item.Command)
+		item.CreatedAt = time.Now()
+		tf.items = append([]TodoItem{item}, tf.items...)
+	})
+}
+
+// update applies fn under the TODOS.md lock and saves. If another run
+// changed the file since it was last loaded or saved, it is reloaded first
+// so that run's changes are kept.
+func (tf *TodoFile) update(fn func()) {
+	unlock := lockFile(tf.projectDir, "TODOS.md")
+	defer unlock()
+	if !modTime(tf.filePath).Equal(tf.modTime) {
+		tf.Load()
+	}
+	fn()
+	tf.write()
+}
+
+// updateAt is update for the item at index, which is found again by
+// content if a reload moved it
+func (tf *TodoFile) updateAt(index int, fn func(i int)) {
+	if index < 0 || index >= len(tf.items) {
+		return
+	}
+	content := tf.items[index].Content
+	tf.update(func() {
+		if index < len(tf.items) && tf.items[index].Content == content {
+			fn(index)
 			return
 		}
+		for i, item := range tf.items {
+			if item.Content == content {
+				fn(i)
+				return
+			}
+		}
+	})
+}
+
+// CompleteCommand marks every open todo created for exactly the given
+// command as completed. Returns true if a todo was completed.
+func (tf *TodoFile) CompleteCommand(command string) bool {
+	command = strings.TrimSpace(command)
+	if command == "" {
+		return false
+	}
+	completed := false
+	tf.update(func() {
+		for i, item := range tf.items {
+			if item.Status != "pending" && item.Status != "in_progress" {
+				continue
+			}
+			if item.Command == command {
+				tf.items[i].Status = "completed"
+				completed = true
+			}
+		}
+	})
+	return completed
+}
+
+// commandFromContent recovers the command of a todo written as
+// "Run: <cmd>" or "Then re-run: <cmd>", for todos loaded from TODOS.md
+func commandFromContent(content string) string {
+	for _, prefix := range []string{"Run: ", "Then re-run: "} {
+		if strings.HasPrefix(content, prefix) {
+			return strings.TrimSpace(strings.TrimPrefix(content, prefix))
+		}
 	}
-	tf.items = append([]TodoItem{{
-		Content:   content,
-		Status:    "pending",
-		CreatedAt: time.Now(),
-	}}, tf.items...)
-	tf.Save()
+	return ""
 }
 
 // SetInProgress marks a todo as in progress by index
 func (tf *TodoFile) SetInProgress(index int) {
-	if index >= 0 && index < len(tf.items) {
-		tf.items[index].Status = "in_progress"
-		tf.Save()
-	}
+	tf.updateAt(index, func(i int) {
+		tf.items[i].Status = "in_progress"
+	})
 }
 
 // Complete marks a todo as completed by index
 func (tf *TodoFile) Complete(index int) {
-	if index >= 0 && index < len(tf.items) {
-		tf.items[index].Status = "completed"
-		tf.Save()
-	}
+	tf.updateAt(index, func(i int) {
+		tf.items[i].Status = "completed"
+	})
 }
 
-// CompleteByContent marks the first matching todo as completed
-func (tf *TodoFile) CompleteByContent(substr string) {
-	for i, item := range tf.items {
-		if strings.Contains(item.Content, substr) && item.Status != "completed" {
-			tf.items[i].Status = "completed"
-			tf.Save()
-			return
+// CompleteByContent marks the first matching todo as completed and returns
+// its content, or "" if no open todo matches
+func (tf *TodoFile) CompleteByContent(substr string) string {
+	content := ""
+	tf.update(func() {
+		for i, item := range tf.items {
+			if strings.Contains(item.Content, substr) && item.Status != "completed" {
+				tf.items[i].Status = "completed"
+				content = item.Content
+				return
+			}
 		}
-	}
+	})
+	return content
 }
 
 // Remove removes a todo by index
 func (tf *TodoFile) Remove(index int) {
-	if index >= 0 && index < len(tf.items) {
-		tf.items = append(tf.items[:index], tf.items[index+1:]...)
-		tf.Save()
-	}
+	tf.updateAt(index, func(i int) {
+		tf.items = append(tf.items[:i], tf.items[i+1:]...)
+	})
 }
 
 // RemoveByContent removes todos containing the given substring
 func (tf *TodoFile) RemoveByContent(substr string) {
-	filtered := tf.items[:0]
-	for _, item := range tf.items {
-		if !strings.Contains(item.Content, substr) {
-			filtered = append(filtered, item)
+	tf.removeIf(func(item TodoItem) bool {
+		return strings.Contains(item.Content, substr)
+	})
+}
+
+// removeIf removes the todos matching drop
+func (tf *TodoFile) removeIf(drop func(TodoItem) bool) {
+	tf.update(func() {
+		filtered := tf.items[:0]
+		for _, item := range tf.items {
+			if !drop(item) {
+				filtered = append(filtered, item)
+			}
 		}
-	}
-	tf.items = filtered
-	tf.Save()
+		tf.items = filtered
+	})
 }
 
 // GetPending returns all pending and in_progress items
@@ -108,6 +205,17 @@ func (tf *TodoFile) GetPending() []TodoItem {
 	return pending
 }
 
+// GetBlocking returns the pending and in_progress error-fix todos
+func (tf *TodoFile) GetBlocking() []TodoItem {
+	var blocking []TodoItem
+	for _, item := range tf.GetPending() {
+		if item.Blocking {
+			blocking = append(blocking, item)
+		}
+	}
+	return blocking
+}
+
 // GetAll returns all items
 func (tf *TodoFile) GetAll() []TodoItem {
 	return tf.items
@@ -115,36 +223,49 @@ func (tf *TodoFile) GetAll() []TodoItem {
 
 // Clear removes all todos
 func (tf *TodoFile) Clear() {
-	tf.items = make([]TodoItem, 0)
-	tf.Save()
+	tf.update(func() {
+		tf.items = make([]TodoItem, 0)
+	})
+}
+
+// ClearBlocking removes all error-fix todos, leaving regular todos alone
+func (tf *TodoFile) ClearBlocking() {
+	tf.removeIf(func(item TodoItem) bool {
+		return item.Blocking
+	})
 }
 
 // ClearCompleted removes only completed todos
 func (tf *TodoFile) ClearCompleted() {
-	filtered := tf.items[:0]
-	for _, item := range tf.items {
-		if item.Status != "completed" {
-			filtered = append(filtered, item)
-		}
-	}
-	tf.items = filtered
-	tf.Save()
+	tf.removeIf(func(item TodoItem) bool {
+		return item.Status == "completed"
+	})
 }
 
 // PopFirst removes and returns the first pending/in_progress item
 func (tf *TodoFile) PopFirst() string {
-	for i, item := range tf.items {
-		if item.Status == "pending" || item.Status == "in_progress" {
-			tf.items[i].Status = "completed"
-			tf.Save()
-			return item.Content
+	content := ""
+	tf.update(func() {
+		for i, item := range tf.items {
+			if item.Status == "pending" || item.Status == "in_progress" {
+				tf.items[i].Status = "completed"
+				content = item.Content
+				return
+			}
 		}
-	}
-	return ""
+	})
+	return content
 }
 
 // Save writes the todos to TODOS.md in GitHub-flavored markdown
 func (tf *TodoFile) Save() error {
+	unlock := lockFile(tf.projectDir, "TODOS.md")
+	defer unlock()
+	return tf.write()
+}
+
+// write renders the todos to TODOS.md; the caller holds the lock
+func (tf *TodoFile) write() error {
 	var sb strings.Builder
 	sb.WriteString("# AICLI Todos\n\n")
 
@@ -165,7 +286,7 @@ func (tf *TodoFile) Save() error {
 	if len(inProgress) > 0 {
 		sb.WriteString("## In Progress\n\n")
 		for _, item := range inProgress {
-			sb.WriteString(fmt.Sprintf("- [ ] %s\n", item.Content))
+			sb.WriteString(fmt.Sprintf("- [ ] %s%s\n", item.Content, itemSuffix(item)))
 		}
 		sb.WriteString("\n")
 	}
@@ -174,7 +295,7 @@ func (tf *TodoFile) Save() error {
 	if len(pending) > 0 {
 		sb.WriteString("## Pending\n\n")
 		for _, item := range pending {
-			sb.WriteString(fmt.Sprintf("- [ ] %s\n", item.Content))
+			sb.WriteString(fmt.Sprintf("- [ ] %s%s\n", item.Content, itemSuffix(item)))
 		}
 		sb.WriteString("\n")
 	}
@@ -184,7 +305,7 @@ func (tf *TodoFile) Save() error {
 		sb.WriteString("## Completed\n\n")
 		for _, item := range completed {
 			dateStr := item.CreatedAt.Format("2006-01-02")
-			sb.WriteString(fmt.Sprintf("- [x] %s *(completed %s)*\n", item.Content, dateStr))
+			sb.WriteString(fmt.Sprintf("- [x] %s *(completed %s)*%s\n", item.Content, dateStr, itemSuffix(item)))
 		}
 		sb.WriteString("\n")
 	}
@@ -193,66 +314,123 @@ func (tf *TodoFile) Save() error {
 	if len(tf.items) == 0 {
 		// Remove file if no todos
 		os.Remove(tf.filePath)
+		tf.modTime = time.Time{}
 		return nil
 	}
 
-	return os.WriteFile(tf.filePath, []byte(sb.String()), 0644)
+	if err := WriteFileAtomic(tf.filePath, []byte(sb.String()), 0644); err != nil {
+		return err
+	}
+	tf.modTime = modTime(tf.filePath)
+	return nil
 }
 
+// itemSuffix renders an item's metadata (blocking flag, creation time) as
+// markdown comments, so it survives a save/load round trip without showing
+// in rendered markdown
+func itemSuffix(item TodoItem) string {
+	suffix := ""
+	if item.Blocking {
+		suffix += " <!-- blocking -->"
+	}
+	if !item.CreatedAt.IsZero() {
+		suffix += fmt.Sprintf(" <!-- created %s -->", item.CreatedAt.UTC().Format(time.RFC3339))
+	}
+	return suffix
+}
+
+var (
+	// todoRegex matches todo items: - [ ] content, - [x] content (also * bullets, [X])
+	todoRegex = regexp.MustCompile(`^[-*]\s+\[([ xX])\]\s+(.+)$`)
+	// createdRegex matches the creation timestamp suffix written by Save
+	createdRegex = regexp.MustCompile(`\s*<!--\s*created:?\s*(\S+)\s*-->\s*$`)
+	// blockingRegex matches the blocking marker written by Save
+	blockingRegex = regexp.MustCompile(`\s*<!--\s*blocking\s*-->\s*$`)
+	// completedRegex matches the completion date suffix written by Save
+	completedRegex = regexp.MustCompile(`\s*\*\(completed [\d-]+\)\*\s*$`)
+)
+
 // Load reads todos from TODOS.md
 func (tf *TodoFile) Load() error {
 	file, err := os.Open(tf.filePath)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return nil // No file yet, that's OK
+			// No file yet, or another run removed the last todo
+			tf.items = make([]TodoItem, 0)
+			tf.modTime = time.Time{}
+			return nil
 		}
 		return err
 	}
 	defer file.Close()
 
+	// Items without a persisted timestamp (older or hand-edited files)
+	// fall back to the file's modification time
+	fallbackTime := time.Now()
+	if info, err := file.Stat(); err == nil {
+		fallbackTime = info.ModTime()
+		tf.modTime = info.ModTime()
+	}
+
 	tf.items = make([]TodoItem, 0)
 	scanner := bufio.NewScanner(file)
 
-	// Regex to match todo items: - [ ] content or - [x] content
-	todoRegex := regexp.MustCompile(`^-\s+\[([ x])\]\s+(.+?)(?:\s*\*\(completed [\d-]+\)\*)?$`)
-
 	currentSection := ""
 	for scanner.Scan() {
 		line := strings.TrimSpace(scanner.Text())
 
 		// Detect section headers
-		if strings.HasPrefix(line, "## In Progress") {
-			currentSection = "in_progress"
-			continue
-		} else if strings.HasPrefix(line, "## Pending") {
-			currentSection = "pending"
-			continue
-		} else if strings.HasPrefix(line, "## Completed") {
-			currentSection = "completed"
+		if strings.HasPrefix(line, "#") {
+			header := strings.ToLower(strings.TrimSpace(strings.TrimLeft(line, "#")))
+			switch {
+			case strings.HasPrefix(header, "in progress"):
+				currentSection = "in_progress"
+			case strings.HasPrefix(header, "pending"):
+				currentSection = "pending"
+			case strings.HasPrefix(header, "completed"):
+				currentSection = "completed"
+			}
 			continue
 		}
 
 		// Parse todo items
-		if matches := todoRegex.FindStringSubmatch(line); matches != nil {
-			checkbox := matches[1]
-			content := matches[2]
-
-			status := currentSection
-			if status == "" {
-				// Fallback to checkbox state
-				if checkbox == "x" {
-					status = "completed"
-				} else {
-					status = "pending"
-				}
+		matches := todoRegex.FindStringSubmatch(line)
+		if matches == nil {
+			continue
+		}
+		checked := matches[1] != " "
+		content := matches[2]
+
+		createdAt := fallbackTime
+		if m := createdRegex.FindStringSubmatch(content); m != nil {
+			if t, err := time.Parse(time.RFC3339, m[1]); err == nil {
+				createdAt = t
 			}
+			content = createdRegex.ReplaceAllString(content, "")
+		}
+		blocking := blockingRegex.MatchString(content)
+		content = blockingRegex.ReplaceAllString(content, "")
+		content = strings.TrimSpace(completedRegex.ReplaceAllString(content, ""))
+		if content == "" {
+			continue
+		}
 
-			tf.items = append(tf.items, TodoItem{
-				Content:   content,
-				Status:    status,
-				CreatedAt: time.Now(), // We don't persist creation time in markdown
-			})
+		// The checkbox is authoritative; the section only distinguishes
+		// in-progress from pending for unchecked items
+		status := "pending"
+		if checked {
+			status = "completed"
+		} else if currentSection == "in_progress" {
+			status = "in_progress"
 		}
+
+		tf.items = append(tf.items, TodoItem{
+			Content:   content,
+			Status:    status,
+			Command:   commandFromContent(content),
+			Blocking:  blocking,
+			CreatedAt: createdAt,
+		})
 	}
 
 	return scanner.Err()
@@ -0,0 +1,208 @@
+package session
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCompleteCommand(t *testing.T) {
+	tests := []struct {
+		name     string
+		setup    func(tf *TodoFile)
+		command  string
+		want     bool
+		blocking int // Blocking todos left open
+	}{
+		{
+			name: "fix todo behind a plain todo",
+			setup: func(tf *TodoFile) {
+				tf.AddBlockingTodo("Then re-run: go test ./...", "go test ./...")
+				tf.AddTodo("Write the docs")
+			},
+			command: "go test ./...",
+			want:    true,
+		},
+		{
+			name: "different command",
+			setup: func(tf *TodoFile) {
+				tf.AddBlockingTodo("Then re-run: go build", "go build")
+			},
+			command:  "go test ./...",
+			want:     false,
+			blocking: 1,
+		},
+		{
+			name: "every todo for the command",
+			setup: func(tf *TodoFile) {
+				tf.AddBlockingTodo("Fix the failing test", "make test")
+				tf.AddCommandTodo("Run: make test", "make test")
+			},
+			command: " make test ",
+			want:    true,
+		},
+		{
+			// The old substring heuristic completed these
+			name: "command is a prefix of the todo's",
+			setup: func(tf *TodoFile) {
+				tf.AddBlockingTodo("Then re-run: go test ./...", "go test ./...")
+			},
+			command:  "go test",
+			want:     false,
+			blocking: 1,
+		},
+		{
+			name: "todo's command is inside the command",
+			setup: func(tf *TodoFile) {
+				tf.AddBlockingTodo("Run: make", "make")
+			},
+			command:  "cmake .. && make install",
+			want:     false,
+			blocking: 1,
+		},
+		{
+			name: "matching text without a command",
+			setup: func(tf *TodoFile) {
+				tf.AddBlockingTodo("Fix: go vet reports a copied lock", "")
+			},
+			command:  "go vet",
+			want:     false,
+			blocking: 1,
+		},
+		{
+			name: "empty command",
+			setup: func(tf *TodoFile) {
+				tf.AddTodo("Write the docs")
+			},
+			command: "",
+			want:    false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tf := NewTodoFile(t.TempDir())
+			tt.setup(tf)
+			if got := tf.CompleteCommand(tt.command); got != tt.want {
+				t.Errorf("CompleteCommand(%q) = %v, want %v", tt.command, got, tt.want)
+			}
+			if got := len(tf.GetBlocking()); got != tt.blocking {
+				t.Errorf("%d blocking todos open, want %d", got, tt.blocking)
+			}
+		})
+	}
+}
+
+func TestCompleteCommandAfterReload(t *testing.T) {
+	dir := t.TempDir()
+	tf := NewTodoFile(dir)
+	tf.AddBlockingTodo("Then re-run: go test ./...", "go test ./...")
+	tf.AddBlockingTodo("Fix: add the missing import", "")
+
+	// The command is recovered from the "Then re-run: " text on load
+	reloaded := NewTodoFile(dir)
+	if reloaded.CompleteCommand("go test") {
+		t.Error("CompleteCommand(\"go test\") completed a todo for \"go test ./...\"")
+	}
+	if !reloaded.CompleteCommand("go test ./...") {
+		t.Error("CompleteCommand(\"go test ./...\") completed nothing after a reload")
+	}
+	if got := reloaded.GetBlocking(); len(got) != 1 || got[0].Content != "Fix: add the missing import" {
+		t.Errorf("blocking todos = %v, want only the fix", got)
+	}
+}
+
+func TestTodoRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	tf := NewTodoFile(dir)
+	tf.AddTodo("Write the docs")
+	tf.AddBlockingTodo("Then re-run: go test ./...", "go test ./...")
+	tf.AddTodo("Add a changelog")
+	tf.AddTodo("Tag the release")
+
+	created := []time.Time{
+		time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		time.Date(2026, 2, 3, 4, 5, 6, 0, time.UTC),
+		time.Date(2026, 3, 4, 5, 6, 7, 0, time.UTC),
+		time.Date(2026, 4, 5, 6, 7, 8, 0, time.UTC),
+	}
+	for i := range tf.items {
+		tf.items[i].CreatedAt = created[i]
+	}
+	tf.items[0].Status = "in_progress" // Tag the release
+	tf.items[1].Status = "completed"   // Add a changelog
+	if err := tf.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]TodoItem{
+		"Tag the release":            {Status: "in_progress", CreatedAt: created[0]},
+		"Add a changelog":            {Status: "completed", CreatedAt: created[1]},
+		"Then re-run: go test ./...": {Status: "pending", Command: "go test ./...", Blocking: true, CreatedAt: created[2]},
+		"Write the docs":             {Status: "pending", CreatedAt: created[3]},
+	}
+	got := NewTodoFile(dir).GetAll()
+	if len(got) != len(want) {
+		t.Fatalf("loaded %d todos, want %d: %v", len(got), len(want), got)
+	}
+	for _, item := range got {
+		w, ok := want[item.Content]
+		if !ok {
+			t.Errorf("unexpected todo %q", item.Content)
+			continue
+		}
+		if item.Status != w.Status || item.Command != w.Command || item.Blocking != w.Blocking || !item.CreatedAt.Equal(w.CreatedAt) {
+			t.Errorf("%q loaded as %+v, want %+v", item.Content, item, w)
+		}
+	}
+}
+
+func TestLoadHandEditedTodos(t *testing.T) {
+	dir := t.TempDir()
+	content := `# Todos
+
+- [ ] No header, unchecked
+* [X] Star bullet, checked
+## In progress
+- [ ] Being worked on <!-- created: 2026-05-06T07:08:09Z -->
+- [x] Checked under in progress
+### Pending
+- [ ] Run: make test <!-- blocking -->
+- [ ] Bad timestamp <!-- created yesterday -->
+- [ ]
+not a todo
+`
+	if err := os.WriteFile(filepath.Join(dir, "TODOS.md"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		content  string
+		status   string
+		command  string
+		blocking bool
+	}{
+		{"No header, unchecked", "pending", "", false},
+		{"Star bullet, checked", "completed", "", false},
+		{"Being worked on", "in_progress", "", false},
+		{"Checked under in progress", "completed", "", false},
+		{"Run: make test", "pending", "make test", true},
+		{"Bad timestamp", "pending", "", false}, // falls back to the file time
+	}
+	got := NewTodoFile(dir).GetAll()
+	if len(got) != len(tests) {
+		t.Fatalf("loaded %d todos, want %d: %v", len(got), len(tests), got)
+	}
+	for i, tt := range tests {
+		item := got[i]
+		if item.Content != tt.content || item.Status != tt.status || item.Command != tt.command || item.Blocking != tt.blocking {
+			t.Errorf("todo %d = %+v, want %q %s command %q blocking %v", i, item, tt.content, tt.status, tt.command, tt.blocking)
+		}
+		if item.CreatedAt.IsZero() {
+			t.Errorf("todo %q has no creation time", item.Content)
+		}
+	}
+	if want := time.Date(2026, 5, 6, 7, 8, 9, 0, time.UTC); !got[2].CreatedAt.Equal(want) {
+		t.Errorf("persisted creation time = %v, want %v", got[2].CreatedAt, want)
+	}
+}
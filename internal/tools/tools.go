@@ -2,6 +2,8 @@ package tools
 
 import (
 	"encoding/json"
+	"fmt"
+	"strings"
 )
 
 type Tool struct {
@@ -23,6 +25,7 @@ type ToolCall struct {
 		Name      string `json:"name"`
 		Arguments string `json:"arguments"`
 	} `json:"function"`
+	Repaired bool `json:"-"` // Arguments were truncated mid-stream and closed by RepairJSON
 }
 
 func GetTools() []Tool {
@@ -44,6 +47,52 @@ func GetTools() []Tool {
 				}`),
 			},
 		},
+		{
+			Type: "function",
+			Function: Function{
+				Name:        "run_command_tracked",
+				Description: "Execute a shell command like run_command and also report which files it created, modified or deleted (from git status before and after). Use for codemods, generators and other commands that rewrite files. The changed files are logged to the changelog.",
+				Parameters: json.RawMessage(`{
+					"type": "object",
+					"properties": {
+						"command": {
+							"type": "string",
+							"description": "The shell command to execute"
+						}
+					},
+					"required": ["command"]
+				}`),
+			},
+		},
+		{
+			Type: "function",
+			Function: Function{
+				Name:        "project_command",
+				Description: "Build, test, run or lint the project using its configured command (falls back to the language's usual command). Prefer this over guessing the command.",
+				Parameters: json.RawMessage(`{
+					"type": "object",
+					"properties": {
+						"action": {
+							"type": "string",
+							"enum": ["build", "test", "run", "lint"],
+							"description": "What to do"
+						}
+					},
+					"required": ["action"]
+				}`),
+			},
+		},
+		{
+			Type: "function",
+			Function: Function{
+				Name:        "lint",
+				Description: "Run the project's linter (configured, or detected: golangci-lint/go vet, ruff, eslint, clippy, ...) and get the findings as a list of file:line: message. Run it after making changes.",
+				Parameters: json.RawMessage(`{
+					"type": "object",
+					"properties": {}
+				}`),
+			},
+		},
 		{
 			Type: "function",
 			Function: Function{
@@ -90,13 +139,21 @@ func GetTools() []Tool {
 			Type: "function",
 			Function: Function{
 				Name:        "read_file",
-				Description: "Read the contents of a file",
+				Description: "Read the contents of a file. Large files are cut to their head and tail; read the rest with start_line and end_line.",
 				Parameters: json.RawMessage(`{
 					"type": "object",
 					"properties": {
 						"path": {
 							"type": "string",
 							"description": "File path to read"
+						},
+						"start_line": {
+							"type": "integer",
+							"description": "First line to read (1-based, optional)"
+						},
+						"end_line": {
+							"type": "integer",
+							"description": "Last line to read, inclusive (optional, default end of file)"
 						}
 					},
 					"required": ["path"]
@@ -118,6 +175,10 @@ func GetTools() []Tool {
 						"max_results": {
 							"type": "integer",
 							"description": "Maximum number of results (default 5)"
+						},
+						"page": {
+							"type": "integer",
+							"description": "Results page to return, starting at 1 (default 1). Use 2, 3, ... when earlier results weren't relevant"
 						}
 					},
 					"required": ["query"]
@@ -128,13 +189,18 @@ func GetTools() []Tool {
 			Type: "function",
 			Function: Function{
 				Name:        "fetch_url",
-				Description: "Fetch and read content from a URL",
+				Description: "Fetch and read content from a URL (HTML pages and PDFs are returned as text)",
 				Parameters: json.RawMessage(`{
 					"type": "object",
 					"properties": {
 						"url": {
 							"type": "string",
 							"description": "URL to fetch"
+						},
+						"format": {
+							"type": "string",
+							"enum": ["text", "markdown"],
+							"description": "text (default) or markdown, which keeps links as [text](url) so you can follow them"
 						}
 					},
 					"required": ["url"]
@@ -243,6 +309,31 @@ func GetTools() []Tool {
 				}`),
 			},
 		},
+		{
+			Type: "function",
+			Function: Function{
+				Name:        "git_blame",
+				Description: "Show the commit, date and author that last changed each line of a file (git blame). Use it when fixing a bug to see when and why code changed; pass a line range for large files",
+				Parameters: json.RawMessage(`{
+					"type": "object",
+					"properties": {
+						"path": {
+							"type": "string",
+							"description": "Path to the file"
+						},
+						"start_line": {
+							"type": "integer",
+							"description": "First line to blame, 1-based (default: 1)"
+						},
+						"end_line": {
+							"type": "integer",
+							"description": "Last line to blame, inclusive (default: end of file)"
+						}
+					},
+					"required": ["path"]
+				}`),
+			},
+		},
 		{
 			Type: "function",
 			Function: Function{
@@ -259,6 +350,26 @@ func GetTools() []Tool {
 				}`),
 			},
 		},
+		{
+			Type: "function",
+			Function: Function{
+				Name:        "tree",
+				Description: "Show the directory structure as an indented tree. Honors .gitignore and skips hidden files. Use this to understand how a project is laid out.",
+				Parameters: json.RawMessage(`{
+					"type": "object",
+					"properties": {
+						"path": {
+							"type": "string",
+							"description": "Directory to show, relative to the project root (default: project root)"
+						},
+						"depth": {
+							"type": "integer",
+							"description": "How many directory levels to show (default 3, max 10)"
+						}
+					}
+				}`),
+			},
+		},
 		{
 			Type: "function",
 			Function: Function{
@@ -287,7 +398,165 @@ func GetTools() []Tool {
 				}`),
 			},
 		},
+		{
+			Type: "function",
+			Function: Function{
+				Name:        "get_context",
+				Description: "Get the current implementation plan (goal, step statuses) and pending todos. Use to check what remains to be done.",
+				Parameters: json.RawMessage(`{
+					"type": "object",
+					"properties": {}
+				}`),
+			},
+		},
+		{
+			Type: "function",
+			Function: Function{
+				Name:        "add_todo",
+				Description: "Add a todo to track work still to be done across turns",
+				Parameters: json.RawMessage(`{
+					"type": "object",
+					"properties": {
+						"content": {
+							"type": "string",
+							"description": "Description of the work to do"
+						}
+					},
+					"required": ["content"]
+				}`),
+			},
+		},
+		{
+			Type: "function",
+			Function: Function{
+				Name:        "complete_todo",
+				Description: "Mark a pending todo as completed",
+				Parameters: json.RawMessage(`{
+					"type": "object",
+					"properties": {
+						"todo": {
+							"type": "string",
+							"description": "Text of the todo to complete (a unique part of it is enough)"
+						}
+					},
+					"required": ["todo"]
+				}`),
+			},
+		},
+	}
+}
+
+// ReadOnlyTools are tools that never change the project or the system and
+// so never require confirmation. The todo tools are included as lightweight
+// bookkeeping. Every other tool is treated as mutating and goes through the
+// permission checks.
+var ReadOnlyTools = map[string]bool{
+	"read_file":     true,
+	"web_search":    true,
+	"fetch_url":     true,
+	"git_status":    true,
+	"git_diff":      true,
+	"git_log":       true,
+	"git_blame":     true,
+	"list_files":    true,
+	"tree":          true,
+	"get_version":   true,
+	"get_context":   true,
+	"add_todo":      true,
+	"complete_todo": true,
+}
+
+// IsReadOnly reports whether a tool is in ReadOnlyTools
+func IsReadOnly(name string) bool {
+	return ReadOnlyTools[name]
+}
+
+// schema is the subset of a tool's JSON schema used for argument validation
+type schema struct {
+	Properties map[string]struct {
+		Type string `json:"type"`
+	} `json:"properties"`
+	Required []string `json:"required"`
+}
+
+// ValidateArgs checks a tool call's arguments against the tool's schema.
+// It reports required fields that are missing and fields whose JSON type
+// doesn't match the schema. An empty string is a value: write_file with
+// empty content truncates or creates an empty file. Unknown tools and arguments that
+// aren't valid JSON are left to the caller.
+func ValidateArgs(name, args string) error {
+	var sc schema
+	found := false
+	for _, t := range GetTools() {
+		if t.Function.Name == name {
+			if err := json.Unmarshal(t.Function.Parameters, &sc); err != nil {
+				return nil
+			}
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil
+	}
+
+	if strings.TrimSpace(args) == "" {
+		args = "{}"
+	}
+	var parsed map[string]interface{}
+	if err := json.Unmarshal([]byte(args), &parsed); err != nil {
+		return nil
+	}
+
+	var missing []string
+	for _, field := range sc.Required {
+		if v, ok := parsed[field]; !ok || v == nil {
+			missing = append(missing, field)
+		}
+	}
+
+	var wrongType []string
+	for field, v := range parsed {
+		prop, ok := sc.Properties[field]
+		if !ok || v == nil || matchesType(prop.Type, v) {
+			continue
+		}
+		wrongType = append(wrongType, fmt.Sprintf("%s (expected %s)", field, prop.Type))
 	}
+
+	var problems []string
+	if len(missing) > 0 {
+		problems = append(problems, "missing required field(s): "+strings.Join(missing, ", "))
+	}
+	if len(wrongType) > 0 {
+		problems = append(problems, "wrong type for: "+strings.Join(wrongType, ", "))
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// matchesType reports whether a decoded JSON value matches a schema type
+func matchesType(schemaType string, v interface{}) bool {
+	switch schemaType {
+	case "string":
+		_, ok := v.(string)
+		return ok
+	case "integer", "number":
+		_, ok := v.(float64)
+		return ok
+	case "boolean":
+		_, ok := v.(bool)
+		return ok
+	case "array":
+		_, ok := v.([]interface{})
+		return ok
+	case "object":
+		_, ok := v.(map[string]interface{})
+		return ok
+	}
+	return true
 }
 
 // Arguments structs for parsing
@@ -295,6 +564,10 @@ type RunCommandArgs struct {
 	Command string `json:"command"`
 }
 
+type ProjectCommandArgs struct {
+	Action string `json:"action"`
+}
+
 type WriteFileArgs struct {
 	Path    string `json:"path"`
 	Content string `json:"content"`
@@ -306,16 +579,20 @@ type WriteDocArgs struct {
 }
 
 type ReadFileArgs struct {
-	Path string `json:"path"`
+	Path      string `json:"path"`
+	StartLine int    `json:"start_line,omitempty"`
+	EndLine   int    `json:"end_line,omitempty"`
 }
 
 type WebSearchArgs struct {
 	Query      string `json:"query"`
 	MaxResults int    `json:"max_results"`
+	Page       int    `json:"page"`
 }
 
 type FetchURLArgs struct {
-	URL string `json:"url"`
+	URL    string `json:"url"`
+	Format string `json:"format"` // "text" (default) or "markdown"
 }
 
 type ScreenshotArgs struct {
@@ -340,10 +617,29 @@ type GitLogArgs struct {
 	Count int `json:"count"`
 }
 
+type GitBlameArgs struct {
+	Path      string `json:"path"`
+	StartLine int    `json:"start_line,omitempty"`
+	EndLine   int    `json:"end_line,omitempty"`
+}
+
 type ListFilesArgs struct {
 	Pattern string `json:"pattern"`
 }
 
+type TreeArgs struct {
+	Path  string `json:"path"`
+	Depth int    `json:"depth"`
+}
+
 type SetVersionArgs struct {
 	Version string `json:"version"`
 }
+
+type AddTodoArgs struct {
+	Content string `json:"content"`
+}
+
+type CompleteTodoArgs struct {
+	Todo string `json:"todo"`
+}
@@ -0,0 +1,46 @@
+package tools
+
+import "testing"
+
+func TestValidateArgs(t *testing.T) {
+	tests := []struct {
+		name, tool, args string
+		wantErr          bool
+	}{
+		{"complete", "write_file", `{"path":"a.go","content":"package a"}`, false},
+		{"empty content", "write_file", `{"path":"empty.txt","content":""}`, false},
+		{"missing content", "write_file", `{"path":"a.go"}`, true},
+		{"null content", "write_file", `{"path":"a.go","content":null}`, true},
+		{"wrong type", "write_file", `{"path":"a.go","content":42}`, true},
+		{"no args", "read_file", ``, true},
+		{"optional integer", "read_file", `{"path":"a.go","start_line":3}`, false},
+		{"integer as string", "read_file", `{"path":"a.go","start_line":"3"}`, true},
+		{"unknown tool", "no_such_tool", `{}`, false},
+		{"invalid JSON", "read_file", `{"path":`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateArgs(tt.tool, tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateArgs(%s, %s) = %v, want error %v", tt.tool, tt.args, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReadOnlyToolsExist(t *testing.T) {
+	defined := make(map[string]bool)
+	for _, tool := range GetTools() {
+		defined[tool.Function.Name] = true
+	}
+	for name := range ReadOnlyTools {
+		if !defined[name] {
+			t.Errorf("read-only tool %q is not defined in GetTools", name)
+		}
+	}
+	for _, name := range []string{"run_command", "write_file", "write_doc", "git_add", "git_commit", "set_version", "screenshot"} {
+		if IsReadOnly(name) {
+			t.Errorf("IsReadOnly(%q) = true for a mutating tool", name)
+		}
+	}
+}
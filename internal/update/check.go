@@ -0,0 +1,69 @@
+package update
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// CheckInterval is how often the background check at startup runs
+const CheckInterval = 24 * time.Hour
+
+// CheckStampPath is the file recording when the background check last ran
+func CheckStampPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", "aicli", "last_update_check"), nil
+}
+
+// CheckDue reports whether the check recorded at path is at least
+// CheckInterval old. A missing or unreadable stamp, or one in the future
+// (a changed clock), is due.
+func CheckDue(path string, now time.Time) bool {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return true
+	}
+	last, err := time.Parse(time.RFC3339, strings.TrimSpace(string(data)))
+	if err != nil || last.After(now) {
+		return true
+	}
+	return now.Sub(last) >= CheckInterval
+}
+
+// RecordCheck stores now as the time of the last check
+func RecordCheck(path string, now time.Time) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(now.UTC().Format(time.RFC3339)+"\n"), 0644)
+}
+
+// CheckInBackground checks for a newer release without blocking, at most
+// once per CheckInterval. The returned channel delivers a one-line notice
+// if an update is available and is closed when the check is done or
+// skipped; errors are ignored.
+func CheckInBackground(currentVersion, channel, token string) <-chan string {
+	notices := make(chan string, 1)
+	path, err := CheckStampPath()
+	if err != nil || !CheckDue(path, time.Now()) {
+		close(notices)
+		return notices
+	}
+	// Record the attempt up front so a failing check isn't retried on every start
+	RecordCheck(path, time.Now())
+
+	go func() {
+		defer close(notices)
+		info, err := CheckForUpdate(currentVersion, channel, token)
+		if err != nil || !IsNewerVersion(info.CurrentVersion, info.LatestVersion) {
+			return
+		}
+		notices <- fmt.Sprintf("⬆ Update available: %s → %s (run with --update to install)", info.CurrentVersion, info.LatestVersion)
+	}()
+	return notices
+}
@@ -0,0 +1,98 @@
+package update
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCheckDue(t *testing.T) {
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		stamp string // "" for no file
+		want  bool
+	}{
+		{"never checked", "", true},
+		{"checked an hour ago", now.Add(-time.Hour).Format(time.RFC3339), false},
+		{"checked just under a day ago", now.Add(-CheckInterval + time.Minute).Format(time.RFC3339), false},
+		{"checked a day ago", now.Add(-CheckInterval).Format(time.RFC3339), true},
+		{"checked last week", now.Add(-7 * 24 * time.Hour).Format(time.RFC3339), true},
+		{"stamp in the future", now.Add(time.Hour).Format(time.RFC3339), true},
+		{"garbage", "yesterday", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "last_update_check")
+			if tt.stamp != "" {
+				os.WriteFile(path, []byte(tt.stamp+"\n"), 0644)
+			}
+			if got := CheckDue(path, now); got != tt.want {
+				t.Errorf("CheckDue = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecordCheck(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config", "aicli", "last_update_check")
+	now := time.Date(2026, 10, 17, 14, 30, 0, 0, time.FixedZone("CEST", 2*3600))
+	if err := RecordCheck(path, now); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	if string(data) != "2026-10-17T12:30:00Z\n" {
+		t.Errorf("stamp = %q, want UTC RFC 3339", data)
+	}
+	if CheckDue(path, now.Add(time.Hour)) {
+		t.Error("check due an hour after it was recorded")
+	}
+	if !CheckDue(path, now.Add(CheckInterval)) {
+		t.Error("check not due a day after it was recorded")
+	}
+}
+
+func TestCheckInBackground(t *testing.T) {
+	tests := []struct {
+		name        string
+		current     string
+		stampAge    time.Duration // 0 for never checked
+		wantNotice  string
+		wantRequest bool
+	}{
+		{"newer release", "1.1.0", 0, "⬆ Update available: 1.1.0 → 1.2.0 (run with --update to install)", true},
+		{"up to date", "1.2.0", 0, "", true},
+		{"checked recently", "1.1.0", time.Hour, "", false},
+		{"checked yesterday", "1.1.0", 25 * time.Hour, "⬆ Update available: 1.1.0 → 1.2.0 (run with --update to install)", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("HOME", t.TempDir())
+			requests, done := releaseServer(t, "")
+			defer done()
+			stamp, err := CheckStampPath()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.stampAge > 0 {
+				RecordCheck(stamp, time.Now().Add(-tt.stampAge))
+			}
+
+			var notices []string
+			for notice := range CheckInBackground(tt.current, ChannelStable, "") {
+				notices = append(notices, notice)
+			}
+			if tt.wantNotice == "" && len(notices) != 0 || tt.wantNotice != "" && (len(notices) != 1 || notices[0] != tt.wantNotice) {
+				t.Errorf("notices = %q, want %q", notices, tt.wantNotice)
+			}
+			if sent := len(*requests) > 0; sent != tt.wantRequest {
+				t.Errorf("checked = %v, want %v", sent, tt.wantRequest)
+			}
+			// Every check that runs records itself, so the next start skips it
+			if CheckDue(stamp, time.Now()) {
+				t.Error("check still due afterwards")
+			}
+		})
+	}
+}
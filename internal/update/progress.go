@@ -0,0 +1,60 @@
+package update
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Download progress styles
+const (
+	ProgressBar     = "bar"     // [#######.....]  58%  4.1/7.0 MB
+	ProgressPercent = "percent" // 58%
+	ProgressNone    = "none"    // Nothing until the download ends
+)
+
+// progressBarWidth is the number of cells in the bar
+const progressBarWidth = 30
+
+// FormatProgress renders download progress in the given style (bar if
+// empty or unknown). When the total size is unknown only the amount
+// downloaded is shown.
+func FormatProgress(style string, downloaded, total int64) string {
+	style = strings.ToLower(strings.TrimSpace(style))
+	if style == ProgressNone {
+		return ""
+	}
+	if total <= 0 {
+		return fmt.Sprintf("%s downloaded", formatMB(downloaded))
+	}
+	if downloaded > total {
+		downloaded = total
+	}
+	pct := int(downloaded * 100 / total)
+	if style == ProgressPercent {
+		return fmt.Sprintf("%d%%", pct)
+	}
+	filled := int(downloaded * progressBarWidth / total)
+	bar := strings.Repeat("#", filled) + strings.Repeat(".", progressBarWidth-filled)
+	return fmt.Sprintf("[%s] %3d%%  %s/%s", bar, pct, strings.TrimSuffix(formatMB(downloaded), " MB"), formatMB(total))
+}
+
+// formatMB renders a byte count in megabytes
+func formatMB(n int64) string {
+	return fmt.Sprintf("%.1f MB", float64(n)/(1024*1024))
+}
+
+// ProgressPrinter returns an onProgress callback for DownloadAndInstall
+// that redraws "label <progress>" on one line of w, only when the
+// rendered text changes
+func ProgressPrinter(w io.Writer, style, label string) func(downloaded, total int64) {
+	last := ""
+	return func(downloaded, total int64) {
+		text := FormatProgress(style, downloaded, total)
+		if text == "" || text == last {
+			return
+		}
+		last = text
+		fmt.Fprintf(w, "\r\033[K%s %s", label, text)
+	}
+}
@@ -0,0 +1,55 @@
+package update
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFormatProgress(t *testing.T) {
+	const mb = 1024 * 1024
+	tests := []struct {
+		name              string
+		style             string
+		downloaded, total int64
+		want              string
+	}{
+		{"bar start", "bar", 0, 7 * mb, "[..............................]   0%  0.0/7.0 MB"},
+		{"bar half", "bar", 3.5 * mb, 7 * mb, "[###############...............]  50%  3.5/7.0 MB"},
+		{"bar done", "bar", 7 * mb, 7 * mb, "[##############################] 100%  7.0/7.0 MB"},
+		{"bar is the default", "", mb, 10 * mb, "[###...........................]  10%  1.0/10.0 MB"},
+		{"unknown style", "spinner", 7 * mb, 7 * mb, "[##############################] 100%  7.0/7.0 MB"},
+		{"overshoot capped", "bar", 8 * mb, 7 * mb, "[##############################] 100%  7.0/7.0 MB"},
+		{"percent", "percent", 4 * mb, 7 * mb, "57%"},
+		{"percent any case", " Percent ", 7 * mb, 7 * mb, "100%"},
+		{"none", "none", 4 * mb, 7 * mb, ""},
+		{"unknown total", "bar", 5 * mb / 2, 0, "2.5 MB downloaded"},
+		{"unknown total percent", "percent", mb, -1, "1.0 MB downloaded"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FormatProgress(tt.style, tt.downloaded, tt.total); got != tt.want {
+				t.Errorf("FormatProgress(%q, %d, %d) = %q, want %q", tt.style, tt.downloaded, tt.total, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProgressPrinter(t *testing.T) {
+	var sb strings.Builder
+	onProgress := ProgressPrinter(&sb, "percent", "Downloading...")
+	for _, n := range []int64{0, 1, 2, 50, 51, 100, 100} {
+		onProgress(n, 200)
+	}
+	// Only changes are redrawn, each over the previous line
+	want := "\r\033[KDownloading... 0%\r\033[KDownloading... 1%\r\033[KDownloading... 25%\r\033[KDownloading... 50%"
+	if got := sb.String(); got != want {
+		t.Errorf("printed %q, want %q", got, want)
+	}
+
+	sb.Reset()
+	onProgress = ProgressPrinter(&sb, "none", "Downloading...")
+	onProgress(100, 200)
+	if sb.Len() != 0 {
+		t.Errorf("style none printed %q", sb.String())
+	}
+}
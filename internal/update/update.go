@@ -21,12 +21,27 @@ const (
 	APIBaseURL  = "https://api.github.com"
 )
 
+// apiBaseURL is APIBaseURL, replaceable for tests
+var apiBaseURL = APIBaseURL
+
+// Release channels
+const (
+	ChannelStable     = "stable"     // The latest full release
+	ChannelPrerelease = "prerelease" // The newest release, pre-releases included
+)
+
+// BackupSuffix is appended to the executable path for the copy of the
+// previous version kept by DownloadAndInstall, which Rollback restores
+const BackupSuffix = ".old"
+
 // Release represents a GitHub release
 type Release struct {
 	TagName     string  `json:"tag_name"`
 	Name        string  `json:"name"`
 	Body        string  `json:"body"`
 	PublishedAt string  `json:"published_at"`
+	Prerelease  bool    `json:"prerelease"`
+	Draft       bool    `json:"draft"`
 	Assets      []Asset `json:"assets"`
 }
 
@@ -47,28 +62,14 @@ type UpdateInfo struct {
 	AssetSize      int64
 }
 
-// CheckForUpdate checks if a newer version is available
-func CheckForUpdate(currentVersion string) (*UpdateInfo, error) {
-	url := fmt.Sprintf("%s/repos/%s/%s/releases/latest", APIBaseURL, GitHubOwner, GitHubRepo)
-
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Get(url)
+// CheckForUpdate looks up the latest release on the channel (stable if
+// empty) and where to download it for this platform. A non-empty token is
+// sent to the GitHub API, which raises its rate limit. Whether the release
+// is newer than currentVersion is for the caller to check with IsNewerVersion.
+func CheckForUpdate(currentVersion, channel, token string) (*UpdateInfo, error) {
+	release, err := latestRelease(channel, token)
 	if err != nil {
-		return nil, fmt.Errorf("failed to check for updates: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode == 404 {
-		return nil, fmt.Errorf("no releases found")
-	}
-
-	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("GitHub API error: %d", resp.StatusCode)
-	}
-
-	var release Release
-	if err := json.NewDecoder(resp.Body).Decode(&release); err != nil {
-		return nil, fmt.Errorf("failed to parse release info: %w", err)
+		return nil, err
 	}
 
 	latestVersion := strings.TrimPrefix(release.TagName, "v")
@@ -101,6 +102,71 @@ func CheckForUpdate(currentVersion string) (*UpdateInfo, error) {
 	}, nil
 }
 
+// latestRelease fetches the newest release on the channel
+func latestRelease(channel, token string) (*Release, error) {
+	path := "releases/latest"
+	if channel == ChannelPrerelease {
+		path = "releases?per_page=10"
+	}
+	url := fmt.Sprintf("%s/repos/%s/%s/%s", apiBaseURL, GitHubOwner, GitHubRepo, path)
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check for updates: %w", err)
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check for updates: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == 404 {
+		return nil, fmt.Errorf("no releases found")
+	}
+
+	// GitHub answers 403 (or 429) once the hourly limit for unauthenticated
+	// requests, shared by everyone behind the same address, is used up
+	if (resp.StatusCode == 403 || resp.StatusCode == 429) && resp.Header.Get("X-RateLimit-Remaining") == "0" {
+		if token == "" {
+			return nil, fmt.Errorf("GitHub API rate limit exceeded; set GITHUB_TOKEN or github_token in the config to raise it")
+		}
+		return nil, fmt.Errorf("GitHub API rate limit exceeded for the configured token")
+	}
+	if resp.StatusCode == 401 && token != "" {
+		return nil, fmt.Errorf("GitHub rejected the token (check GITHUB_TOKEN or github_token)")
+	}
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("GitHub API error: %d", resp.StatusCode)
+	}
+
+	if channel != ChannelPrerelease {
+		var release Release
+		if err := json.NewDecoder(resp.Body).Decode(&release); err != nil {
+			return nil, fmt.Errorf("failed to parse release info: %w", err)
+		}
+		return &release, nil
+	}
+
+	// The list is newest first; drafts are only visible to maintainers
+	var releases []Release
+	if err := json.NewDecoder(resp.Body).Decode(&releases); err != nil {
+		return nil, fmt.Errorf("failed to parse release info: %w", err)
+	}
+	for i := range releases {
+		if !releases[i].Draft {
+			return &releases[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no releases found")
+}
+
 // IsNewerVersion returns true if latest is newer than current
 func IsNewerVersion(current, latest string) bool {
 	current = strings.TrimPrefix(current, "v")
@@ -147,16 +213,13 @@ func getAssetName() string {
 	}
 }
 
-// DownloadAndInstall downloads the update and installs it
+// DownloadAndInstall downloads the update and installs it, keeping the
+// previous binary next to it (BackupSuffix) for Rollback
 func DownloadAndInstall(info *UpdateInfo, onProgress func(downloaded, total int64)) error {
 	// Get the current executable path
-	execPath, err := os.Executable()
-	if err != nil {
-		return fmt.Errorf("failed to get executable path: %w", err)
-	}
-	execPath, err = filepath.EvalSymlinks(execPath)
+	execPath, err := executablePath()
 	if err != nil {
-		return fmt.Errorf("failed to resolve executable path: %w", err)
+		return err
 	}
 
 	// Download the asset to a temp file
@@ -219,8 +282,8 @@ func DownloadAndInstall(info *UpdateInfo, onProgress func(downloaded, total int6
 	defer os.Remove(newBinaryPath)
 
 	// Replace the current executable
-	// First, rename the old one as backup
-	backupPath := execPath + ".old"
+	// First, rename the old one as backup (kept for Rollback)
+	backupPath := execPath + BackupSuffix
 	os.Remove(backupPath) // Remove any existing backup
 
 	if err := os.Rename(execPath, backupPath); err != nil {
@@ -239,12 +302,58 @@ func DownloadAndInstall(info *UpdateInfo, onProgress func(downloaded, total int6
 		return fmt.Errorf("failed to set permissions: %w", err)
 	}
 
-	// Remove backup
-	os.Remove(backupPath)
-
 	return nil
 }
 
+// executablePath returns the running binary's path with symlinks resolved
+func executablePath() (string, error) {
+	execPath, err := os.Executable()
+	if err != nil {
+		return "", fmt.Errorf("failed to get executable path: %w", err)
+	}
+	execPath, err = filepath.EvalSymlinks(execPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve executable path: %w", err)
+	}
+	return execPath, nil
+}
+
+// BackupPath returns where the previous version is kept, and whether a
+// backup exists there
+func BackupPath() (string, bool) {
+	execPath, err := executablePath()
+	if err != nil {
+		return "", false
+	}
+	backupPath := execPath + BackupSuffix
+	_, err = os.Stat(backupPath)
+	return backupPath, err == nil
+}
+
+// Rollback swaps the running binary with the backup of the previous version
+// kept by DownloadAndInstall, so rolling back twice undoes the rollback
+func Rollback() error {
+	execPath, err := executablePath()
+	if err != nil {
+		return err
+	}
+	backupPath := execPath + BackupSuffix
+	if _, err := os.Stat(backupPath); err != nil {
+		return fmt.Errorf("no previous version to roll back to (%s not found)", backupPath)
+	}
+
+	swapPath := execPath + ".swap"
+	os.Remove(swapPath)
+	if err := os.Rename(execPath, swapPath); err != nil {
+		return fmt.Errorf("failed to move current binary: %w", err)
+	}
+	if err := os.Rename(backupPath, execPath); err != nil {
+		os.Rename(swapPath, execPath)
+		return fmt.Errorf("failed to restore previous binary: %w", err)
+	}
+	return os.Rename(swapPath, backupPath)
+}
+
 // extractZip extracts the aicli binary from a zip file
 func extractZip(zipPath string) (string, error) {
 	r, err := zip.OpenReader(zipPath)
@@ -0,0 +1,191 @@
+package update
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// releaseServer serves the GitHub releases API for the repo: /latest
+// answers the latest full release and the list includes pre-releases and
+// drafts, newest first. It records the request paths and headers.
+func releaseServer(t *testing.T, list string) (*[]*http.Request, func()) {
+	t.Helper()
+	asset := fmt.Sprintf(`[{"name":%q,"browser_download_url":"https://example.com/%[1]s","size":2048}]`, getAssetName())
+	latest := `{"tag_name":"v1.2.0","body":"Stable notes","assets":` + asset + `}`
+	if list == "" {
+		list = `[{"tag_name":"v1.4.0","draft":true,"assets":` + asset + `},` +
+			`{"tag_name":"v1.3.0-rc1","prerelease":true,"body":"RC notes","assets":` + asset + `},` +
+			latest + `]`
+	}
+
+	var requests []*http.Request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r)
+		switch r.URL.Path {
+		case "/repos/glennswest/aicli/releases/latest":
+			fmt.Fprint(w, latest)
+		case "/repos/glennswest/aicli/releases":
+			fmt.Fprint(w, list)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	prev := apiBaseURL
+	apiBaseURL = srv.URL
+	return &requests, func() {
+		apiBaseURL = prev
+		srv.Close()
+	}
+}
+
+func TestCheckForUpdateChannels(t *testing.T) {
+	tests := []struct {
+		channel     string
+		wantPath    string
+		wantVersion string
+		wantNotes   string
+	}{
+		{"", "/repos/glennswest/aicli/releases/latest", "1.2.0", "Stable notes"},
+		{ChannelStable, "/repos/glennswest/aicli/releases/latest", "1.2.0", "Stable notes"},
+		// The newest release that is not a draft, pre-releases included
+		{ChannelPrerelease, "/repos/glennswest/aicli/releases", "1.3.0-rc1", "RC notes"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.channel, func(t *testing.T) {
+			requests, done := releaseServer(t, "")
+			defer done()
+
+			info, err := CheckForUpdate("v1.1.0", tt.channel, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(*requests) != 1 {
+				t.Fatalf("sent %d requests, want 1", len(*requests))
+			}
+			if path := (*requests)[0].URL.Path; path != tt.wantPath {
+				t.Errorf("requested %s, want %s", path, tt.wantPath)
+			}
+			if info.LatestVersion != tt.wantVersion || info.CurrentVersion != "1.1.0" || info.ReleaseNotes != tt.wantNotes {
+				t.Errorf("info = %+v", info)
+			}
+			if info.AssetName != getAssetName() || info.DownloadURL != "https://example.com/"+getAssetName() || info.AssetSize != 2048 {
+				t.Errorf("asset = %s %s %d", info.AssetName, info.DownloadURL, info.AssetSize)
+			}
+		})
+	}
+}
+
+func TestCheckForUpdateToken(t *testing.T) {
+	requests, done := releaseServer(t, "")
+	defer done()
+
+	CheckForUpdate("1.0.0", ChannelStable, "")
+	CheckForUpdate("1.0.0", ChannelStable, "ghp_secret")
+	if got := (*requests)[0].Header.Get("Authorization"); got != "" {
+		t.Errorf("Authorization without a token = %q", got)
+	}
+	if got := (*requests)[1].Header.Get("Authorization"); got != "Bearer ghp_secret" {
+		t.Errorf("Authorization = %q, want the bearer token", got)
+	}
+	if got := (*requests)[1].Header.Get("Accept"); got != "application/vnd.github+json" {
+		t.Errorf("Accept = %q", got)
+	}
+}
+
+func TestCheckForUpdateErrors(t *testing.T) {
+	t.Run("only drafts", func(t *testing.T) {
+		_, done := releaseServer(t, `[{"tag_name":"v2.0.0","draft":true}]`)
+		defer done()
+		if _, err := CheckForUpdate("1.0.0", ChannelPrerelease, ""); err == nil || err.Error() != "no releases found" {
+			t.Errorf("err = %v, want no releases found", err)
+		}
+	})
+	t.Run("no asset for this platform", func(t *testing.T) {
+		_, done := releaseServer(t, `[{"tag_name":"v2.0.0","assets":[{"name":"aicli-plan9.zip"}]}]`)
+		defer done()
+		if _, err := CheckForUpdate("1.0.0", ChannelPrerelease, ""); err == nil || !strings.Contains(err.Error(), "no release asset found") {
+			t.Errorf("err = %v, want no release asset", err)
+		}
+	})
+}
+
+func TestIsNewerVersion(t *testing.T) {
+	tests := []struct {
+		current, latest string
+		want            bool
+	}{
+		{"1.2.0", "1.2.1", true},
+		{"v1.2.0", "v1.3.0", true},
+		{"1.9.9", "2.0.0", true},
+		{"1.2", "1.2.1", true},
+		{"1.10.0", "1.9.0", false},
+		{"1.2.0", "1.2.0", false},
+		{"v1.2.0", "1.2.0", false},
+		{"2.0.0", "1.9.9", false},
+		{"1.2.0", "1.3.0-rc1", true},
+		{"1.3.0", "1.3.0-rc1", false},
+		{"dev", "1.0.0", true},
+	}
+	for _, tt := range tests {
+		if got := IsNewerVersion(tt.current, tt.latest); got != tt.want {
+			t.Errorf("IsNewerVersion(%q, %q) = %v, want %v", tt.current, tt.latest, got, tt.want)
+		}
+	}
+}
+
+func TestCheckForUpdateAuthErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		header map[string]string
+		token  string
+		want   string
+	}{
+		{"rate limited without a token", 403, map[string]string{"X-RateLimit-Remaining": "0"}, "",
+			"GitHub API rate limit exceeded; set GITHUB_TOKEN or github_token in the config to raise it"},
+		{"rate limited with a token", 429, map[string]string{"X-RateLimit-Remaining": "0"}, "ghp_x",
+			"GitHub API rate limit exceeded for the configured token"},
+		{"forbidden for another reason", 403, nil, "", "GitHub API error: 403"},
+		{"token rejected", 401, nil, "ghp_bad", "GitHub rejected the token (check GITHUB_TOKEN or github_token)"},
+		{"unauthorized without a token", 401, nil, "", "GitHub API error: 401"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				for k, v := range tt.header {
+					w.Header().Set(k, v)
+				}
+				w.WriteHeader(tt.status)
+			}))
+			defer srv.Close()
+			prev := apiBaseURL
+			apiBaseURL = srv.URL
+			defer func() { apiBaseURL = prev }()
+
+			if _, err := CheckForUpdate("1.0.0", ChannelStable, tt.token); err == nil || err.Error() != tt.want {
+				t.Errorf("err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestTokenSentOnEveryChannel(t *testing.T) {
+	requests, done := releaseServer(t, "")
+	defer done()
+	t.Setenv("HOME", t.TempDir())
+
+	CheckForUpdate("1.0.0", ChannelPrerelease, "ghp_secret")
+	for range CheckInBackground("1.0.0", ChannelStable, "ghp_secret") {
+	}
+	if len(*requests) != 2 {
+		t.Fatalf("sent %d requests, want 2", len(*requests))
+	}
+	for _, r := range *requests {
+		if got := r.Header.Get("Authorization"); got != "Bearer ghp_secret" {
+			t.Errorf("%s: Authorization = %q", r.URL.Path, got)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package web
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+var (
+	mdLinkRegex    = regexp.MustCompile(`(?is)<a\s[^>]*?href\s*=\s*["']([^"']*)["'][^>]*>(.*?)</a>`)
+	mdHeadingRegex = regexp.MustCompile(`(?is)<h([1-6])[^>]*>(.*?)</h[1-6]>`)
+	mdCodeRegex    = regexp.MustCompile(`(?is)<code[^>]*>(.*?)</code>`)
+	mdPreRegex     = regexp.MustCompile(`(?is)<pre[^>]*>(.*?)</pre>`)
+	mdListRegex    = regexp.MustCompile(`(?i)<li[^>]*>`)
+	mdBreakRegex   = regexp.MustCompile(`(?i)<br\s*/?\s*>`)
+	mdBlockRegex   = regexp.MustCompile(`(?i)</(p|div|li|tr|ul|ol|table|section|article)>`)
+	mdTagRegex     = regexp.MustCompile(`<[^>]+>`)
+	mdScriptRegex  = regexp.MustCompile(`(?is)<(script|style|noscript)[^>]*>.*?</(script|style|noscript)>`)
+)
+
+// htmlToMarkdown converts a page to lightweight markdown, keeping links as
+// [text](url) (resolved against pageURL), headings, lists and code
+func htmlToMarkdown(html, pageURL string) string {
+	base, _ := url.Parse(pageURL)
+
+	html = mdScriptRegex.ReplaceAllString(html, "")
+
+	html = mdPreRegex.ReplaceAllStringFunc(html, func(m string) string {
+		code := mdTagRegex.ReplaceAllString(mdPreRegex.FindStringSubmatch(m)[1], "")
+		return "\n```\n" + strings.Trim(code, "\n") + "\n```\n"
+	})
+	html = mdCodeRegex.ReplaceAllStringFunc(html, func(m string) string {
+		return "`" + mdTagRegex.ReplaceAllString(mdCodeRegex.FindStringSubmatch(m)[1], "") + "`"
+	})
+
+	html = mdLinkRegex.ReplaceAllStringFunc(html, func(m string) string {
+		parts := mdLinkRegex.FindStringSubmatch(m)
+		href := cleanHTML(parts[1])
+		text := strings.Join(strings.Fields(mdTagRegex.ReplaceAllString(parts[2], "")), " ")
+		if text == "" || href == "" || strings.HasPrefix(href, "javascript:") {
+			return text
+		}
+		if base != nil {
+			if ref, err := url.Parse(href); err == nil {
+				href = base.ResolveReference(ref).String()
+			}
+		}
+		return "[" + text + "](" + href + ")"
+	})
+
+	html = mdHeadingRegex.ReplaceAllStringFunc(html, func(m string) string {
+		parts := mdHeadingRegex.FindStringSubmatch(m)
+		level := int(parts[1][0] - '0')
+		text := strings.Join(strings.Fields(mdTagRegex.ReplaceAllString(parts[2], "")), " ")
+		return "\n" + strings.Repeat("#", level) + " " + text + "\n"
+	})
+
+	html = mdListRegex.ReplaceAllString(html, "\n- ")
+	html = mdBreakRegex.ReplaceAllString(html, "\n")
+	html = mdBlockRegex.ReplaceAllString(html, "\n")
+	text := cleanHTML(mdTagRegex.ReplaceAllString(html, ""))
+
+	// Collapse whitespace, keeping code blocks as they are
+	var lines []string
+	inCode := false
+	for _, line := range strings.Split(text, "\n") {
+		if strings.TrimSpace(line) == "```" {
+			inCode = !inCode
+			lines = append(lines, "```")
+			continue
+		}
+		if inCode {
+			lines = append(lines, line)
+			continue
+		}
+		if line = strings.Join(strings.Fields(line), " "); line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	return truncateText(strings.Join(lines, "\n"))
+}
@@ -0,0 +1,58 @@
+package web
+
+import (
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files")
+
+func TestHTMLToMarkdown(t *testing.T) {
+	html, err := os.ReadFile(filepath.Join("testdata", "apiref.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := htmlToMarkdown(string(html), "https://example.com/docs/api/")
+
+	golden := filepath.Join("testdata", "apiref.md")
+	if *update {
+		os.WriteFile(golden, []byte(got), 0644)
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != string(want) {
+		t.Errorf("markdown differs from %s:\n%s", golden, got)
+	}
+}
+
+func TestFetchPageAsMarkdown(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<p>Read the <a href="/guide">guide</a>.</p>`))
+	}))
+	defer srv.Close()
+
+	w := NewSearch()
+	tests := []struct {
+		markdown bool
+		want     string
+	}{
+		{true, "Read the [guide](" + srv.URL + "/guide)."},
+		{false, "Read the guide."},
+	}
+	for _, tt := range tests {
+		got, err := w.FetchPageAs(srv.URL+"/docs/", tt.markdown)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("FetchPageAs(markdown=%v) = %q, want %q", tt.markdown, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,343 @@
+package web
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+)
+
+// isPDF reports whether a response is a PDF, by content type, URL or the
+// %PDF- magic bytes (servers often send PDFs as application/octet-stream)
+func isPDF(contentType, pageURL string, body []byte) bool {
+	if strings.Contains(strings.ToLower(contentType), "application/pdf") {
+		return true
+	}
+	path := strings.ToLower(pageURL)
+	if i := strings.IndexAny(path, "?#"); i >= 0 {
+		path = path[:i]
+	}
+	if strings.HasSuffix(path, ".pdf") {
+		return true
+	}
+	return bytes.HasPrefix(bytes.TrimSpace(body), []byte("%PDF-"))
+}
+
+// pdfToText extracts the text drawn by a PDF's content streams. It handles
+// uncompressed and FlateDecode streams with simple (single-byte or UTF-16)
+// string encodings, which covers most generated documentation. Encrypted,
+// scanned or CID-font PDFs return an error instead of garbage.
+func pdfToText(data []byte) (string, error) {
+	if !bytes.HasPrefix(bytes.TrimSpace(data), []byte("%PDF-")) {
+		return "", fmt.Errorf("not a PDF file")
+	}
+	if bytes.Contains(data, []byte("/Encrypt")) {
+		return "", fmt.Errorf("PDF is encrypted")
+	}
+
+	var sb strings.Builder
+	for _, content := range pdfContentStreams(data) {
+		sb.WriteString(pdfContentText(content))
+		sb.WriteString("\n")
+	}
+
+	var lines []string
+	for _, line := range strings.Split(sb.String(), "\n") {
+		line = strings.Join(strings.Fields(line), " ")
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	text := strings.Join(lines, "\n")
+
+	if text == "" {
+		return "", fmt.Errorf("no extractable text (the PDF may be scanned images)")
+	}
+	if !mostlyPrintable(text) {
+		return "", fmt.Errorf("text uses an embedded font encoding that can't be decoded")
+	}
+	return text, nil
+}
+
+// maxPDFInflated caps the decompressed size of all content streams of a
+// PDF together, so a small file inflating to gigabytes (a zip bomb) can't
+// exhaust memory. Text content is far below this.
+const maxPDFInflated = 32 << 20
+
+// pdfContentStreams returns the decoded bodies of all streams that may hold
+// page content, skipping images, fonts and cross-reference data
+func pdfContentStreams(data []byte) [][]byte {
+	var streams [][]byte
+	budget := int64(maxPDFInflated)
+	pos := 0
+	for {
+		i := bytes.Index(data[pos:], []byte("stream"))
+		if i < 0 {
+			break
+		}
+		start := pos + i
+		pos = start + len("stream")
+
+		// Skip "endstream" and require the keyword to end the line
+		if start >= 3 && string(data[start-3:start]) == "end" {
+			continue
+		}
+		bodyStart := pos
+		if bodyStart < len(data) && data[bodyStart] == '\r' {
+			bodyStart++
+		}
+		if bodyStart >= len(data) || data[bodyStart] != '\n' {
+			continue
+		}
+		bodyStart++
+
+		end := bytes.Index(data[bodyStart:], []byte("endstream"))
+		if end < 0 {
+			break
+		}
+		body := data[bodyStart : bodyStart+end]
+		pos = bodyStart + end + len("endstream")
+
+		dict := data[:start]
+		if d := bytes.LastIndex(dict, []byte("obj")); d >= 0 {
+			dict = dict[d:]
+		}
+		if bytes.Contains(dict, []byte("/Image")) || bytes.Contains(dict, []byte("/FontFile")) ||
+			bytes.Contains(dict, []byte("/Length1")) || bytes.Contains(dict, []byte("/XRef")) ||
+			bytes.Contains(dict, []byte("/ObjStm")) || bytes.Contains(dict, []byte("/Metadata")) {
+			continue
+		}
+
+		if bytes.Contains(dict, []byte("/FlateDecode")) {
+			if budget <= 0 {
+				break
+			}
+			r, err := zlib.NewReader(bytes.NewReader(body))
+			if err != nil {
+				continue
+			}
+			// Keep whatever inflated before an error (truncated streams are common)
+			decoded, _ := io.ReadAll(io.LimitReader(r, budget))
+			r.Close()
+			budget -= int64(len(decoded))
+			body = decoded
+		} else if bytes.Contains(dict, []byte("/Filter")) {
+			// Other filters (DCT, LZW, ...) are not text we can read
+			continue
+		}
+		streams = append(streams, body)
+	}
+	return streams
+}
+
+// pdfContentText interprets the text operators of a content stream
+func pdfContentText(content []byte) string {
+	var out strings.Builder
+	var strs []string  // String operands since the last operator
+	var nums []float64 // Numeric operands since the last operator
+	inArray := false
+
+	for i := 0; i < len(content); {
+		ch := content[i]
+		switch {
+		case ch == '%':
+			for i < len(content) && content[i] != '\n' && content[i] != '\r' {
+				i++
+			}
+
+		case ch == '(':
+			s, n := pdfLiteralString(content[i:])
+			strs = append(strs, s)
+			i += n
+
+		case ch == '<' && i+1 < len(content) && content[i+1] == '<',
+			ch == '>' && i+1 < len(content) && content[i+1] == '>':
+			i += 2
+
+		case ch == '<':
+			end := bytes.IndexByte(content[i:], '>')
+			if end < 0 {
+				return out.String()
+			}
+			strs = append(strs, pdfHexString(content[i+1:i+end]))
+			i += end + 1
+
+		case ch == '[':
+			inArray = true
+			i++
+
+		case ch == ']':
+			inArray = false
+			i++
+
+		case ch == '/':
+			i++
+			for i < len(content) && !pdfDelimiter(content[i]) {
+				i++
+			}
+
+		case ch == '-' || ch == '+' || ch == '.' || (ch >= '0' && ch <= '9'):
+			j := i + 1
+			for j < len(content) && (content[j] == '.' || (content[j] >= '0' && content[j] <= '9')) {
+				j++
+			}
+			n, _ := strconv.ParseFloat(string(content[i:j]), 64)
+			// A large negative kerning inside a TJ array is a word gap
+			if inArray && n < -250 {
+				strs = append(strs, " ")
+			}
+			nums = append(nums, n)
+			i = j
+
+		case pdfDelimiter(ch):
+			i++
+
+		default:
+			j := i
+			for j < len(content) && !pdfDelimiter(content[j]) {
+				j++
+			}
+			op := string(content[i:j])
+			i = j
+
+			switch op {
+			case "Tj", "TJ":
+				out.WriteString(strings.Join(strs, ""))
+			case "'", "\"":
+				out.WriteString("\n" + strings.Join(strs, ""))
+			case "T*", "ET":
+				out.WriteString("\n")
+			case "Td", "TD":
+				if len(nums) >= 2 && nums[len(nums)-1] != 0 {
+					out.WriteString("\n")
+				} else {
+					out.WriteString(" ")
+				}
+			case "ID":
+				// Inline image data runs until EI
+				if end := bytes.Index(content[i:], []byte("EI")); end >= 0 {
+					i += end + 2
+				} else {
+					i = len(content)
+				}
+			}
+			strs = nil
+			nums = nil
+		}
+	}
+	return out.String()
+}
+
+// pdfLiteralString decodes a (...) string starting at data[0], returning the
+// text and the number of bytes consumed
+func pdfLiteralString(data []byte) (string, int) {
+	var buf []byte
+	depth := 0
+	i := 0
+	for i < len(data) {
+		ch := data[i]
+		switch {
+		case ch == '\\' && i+1 < len(data):
+			i++
+			switch esc := data[i]; esc {
+			case 'n':
+				buf = append(buf, '\n')
+			case 'r':
+				buf = append(buf, '\r')
+			case 't':
+				buf = append(buf, '\t')
+			case 'b', 'f':
+			case '\r', '\n':
+				// Line continuation
+			default:
+				if esc >= '0' && esc <= '7' {
+					j := i
+					for j < len(data) && j < i+3 && data[j] >= '0' && data[j] <= '7' {
+						j++
+					}
+					v, _ := strconv.ParseUint(string(data[i:j]), 8, 8)
+					buf = append(buf, byte(v))
+					i = j - 1
+				} else {
+					buf = append(buf, esc)
+				}
+			}
+		case ch == '(':
+			if depth > 0 {
+				buf = append(buf, ch)
+			}
+			depth++
+		case ch == ')':
+			depth--
+			if depth == 0 {
+				return pdfDecodeBytes(buf), i + 1
+			}
+			buf = append(buf, ch)
+		default:
+			buf = append(buf, ch)
+		}
+		i++
+	}
+	return pdfDecodeBytes(buf), i
+}
+
+// pdfHexString decodes the inside of a <...> string
+func pdfHexString(hex []byte) string {
+	var digits []byte
+	for _, c := range hex {
+		if (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F') {
+			digits = append(digits, c)
+		}
+	}
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	buf := make([]byte, 0, len(digits)/2)
+	for i := 0; i < len(digits); i += 2 {
+		v, _ := strconv.ParseUint(string(digits[i:i+2]), 16, 8)
+		buf = append(buf, byte(v))
+	}
+	return pdfDecodeBytes(buf)
+}
+
+// pdfDecodeBytes converts string bytes to text: UTF-16BE with a byte order
+// mark, otherwise Latin-1 (close enough to PDFDocEncoding/WinAnsi for text)
+func pdfDecodeBytes(b []byte) string {
+	if len(b) >= 2 && b[0] == 0xFE && b[1] == 0xFF {
+		var units []uint16
+		for i := 2; i+1 < len(b); i += 2 {
+			units = append(units, uint16(b[i])<<8|uint16(b[i+1]))
+		}
+		return string(utf16.Decode(units))
+	}
+	runes := make([]rune, len(b))
+	for i, c := range b {
+		runes[i] = rune(c)
+	}
+	return string(runes)
+}
+
+func pdfDelimiter(c byte) bool {
+	switch c {
+	case ' ', '\t', '\r', '\n', '\f', 0, '(', ')', '<', '>', '[', ']', '{', '}', '/', '%':
+		return true
+	}
+	return false
+}
+
+// mostlyPrintable reports whether at least 90% of the text is printable,
+// which separates real text from undecoded glyph ids
+func mostlyPrintable(s string) bool {
+	total, printable := 0, 0
+	for _, r := range s {
+		total++
+		if unicode.IsPrint(r) || unicode.IsSpace(r) {
+			printable++
+		}
+	}
+	return total > 0 && printable*10 >= total*9
+}
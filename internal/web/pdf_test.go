@@ -0,0 +1,43 @@
+package web
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"testing"
+)
+
+// flateStream compresses content as a PDF FlateDecode stream object
+func flateStream(content []byte) []byte {
+	var z bytes.Buffer
+	w := zlib.NewWriter(&z)
+	w.Write(content)
+	w.Close()
+	return []byte(fmt.Sprintf("1 0 obj\n<< /Length %d /Filter /FlateDecode >>\nstream\n%s\nendstream\nendobj\n", z.Len(), z.Bytes()))
+}
+
+func TestPDFContentStreams(t *testing.T) {
+	text := []byte("BT /F1 12 Tf (Hello PDF) Tj ET")
+	tests := []struct {
+		name    string
+		pdf     []byte
+		wantLen []int
+	}{
+		{"plain stream", append([]byte("%PDF-1.4\n1 0 obj\n<< /Length 31 >>\nstream\n"), append(text, []byte("\nendstream\nendobj\n")...)...), []int{len(text) + 1}},
+		{"flate stream", append([]byte("%PDF-1.4\n"), flateStream(text)...), []int{len(text)}},
+		{"zip bomb is capped", append([]byte("%PDF-1.4\n"), flateStream(make([]byte, maxPDFInflated+1<<20))...), []int{maxPDFInflated}},
+		{"budget spent by an earlier stream", append(append([]byte("%PDF-1.4\n"), flateStream(make([]byte, maxPDFInflated))...), flateStream(text)...), []int{maxPDFInflated}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			streams := pdfContentStreams(tt.pdf)
+			var got []int
+			for _, s := range streams {
+				got = append(got, len(s))
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.wantLen) {
+				t.Errorf("stream sizes = %v, want %v", got, tt.wantLen)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package web
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrRateLimited is returned when the per-minute web request budget is spent
+var ErrRateLimited = fmt.Errorf("rate limited: too many web requests this minute, try again later")
+
+// rateLimiter is a token bucket that refills perMinute tokens every minute
+type rateLimiter struct {
+	mu        sync.Mutex
+	perMinute int
+	tokens    float64
+	last      time.Time
+	now       func() time.Time
+}
+
+func newRateLimiter(perMinute int) *rateLimiter {
+	return &rateLimiter{
+		perMinute: perMinute,
+		tokens:    float64(perMinute),
+		last:      time.Now(),
+		now:       time.Now,
+	}
+}
+
+// allow takes one token if available. A limit of 0 or less never limits.
+func (r *rateLimiter) allow() bool {
+	if r == nil || r.perMinute <= 0 {
+		return true
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	r.tokens += now.Sub(r.last).Minutes() * float64(r.perMinute)
+	if r.tokens > float64(r.perMinute) {
+		r.tokens = float64(r.perMinute)
+	}
+	r.last = now
+
+	if r.tokens < 1 {
+		return false
+	}
+	r.tokens--
+	return true
+}
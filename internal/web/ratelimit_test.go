@@ -0,0 +1,86 @@
+package web
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	clock := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	r := newRateLimiter(3)
+	r.last = clock
+	r.now = func() time.Time { return clock }
+
+	for i := 0; i < 3; i++ {
+		if !r.allow() {
+			t.Fatalf("request %d refused within the limit", i+1)
+		}
+	}
+	if r.allow() {
+		t.Fatal("request 4 within a minute was allowed")
+	}
+
+	// A third of a minute refills one of three tokens
+	clock = clock.Add(20 * time.Second)
+	if !r.allow() {
+		t.Error("request refused after a token refilled")
+	}
+	if r.allow() {
+		t.Error("second request allowed after only one token refilled")
+	}
+
+	// A long idle spell refills to the cap, not beyond
+	clock = clock.Add(time.Hour)
+	for i := 0; i < 3; i++ {
+		if !r.allow() {
+			t.Fatalf("request %d refused after the window passed", i+1)
+		}
+	}
+	if r.allow() {
+		t.Error("bucket refilled beyond the per-minute cap")
+	}
+}
+
+func TestRateLimiterDisabled(t *testing.T) {
+	for _, perMinute := range []int{0, -1} {
+		r := newRateLimiter(perMinute)
+		for i := 0; i < 100; i++ {
+			if !r.allow() {
+				t.Fatalf("limit %d refused request %d", perMinute, i+1)
+			}
+		}
+	}
+	var unset *rateLimiter
+	if !unset.allow() {
+		t.Error("nil limiter refused a request")
+	}
+}
+
+func TestFetchRateLimited(t *testing.T) {
+	requests := 0
+	w := NewSearch()
+	w.SetRateLimit(2)
+	w.client.Transport = roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		requests++
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader("<p>hello</p>")),
+			Header:     http.Header{"Content-Type": {"text/html"}},
+		}, nil
+	})
+
+	for i := 0; i < 2; i++ {
+		if _, err := w.FetchPageAs("https://example.com/", false); err != nil {
+			t.Fatalf("fetch %d: %v", i+1, err)
+		}
+	}
+	if _, err := w.FetchPageAs("https://example.com/", false); err != ErrRateLimited {
+		t.Errorf("fetch 3 err = %v, want ErrRateLimited", err)
+	}
+	if requests != 2 {
+		t.Errorf("%d HTTP requests, want 2 (the limited fetch must not hit the network)", requests)
+	}
+}
@@ -16,8 +16,18 @@ type SearchResult struct {
 	Snippet string
 }
 
+// DefaultUserAgent identifies aicli to the sites it talks to
+const DefaultUserAgent = "aicli (+https://github.com/glennswest/aicli)"
+
+// BrowserUserAgent is retried when a site rejects DefaultUserAgent
+const BrowserUserAgent = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36"
+
 type WebSearch struct {
-	client *http.Client
+	client    *http.Client
+	limiter   *rateLimiter
+	userAgent string
+	fallback  bool              // Retry with BrowserUserAgent on 403
+	headers   map[string]string // Extra request headers (e.g. Accept-Language)
 }
 
 func NewSearch() *WebSearch {
@@ -25,49 +35,132 @@ func NewSearch() *WebSearch {
 		client: &http.Client{
 			Timeout: 10 * time.Second,
 		},
+		userAgent: DefaultUserAgent,
+		fallback:  true,
+	}
+}
+
+// SetUserAgent replaces the default User-Agent. An explicit User-Agent is
+// always sent as is, without the browser fallback.
+func (w *WebSearch) SetUserAgent(ua string) {
+	if ua == "" {
+		return
+	}
+	w.userAgent = ua
+	w.fallback = false
+}
+
+// SetHeaders adds headers sent with every request
+func (w *WebSearch) SetHeaders(headers map[string]string) {
+	w.headers = headers
+}
+
+// get performs a GET with the configured User-Agent and headers, retrying
+// once with a browser-like User-Agent if the site refuses the default one
+func (w *WebSearch) get(rawURL string) (*http.Response, error) {
+	resp, err := w.getAs(rawURL, w.userAgent)
+	if err != nil || resp.StatusCode != http.StatusForbidden || !w.fallback {
+		return resp, err
+	}
+	resp.Body.Close()
+	return w.getAs(rawURL, BrowserUserAgent)
+}
+
+func (w *WebSearch) getAs(rawURL, userAgent string) (*http.Response, error) {
+	req, err := http.NewRequest("GET", rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("User-Agent", userAgent)
+	for k, v := range w.headers {
+		req.Header.Set(k, v)
 	}
+
+	return w.client.Do(req)
+}
+
+// SetRateLimit caps web requests (searches and fetches) per minute; 0 or
+// less disables the cap
+func (w *WebSearch) SetRateLimit(perMinute int) {
+	w.limiter = newRateLimiter(perMinute)
 }
 
 func (w *WebSearch) Search(query string, maxResults int) ([]SearchResult, error) {
+	return w.SearchPage(query, maxResults, 1)
+}
+
+// SearchPage returns page (1-based) of the results for query, maxResults
+// per page. It counts as one request against the rate limit, even when it
+// needs a second results page from DuckDuckGo.
+func (w *WebSearch) SearchPage(query string, maxResults, page int) ([]SearchResult, error) {
+	if !w.limiter.allow() {
+		return nil, ErrRateLimited
+	}
 	if maxResults <= 0 {
 		maxResults = 5
 	}
+	if page < 1 {
+		page = 1
+	}
+	offset := (page - 1) * maxResults
 
-	// Use DuckDuckGo HTML version (no API key needed)
-	searchURL := fmt.Sprintf("https://html.duckduckgo.com/html/?q=%s", url.QueryEscape(query))
-
-	req, err := http.NewRequest("GET", searchURL, nil)
+	// The first DuckDuckGo page usually holds enough results for the first
+	// few pages; only ask DuckDuckGo for a later page when it runs out
+	body, err := w.fetchResults(query, 0)
 	if err != nil {
 		return nil, err
 	}
+	results := parseResults(body, offset, maxResults)
+	if len(results) == 0 && offset > 0 {
+		if body, err = w.fetchResults(query, offset); err != nil {
+			return nil, err
+		}
+		results = parseResults(body, 0, maxResults)
+	}
+	return results, nil
+}
 
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36")
+// fetchResults gets the DuckDuckGo HTML results starting at offset
+func (w *WebSearch) fetchResults(query string, offset int) (string, error) {
+	// Use DuckDuckGo HTML version (no API key needed)
+	searchURL := fmt.Sprintf("https://html.duckduckgo.com/html/?q=%s", url.QueryEscape(query))
+	if offset > 0 {
+		searchURL += fmt.Sprintf("&s=%d&dc=%d", offset, offset+1)
+	}
 
-	resp, err := w.client.Do(req)
+	resp, err := w.get(searchURL)
 	if err != nil {
-		return nil, fmt.Errorf("search request failed: %w", err)
+		return "", fmt.Errorf("search request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
-
-	return parseResults(string(body), maxResults), nil
+	return string(body), nil
 }
 
-func parseResults(html string, max int) []SearchResult {
+// parseResults extracts up to max results from a results page, skipping the
+// first offset
+func parseResults(html string, offset, max int) []SearchResult {
 	var results []SearchResult
 
 	// Extract result blocks
 	resultRegex := regexp.MustCompile(`<a rel="nofollow" class="result__a" href="([^"]+)"[^>]*>([^<]+)</a>`)
 	snippetRegex := regexp.MustCompile(`<a class="result__snippet"[^>]*>([^<]+)</a>`)
 
-	matches := resultRegex.FindAllStringSubmatch(html, max)
-	snippets := snippetRegex.FindAllStringSubmatch(html, max)
+	matches := resultRegex.FindAllStringSubmatch(html, -1)
+	snippets := snippetRegex.FindAllStringSubmatch(html, -1)
 
 	for i, match := range matches {
+		if i < offset {
+			continue
+		}
+		if len(results) >= max {
+			break
+		}
 		if len(match) < 3 {
 			continue
 		}
@@ -119,14 +212,17 @@ func cleanHTML(s string) string {
 }
 
 func (w *WebSearch) FetchPage(pageURL string) (string, error) {
-	req, err := http.NewRequest("GET", pageURL, nil)
-	if err != nil {
-		return "", err
-	}
+	return w.FetchPageAs(pageURL, false)
+}
 
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36")
+// FetchPageAs fetches a page as plain text, or as markdown that keeps links
+// as [text](url) when markdown is set. PDFs are always plain text.
+func (w *WebSearch) FetchPageAs(pageURL string, markdown bool) (string, error) {
+	if !w.limiter.allow() {
+		return "", ErrRateLimited
+	}
 
-	resp, err := w.client.Do(req)
+	resp, err := w.get(pageURL)
 	if err != nil {
 		return "", fmt.Errorf("fetch failed: %w", err)
 	}
@@ -137,6 +233,18 @@ func (w *WebSearch) FetchPage(pageURL string) (string, error) {
 		return "", err
 	}
 
+	if isPDF(resp.Header.Get("Content-Type"), pageURL, body) {
+		text, err := pdfToText(body)
+		if err != nil {
+			return "", fmt.Errorf("could not extract text from PDF: %w", err)
+		}
+		return truncateText(text), nil
+	}
+
+	if markdown {
+		return htmlToMarkdown(string(body), pageURL), nil
+	}
+
 	// Basic HTML to text conversion
 	return htmlToText(string(body)), nil
 }
@@ -167,12 +275,13 @@ func htmlToText(html string) string {
 		}
 	}
 
-	result := strings.Join(cleaned, "\n")
+	return truncateText(strings.Join(cleaned, "\n"))
+}
 
-	// Truncate if too long
-	if len(result) > 8000 {
-		result = result[:8000] + "\n... (truncated)"
+// truncateText caps fetched page text at 8000 bytes
+func truncateText(text string) string {
+	if len(text) > 8000 {
+		text = text[:8000] + "\n... (truncated)"
 	}
-
-	return result
+	return text
 }
@@ -0,0 +1,122 @@
+package web
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// roundTripFunc serves requests without a network
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+// resultsPage renders n DuckDuckGo results
+func resultsPage(n int) string {
+	var sb strings.Builder
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&sb, `<a rel="nofollow" class="result__a" href="https://example.com/%d">Result %d</a>`, i, i)
+		fmt.Fprintf(&sb, `<a class="result__snippet" href="#">Snippet %d</a>`, i)
+	}
+	return sb.String()
+}
+
+func TestSearchPageChargesOnce(t *testing.T) {
+	tests := []struct {
+		name         string
+		page         int
+		limit        int
+		searches     int
+		wantRequests int // HTTP requests made
+		wantLimited  int // Searches refused by the rate limit
+	}{
+		{"first page", 1, 2, 2, 2, 0},
+		{"later page needing a second fetch", 2, 2, 2, 4, 0},
+		{"limit reached", 2, 2, 3, 4, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests := 0
+			w := NewSearch()
+			w.SetRateLimit(tt.limit)
+			w.client.Transport = roundTripFunc(func(r *http.Request) (*http.Response, error) {
+				requests++
+				// The first page holds 3 results, so page 2 of 5 needs the
+				// page starting at offset 5
+				n := 3
+				if r.URL.Query().Get("s") != "" {
+					n = 5
+				}
+				return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(resultsPage(n))), Header: http.Header{}}, nil
+			})
+
+			limited := 0
+			for i := 0; i < tt.searches; i++ {
+				results, err := w.SearchPage("golang", 5, tt.page)
+				if err == ErrRateLimited {
+					limited++
+					continue
+				}
+				if err != nil || len(results) == 0 {
+					t.Fatalf("search %d: %d results, err %v", i, len(results), err)
+				}
+			}
+			if requests != tt.wantRequests || limited != tt.wantLimited {
+				t.Errorf("%d requests, %d limited; want %d, %d", requests, limited, tt.wantRequests, tt.wantLimited)
+			}
+		})
+	}
+}
+
+func TestRequestHeaders(t *testing.T) {
+	tests := []struct {
+		name        string
+		userAgent   string
+		headers     map[string]string
+		refuse      string // User-Agent the site answers 403
+		wantAgents  []string
+		wantRefused bool
+	}{
+		{"default user agent", "", nil, "", []string{DefaultUserAgent}, false},
+		{"configured user agent and headers", "mybot/1.0", map[string]string{"Accept-Language": "de-DE"}, "", []string{"mybot/1.0"}, false},
+		{"default refused falls back to browser", "", nil, DefaultUserAgent, []string{DefaultUserAgent, BrowserUserAgent}, false},
+		{"configured refused has no fallback", "mybot/1.0", nil, "mybot/1.0", []string{"mybot/1.0"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var agents []string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				agents = append(agents, r.UserAgent())
+				for k, v := range tt.headers {
+					if got := r.Header.Get(k); got != v {
+						t.Errorf("header %s = %q, want %q", k, got, v)
+					}
+				}
+				if r.UserAgent() == tt.refuse {
+					w.WriteHeader(http.StatusForbidden)
+					return
+				}
+				w.Header().Set("Content-Type", "text/html")
+				fmt.Fprint(w, "<p>welcome</p>")
+			}))
+			defer srv.Close()
+
+			w := NewSearch()
+			w.SetUserAgent(tt.userAgent)
+			w.SetHeaders(tt.headers)
+			text, err := w.FetchPage(srv.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if gotRefused := !strings.Contains(text, "welcome"); gotRefused != tt.wantRefused {
+				t.Errorf("page = %q, refused %v, want %v", text, gotRefused, tt.wantRefused)
+			}
+			if strings.Join(agents, "|") != strings.Join(tt.wantAgents, "|") {
+				t.Errorf("User-Agents sent = %q, want %q", agents, tt.wantAgents)
+			}
+		})
+	}
+}
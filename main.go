@@ -2,17 +2,22 @@ package main
 
 import (
 	"bufio"
+	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
 
+	"aicli/internal/accessible"
 	"aicli/internal/chat"
 	"aicli/internal/client"
 	"aicli/internal/config"
 	"aicli/internal/discovery"
 	"aicli/internal/executor"
+	"aicli/internal/logging"
 	"aicli/internal/session"
 	"aicli/internal/update"
 )
@@ -20,6 +25,26 @@ import (
 // Version is set at build time via ldflags
 var version = "dev"
 
+// versionInfo is the --version-json output. Project is left out, and Error
+// set, when the project version can't be read.
+type versionInfo struct {
+	AICLI   string `json:"aicli"`
+	Project string `json:"project,omitempty"`
+	Go      string `json:"go"`
+	Error   string `json:"error,omitempty"`
+}
+
+// versionJSONOutput builds the --version-json output for the project in workDir
+func versionJSONOutput(workDir string) ([]byte, error) {
+	info := versionInfo{AICLI: version, Go: runtime.Version()}
+	if v, err := executor.New(workDir).GetVersion(); err != nil {
+		info.Error = fmt.Sprintf("reading project version: %v", err)
+	} else {
+		info.Project = v.String()
+	}
+	return json.Marshal(info)
+}
+
 var (
 	endpoint     string
 	apiKey       string
@@ -27,19 +52,41 @@ var (
 	maxTokens    int
 	temperature  float64
 	prompt       string
+	promptFile   string
 	fileArgs     []string
 	showConfig   bool
 	initConfig   bool
 	playbackFile string
 	listSessions bool
+	diskUsage    bool
 	showVersion  bool
+	versionJSON  bool
 	autoMode     bool
 	insecure     bool
 	checkUpdate  bool
+	updateCheck  bool
+	rollback     bool
+	channel      string
 	debugMode    bool
 	planGoal     string
 	planNext     bool
 	planRun      bool
+	workDirFlag  string
+	quietMode    bool
+	imagePath    string
+	accessibleUI bool
+	noTools      bool
+	noSysPrompt  bool
+	showPrompt   bool
+	confirmDflt  string
+	failOn       string
+	maxSteps     int
+	reasoning    string
+	logFile      string
+
+	// stdout is where final output goes; with --quiet, os.Stdout is
+	// silenced and only writes through this reach the terminal
+	stdout io.Writer = os.Stdout
 )
 
 func init() {
@@ -54,32 +101,144 @@ func init() {
 	flag.Float64Var(&temperature, "t", 0, "Temperature (shorthand)")
 	flag.StringVar(&prompt, "prompt", "", "Single prompt (non-interactive mode)")
 	flag.StringVar(&prompt, "p", "", "Single prompt (shorthand)")
+	flag.StringVar(&promptFile, "prompt-file", "", "Read the single prompt from a file")
 	flag.BoolVar(&showConfig, "config", false, "Show current configuration")
 	flag.BoolVar(&initConfig, "init", false, "Initialize config file and VERSION")
 	flag.StringVar(&playbackFile, "playback", "", "Replay a session file")
 	flag.BoolVar(&listSessions, "sessions", false, "List recorded sessions")
+	flag.BoolVar(&diskUsage, "disk-usage", false, "Show the disk space used under .aicli (sessions, debug, caches, backups)")
 	flag.BoolVar(&showVersion, "version", false, "Show project version")
 	flag.BoolVar(&showVersion, "v", false, "Show project version (shorthand)")
+	flag.BoolVar(&versionJSON, "version-json", false, "Print aicli, project and Go versions as JSON")
 	flag.BoolVar(&autoMode, "auto", false, "Auto-execute mode (skip confirmations)")
 	flag.BoolVar(&insecure, "insecure", false, "Skip TLS certificate verification")
 	flag.BoolVar(&checkUpdate, "update", false, "Check for updates and install if available")
+	flag.BoolVar(&updateCheck, "update-check", false, "Report whether an update is available without installing it")
+	flag.BoolVar(&rollback, "update-rollback", false, "Restore the version replaced by the last --update")
+	flag.StringVar(&channel, "update-channel", "", "Release channel for updates: stable or prerelease")
 	flag.BoolVar(&debugMode, "debug", false, "Enable debug logging for discovery")
 	flag.StringVar(&planGoal, "plan", "", "Create an implementation plan for the given goal")
 	flag.BoolVar(&planNext, "plan-next", false, "Execute the next pending plan step")
 	flag.BoolVar(&planRun, "plan-run", false, "Execute all remaining plan steps")
+	flag.StringVar(&workDirFlag, "dir", "", "Project directory to work in (default: current directory)")
+	flag.StringVar(&workDirFlag, "C", "", "Project directory (shorthand)")
+	flag.BoolVar(&quietMode, "quiet", false, "Only print the final response (single-prompt and piped modes)")
+	flag.BoolVar(&quietMode, "q", false, "Quiet mode (shorthand)")
+	flag.BoolVar(&noSysPrompt, "no-system-prompt", false, "Don't send a system prompt")
+	flag.BoolVar(&showPrompt, "show-prompt", false, "Print the system prompt that would be sent for this directory and exit")
+	flag.StringVar(&confirmDflt, "confirm-default", "", "Confirmation answer without a terminal: decline, approve or approve-read-only")
+	flag.IntVar(&maxSteps, "max-steps", 0, "Stop a single-prompt run after this many rounds of tool calls (0 = no limit)")
+	flag.StringVar(&failOn, "fail-on", "", "Exit non-zero on any tool failure (any) or only unrecovered ones (unrecovered)")
+	flag.StringVar(&reasoning, "reasoning-effort", "", "Reasoning effort for reasoning models: low, medium or high")
+	flag.StringVar(&logFile, "log-file", "", "Append an event log (requests, tool calls, errors) to this file")
+	flag.BoolVar(&noTools, "no-tools", false, "Plain chat: don't send tools or run tool calls")
+	flag.BoolVar(&accessibleUI, "accessible", false, "Screen-reader friendly output (words instead of symbols, no colors or spinners)")
+	flag.StringVar(&imagePath, "image", "", "Attach an image to the first message (vision models)")
 }
 
 func main() {
 	flag.Parse()
 	fileArgs = flag.Args()
 
+	// Switch to the project directory first so the executor, session recorder,
+	// client debug logs, and all project files resolve under it
+	if workDirFlag != "" {
+		if err := changeWorkDir(workDirFlag); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			accessible.Exit(1)
+		}
+	}
+
+	// Read the prompt from a file (after -C, so relative paths resolve there)
+	if promptFile != "" {
+		if prompt != "" {
+			fmt.Fprintln(os.Stderr, "Error: --prompt-file and -p/--prompt are mutually exclusive")
+			accessible.Exit(1)
+		}
+		data, err := os.ReadFile(promptFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading prompt file: %v\n", err)
+			accessible.Exit(1)
+		}
+		prompt = strings.TrimSpace(string(data))
+		if prompt == "" {
+			fmt.Fprintf(os.Stderr, "Error: prompt file %s is empty\n", promptFile)
+			accessible.Exit(1)
+		}
+	}
+
+	// Quiet mode: silence all decorative output in single-prompt and piped
+	// modes. Errors still go to stderr; interactive mode ignores --quiet.
+	if quietMode {
+		stat, _ := os.Stdin.Stat()
+		piped := stat != nil && (stat.Mode()&os.ModeCharDevice) == 0
+		if prompt != "" || piped {
+			if devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0); err == nil {
+				os.Stdout = devNull
+			}
+		}
+	}
+
 	// Set the app version for other packages to use
 	config.AppVersion = version
 
 	cfg, err := config.Load()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
-		os.Exit(1)
+		accessible.Exit(1)
+	}
+	if note := cfg.Migration(); note != "" {
+		fmt.Fprintf(os.Stderr, "\033[90m%s\033[0m\n", note)
+	}
+
+	// Accessibility mode: rewrite status symbols as words for screen readers
+	if accessible.Enabled(cfg.Accessible || accessibleUI) {
+		defer accessible.Wrap()()
+	}
+
+	if noSysPrompt {
+		cfg.NoSystemPrompt = true
+	}
+	if logFile != "" {
+		cfg.LogFile = logFile
+	}
+	if cfg.LogFile != "" {
+		closeLog, err := logging.Open(cfg.LogFile, cfg.LogLevel)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		} else {
+			defer closeLog()
+			logging.Info("aicli started", "version", config.AppVersion, "model", cfg.Model, "endpoint", cfg.APIEndpoint)
+		}
+	}
+	if confirmDflt != "" {
+		switch confirmDflt {
+		case config.ConfirmDecline, config.ConfirmApprove, config.ConfirmApproveReadOnly:
+			cfg.ConfirmDefault = confirmDflt
+		default:
+			fmt.Fprintf(os.Stderr, "Error: --confirm-default must be decline, approve or approve-read-only\n")
+			accessible.Exit(1)
+		}
+	}
+	if reasoning != "" {
+		cfg.ReasoningEffort = reasoning
+		if cfg.GetReasoningEffort() == "" {
+			fmt.Fprintf(os.Stderr, "Error: --reasoning-effort must be low, medium or high\n")
+			accessible.Exit(1)
+		}
+	}
+	if failOn != "" {
+		switch failOn {
+		case config.FailOnAny, config.FailOnUnrecovered:
+			cfg.FailOn = failOn
+		default:
+			fmt.Fprintf(os.Stderr, "Error: --fail-on must be any or unrecovered\n")
+			accessible.Exit(1)
+		}
+	}
+	if maxSteps < 0 {
+		fmt.Fprintf(os.Stderr, "Error: --max-steps must be 0 (no limit) or more\n")
+		accessible.Exit(1)
 	}
 
 	// Apply insecure setting from config or command line flag
@@ -89,6 +248,16 @@ func main() {
 	}
 
 	// Handle --version early (no Ollama needed)
+	if versionJSON {
+		workDir, _ := os.Getwd()
+		data, err := versionJSONOutput(workDir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			accessible.Exit(1)
+		}
+		fmt.Println(string(data))
+		return
+	}
 	if showVersion {
 		workDir, _ := os.Getwd()
 		exec := executor.New(workDir)
@@ -99,8 +268,23 @@ func main() {
 	}
 
 	// Handle --update early (no Ollama needed)
-	if checkUpdate {
-		handleUpdate()
+	if channel != "" {
+		cfg.UpdateChannel = channel
+	}
+	// A bad channel in the config only matters to the update flags; the
+	// background check treats it as stable
+	if channel != "" || checkUpdate || updateCheck {
+		if ch := cfg.GetUpdateChannel(); ch != update.ChannelStable && ch != update.ChannelPrerelease {
+			fmt.Fprintf(os.Stderr, "Error: unknown update channel %q; use stable or prerelease\n", ch)
+			accessible.Exit(1)
+		}
+	}
+	if rollback {
+		handleRollback()
+		return
+	}
+	if checkUpdate || updateCheck {
+		handleUpdate(cfg, updateCheck)
 		return
 	}
 
@@ -120,6 +304,45 @@ func main() {
 	if temperature > 0 {
 		cfg.Temperature = temperature
 	}
+	if err := cfg.Validate(); err != nil {
+		source := cfg.LoadedFrom()
+		if source == "" {
+			source = "defaults and flags"
+		}
+		fmt.Fprintf(os.Stderr, "Error: invalid configuration (%s):\n", source)
+		for _, line := range strings.Split(err.Error(), "\n") {
+			fmt.Fprintf(os.Stderr, "  - %s\n", line)
+		}
+		accessible.Exit(1)
+	}
+	for _, warning := range cfg.Warnings() {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+	}
+
+	// Handle --show-prompt (no endpoint needed)
+	if showPrompt {
+		workDir, _ := os.Getwd()
+		prompt := client.SystemPrompt(cfg, workDir)
+		if prompt == "" {
+			fmt.Fprintln(os.Stderr, "No system prompt is sent")
+			return
+		}
+		fmt.Println(prompt)
+		return
+	}
+
+	// Handle --disk-usage (no endpoint needed)
+	if diskUsage {
+		workDir, _ := os.Getwd()
+		usage, err := session.DiskUsageOf(workDir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			accessible.Exit(1)
+		}
+		fmt.Printf("Disk usage of %s:\n", filepath.Join(workDir, ".aicli"))
+		fmt.Print(session.FormatDiskUsage(usage))
+		return
+	}
 
 	// Set debug mode for discovery
 	if debugMode {
@@ -147,7 +370,7 @@ func main() {
 	if initConfig {
 		if err := cfg.Save(); err != nil {
 			fmt.Fprintf(os.Stderr, "Error saving config: %v\n", err)
-			os.Exit(1)
+			accessible.Exit(1)
 		}
 		path, _ := config.ConfigPath()
 		fmt.Printf("Config saved to: %s\n", path)
@@ -155,10 +378,19 @@ func main() {
 		// Initialize VERSION file
 		if err := exec.InitVersion(); err != nil {
 			fmt.Fprintf(os.Stderr, "Error creating VERSION: %v\n", err)
-			os.Exit(1)
+			accessible.Exit(1)
 		}
 		v, _ := exec.GetVersion()
 		fmt.Printf("VERSION initialized: %s\n", v.String())
+
+		// Keep session files out of version control
+		if !cfg.DisableGitignore {
+			if added, err := session.EnsureGitignore(workDir); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: could not update .gitignore: %v\n", err)
+			} else if added {
+				fmt.Println("Added .aicli/ to .gitignore")
+			}
+		}
 		return
 	}
 
@@ -187,7 +419,7 @@ func main() {
 		sessions, err := session.ListSessions(workDir)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
+			accessible.Exit(1)
 		}
 		if len(sessions) == 0 {
 			fmt.Println("No sessions found in .aicli/")
@@ -215,12 +447,12 @@ func main() {
 		c, err := chat.NewPlaybackMode(cfg, sessionPath)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error loading session: %v\n", err)
-			os.Exit(1)
+			accessible.Exit(1)
 		}
 
 		if err := c.Run(); err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
+			accessible.Exit(1)
 		}
 		return
 	}
@@ -266,10 +498,27 @@ func main() {
 	if cfg.ShouldPreloadModel() {
 		ensureModelLoaded(cfg)
 	}
-	checkUpdateOnStartup()
 	runInteractive(cfg)
 }
 
+// changeWorkDir validates dir and makes it the process working directory.
+// Like make -C, relative file arguments are then resolved under dir.
+func changeWorkDir(dir string) error {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return fmt.Errorf("invalid directory %s: %w", dir, err)
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return fmt.Errorf("directory %s: %w", dir, err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+
+	return os.Chdir(abs)
+}
+
 func runSinglePrompt(cfg *config.Config, prompt string) {
 	// Add file context if provided
 	if len(fileArgs) > 0 {
@@ -291,12 +540,25 @@ func runSinglePrompt(cfg *config.Config, prompt string) {
 	c, err := chat.NewNonInteractive(cfg, autoMode)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+		accessible.Exit(1)
 	}
+	if quietMode {
+		c.SetQuietOutput(stdout)
+	}
+	c.SetMaxSteps(maxSteps)
+	applyChatFlags(c)
 
 	if err := c.RunSingle(prompt); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+		accessible.Exit(1)
+	}
+
+	// One machine-readable line for scripts, and a failing exit status if
+	// a tool failed (per fail_on)
+	stats := c.Stats()
+	fmt.Fprintf(os.Stderr, "aicli: %s\n", stats)
+	if stats.Failed(cfg.GetFailOn()) {
+		accessible.Exit(1)
 	}
 }
 
@@ -316,18 +578,18 @@ func runPipedInput(cfg *config.Config) {
 	prompt := input.String()
 	if prompt == "" {
 		fmt.Fprintln(os.Stderr, "No input provided")
-		os.Exit(1)
+		accessible.Exit(1)
 	}
 
 	c := client.New(cfg)
 	_, err := c.Complete(prompt, true, func(token string) {
-		fmt.Print(token)
+		fmt.Fprint(stdout, token)
 	})
-	fmt.Println()
+	fmt.Fprintln(stdout)
 
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+		accessible.Exit(1)
 	}
 }
 
@@ -335,12 +597,12 @@ func runPlanMode(cfg *config.Config, goal string) {
 	c, err := chat.NewNonInteractive(cfg, autoMode)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+		accessible.Exit(1)
 	}
 
 	if err := c.RunPlan(goal); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+		accessible.Exit(1)
 	}
 }
 
@@ -348,18 +610,18 @@ func runPlanExec(cfg *config.Config, all bool) {
 	c, err := chat.NewNonInteractive(cfg, true) // auto-exec for plan steps
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+		accessible.Exit(1)
 	}
 
 	if all {
 		if err := c.RunPlanAll(); err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
+			accessible.Exit(1)
 		}
 	} else {
 		if err := c.RunPlanNext(); err != nil {
 			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
+			accessible.Exit(1)
 		}
 	}
 }
@@ -368,12 +630,34 @@ func runInteractive(cfg *config.Config) {
 	c, err := chat.New(cfg)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error starting chat: %v\n", err)
-		os.Exit(1)
+		accessible.Exit(1)
+	}
+	applyChatFlags(c)
+
+	// Check for a newer release in the background (at most once a day);
+	// the chat prints the notice at the next prompt
+	if !cfg.NoUpdateCheck {
+		c.SetNotices(update.CheckInBackground(version, cfg.GetUpdateChannel(), cfg.GetGitHubToken()))
 	}
 
 	if err := c.Run(); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+		accessible.Exit(1)
+	}
+}
+
+// applyChatFlags applies --no-tools and attaches the --image file to the
+// chat's first message
+func applyChatFlags(c *chat.Chat) {
+	if noTools {
+		c.SetToolsEnabled(false)
+	}
+	if imagePath == "" {
+		return
+	}
+	if err := c.AttachImage(imagePath); err != nil {
+		fmt.Fprintf(os.Stderr, "Error attaching image: %v\n", err)
+		accessible.Exit(1)
 	}
 }
 
@@ -473,26 +757,20 @@ func warnIfUnencrypted(endpoint string) {
 	}
 }
 
-// checkUpdateOnStartup silently checks for updates and notifies user if available
-func checkUpdateOnStartup() {
-	info, err := update.CheckForUpdate(version)
-	if err != nil {
-		return // Silently fail - don't interrupt startup
-	}
-
-	if update.IsNewerVersion(info.CurrentVersion, info.LatestVersion) {
-		fmt.Printf("\033[33m⬆ Update available: %s → %s (run with --update to install)\033[0m\n\n", info.CurrentVersion, info.LatestVersion)
+// handleUpdate checks for updates and prompts user to install, or with
+// checkOnly just reports what is available
+func handleUpdate(cfg *config.Config, checkOnly bool) {
+	channel := cfg.GetUpdateChannel()
+	if channel == update.ChannelStable {
+		fmt.Printf("Checking for updates...\n")
+	} else {
+		fmt.Printf("Checking for updates (%s channel)...\n", channel)
 	}
-}
 
-// handleUpdate checks for updates and prompts user to install
-func handleUpdate() {
-	fmt.Printf("Checking for updates...\n")
-
-	info, err := update.CheckForUpdate(version)
+	info, err := update.CheckForUpdate(version, channel, cfg.GetGitHubToken())
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "\033[31m✗ Failed to check for updates: %v\033[0m\n", err)
-		os.Exit(1)
+		accessible.Exit(1)
 	}
 
 	if !update.IsNewerVersion(info.CurrentVersion, info.LatestVersion) {
@@ -518,12 +796,17 @@ func handleUpdate() {
 		}
 	}
 
+	if checkOnly {
+		fmt.Println("\nRun aicli --update to install it.")
+		return
+	}
+
 	fmt.Printf("\nDo you want to update? [y/N]: ")
 	reader := bufio.NewReader(os.Stdin)
 	response, err := reader.ReadString('\n')
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error reading input: %v\n", err)
-		os.Exit(1)
+		accessible.Exit(1)
 	}
 
 	response = strings.TrimSpace(strings.ToLower(response))
@@ -534,16 +817,26 @@ func handleUpdate() {
 
 	fmt.Printf("\nDownloading update...")
 
-	err = update.DownloadAndInstall(info, func(downloaded, total int64) {
-		pct := float64(downloaded) / float64(total) * 100
-		fmt.Printf("\rDownloading update... %.1f%%", pct)
-	})
+	err = update.DownloadAndInstall(info, update.ProgressPrinter(os.Stdout, cfg.ProgressStyle, "Downloading update..."))
 
 	if err != nil {
 		fmt.Printf("\n\033[31m✗ Update failed: %v\033[0m\n", err)
-		os.Exit(1)
+		accessible.Exit(1)
 	}
 
 	fmt.Printf("\n\033[32m✓ Successfully updated to version %s\033[0m\n", info.LatestVersion)
 	fmt.Println("Please restart aicli to use the new version.")
+	if backup, ok := update.BackupPath(); ok {
+		fmt.Printf("\033[90mThe previous version is kept as %s (aicli --update-rollback restores it)\033[0m\n", backup)
+	}
+}
+
+// handleRollback restores the binary replaced by the last update
+func handleRollback() {
+	if err := update.Rollback(); err != nil {
+		fmt.Fprintf(os.Stderr, "\033[31m✗ Rollback failed: %v\033[0m\n", err)
+		accessible.Exit(1)
+	}
+	fmt.Println("\033[32m✓ Restored the previous version\033[0m")
+	fmt.Println("Please restart aicli to use it. Run --update-rollback again to undo.")
 }
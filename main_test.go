@@ -0,0 +1,501 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+
+	"aicli/internal/chat"
+	"aicli/internal/config"
+	"aicli/internal/executor"
+	"aicli/internal/lang"
+	"aicli/internal/session"
+)
+
+// TestMain runs main itself when the test binary is started by runMain,
+// so tests can check flags that exit the process
+func TestMain(m *testing.M) {
+	if os.Getenv("AICLI_TEST_MAIN") == "1" {
+		os.Args = append([]string{"aicli"}, strings.Fields(os.Getenv("AICLI_TEST_ARGS"))...)
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runMain runs aicli with args in a child process, returning its stderr
+// and exit code
+func runMain(t *testing.T, args ...string) (string, int) {
+	t.Helper()
+	_, stderr, code := runMainOutput(t, args...)
+	return stderr, code
+}
+
+// runMainOutput is runMain also returning stdout
+func runMainOutput(t *testing.T, args ...string) (string, string, int) {
+	t.Helper()
+	cmd := exec.Command(os.Args[0])
+	cmd.Env = append(os.Environ(), "AICLI_TEST_MAIN=1", "AICLI_TEST_ARGS="+strings.Join(args, " "), "HOME="+t.TempDir())
+	var stdout, stderr strings.Builder
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return stdout.String(), stderr.String(), exitErr.ExitCode()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return stdout.String(), stderr.String(), 0
+}
+
+func TestVersionJSONOutput(t *testing.T) {
+	tests := []struct {
+		name        string
+		setup       func(dir string)
+		wantProject string
+		wantErr     bool
+	}{
+		{"VERSION file", func(dir string) { os.WriteFile(filepath.Join(dir, "VERSION"), []byte("v2.3.4\n"), 0644) }, "2.3.4", false},
+		{"no VERSION", func(dir string) {}, "0.1.0", false},
+		{"unreadable VERSION", func(dir string) { os.Mkdir(filepath.Join(dir, "VERSION"), 0755) }, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			tt.setup(dir)
+			data, err := versionJSONOutput(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got map[string]string
+			if err := json.Unmarshal(data, &got); err != nil {
+				t.Fatalf("output %s is not JSON: %v", data, err)
+			}
+			if got["aicli"] != version || got["go"] != runtime.Version() {
+				t.Errorf("aicli/go = %q/%q, want %q/%q", got["aicli"], got["go"], version, runtime.Version())
+			}
+			project, hasProject := got["project"]
+			if tt.wantErr {
+				if hasProject || got["error"] == "" {
+					t.Errorf("output %s: want no project and an error", data)
+				}
+				return
+			}
+			if project != tt.wantProject || got["error"] != "" {
+				t.Errorf("output %s: want project %q and no error", data, tt.wantProject)
+			}
+		})
+	}
+}
+
+func TestWorkDirFlagErrors(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "notes.txt")
+	os.WriteFile(file, []byte("x"), 0644)
+	tests := []struct {
+		name string
+		dir  string
+		want string
+	}{
+		{"missing", filepath.Join(t.TempDir(), "nope"), "no such file or directory"},
+		{"not a directory", file, "is not a directory"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, flag := range []string{"-dir", "-C"} {
+				stderr, code := runMain(t, flag, tt.dir, "--version")
+				if code != 1 || !strings.Contains(stderr, tt.want) {
+					t.Errorf("%s %s: exit %d, stderr %q; want exit 1 and %q", flag, tt.dir, code, stderr, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestChangeWorkDirResolvesProjectFiles(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	start := t.TempDir()
+	project := t.TempDir()
+	os.WriteFile(filepath.Join(project, "main.go"), []byte("package main\n"), 0644)
+	t.Chdir(start)
+
+	if err := changeWorkDir(project); err != nil {
+		t.Fatal(err)
+	}
+	wd, _ := os.Getwd()
+	if real, _ := filepath.EvalSymlinks(project); wd != project && wd != real {
+		t.Fatalf("working directory = %s, want %s", wd, project)
+	}
+
+	if _, err := chat.NewNonInteractive(&config.Config{Model: "test"}, false); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{".aicli", ".gitignore", "VERSION"} {
+		if _, err := os.Stat(filepath.Join(project, name)); err != nil {
+			t.Errorf("%s not created in the project: %v", name, err)
+		}
+		if _, err := os.Stat(filepath.Join(start, name)); err == nil {
+			t.Errorf("%s created in the starting directory", name)
+		}
+	}
+	if content, err := executor.New(wd).ReadFile("main.go"); err != nil || content != "package main\n" {
+		t.Errorf("ReadFile(main.go) = %q, %v", content, err)
+	}
+}
+
+// fakeChatServer answers every chat request with "ok", recording the last
+// user message sent
+func fakeChatServer(t *testing.T) (url string, lastPrompt func() string) {
+	t.Helper()
+	var mu sync.Mutex
+	var last string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Messages []struct {
+				Role    string `json:"role"`
+				Content string `json:"content"`
+			} `json:"messages"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		mu.Lock()
+		for _, m := range req.Messages {
+			if m.Role == "user" {
+				last = m.Content
+			}
+		}
+		mu.Unlock()
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: "+`{"choices":[{"delta":{"content":"ok"},"finish_reason":"stop"}]}`+"\n\ndata: [DONE]\n\n")
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL + "/v1", func() string {
+		mu.Lock()
+		defer mu.Unlock()
+		return last
+	}
+}
+
+// projectWithConfig creates a project directory whose local config points
+// at endpoint
+func projectWithConfig(t *testing.T, endpoint string) string {
+	t.Helper()
+	dir := t.TempDir()
+	cfg := fmt.Sprintf(`{"api_endpoint":%q,"model":"test","preload_model":false,"no_update_check":true}`, endpoint)
+	os.MkdirAll(filepath.Join(dir, ".aicli"), 0755)
+	if err := os.WriteFile(filepath.Join(dir, config.LocalConfigPath()), []byte(cfg), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestPromptFile(t *testing.T) {
+	endpoint, lastPrompt := fakeChatServer(t)
+	dir := projectWithConfig(t, endpoint)
+	os.WriteFile(filepath.Join(dir, "prompt.txt"), []byte("\nSummarize the notes\nin one line.\n\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("release on friday"), 0644)
+	os.WriteFile(filepath.Join(dir, "empty.txt"), []byte(" \n"), 0644)
+
+	stderr, code := runMain(t, "-C", dir, "--prompt-file", "prompt.txt", "notes.txt")
+	if code != 0 {
+		t.Fatalf("exit %d: %s", code, stderr)
+	}
+	got := lastPrompt()
+	if !strings.HasSuffix(got, "\n\nSummarize the notes\nin one line.") {
+		t.Errorf("prompt = %q, want the trimmed file contents last", got)
+	}
+	if !strings.HasPrefix(got, "File `notes.txt`:") || !strings.Contains(got, "release on friday") {
+		t.Errorf("prompt = %q, want the file argument as context", got)
+	}
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"--prompt-file", "prompt.txt", "-p", "hi"}, "mutually exclusive"},
+		{[]string{"--prompt-file", "missing.txt"}, "Error reading prompt file"},
+		{[]string{"--prompt-file", "empty.txt"}, "is empty"},
+	}
+	for _, tt := range tests {
+		stderr, code := runMain(t, append([]string{"-C", dir}, tt.args...)...)
+		if code != 1 || !strings.Contains(stderr, tt.want) {
+			t.Errorf("%v: exit %d, stderr %q; want exit 1 with %q", tt.args, code, stderr, tt.want)
+		}
+	}
+}
+
+func TestQuietOutput(t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "text/event-stream")
+		if requests%2 == 0 {
+			fmt.Fprint(w, "data: "+`{"choices":[{"delta":{"content":"Two files."},"finish_reason":"stop"}]}`+"\n\ndata: [DONE]\n\n")
+			return
+		}
+		call := `{"index":0,"id":"call_1","type":"function","function":{"name":"list_files","arguments":"{\"pattern\":\"*\"}"}}`
+		fmt.Fprint(w, "data: "+`{"choices":[{"delta":{"content":"Let me look.","tool_calls":[`+call+`]}}]}`+"\n\n"+
+			"data: "+`{"choices":[{"delta":{},"finish_reason":"tool_calls"}]}`+"\n\ndata: [DONE]\n\n")
+	}))
+	defer srv.Close()
+	dir := projectWithConfig(t, srv.URL+"/v1")
+
+	stdout, stderr, code := runMainOutput(t, "-C", dir, "--quiet", "-p", "count")
+	if code != 0 {
+		t.Fatalf("exit %d: %s", code, stderr)
+	}
+	if stdout != "Two files.\n" {
+		t.Errorf("quiet stdout = %q, want only the final reply", stdout)
+	}
+	if !strings.Contains(stderr, "aicli: ") {
+		t.Errorf("stderr = %q, want the summary line still on stderr", stderr)
+	}
+
+	stdout, _, _ = runMainOutput(t, "-C", dir, "-p", "count")
+	if !strings.Contains(stdout, "list_files") || !strings.Contains(stdout, "Two files.") {
+		t.Errorf("normal stdout = %q, want tool output and the reply", stdout)
+	}
+}
+
+func TestNoSystemPromptFlag(t *testing.T) {
+	var mu sync.Mutex
+	var roles []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Messages []struct {
+				Role string `json:"role"`
+			} `json:"messages"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		mu.Lock()
+		roles = nil
+		for _, m := range req.Messages {
+			roles = append(roles, m.Role)
+		}
+		mu.Unlock()
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: "+`{"choices":[{"delta":{"content":"ok"},"finish_reason":"stop"}]}`+"\n\ndata: [DONE]\n\n")
+	}))
+	defer srv.Close()
+	dir := projectWithConfig(t, srv.URL+"/v1")
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-C", dir, "-p", "hello"}, "system,user"},
+		{[]string{"-C", dir, "--no-system-prompt", "-p", "hello"}, "user"},
+	}
+	for _, tt := range tests {
+		if stderr, code := runMain(t, tt.args...); code != 0 {
+			t.Fatalf("%v: exit %d: %s", tt.args, code, stderr)
+		}
+		mu.Lock()
+		got := strings.Join(roles, ",")
+		mu.Unlock()
+		if got != tt.want {
+			t.Errorf("%v: sent roles %s, want %s", tt.args, got, tt.want)
+		}
+	}
+}
+
+// commandServer answers a run's first chat requests with one run_command
+// call per command, in order, and then with a final reply
+func commandServer(t *testing.T, commands ...string) string {
+	t.Helper()
+	var mu sync.Mutex
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/chat/completions") {
+			http.NotFound(w, r)
+			return
+		}
+		mu.Lock()
+		n := requests
+		requests++
+		mu.Unlock()
+		w.Header().Set("Content-Type", "text/event-stream")
+		if n >= len(commands) {
+			fmt.Fprint(w, "data: "+`{"choices":[{"delta":{"content":"Done."},"finish_reason":"stop"}]}`+"\n\ndata: [DONE]\n\n")
+			return
+		}
+		args, _ := json.Marshal(map[string]string{"command": commands[n]})
+		call, _ := json.Marshal(map[string]interface{}{
+			"index": 0, "id": fmt.Sprintf("call_%d", n), "type": "function",
+			"function": map[string]string{"name": "run_command", "arguments": string(args)},
+		})
+		fmt.Fprint(w, "data: "+`{"choices":[{"delta":{"tool_calls":[`+string(call)+`]}}]}`+"\n\n"+
+			"data: "+`{"choices":[{"delta":{},"finish_reason":"tool_calls"}]}`+"\n\ndata: [DONE]\n\n")
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL + "/v1"
+}
+
+func TestFailOnExitCode(t *testing.T) {
+	// Fails the first time, succeeds once retried
+	flaky := "test -f tried || { touch tried; exit 1; }"
+	tests := []struct {
+		name     string
+		commands []string
+		failOn   string
+		wantCode int
+		wantLine string
+	}{
+		{"all succeed", []string{"true"}, "", 0, "aicli: 0 files written, 1 command run, 0 failures"},
+		{"recovered failure fails by default", []string{flaky, flaky}, "", 1, "aicli: 0 files written, 2 commands run, 1 failure"},
+		{"recovered failure passes with unrecovered", []string{flaky, flaky}, "unrecovered", 0, "aicli: 0 files written, 2 commands run, 1 failure"},
+		{"unrecovered failure fails with unrecovered", []string{"false", "true"}, "unrecovered", 1, "aicli: 0 files written, 2 commands run, 1 failure"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := projectWithConfig(t, commandServer(t, tt.commands...))
+			args := []string{"-C", dir, "--auto"}
+			if tt.failOn != "" {
+				args = append(args, "--fail-on", tt.failOn)
+			}
+			stderr, code := runMain(t, append(args, "-p", "check")...)
+			if code != tt.wantCode {
+				t.Errorf("exit %d, want %d: %s", code, tt.wantCode, stderr)
+			}
+			if !strings.Contains(stderr, tt.wantLine+"\n") {
+				t.Errorf("stderr = %q, want the summary %q", stderr, tt.wantLine)
+			}
+		})
+	}
+
+	t.Run("invalid policy", func(t *testing.T) {
+		dir := projectWithConfig(t, commandServer(t))
+		stderr, code := runMain(t, "-C", dir, "--fail-on", "sometimes", "-p", "check")
+		if code == 0 || !strings.Contains(stderr, "--fail-on must be any or unrecovered") {
+			t.Errorf("exit %d, stderr %q; want a usage error", code, stderr)
+		}
+	})
+}
+
+func TestReasoningEffortFlagErrors(t *testing.T) {
+	dir := projectWithConfig(t, commandServer(t))
+	stderr, code := runMain(t, "-C", dir, "--reasoning-effort", "extreme", "-p", "hi")
+	if code == 0 || !strings.Contains(stderr, "--reasoning-effort must be low, medium or high") {
+		t.Errorf("exit %d, stderr %q; want a usage error", code, stderr)
+	}
+	if stderr, code := runMain(t, "-C", dir, "--reasoning-effort", "Medium", "-p", "hi"); code != 0 {
+		t.Errorf("valid effort: exit %d: %s", code, stderr)
+	}
+}
+
+func TestLogFileFlag(t *testing.T) {
+	dir := projectWithConfig(t, commandServer(t, "echo hi"))
+	logPath := filepath.Join(t.TempDir(), "aicli.log")
+
+	stdout, stderr, code := runMainOutput(t, "-C", dir, "--auto", "--quiet", "--log-file", logPath, "-p", "greet")
+	if code != 0 {
+		t.Fatalf("exit %d: %s", code, stderr)
+	}
+	if stdout != "Done.\n" {
+		t.Errorf("stdout = %q, want only the reply (logging must not write to it)", stdout)
+	}
+	data, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`msg="aicli started"`, `msg="request sent"`, `msg="response received"`, `msg="tool executed" tool=run_command`} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("log lacks %s:\n%s", want, data)
+		}
+	}
+}
+
+func TestInvalidConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  string
+		want []string
+	}{
+		{"out of range values", `{"api_endpoint":"localhost:11434","model":"test","temperature":5,"tool_permissions":{"git_push":"sometimes"},"no_update_check":true}`,
+			[]string{"Error: invalid configuration", `  - api_endpoint: "localhost:11434" is not a valid URL`, "  - temperature: 5 is out of range", `  - tool_permissions.git_push: "sometimes" is not valid`}},
+		{"wrong type", "{\n  \"model\": \"test\",\n  \"temperature\": \"warm\"\n}",
+			[]string{"line 3: temperature must be a number, not string"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			os.MkdirAll(filepath.Join(dir, ".aicli"), 0755)
+			os.WriteFile(filepath.Join(dir, config.LocalConfigPath()), []byte(tt.cfg), 0644)
+
+			stderr, code := runMain(t, "-C", dir, "-p", "hi")
+			if code != 1 {
+				t.Fatalf("exit %d, want 1: %s", code, stderr)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(stderr, want) {
+					t.Errorf("stderr lacks %q:\n%s", want, stderr)
+				}
+			}
+		})
+	}
+}
+
+func TestShowPrompt(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, ".aicli"), 0755)
+	os.WriteFile(filepath.Join(dir, config.LocalConfigPath()), []byte(`{"model":"test","system_prompt":"You are a careful engineer.","no_update_check":true}`), 0644)
+	os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module demo\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "requirements.txt"), []byte("requests\n"), 0644)
+	session.NewMemoryFile(dir).Add("we use pnpm")
+
+	stdout, stderr, code := runMainOutput(t, "-C", dir, "--show-prompt")
+	if code != 0 {
+		t.Fatalf("exit %d: %s", code, stderr)
+	}
+	want := "You are a careful engineer.\n\n" +
+		lang.ErrorRules[lang.LangGo] + "\n\n" + lang.ErrorRules[lang.LangPython] + "\n\n" +
+		strings.TrimSpace(session.NewMemoryFile(dir).PromptSection()) + "\n"
+	if stdout != want {
+		t.Errorf("--show-prompt printed:\n%s\nwant:\n%s", stdout, want)
+	}
+
+	// The configured prompt turned off sends nothing, which is said on stderr
+	stdout, stderr, code = runMainOutput(t, "-C", dir, "--show-prompt", "--no-system-prompt")
+	if code != 0 || stdout != "" || !strings.Contains(stderr, "No system prompt is sent") {
+		t.Errorf("--no-system-prompt: exit %d, stdout %q, stderr %q", code, stdout, stderr)
+	}
+}
+
+func TestDiskUsageFlag(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, ".aicli", "debug"), 0755)
+	os.WriteFile(filepath.Join(dir, ".aicli", "session_1.jsonl"), make([]byte, 2048), 0644)
+	os.WriteFile(filepath.Join(dir, ".aicli", "debug", "request_1.json"), make([]byte, 10), 0644)
+
+	stdout, stderr, code := runMainOutput(t, "-C", dir, "--disk-usage")
+	if code != 0 {
+		t.Fatalf("exit %d: %s", code, stderr)
+	}
+	for _, want := range []string{"Disk usage of " + filepath.Join(dir, ".aicli"), "  sessions       2.0 KB      1 files\n", "  debug            10 B      1 files\n", "  total          2.0 KB      2 files\n"} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("output lacks %q:\n%s", want, stdout)
+		}
+	}
+}
+
+func TestUpdateChannelFlag(t *testing.T) {
+	dir := projectWithConfig(t, "http://127.0.0.1:1/v1")
+	for _, args := range [][]string{
+		{"--update-check", "--update-channel", "nightly"},
+		{"--update", "--update-channel", "Nightly"},
+	} {
+		stderr, code := runMain(t, append([]string{"-C", dir}, args...)...)
+		if code != 1 || !strings.Contains(stderr, `unknown update channel "nightly"; use stable or prerelease`) {
+			t.Errorf("%v: exit %d, stderr %q", args, code, stderr)
+		}
+	}
+}